@@ -0,0 +1,256 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// actionQueueSize is how many actions from other goroutines (GPIO buttons,
+// the web API) may wait for the next tick
+const actionQueueSize = 16
+
+// newActions returns what the user can do by name. Keys, touch buttons,
+// GPIO buttons, gamepad buttons and the web API all run these, so a new
+// input only has to say which name it runs.
+func (a *App) newActions() map[string]func() {
+	return map[string]func(){
+		"zoom_in": func() {
+			if a.zoom < MaxZoom {
+				a.zoom++
+			}
+		},
+		"zoom_out": func() {
+			if a.zoom > MinZoom {
+				a.zoom--
+			}
+		},
+		"fit":             a.zoomToFit,
+		"zero_altitude":   a.zeroAltitude,
+		"center_aircraft": a.centerOnAircraft,
+		"center_home":     a.centerOnHome,
+		"move_home": func() {
+			if a.movingHome {
+				a.finishMovingHome(true)
+			} else {
+				a.startMovingHome()
+			}
+		},
+		"follow": func() {
+			a.followAircraft = !a.followAircraft
+		},
+		"set_home": func() {
+			state := a.client.GetState()
+			if state.HasGPS && (state.Latitude != 0 || state.Longitude != 0) {
+				a.setHome(float64(state.Latitude), float64(state.Longitude))
+				a.notify(logApp, LevelInfo, "Home set to %.6f, %.6f", a.homeLat, a.homeLon)
+			} else {
+				a.toasts.Add(LevelWarn, "No GPS fix to set home from")
+			}
+		},
+		"set_pilot": func() {
+			a.setPilot(a.centerLat, a.centerLon)
+			a.notify(logApp, LevelInfo, "Pilot position set to %.6f, %.6f", a.pilotLat, a.pilotLon)
+		},
+		"next_wp": func() {
+			if a.navigator != nil {
+				a.navigator.Skip(1)
+			}
+		},
+		"prev_wp": func() {
+			if a.navigator != nil {
+				a.navigator.Skip(-1)
+			}
+		},
+		"rally_lines": func() {
+			a.config.Map.RallyLines = !a.config.Map.RallyLines
+		},
+		"clear_path": func() {
+			a.flightPath = nil
+			a.notify(logApp, LevelInfo, "Flight path cleared")
+		},
+		"grid": func() {
+			a.config.Map.Grid = cycle(gridModes, a.config.Map.Grid, 1)
+		},
+		"path_3d": func() {
+			a.config.Map.Path3D = !a.config.Map.Path3D
+		},
+		"hud": func() {
+			a.hudMode = (a.hudMode + 1) % hudModes
+		},
+		"map_source": func() {
+			a.config.Map.Source = a.tileManager.ToggleSource().Key()
+			a.notify(logTile, LevelInfo, "Map source: %s", a.tileManager.SourceName())
+		},
+		"touch_buttons": func() {
+			a.showTouchBtns = !a.showTouchBtns
+			a.touchControls.Wake()
+		},
+		// The encoder's: they work the open menu, or without one push
+		// opens settings and turning zooms
+		"menu_select": func() {
+			if menu := a.openMenu(); menu != nil {
+				menu.Select()
+			} else {
+				a.settingsMenu.Open()
+			}
+		},
+		"menu_up":    func() { a.menuOrZoom(-1) },
+		"menu_down":  func() { a.menuOrZoom(1) },
+		"link":       a.toggleLink,
+		"port":       func() { a.portMenu.Open() },
+		"settings":   func() { a.settingsMenu.Open() },
+		"weather":    func() { a.weatherMenu.Open() },
+		"logbook":    func() { a.logbookMenu.Open() },
+		"connection": a.openDeviceMenu,
+		"vtx":        a.openVTXMenu,
+		"profile":    a.toggleProfile,
+		"profile_undo": func() {
+			if a.profiling {
+				a.undoProfilePoint()
+			}
+		},
+		"find": a.findAircraft,
+		"help": func() {
+			a.showHelp = !a.showHelp
+		},
+		"perf": func() { a.perf.Toggle() },
+		"fullscreen": func() {
+			a.fullscreen = !ebiten.IsFullscreen()
+			ebiten.SetFullscreen(a.fullscreen)
+		},
+		"quit": a.confirmQuit,
+	}
+}
+
+// menuOrZoom moves delta entries in the open menu, or zooms in for a
+// positive delta without one
+func (a *App) menuOrZoom(delta int) {
+	if menu := a.openMenu(); menu != nil {
+		menu.Move(delta)
+	} else if delta > 0 && a.zoom < MaxZoom {
+		a.zoom++
+	} else if delta < 0 && a.zoom > MinZoom {
+		a.zoom--
+	}
+}
+
+// ActionNames lists the actions, for the docs and error messages
+func (a *App) ActionNames() []string {
+	names := make([]string, 0, len(a.actions))
+	for name := range a.actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runAction runs an action on the game loop, reporting whether it exists
+func (a *App) runAction(name string) bool {
+	run, ok := a.actions[name]
+	if ok {
+		run()
+		a.plugins.NotifyAction(name)
+	}
+	return ok
+}
+
+// queueAction runs an action from another goroutine on the next tick
+func (a *App) queueAction(name string) error {
+	if _, ok := a.actions[name]; !ok {
+		return fmt.Errorf("%w %q", errUnknownAction, name)
+	}
+	select {
+	case a.actionQueue <- name:
+		return nil
+	default:
+		return fmt.Errorf("too many actions waiting")
+	}
+}
+
+// runQueuedActions runs the actions queued since the last tick
+func (a *App) runQueuedActions() {
+	for {
+		select {
+		case name := <-a.actionQueue:
+			a.runAction(name)
+		default:
+			return
+		}
+	}
+}
+
+// keyBinding runs an action when its key is pressed, with Shift or without
+type keyBinding struct {
+	key    ebiten.Key
+	shift  bool
+	action string
+}
+
+// keyBindings are the keyboard shortcuts. A key without a Shift binding
+// runs its action with Shift held too.
+var keyBindings = []keyBinding{
+	{ebiten.KeyEqual, false, "zoom_in"},
+	{ebiten.KeyKPAdd, false, "zoom_in"},
+	{ebiten.KeyMinus, false, "zoom_out"},
+	{ebiten.KeyKPSubtract, false, "zoom_out"},
+	{ebiten.KeyZ, false, "fit"},
+	{ebiten.KeyZ, true, "zero_altitude"},
+	{ebiten.KeyG, false, "center_aircraft"},
+	{ebiten.KeyB, false, "center_home"},
+	{ebiten.KeyB, true, "move_home"},
+	{ebiten.KeyF, false, "follow"},
+	{ebiten.KeyH, false, "set_home"},
+	{ebiten.KeyH, true, "set_pilot"},
+	{ebiten.KeyN, false, "next_wp"},
+	{ebiten.KeyN, true, "prev_wp"},
+	{ebiten.KeyR, false, "rally_lines"},
+	{ebiten.KeyC, false, "clear_path"},
+	{ebiten.KeyF1, false, "help"},
+	{ebiten.KeySlash, false, "help"},
+	{ebiten.KeyU, false, "grid"},
+	{ebiten.KeyDigit3, false, "path_3d"},
+	{ebiten.KeyV, false, "hud"},
+	{ebiten.KeyM, false, "map_source"},
+	{ebiten.KeyT, false, "touch_buttons"},
+	{ebiten.KeyL, false, "link"},
+	{ebiten.KeyP, false, "port"},
+	{ebiten.KeyO, false, "settings"},
+	{ebiten.KeyE, false, "weather"},
+	{ebiten.KeyE, true, "profile"},
+	{ebiten.KeyBackspace, false, "profile_undo"},
+	{ebiten.KeyX, false, "find"},
+	{ebiten.KeyI, false, "connection"},
+	{ebiten.KeyK, false, "logbook"},
+	{ebiten.KeyF3, false, "perf"},
+	{ebiten.KeyF11, false, "fullscreen"},
+	{ebiten.KeyEscape, false, "quit"},
+	{ebiten.KeyQ, false, "quit"},
+}
+
+// handleKeyBindings runs the actions of the keys just pressed
+func (a *App) handleKeyBindings() {
+	shift := ebiten.IsKeyPressed(ebiten.KeyShift)
+	for _, b := range keyBindings {
+		if !inpututil.IsKeyJustPressed(b.key) || b.shift && !shift {
+			continue
+		}
+		if shift && !b.shift && hasShiftBinding(b.key) {
+			continue
+		}
+		a.runAction(b.action)
+	}
+}
+
+func hasShiftBinding(key ebiten.Key) bool {
+	for _, b := range keyBindings {
+		if b.key == key && b.shift {
+			return true
+		}
+	}
+	return false
+}
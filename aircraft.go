@@ -0,0 +1,129 @@
+//go:build !headless
+
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/png" // Register PNG decoder
+	"math"
+	"os"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Built-in icons are white with a dark outline, pointing north, so the
+// configured color can tint them
+//
+//go:embed icons/*.png
+var iconFiles embed.FS
+
+// aircraftIcons are the built-in icon names
+var aircraftIcons = []string{"plane", "wing", "quad", "arrow"}
+
+// AircraftSprite is a loaded aircraft icon
+type AircraftSprite struct {
+	img    *ebiten.Image
+	size   float64
+	tint   color.RGBA
+	tinted bool
+}
+
+// LoadAircraftSprite loads a built-in icon or a PNG file. It returns nil
+// for "triangle", the vector icon drawn without a sprite.
+func LoadAircraftSprite(icon AircraftIcon) (*AircraftSprite, error) {
+	if icon.Icon == "triangle" || icon.Icon == "" {
+		return nil, nil
+	}
+
+	var data []byte
+	var err error
+	if isBuiltinIcon(icon.Icon) {
+		data, err = iconFiles.ReadFile("icons/" + icon.Icon + ".png")
+	} else {
+		data, err = os.ReadFile(icon.Icon)
+	}
+	if err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", icon.Icon, err)
+	}
+
+	s := &AircraftSprite{img: ebiten.NewImageFromImage(src), size: float64(icon.Size)}
+	if s.size <= 0 {
+		s.size = 32
+	}
+	if icon.Color != "" {
+		if s.tint, err = parseHexColor(icon.Color); err != nil {
+			return nil, err
+		}
+		s.tinted = true
+	}
+	return s, nil
+}
+
+func isBuiltinIcon(name string) bool {
+	for _, n := range aircraftIcons {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Draw draws the sprite centered on x, y, turned to heading in degrees
+func (s *AircraftSprite) Draw(dst *ebiten.Image, x, y, heading float32) {
+	b := s.img.Bounds()
+	w, h := float64(b.Dx()), float64(b.Dy())
+
+	op := &ebiten.DrawImageOptions{Filter: ebiten.FilterLinear}
+	op.GeoM.Translate(-w/2, -h/2)
+	op.GeoM.Scale(s.size/math.Max(w, h), s.size/math.Max(w, h))
+	op.GeoM.Rotate(float64(heading) * math.Pi / 180)
+	op.GeoM.Translate(float64(x), float64(y))
+	if s.tinted {
+		op.ColorScale.ScaleWithColor(s.tint)
+	}
+	dst.DrawImage(s.img, op)
+}
+
+// parseHexColor parses "#rrggbb" or "#rrggbbaa"
+func parseHexColor(s string) (color.RGBA, error) {
+	c := color.RGBA{A: 255}
+	var err error
+	switch len(s) {
+	case 7:
+		_, err = fmt.Sscanf(s, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	case 9:
+		_, err = fmt.Sscanf(s, "#%02x%02x%02x%02x", &c.R, &c.G, &c.B, &c.A)
+	default:
+		err = fmt.Errorf("want #rrggbb")
+	}
+	if err != nil {
+		return c, fmt.Errorf("color %q: %w", s, err)
+	}
+	return c, nil
+}
+
+// applyAircraftIcon loads the icon of the selected profile if it changed
+func (a *App) applyAircraftIcon() {
+	icon, ok := a.config.Aircraft.Profiles[a.config.Aircraft.Profile]
+	if !ok {
+		icon = AircraftIcon{Icon: "triangle"}
+	}
+	if a.aircraftIconLoaded && icon == a.aircraftIcon {
+		return
+	}
+	a.aircraftIcon, a.aircraftIconLoaded = icon, true
+
+	sprite, err := LoadAircraftSprite(icon)
+	if err != nil {
+		logApp.Errorf("Aircraft icon %q: %v (using triangle)", a.config.Aircraft.Profile, err)
+	}
+	a.aircraftSprite = sprite
+}
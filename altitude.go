@@ -0,0 +1,116 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// AltitudeSources lists the altitude.source values: auto is the baro
+// altitude, or GPS without a barometer
+var AltitudeSources = []string{"auto", "gps", "baro", "fused"}
+
+const (
+	// fusedGPSWeight is how far GPS pulls the fused altitude towards itself
+	// every fusedGPSInterval, so the fused altitude follows the baro's
+	// changes and drifts to GPS over some seconds
+	fusedGPSWeight   = 0.02
+	fusedGPSInterval = 100 * time.Millisecond
+)
+
+// altitudeRef works out the altitude the tapes and alerts use. GPS
+// altitude is above sea level and wanders by meters; the FC's baro
+// altitude is smooth and zeroed at arming, but drifts with the weather and
+// the FC's temperature, so the two often disagree by tens of meters. The
+// fused altitude follows the baro's changes and GPS over the long run.
+type altitudeRef struct {
+	source string
+
+	// Fused altitude, above sea level where there is GPS
+	fused    float64
+	hasFused bool
+	lastBaro float32
+	lastPull time.Time
+
+	// Values at the last zero, subtracted from then on
+	zeroed                     bool
+	zeroGPS, zeroBaro, zeroFus float64
+}
+
+// update follows the raw altitudes of a new state
+func (r *altitudeRef) update(s *TelemetryState) {
+	gps := s.HasGPS && (s.Latitude != 0 || s.Longitude != 0)
+	switch {
+	case !r.hasFused && gps:
+		r.fused, r.hasFused = float64(s.Altitude), true
+	case !r.hasFused && s.BaroAltitude != 0:
+		r.fused, r.hasFused = float64(s.BaroAltitude), true
+	case r.hasFused:
+		// Not when the FC zeroes its baro at arming
+		rezeroed := s.BaroAltitude == 0 && math.Abs(float64(r.lastBaro)) > 1
+		if s.BaroAltitude != r.lastBaro && !rezeroed {
+			r.fused += float64(s.BaroAltitude - r.lastBaro)
+		}
+		// Timed by the telemetry, so publishing for other reasons doesn't pull
+		if gps && s.LastUpdate.Sub(r.lastPull) >= fusedGPSInterval {
+			r.fused += (float64(s.Altitude) - r.fused) * fusedGPSWeight
+			r.lastPull = s.LastUpdate
+		}
+	}
+	r.lastBaro = s.BaroAltitude
+}
+
+// zero makes the altitudes as they are now read 0; the baro's only with
+// baro, as the FC zeroes it itself at arming
+func (r *altitudeRef) zero(s *TelemetryState, baro bool) {
+	r.zeroed = true
+	r.zeroGPS, r.zeroFus = float64(s.Altitude), r.fused
+	if baro {
+		r.zeroBaro = float64(s.BaroAltitude)
+	}
+}
+
+// apply sets the state's reference altitude from the source
+func (r *altitudeRef) apply(s *TelemetryState) {
+	source := r.source
+	if source == "auto" || source == "" {
+		source = "gps"
+		if s.BaroAltitude != 0 {
+			source = "baro"
+		}
+	}
+	var alt, zero float64
+	switch source {
+	case "baro":
+		alt, zero = float64(s.BaroAltitude), r.zeroBaro
+	case "fused":
+		alt, zero = r.fused, r.zeroFus
+	default:
+		alt, zero = float64(s.Altitude), r.zeroGPS
+	}
+	if r.zeroed {
+		alt -= zero
+	}
+	s.RefAltitude, s.RefSource = float32(alt), source
+}
+
+// SetAltitudeSource picks the altitude relativeAltitude returns, one of
+// AltitudeSources
+func (c *GRPCClient) SetAltitudeSource(source string) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.altitude.source == source {
+		return
+	}
+	c.altitude.source = source
+	c.publish()
+}
+
+// ZeroAltitude makes the current altitude the zero of every source, e.g.
+// on the ground before takeoff. At arming leave out the baro, which the FC
+// zeroes itself as it arms.
+func (c *GRPCClient) ZeroAltitude(baro bool) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.altitude.zero(c.state, baro)
+	c.publish()
+}
@@ -1,13 +1,18 @@
+//go:build !headless
+
 package main
 
 import (
 	"fmt"
 	"image/color"
-	"log"
 	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/colorm"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/vector"
@@ -22,6 +27,27 @@ type App struct {
 	panel          *Panel
 	touchControls  *TouchControls
 	gpioController *GPIOController
+	recorder       *Recorder
+	uploader       *Uploader
+	logbook        *Logbook
+	webServer      *WebServer
+	tracker        *Tracker
+	buddyShare     *BuddyShare
+	failover       *BackendFailover
+	mqtt           *MQTTPublisher
+	share          *SharePublisher
+	statusLine     *StatusLine
+	plugins        *PluginHost
+	weather        *WeatherService
+	displayPort    *DisplayPort
+	perf           *PerfOverlay
+
+	// Settings
+	config       *Config
+	configPath   string
+	savedConfig  []byte // Last written config, to skip redundant writes
+	lastAutosave time.Time
+	mapBg        color.RGBA
 
 	// View state
 	centerLat  float64
@@ -36,19 +62,115 @@ type App struct {
 	showTouchBtns bool
 
 	// Home position
-	homeLat    float64
-	homeLon    float64
-	homeSet    bool
+	homeLat   float64
+	homeLon   float64
+	homeSet   bool
+	homeSetAt time.Time
+	homeMenu  *Menu // Offered at startup with the last session's home, nil otherwise
+	// Home as of the last tick, for the goroutines that read it (web, MQTT,
+	// DisplayPort, status line, plugins) while the game loop moves it
+	sharedHome atomic.Pointer[HomeConfig]
+
+	// Dragging the home marker to correct it, and where it was before
+	movingHome   bool
+	draggingHome bool
+	homeGrabX    float64 // Marker's offset from the cursor while dragged
+	homeGrabY    float64
+	homeBefore   [2]float64
+
+	// Where the pilot and ground station stand, for line-of-sight distance
+	pilotLat float64
+	pilotLon float64
+	pilotSet bool
+	localGPS *LocalGPS // Moves the pilot's position while it has a fix
+	gpsSeen  bool      // The local GPS has had a fix this session
 
 	// Flight path history
-	flightPath []struct{ lat, lon float64 }
-	maxPathLen int
+	flightPath  []pathPoint
+	maxPathLen  int
+	pathLayer   pathLayer
+	flightNum   int       // The session's flight the trail is adding to, from 1
+	flightStart time.Time // Its takeoff, as the logbook saw it
+	flightSeen  bool      // A flight has begun this session
+	armWatch    armWatch
+	fixGate     fixGate
+
+	// Trails archived when a new flight clears the map, and their GPX file
+	archivedTracks []gpxTrack
+	trailArchive   string
+
+	compareTrack []TrackPoint     // Past flight drawn under the live track
+	inspection   *trackInspection // Trail point clicked on, nil if none
+
+	// Ground elevation, and the route drawn for an elevation profile
+	dem          *DEM
+	profiling    bool
+	profileRoute [][2]float64
+	profile      []ElevationSample
+	overlays     []*GeoLayer
+	layers       *LayerStack       // What is drawn on the map, in order
+	secondScreen bool              // Mirroring another elrs-map (-second-screen)
+	actions      map[string]func() // What inputs can do, by name
+	actionQueue  chan string       // Actions from other goroutines, run on the next tick
 
 	// UI state
 	showHelp     bool
 	selectedPort int
 	ports        []string
 	lastPortScan time.Time
+	portScanErr  string // Last port listing failure, so it is shown once
+	baudRate     int32
+	portMenu     *Menu
+	settingsMenu *Menu
+	trackerMenu  *Menu
+	weatherMenu  *Menu
+	osdMenu      *Menu
+	layersMenu   *Menu
+	wizardMenu   *Menu
+	wizardPage   int
+	pickingSite  bool // The wizard is waiting for the flying site on the map
+	logbookMenu  *Menu
+	deviceMenu   *Menu
+	vtxMenu      *Menu
+	backendMenu  *Menu
+	restoreMenu  *Menu // Offered after a crash, nil otherwise
+	quitMenu     *Menu
+
+	// Backend discovery
+	backends    []MDNSService
+	discovering bool
+	autoPick    bool // Use the result without asking if there is only one
+	discovered  chan []MDNSService
+	failedOver  chan string // Backend the failover moved to with the link up, to restart it there
+
+	// Connection details
+	devices         deviceInfo
+	deviceInfo      chan deviceInfo
+	fetchingDevices bool
+	deviceStatus    string      // Outcome of the last device command
+	commandDone     chan string // Device command results, as deviceStatus
+
+	// Failsafe or return to home from the flight mode, and for how long
+	failsafe     FailsafeMonitor
+	failsafeMode string
+	failsafeTime time.Duration
+
+	// Magnetometer heading correction
+	heading *HeadingCorrector
+
+	// Link timing history
+	latency    *LatencyMonitor
+	lqTimeline LQTimeline
+	txPower    TXPowerTracker
+
+	// Find my plane
+	findStatus string
+	findUntil  time.Time
+	findDone   chan string
+
+	// Closed to make the game loop return ebiten.Termination
+	quit     chan struct{}
+	quitOnce sync.Once
 
 	// Dragging
 	dragging   bool
@@ -56,13 +178,43 @@ type App struct {
 	dragStartY int
 	dragLat    float64
 	dragLon    float64
+	dragMoved  bool // Past clickSlop, so releasing isn't a click
+
+	// Wheel travel toward the next zoom level, for trackpads' small steps
+	wheelAccum float64
+	wheelLast  time.Time
+	wheelStep  time.Time
 
 	// Auto-follow aircraft
 	followAircraft bool
+	followLead     [2]float64 // Screen pixels the map's middle is ahead of the aircraft, smoothed
+
+	// Mission being flown, nil without one
+	navigator *Navigator
+	navInfo   *NavInfo
+	rallyInfo *RallyInfo // Nearest rally point, nil without any or a fix
+
+	// Aircraft icon of the selected profile; nil draws the triangle
+	aircraftSprite     *AircraftSprite
+	aircraftIcon       AircraftIcon
+	aircraftIconLoaded bool
+
+	// Rendering
+	redraw      bool // Power save: something changed since the last frame
+	lastFrame   frameKey
+	lastDraw    time.Time
+	mapLayer    *ebiten.Image
+	mapLayerKey mapLayerKey
+	cockpitPane *ebiten.Image // Split layout instruments, sized to their side
+	miniPane    *ebiten.Image // Map-only mode attitude and heading
+	bigText     *ebiten.Image // Big numbers mode text, before scaling up
+	toasts      *Toasts
+	tileOp      ebiten.DrawImageOptions // Reused for every tile
+	tileBatch   []placedTile
 }
 
 // NewApp creates a new application
-func NewApp(client *GRPCClient, tileManager *TileManager, width, height int, fullscreen bool) *App {
+func NewApp(client *GRPCClient, tileManager *TileManager, cfg *Config, width, height int, fullscreen bool) *App {
 	app := &App{
 		client:         client,
 		tileManager:    tileManager,
@@ -71,22 +223,121 @@ func NewApp(client *GRPCClient, tileManager *TileManager, width, height int, ful
 		panel:          NewPanel(),
 		touchControls:  NewTouchControls(),
 		gpioController: NewGPIOController(),
-		centerLat:      -22.9064,  // Default: Campinas, Brazil
-		centerLon:      -47.0616,
+		recorder:       NewRecorder(client, &cfg.Record),
+		uploader:       NewUploader(&cfg.Upload, &cfg.Record),
+		logbook:        NewLogbook(LogbookPath()),
+		webServer:      NewWebServer(client, cfg),
+		tracker:        NewTracker(&cfg.Tracker),
+		buddyShare:     NewBuddyShare(client, &cfg.Buddy),
+		failover:       NewBackendFailover(client, &cfg.Backend),
+		mqtt:           NewMQTTPublisher(client, &cfg.MQTT),
+		statusLine:     NewStatusLine(client, cfg),
+		plugins:        NewPluginHost(client, cfg),
+		weather:        NewWeatherService(&cfg.Weather, WeatherCachePath()),
+		displayPort:    NewDisplayPort(client, &cfg.OSDOut),
+		perf:           NewPerfOverlay(),
+		localGPS:       NewLocalGPS(&cfg.GPS),
+		heading:        NewHeadingCorrector(&cfg.Heading),
+		latency:        NewLatencyMonitor(client),
+		dem:            NewDEM(cfg.DEM.Dir),
+		toasts:         &Toasts{},
+		config:         cfg,
 		zoom:           DefaultZoom,
 		width:          width,
 		height:         height,
 		fullscreen:     fullscreen,
 		maxPathLen:     1000,
-		followAircraft: true,
+		flightNum:      1,
 		showHelp:       false,
 		hudMode:        2, // Default to Panel+map
-		showTouchBtns:  false,
+		baudRate:       DefaultBaudRate,
+		quit:           make(chan struct{}),
+		discovered:     make(chan []MDNSService, 1),
+		failedOver:     make(chan string, 1),
+		deviceInfo:     make(chan deviceInfo, 1),
+		commandDone:    make(chan string, 1),
+		findDone:       make(chan string, 1),
+	}
+	tileManager.OnDownloadError(func(reason string) {
+		app.toasts.Add(LevelWarn, "Tile download failed: %s", reason)
+	})
+	app.portMenu = app.newPortMenu()
+	app.quitMenu = app.newQuitMenu()
+	app.trackerMenu = app.newTrackerMenu()
+	app.weatherMenu = app.newConditionsMenu()
+	app.osdMenu = app.newOSDMenu()
+	app.layers = app.newMapLayers()
+	app.layersMenu = app.newLayersMenu()
+	app.wizardMenu = app.newWizardMenu()
+	app.logbookMenu = app.newLogbookMenu()
+	app.deviceMenu = app.newDeviceMenu()
+	app.vtxMenu = app.newVTXMenu()
+	app.backendMenu = app.newBackendMenu()
+	app.settingsMenu = app.newSettingsMenu()
+	// Restore the view, home and link settings from the last session
+	app.restoreState()
+	app.applyConfig()
+	app.loadMission()
+	app.loadCompareTrack()
+	app.loadOverlays()
+	// Warm the tiles for home and the last view while the window opens
+	if cfg.Map.Preheat {
+		homeLat, homeLon := cfg.Map.DefaultLat, cfg.Map.DefaultLon
+		if home := cfg.State.Home; home.Set {
+			homeLat, homeLon = home.Lat, home.Lon
+		}
+		tileManager.Preheat(homeLat, homeLon, app.zoom, width, height)
+		tileManager.Preheat(app.centerLat, app.centerLon, app.zoom, width, height)
 	}
+	app.actions = app.newActions()
+	app.actionQueue = make(chan string, actionQueueSize)
 	// Setup touch buttons (still available if enabled)
-	app.touchControls.SetupDefaultButtons(app)
+	app.touchControls.SetupButtons(app, cfg.Touch)
 	// Setup GPIO buttons
 	app.gpioController.SetupDefaultButtons(app)
+	app.publishHome()
+	app.webServer.Home = app.loadHome
+	app.displayPort.Home = app.loadHome
+	app.mqtt.Home = app.loadHome
+	app.statusLine.Home = app.loadHome
+	app.plugins.Home = app.loadHome
+	app.plugins.Alert = func(level LogLevel, text string) { app.toasts.Add(level, "%s", text) }
+	app.plugins.Action = app.queueAction
+	app.share = NewSharePublisher(app.webServer, &cfg.Share)
+	app.client.OnStreamRestart = func(quiet time.Duration) {
+		app.toasts.Add(LevelWarn, "Telemetry stream restarted after %s without data", quiet.Round(time.Second))
+	}
+	app.failover.OnSwitch = func(addr, role string, linkWasStarted bool) {
+		app.notify(logTelem, LevelWarn, "Telemetry now from %s (%s backend)", addr, role)
+		app.warnOldBackend()
+		if linkWasStarted {
+			// The settings menu edits the port on the game loop, so the
+			// link is restarted there
+			select {
+			case app.failedOver <- addr:
+			default:
+			}
+		}
+	}
+	app.uploader.Active = app.recorder.Active
+	app.logbook.Log = app.recorder.Active
+	app.logbook.OnRecord = func(profile string, records []string) {
+		app.toasts.Add(LevelInfo, "New %s record: %s", profile, strings.Join(records, ", "))
+	}
+	app.webServer.Tiles = tileManager
+	app.webServer.Action = app.queueAction
+	app.webServer.Metrics = func(m metricsWriter) { app.perf.WriteMetrics(m, tileManager) }
+	app.displayPort.Units = func() Units { return app.config.Display.Units }
+	app.displayPort.Charge = func(s TelemetryState) uint32 { return app.config.Battery.Charge(s) }
+	app.mqtt.Charge = app.displayPort.Charge
+
+	// Offer to bring back the track from a session that crashed
+	if snap, err := LoadSnapshot(SnapshotPath()); err != nil {
+		logApp.Warnf("Could not read crash snapshot: %v", err)
+	} else if snap != nil {
+		app.restoreMenu = app.newRestoreMenu(snap)
+		app.restoreMenu.Open()
+	}
 	return app
 }
 
@@ -95,6 +346,9 @@ func (a *App) Run() error {
 	ebiten.SetWindowSize(a.width, a.height)
 	ebiten.SetWindowTitle("ELRS Ground Station")
 	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	// The close button goes through the same confirmation as Q/Esc
+	ebiten.SetWindowClosingHandled(true)
+	selectMonitor(a.config.Window.Monitor)
 
 	if a.fullscreen {
 		ebiten.SetFullscreen(true)
@@ -102,44 +356,176 @@ func (a *App) Run() error {
 
 	// Connect to gRPC backend
 	if err := a.client.Connect(); err != nil {
-		log.Printf("Warning: Could not connect to backend: %v", err)
+		a.notify(logTelem, LevelWarn, "Could not connect to backend at %s: %v", a.client.Address(), err)
+		if a.config.Backend.Discover {
+			a.discoverBackends(true)
+		}
 	} else {
 		a.client.StartTelemetryStream()
+		a.warnOldBackend()
+	}
+	a.failover.Start()
+
+	// Start GPIO controller (will auto-detect if on Pi); the buttons
+	// belong to the first screen
+	if !a.secondScreen {
+		if err := a.gpioController.Start(); err != nil {
+			logGPIO.Errorf("GPIO controller error: %v", err)
+		}
 	}
 
-	// Start GPIO controller (will auto-detect if on Pi)
-	if err := a.gpioController.Start(); err != nil {
-		log.Printf("GPIO controller error: %v", err)
+	if err := a.tracker.Start(); err != nil {
+		logTracker.Errorf("Could not start tracker: %v", err)
+	}
+
+	if a.config.Record.Enabled {
+		if err := a.recorder.Start(); err != nil {
+			logRec.Errorf("Could not start recorder: %v", err)
+		}
+	}
+	if a.config.Web.Enabled {
+		a.webServer.Start()
+	}
+	if a.config.Buddy.Enabled {
+		if err := a.buddyShare.Start(); err != nil {
+			logBuddy.Errorf("Could not start position sharing: %v", err)
+		}
+	}
+	if a.config.MQTT.Enabled {
+		a.mqtt.Start()
+	}
+	if a.config.Share.Enabled {
+		a.share.Start()
+	}
+	if a.config.Status.Enabled {
+		a.statusLine.Start()
+	}
+	a.plugins.Start()
+	if a.config.Upload.Enabled {
+		a.uploader.Start()
+	}
+	a.weather.Start()
+	if a.config.OSDOut.Enabled {
+		if err := a.displayPort.Start(); err != nil {
+			logOSDOut.Errorf("Could not start DisplayPort output: %v", err)
+		}
+	}
+	if a.config.GPS.Enabled {
+		a.localGPS.Start()
 	}
 
 	return ebiten.RunGame(a)
 }
 
-// Shutdown cleans up resources
+// Shutdown cleans up resources once the game loop has returned. Inputs go
+// first so no button fires on a closed client, and the recorder last so it
+// keeps everything received until the link stopped.
 func (a *App) Shutdown() {
+	logApp.Infof("Shutting down...")
 	a.gpioController.Stop()
-	a.client.StopTelemetryStream()
+	a.tracker.Stop()
+	a.buddyShare.Stop()
+	a.failover.Stop()
+	a.mqtt.Stop()
+	a.share.Stop()
+	a.statusLine.Stop()
+	a.plugins.Stop()
+	a.uploader.Stop()
+	a.weather.Stop()
+	a.displayPort.Stop()
+	a.localGPS.Stop()
+	if a.config.Web.Enabled {
+		a.webServer.Stop()
+	}
 	a.client.StopLink()
 	a.client.Disconnect()
+	a.recorder.Stop()
+	a.logbook.Close()
+	a.saveConfig()
+	if bytes, tiles := a.tileManager.DataUsed(); tiles > 0 {
+		logTile.Infof("Downloaded %d tiles (%s) this session", tiles, formatBytes(bytes))
+	}
+	a.tileManager.Close()
+}
+
+// RequestQuit makes the game loop exit on its next update. It is safe to
+// call from any goroutine.
+func (a *App) RequestQuit() {
+	a.quitOnce.Do(func() { close(a.quit) })
+}
+
+// confirmQuit quits right away, or asks first while the link is active
+func (a *App) confirmQuit() {
+	if a.client.IsLinkStarted() {
+		a.quitMenu.Open()
+		return
+	}
+	a.RequestQuit()
+}
+
+// newQuitMenu builds the exit confirmation; Cancel comes first so a stray
+// Enter or encoder push keeps the app running
+func (a *App) newQuitMenu() *Menu {
+	m := NewMenu("Link is active - quit?")
+	m.Items = []MenuItem{
+		{Label: "Cancel", OnSelect: m.Close},
+		{
+			Label: "Quit",
+			OnSelect: func() {
+				m.Close()
+				a.RequestQuit()
+			},
+		},
+	}
+	return m
 }
 
 // Update handles input and logic updates
 func (a *App) Update() error {
+	defer a.recoverPanic()
+	defer a.publishHome()
+
+	select {
+	case <-a.quit:
+		return ebiten.Termination
+	default:
+	}
+	if ebiten.IsWindowBeingClosed() {
+		a.confirmQuit()
+	}
+
 	// Get current screen size
 	a.width, a.height = ebiten.WindowSize()
 
-	// Handle touch input first (before keyboard to allow touch override)
-	if a.showTouchBtns {
-		a.touchControls.UpdateLayout(a.width, a.height)
-		a.touchControls.Update()
-		a.touchControls.UpdateButtonStates(a)
-	}
+	// An open menu takes all input until it is closed
+	if menu := a.openMenu(); menu != nil {
+		menu.Update()
+		a.handleGamepadMenu(menu)
+	} else {
+		// Handle touch input first (before keyboard to allow touch override)
+		if a.showTouchBtns {
+			a.touchControls.UpdateLayout(a.width, a.height)
+			a.touchControls.Update()
+			a.touchControls.UpdateButtonStates(a)
+		}
 
-	// Handle keyboard input
-	a.handleKeyboard()
+		// Handle keyboard and gamepad input
+		a.handleKeyboard()
+		a.handleGamepad()
 
-	// Handle mouse input
-	a.handleMouse()
+		// Handle mouse input
+		if !a.pickingSite || !a.updatePickingSite() {
+			a.handleMouse()
+		}
+	}
+
+	a.runQueuedActions()
+	a.handleDiscovery()
+	a.handleFailover()
+	a.handleDeviceInfo()
+	a.handleFind()
+	a.tileManager.Upload()
+	a.layers.Update()
 
 	// Update port list periodically
 	if time.Since(a.lastPortScan) > 2*time.Second {
@@ -147,71 +533,135 @@ func (a *App) Update() error {
 		a.lastPortScan = time.Now()
 	}
 
+	// Persist settings and view state (only written when changed)
+	if time.Since(a.lastAutosave) > 15*time.Second {
+		a.saveConfig()
+		a.lastAutosave = time.Now()
+	}
+
 	// Update flight path and follow aircraft
 	state := a.client.GetState()
+	a.gpioController.UpdateIndicators(state, a.config.Alerts, a.config.Battery)
+	a.updatePilot(state)
+	a.heading.Update(a.client, state, a.centerLat, a.centerLon)
+	a.client.SetBatteryCalibration(a.config.Aircraft.Calibration())
+	a.client.SetSlowHeading(a.config.Aircraft.SlowHeading())
+	a.client.SetAltitudeSource(a.config.Altitude.Source)
+	// The tracker stands with the pilot when that is known
+	if a.pilotSet {
+		a.tracker.Update(state, a.pilotLat, a.pilotLon, true)
+	} else {
+		a.tracker.Update(state, a.homeLat, a.homeLon, a.homeSet)
+	}
+	a.logbook.Update(state, a.config.Aircraft.Profile)
+	a.latency.Update()
+	a.recordLQ(state)
+	a.failsafeMode, a.failsafeTime = a.failsafe.Update(state)
+	// Forecast for home, or wherever the map is before home is set
+	if a.homeSet {
+		a.weather.SetLocation(a.homeLat, a.homeLon)
+		if !a.movingHome {
+			a.tileManager.PrefetchAround(a.homeLat, a.homeLon, a.zoom)
+		}
+	} else {
+		a.weather.SetLocation(a.centerLat, a.centerLon)
+	}
+	// Arming or taking off again starts a new trail, in its own color
+	if a.newFlight(state) {
+		a.startFlight()
+		if a.config.Altitude.ZeroOnArm {
+			a.client.ZeroAltitude(false)
+		}
+	}
+	goodFix, settled := a.fixGate.Update(state, &a.config.Map)
+	a.autoHome(state, goodFix && settled)
 	if state.HasGPS && state.Latitude != 0 && state.Longitude != 0 {
-		// Add to flight path
-		a.flightPath = append(a.flightPath, struct{ lat, lon float64 }{
-			lat: float64(state.Latitude),
-			lon: float64(state.Longitude),
-		})
-		if len(a.flightPath) > a.maxPathLen {
-			a.flightPath = a.flightPath[1:]
+		// Add to flight path once a cold GPS has stopped wandering
+		if settled {
+			a.flightPath = appendPathPoint(a.flightPath, pathPoint{
+				lat:      float64(state.Latitude),
+				lon:      float64(state.Longitude),
+				alt:      relativeAltitude(state),
+				at:       state.LastUpdate,
+				flight:   a.flightNum,
+				poor:     !goodFix,
+				maxPower: a.txPower.AtMax(),
+			})
+			if len(a.flightPath) > a.maxPathLen {
+				a.flightPath = a.flightPath[1:]
+			}
 		}
 
 		// Follow aircraft
 		if a.followAircraft {
-			a.centerLat = float64(state.Latitude)
-			a.centerLon = float64(state.Longitude)
+			a.followCenter(state)
+		} else {
+			a.followLead = [2]float64{}
 		}
 	}
+	a.updateNav(state)
+	a.updateRally(state)
+	a.perf.Update(a.tileManager, a.client)
+	a.updateRedraw(state)
 
 	return nil
 }
 
 // Draw renders the application
 func (a *App) Draw(screen *ebiten.Image) {
-	// Clear screen
-	screen.Fill(color.RGBA{30, 30, 30, 255})
+	defer a.recoverPanic()
 
-	// Calculate map offset based on HUD mode
-	mapOffsetX := 0
-	if a.hudMode == 2 {
-		mapOffsetX = a.panel.GetPanelWidth()
+	if a.skipDraw() {
+		return
 	}
+	start := time.Now()
+	defer func() { a.perf.FrameTime(time.Since(start)) }()
 
-	// Draw map tiles (with offset for panel mode)
-	a.drawMapWithOffset(screen, mapOffsetX)
-
-	// Draw flight path
-	a.drawFlightPathWithOffset(screen, mapOffsetX)
+	// Clear screen
+	screen.Fill(a.mapBg)
 
-	// Draw home marker
-	a.drawHomeMarkerWithOffset(screen, mapOffsetX)
+	// Calculate map area based on HUD mode and panel side
+	mapOffsetX, mapWidth := a.mapArea()
 
-	// Draw aircraft
-	a.drawAircraftWithOffset(screen, mapOffsetX)
+	// Draw the map layers, tiles first
+	a.layers.Draw(screen, a.mapView(mapOffsetX, mapWidth))
 
 	// Get telemetry state for HUD
 	state := a.client.GetState()
+	// Distances are from home or the pilot, as chosen
+	refLat, refLon, refSet, refLabel := a.distanceRef()
 	homeDist := 0.0
 	homeBearing := 0.0
-	if a.homeSet && state.HasGPS {
-		homeDist = a.calculateDistance(float64(state.Latitude), float64(state.Longitude), a.homeLat, a.homeLon)
-		homeBearing = a.calculateBearing(float64(state.Latitude), float64(state.Longitude), a.homeLat, a.homeLon)
+	if refSet && state.HasGPS {
+		homeDist = a.calculateDistance(float64(state.Latitude), float64(state.Longitude), refLat, refLon)
+		homeBearing = a.calculateBearing(float64(state.Latitude), float64(state.Longitude), refLat, refLon)
 	}
+	a.panel.HomeLabel = refLabel
 
 	// Draw HUD based on mode
+	a.osd.Nav, a.panel.Nav = a.navInfo, a.navInfo
+	a.osd.Rally, a.panel.Rally = a.rallyInfo, a.rallyInfo
 	switch a.hudMode {
 	case 0: // Full map only - no overlay
 		// Just show minimal status in corner
 		a.drawMinimalStatus(screen, state)
+		a.drawMiniAttitude(screen, state)
 	case 1: // OSD overlay on full map
-		a.osd.Draw(screen, state, a.homeSet, homeDist, homeBearing)
+		a.osd.Draw(screen, state, refSet, homeDist, homeBearing)
 	case 2: // Panel + map
-		a.panel.Draw(screen, state, a.homeSet, homeDist, homeBearing)
+		a.panel.Draw(screen, state, refSet, homeDist, homeBearing)
+	case 3: // Map + cockpit side by side
+		a.drawCockpitPane(screen, state, homeDist, homeBearing)
+	case 4: // Big numbers instead of the map
+		a.drawBigNumbers(screen, state, refSet, homeDist)
 	}
 
+	// Draw legal limit warning
+	a.drawLimitWarning(screen, checkLimits(state, a.config.Alerts, refSet, homeDist), mapOffsetX, mapWidth)
+
+	// Draw failsafe / RTH banner
+	a.drawFailsafeWithOffset(screen, mapOffsetX, mapWidth)
+
 	// Draw help overlay
 	if a.showHelp {
 		a.drawHelp(screen)
@@ -222,8 +672,62 @@ func (a *App) Draw(screen *ebiten.Image) {
 		a.touchControls.Draw(screen)
 	}
 
+	// Draw wind box
+	a.drawWindWithOffset(screen, mapOffsetX, mapWidth)
+
+	// Draw link latency graph
+	a.drawLatencyWithOffset(screen, mapOffsetX, mapWidth)
+
+	// Draw LQ timeline
+	a.drawLQTimelineWithOffset(screen, mapOffsetX, mapWidth)
+
+	// Draw the elevation profile being drawn
+	a.drawProfileWithOffset(screen, mapOffsetX, mapWidth)
+
+	// Credit the map tiles
+	a.drawAttributionWithOffset(screen, mapOffsetX, mapWidth)
+
+	// Draw notifications
+	a.drawToastsWithOffset(screen, mapOffsetX, mapWidth)
+
 	// Draw status bar
 	a.drawStatusBar(screen)
+
+	// Draw diagnostics
+	a.perf.Draw(screen)
+
+	// Draw setup wizard site picker
+	a.drawPickingSite(screen)
+
+	// Draw open menu on top of everything
+	if menu := a.openMenu(); menu != nil {
+		menu.Draw(screen)
+	}
+}
+
+// openMenu returns the menu currently shown, or nil
+func (a *App) openMenu() *Menu {
+	for _, m := range []*Menu{a.wizardMenu, a.restoreMenu, a.homeMenu, a.quitMenu, a.portMenu, a.settingsMenu, a.trackerMenu, a.weatherMenu, a.osdMenu, a.layersMenu, a.logbookMenu, a.deviceMenu, a.vtxMenu, a.backendMenu} {
+		if m != nil && m.IsOpen() {
+			return m
+		}
+	}
+	return nil
+}
+
+// mapArea returns the left edge and width of the map region on screen
+func (a *App) mapArea() (int, int) {
+	if a.hudMode == 3 {
+		return a.splitArea()
+	}
+	if a.hudMode != 2 {
+		return 0, a.width
+	}
+	panelW := a.panel.GetPanelWidth()
+	if a.panel.RightSide {
+		return 0, a.width - panelW
+	}
+	return panelW, a.width - panelW
 }
 
 // drawMinimalStatus draws minimal info for full-map mode
@@ -231,129 +735,194 @@ func (a *App) drawMinimalStatus(screen *ebiten.Image, state TelemetryState) {
 	// Small semi-transparent box in top-left
 	vector.DrawFilledRect(screen, 5, 5, 200, 35, color.RGBA{0, 0, 0, 180}, true)
 	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%.5f, %.5f", state.Latitude, state.Longitude), 10, 8)
-	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("ALT:%dm SPD:%.0fkm/h", state.Altitude, state.GroundSpeed), 10, 22)
+	units := a.config.Display.Units
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("ALT:%.0f%s SPD:%.0f%s", units.Altitude(relativeAltitude(state)), units.AltitudeLabel(), units.Speed(float64(state.GroundSpeed)), units.SpeedLabel()), 10, 22)
 }
 
-// drawMapWithOffset draws map tiles with X offset for panel
-func (a *App) drawMapWithOffset(screen *ebiten.Image, offsetX int) {
-	// Get visible tiles
-	mapWidth := a.width - offsetX
-	coords := a.tileManager.GetTilesForView(a.centerLat, a.centerLon, a.zoom, mapWidth, a.height)
-
-	// Calculate center pixel position
-	centerPixelX, centerPixelY := LatLonToPixel(a.centerLat, a.centerLon, a.zoom)
-
-	// Screen center (adjusted for panel offset)
-	screenCenterX := float64(offsetX + mapWidth/2)
-	screenCenterY := float64(a.height / 2)
-
-	for _, coord := range coords {
-		tile := a.tileManager.GetTile(coord)
+// drawTilesWithOffset draws the map tiles of the view
+func (a *App) drawTilesWithOffset(screen *ebiten.Image, v MapView) {
+	// Get visible tiles, enough to cover the map area however it is turned
+	left, top, w, h := v.WorldBounds()
+	geo := v.GeoM()
+
+	// Missing tiles go into one path, so they are a single draw call and
+	// the tile images that follow batch together
+	var placeholders vector.Path
+	tiles := a.tileBatch[:0]
+	for _, vt := range TilesInBounds(left, top, w, h, v.TileZoom()) {
+		tile := a.tileManager.GetTile(vt.Coord)
 		if tile == nil {
-			// Draw placeholder
-			tilePixelX := float64(coord.X * TileSize)
-			tilePixelY := float64(coord.Y * TileSize)
-			screenX := screenCenterX + (tilePixelX - centerPixelX)
-			screenY := screenCenterY + (tilePixelY - centerPixelY)
-
-			// Only draw if visible in map area
-			if screenX+TileSize > float64(offsetX) && screenX < float64(a.width) {
-				vector.DrawFilledRect(screen, float32(screenX), float32(screenY), TileSize, TileSize, color.RGBA{50, 50, 55, 255}, true)
-				vector.StrokeRect(screen, float32(screenX), float32(screenY), TileSize, TileSize, 1, color.RGBA{70, 70, 75, 255}, true)
+			for i, c := range [4][2]float64{{0, 0}, {TileSize, 0}, {TileSize, TileSize}, {0, TileSize}} {
+				x, y := geo.Apply(vt.X+c[0], vt.Y+c[1])
+				if i == 0 {
+					placeholders.MoveTo(float32(x), float32(y))
+				} else {
+					placeholders.LineTo(float32(x), float32(y))
+				}
 			}
+			placeholders.Close()
 			continue
 		}
+		tiles = append(tiles, placedTile{tile, vt.X, vt.Y})
+	}
+	a.tileBatch = tiles
 
-		// Calculate screen position
-		tilePixelX := float64(coord.X * TileSize)
-		tilePixelY := float64(coord.Y * TileSize)
-		screenX := screenCenterX + (tilePixelX - centerPixelX)
-		screenY := screenCenterY + (tilePixelY - centerPixelY)
-
-		// Only draw if visible in map area
-		if screenX+TileSize > float64(offsetX) && screenX < float64(a.width) {
-			op := &ebiten.DrawImageOptions{}
-			op.GeoM.Translate(screenX, screenY)
-			screen.DrawImage(tile, op)
+	drawPath(screen, &placeholders, color.RGBA{50, 50, 55, 255}, color.RGBA{70, 70, 75, 255})
+
+	op := &a.tileOp
+	op.Filter = ebiten.FilterNearest
+	if v.Scaled() {
+		op.Filter = ebiten.FilterLinear
+	}
+	// Brightness, contrast and saturation for washed-out imagery; the same
+	// matrix for every tile keeps them batched
+	cm, adjusted := mapColorM(a.config.Map)
+	cop := &colorm.DrawImageOptions{Filter: op.Filter}
+	for _, t := range tiles {
+		op.GeoM.Reset()
+		op.GeoM.Translate(t.x, t.y)
+		op.GeoM.Concat(geo)
+		if adjusted {
+			cop.GeoM = op.GeoM
+			colorm.DrawImage(screen, t.img, cm, cop)
+			continue
 		}
+		screen.DrawImage(t.img, op)
 	}
 }
 
-// drawFlightPathWithOffset draws flight path with X offset
-func (a *App) drawFlightPathWithOffset(screen *ebiten.Image, offsetX int) {
-	if len(a.flightPath) < 2 {
+// drawFlightPathWithOffset draws the flight path
+func (a *App) drawFlightPathWithOffset(screen *ebiten.Image, v MapView) {
+	if len(a.flightPath) < 2 || v.Width <= 0 || v.Height <= 0 {
 		return
 	}
 
-	mapWidth := a.width - offsetX
-	centerPixelX, centerPixelY := LatLonToPixel(a.centerLat, a.centerLon, a.zoom)
-	screenCenterX := float64(offsetX + mapWidth/2)
-	screenCenterY := float64(a.height / 2)
+	// World pixels the map area shows
+	left, top, w, h := v.WorldBounds()
+	width, height := int(math.Ceil(w)), int(math.Ceil(h))
 
-	for i := 1; i < len(a.flightPath); i++ {
-		p1 := a.flightPath[i-1]
-		p2 := a.flightPath[i]
+	l := &a.pathLayer
+	lift := 0.0
+	if a.config.Map.Path3D {
+		lift = a.config.Map.Path3DScale
+	}
+	if !l.covers(v.TileZoom(), lift, left, top, width, height) || !l.extend(a.flightPath) {
+		l.render(a.flightPath, v.TileZoom(), lift, left, top, width, height)
+	}
 
-		x1, y1 := LatLonToPixel(p1.lat, p1.lon, a.zoom)
-		x2, y2 := LatLonToPixel(p2.lat, p2.lon, a.zoom)
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(l.originX, l.originY)
+	op.GeoM.Concat(v.GeoM())
+	if v.Scaled() {
+		op.Filter = ebiten.FilterLinear
+	}
+	screen.DrawImage(l.img, op)
+	a.drawPathDropLine(screen, v)
+}
+
+// drawHomeLineWithOffset draws a line from home to the aircraft, labeled
+// with the distance and the bearing from home
+func (a *App) drawHomeLineWithOffset(screen *ebiten.Image, v MapView) {
+	state := a.client.GetState()
+	refLat, refLon, refSet, _ := a.distanceRef()
+	if !a.config.Map.HomeLine || !refSet || !state.HasGPS || (state.Latitude == 0 && state.Longitude == 0) {
+		return
+	}
+
+	lat, lon := float64(state.Latitude), float64(state.Longitude)
+	hx, hy := v.LatLonToScreen(refLat, refLon)
+	ax, ay := v.LatLonToScreen(lat, lon)
+	hsx, hsy := float32(hx), float32(hy)
+	asx, asy := float32(ax), float32(ay)
 
-		sx1 := float32(screenCenterX + (x1 - centerPixelX))
-		sy1 := float32(screenCenterY + (y1 - centerPixelY))
-		sx2 := float32(screenCenterX + (x2 - centerPixelX))
-		sy2 := float32(screenCenterY + (y2 - centerPixelY))
+	// Dark underlay keeps the line visible on bright imagery
+	vector.StrokeLine(screen, hsx, hsy, asx, asy, 4, color.RGBA{0, 0, 0, 120}, true)
+	vector.StrokeLine(screen, hsx, hsy, asx, asy, 2, color.RGBA{255, 255, 255, 220}, true)
 
-		// Color gradient (older = more transparent)
-		alpha := uint8(100 + (155 * i / len(a.flightPath)))
-		pathColor := color.RGBA{255, 200, 0, alpha}
+	dist := DistanceMeters(refLat, refLon, lat, lon)
+	bearing := BearingDegrees(refLat, refLon, lat, lon)
+	label := fmt.Sprintf("%s %03.0f°", a.config.Display.Units.FormatDistance(dist), bearing)
 
-		vector.StrokeLine(screen, sx1, sy1, sx2, sy2, 2, pathColor, true)
+	// Label at the midpoint, unless it is too short to read
+	mx, my := (hsx+asx)/2, (hsy+asy)/2
+	if math.Hypot(float64(asx-hsx), float64(asy-hsy)) < 60 || !v.ContainsX(float64(mx)) {
+		return
 	}
+	w := float32(len([]rune(label))*6 + 8)
+	vector.DrawFilledRect(screen, mx-w/2, my-9, w, 16, color.RGBA{0, 0, 0, 180}, false)
+	ebitenutil.DebugPrintAt(screen, label, int(mx-w/2)+4, int(my)-8)
 }
 
-// drawHomeMarkerWithOffset draws home marker with X offset
-func (a *App) drawHomeMarkerWithOffset(screen *ebiten.Image, offsetX int) {
+// drawHomeMarkerWithOffset draws the home marker
+func (a *App) drawHomeMarkerWithOffset(screen *ebiten.Image, v MapView) {
 	if !a.homeSet {
 		return
 	}
 
-	mapWidth := a.width - offsetX
-	centerPixelX, centerPixelY := LatLonToPixel(a.centerLat, a.centerLon, a.zoom)
-	screenCenterX := float64(offsetX + mapWidth/2)
-	screenCenterY := float64(a.height / 2)
-
-	hx, hy := LatLonToPixel(a.homeLat, a.homeLon, a.zoom)
-	sx := float32(screenCenterX + (hx - centerPixelX))
-	sy := float32(screenCenterY + (hy - centerPixelY))
+	hx, hy := v.LatLonToScreen(a.homeLat, a.homeLon)
+	sx, sy := float32(hx), float32(hy)
 
 	// Only draw if in map area
-	if sx > float32(offsetX) && sx < float32(a.width) {
+	if v.ContainsX(hx) {
 		// Home icon - house shape
 		vector.DrawFilledCircle(screen, sx, sy, 8, color.RGBA{0, 255, 0, 200}, true)
 		vector.StrokeCircle(screen, sx, sy, 8, 2, color.RGBA{255, 255, 255, 255}, true)
 		ebitenutil.DebugPrintAt(screen, "H", int(sx)-3, int(sy)-6)
+		if a.movingHome {
+			// Ring to grab it by
+			vector.StrokeCircle(screen, sx, sy, homeGrabRadius, 2, color.RGBA{255, 220, 0, 255}, true)
+			ebitenutil.DebugPrintAt(screen, "Drag, Enter keeps", int(sx)-51, int(sy)+homeGrabRadius+2)
+		}
 	}
 }
 
-// drawAircraftWithOffset draws aircraft with X offset
-func (a *App) drawAircraftWithOffset(screen *ebiten.Image, offsetX int) {
+// drawBuddiesWithOffset draws other ground stations' aircraft with name labels
+func (a *App) drawBuddiesWithOffset(screen *ebiten.Image, v MapView) {
+	buddies := a.buddyShare.Buddies()
+	if len(buddies) == 0 {
+		return
+	}
+
+	buddyColor := color.RGBA{80, 200, 255, 255}
+
+	for _, b := range buddies {
+		bx, by := v.LatLonToScreen(b.Lat, b.Lon)
+		if !v.ContainsX(bx) {
+			continue
+		}
+		sx, sy := float32(bx), float32(by)
+
+		// Dot with a short heading tick
+		headingRad := v.ScreenHeading(float64(b.Heading)) * math.Pi / 180
+		vector.StrokeLine(screen, sx, sy, sx+14*float32(math.Sin(headingRad)), sy-14*float32(math.Cos(headingRad)), 2, buddyColor, true)
+		vector.DrawFilledCircle(screen, sx, sy, 6, buddyColor, true)
+		vector.StrokeCircle(screen, sx, sy, 6, 1, color.RGBA{255, 255, 255, 255}, true)
+
+		units := a.config.Display.Units
+		label := fmt.Sprintf("%s %.0f%s", b.Name, units.Altitude(float64(b.Alt)), units.AltitudeLabel())
+		ebitenutil.DebugPrintAt(screen, label, int(sx)+10, int(sy)-8)
+	}
+}
+
+// drawAircraftWithOffset draws the aircraft
+func (a *App) drawAircraftWithOffset(screen *ebiten.Image, v MapView) {
 	state := a.client.GetState()
 	if !state.HasGPS || (state.Latitude == 0 && state.Longitude == 0) {
 		return
 	}
 
-	mapWidth := a.width - offsetX
-	centerPixelX, centerPixelY := LatLonToPixel(a.centerLat, a.centerLon, a.zoom)
-	screenCenterX := float64(offsetX + mapWidth/2)
-	screenCenterY := float64(a.height / 2)
-
-	ax, ay := LatLonToPixel(float64(state.Latitude), float64(state.Longitude), a.zoom)
-	sx := float32(screenCenterX + (ax - centerPixelX))
-	sy := float32(screenCenterY + (ay - centerPixelY))
+	ax, ay := v.LatLonToScreen(float64(state.Latitude), float64(state.Longitude))
+	sx, sy := float32(ax), float32(ay)
 
 	// Only draw if in map area
-	if sx > float32(offsetX) && sx < float32(a.width) {
-		// Draw aircraft triangle pointing in heading direction
-		a.drawAircraftTriangleAt(screen, sx, sy, state.Heading)
+	if v.ContainsX(ax) {
+		// Draw aircraft icon pointing in heading direction
+		heading := float32(v.ScreenHeading(float64(state.Heading)))
+		if a.aircraftSprite != nil {
+			a.aircraftSprite.Draw(screen, sx, sy, heading)
+		} else {
+			a.drawAircraftTriangleAt(screen, sx, sy, heading)
+		}
 	}
 }
 
@@ -397,140 +966,70 @@ func (a *App) Layout(outsideWidth, outsideHeight int) (int, int) {
 }
 
 func (a *App) handleKeyboard() {
-	// Zoom
-	if inpututil.IsKeyJustPressed(ebiten.KeyEqual) || inpututil.IsKeyJustPressed(ebiten.KeyKPAdd) {
-		if a.zoom < MaxZoom {
-			a.zoom++
-			a.tileManager.ClearCache()
+	// Keep or put back a moved home, before Esc can quit
+	if a.movingHome {
+		if inpututil.IsKeyJustPressed(ebiten.KeyEnter) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadEnter) {
+			a.finishMovingHome(true)
 		}
-	}
-	if inpututil.IsKeyJustPressed(ebiten.KeyMinus) || inpututil.IsKeyJustPressed(ebiten.KeyKPSubtract) {
-		if a.zoom > MinZoom {
-			a.zoom--
-			a.tileManager.ClearCache()
-		}
-	}
-
-	// Pan with arrow keys
-	panSpeed := 0.001 * math.Pow(2, float64(18-a.zoom))
-	if ebiten.IsKeyPressed(ebiten.KeyUp) || ebiten.IsKeyPressed(ebiten.KeyW) {
-		a.centerLat += panSpeed
-		a.followAircraft = false
-	}
-	if ebiten.IsKeyPressed(ebiten.KeyDown) || ebiten.IsKeyPressed(ebiten.KeyS) {
-		a.centerLat -= panSpeed
-		a.followAircraft = false
-	}
-	if ebiten.IsKeyPressed(ebiten.KeyLeft) || ebiten.IsKeyPressed(ebiten.KeyA) {
-		a.centerLon -= panSpeed
-		a.followAircraft = false
-	}
-	if ebiten.IsKeyPressed(ebiten.KeyRight) || ebiten.IsKeyPressed(ebiten.KeyD) {
-		a.centerLon += panSpeed
-		a.followAircraft = false
-	}
-
-	// Toggle follow mode
-	if inpututil.IsKeyJustPressed(ebiten.KeyF) {
-		a.followAircraft = !a.followAircraft
-	}
-
-	// Set home position
-	if inpututil.IsKeyJustPressed(ebiten.KeyH) {
-		state := a.client.GetState()
-		if state.HasGPS {
-			a.homeLat = float64(state.Latitude)
-			a.homeLon = float64(state.Longitude)
-			a.homeSet = true
-			log.Printf("Home set to %.6f, %.6f", a.homeLat, a.homeLon)
+		if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+			a.finishMovingHome(false)
+			return
 		}
 	}
 
-	// Clear flight path
-	if inpututil.IsKeyJustPressed(ebiten.KeyC) {
-		a.flightPath = nil
-	}
-
-	// Toggle help
-	if inpututil.IsKeyJustPressed(ebiten.KeyF1) || inpututil.IsKeyJustPressed(ebiten.KeySlash) {
-		a.showHelp = !a.showHelp
-	}
+	// Pan with arrow keys, in screen pixels so it is as quick at any zoom
+	// and latitude
+	a.panKeys()
 
-	// Cycle HUD mode (0=off, 1=OSD, 2=cockpit)
-	if inpututil.IsKeyJustPressed(ebiten.KeyV) {
-		a.hudMode = (a.hudMode + 1) % 3
-	}
-
-	// Toggle map source (street/satellite)
-	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
-		source := a.tileManager.ToggleSource()
-		log.Printf("Map source: %s", a.tileManager.SourceName())
-		_ = source
-	}
-
-	// Toggle touch buttons
-	if inpututil.IsKeyJustPressed(ebiten.KeyT) {
-		a.showTouchBtns = !a.showTouchBtns
-	}
-
-	// Connect/disconnect link
-	if inpututil.IsKeyJustPressed(ebiten.KeyL) {
-		if a.client.IsLinkStarted() {
-			a.client.StopLink()
-		} else if len(a.ports) > 0 && a.selectedPort < len(a.ports) {
-			a.client.StartLink(a.ports[a.selectedPort], 420000)
-		}
-	}
-
-	// Cycle through ports
-	if inpututil.IsKeyJustPressed(ebiten.KeyP) {
-		if len(a.ports) > 0 {
-			a.selectedPort = (a.selectedPort + 1) % len(a.ports)
-		}
-	}
-
-	// Fullscreen toggle
-	if inpututil.IsKeyJustPressed(ebiten.KeyF11) {
-		ebiten.SetFullscreen(!ebiten.IsFullscreen())
-	}
-
-	// Quit
-	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || inpututil.IsKeyJustPressed(ebiten.KeyQ) {
-		a.Shutdown()
-	}
+	// Everything else is a key bound to an action
+	a.handleKeyBindings()
 }
 
 func (a *App) handleMouse() {
 	// Scroll to zoom
-	_, dy := ebiten.Wheel()
-	if dy > 0 && a.zoom < MaxZoom {
-		a.zoom++
-	} else if dy < 0 && a.zoom > MinZoom {
-		a.zoom--
-	}
+	_, wheel := ebiten.Wheel()
+	a.wheelZoom(wheel)
 
-	// Drag to pan
+	// Drag to pan, unless it picks up the home marker
 	x, y := ebiten.CursorPosition()
+	if a.dragHome(x, y) {
+		return
+	}
 	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
 		a.dragging = true
 		a.dragStartX = x
 		a.dragStartY = y
 		a.dragLat = a.centerLat
 		a.dragLon = a.centerLon
+		a.dragMoved = false
 	}
 
 	if a.dragging {
 		if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
 			dx := float64(x - a.dragStartX)
 			dy := float64(y - a.dragStartY)
-
-			// Convert pixel delta to lat/lon delta
-			scale := 360.0 / (float64(TileSize) * math.Pow(2, float64(a.zoom)))
-			a.centerLon = a.dragLon - dx*scale
-			a.centerLat = a.dragLat + dy*scale*math.Cos(a.centerLat*math.Pi/180)
+			// Hold still for a click, so a shaky hand doesn't pan
+			if !a.dragMoved && math.Abs(dx) <= clickSlop && math.Abs(dy) <= clickSlop {
+				return
+			}
+			a.dragMoved = true
+
+			// Center what was at the middle less the drag, so the map
+			// moves with the mouse
+			v := a.currentMapView()
+			v.CenterLat, v.CenterLon = a.dragLat, a.dragLon
+			mx, my := v.middle()
+			a.centerLat, a.centerLon = v.ScreenToLatLon(mx-dx, my-dy)
 			a.followAircraft = false
 		} else {
 			a.dragging = false
+			// A click adds to the profiled route, or shows what was
+			// recorded there on the trail
+			if !a.dragMoved && a.profiling {
+				a.addProfilePoint(x, y)
+			} else if !a.dragMoved {
+				a.inspectAt(x, y)
+			}
 		}
 	}
 }
@@ -541,129 +1040,48 @@ func (a *App) scanPorts() {
 	}
 	ports, err := a.client.GetTransmitters()
 	if err != nil {
+		if msg := describeLinkError(err, "", a.client.Address()); msg != a.portScanErr {
+			a.portScanErr = msg
+			a.notify(logTelem, LevelWarn, "Could not list transmitters: %s", msg)
+		}
 		return
 	}
-	a.ports = ports
-}
-
-func (a *App) drawMap(screen *ebiten.Image) {
-	tiles := a.tileManager.GetTilesForView(a.centerLat, a.centerLon, a.zoom, a.width, a.height)
-
-	// Calculate center pixel position
-	centerPx, centerPy := LatLonToPixel(a.centerLat, a.centerLon, a.zoom)
-
-	for _, coord := range tiles {
-		tile := a.tileManager.GetTile(coord)
-		if tile == nil {
-			// Draw placeholder
-			tileX := float64(coord.X*TileSize) - centerPx + float64(a.width)/2
-			tileY := float64(coord.Y*TileSize) - centerPy + float64(a.height)/2
-			vector.DrawFilledRect(screen, float32(tileX), float32(tileY), TileSize, TileSize, color.RGBA{50, 50, 50, 255}, false)
-			continue
-		}
+	a.portScanErr = ""
 
-		// Calculate tile position on screen
-		tileX := float64(coord.X*TileSize) - centerPx + float64(a.width)/2
-		tileY := float64(coord.Y*TileSize) - centerPy + float64(a.height)/2
-
-		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(tileX, tileY)
-		screen.DrawImage(tile, op)
+	// Keep the selection on the same port name (or the last used one) as the list changes
+	want := a.config.Backend.Port
+	if len(a.ports) > 0 && a.selectedPort < len(a.ports) {
+		want = a.ports[a.selectedPort]
 	}
-}
-
-func (a *App) drawFlightPath(screen *ebiten.Image) {
-	if len(a.flightPath) < 2 {
-		return
+	a.ports = ports
+	for i, p := range ports {
+		if p == want {
+			a.selectedPort = i
+			break
+		}
 	}
-
-	centerPx, centerPy := LatLonToPixel(a.centerLat, a.centerLon, a.zoom)
-
-	for i := 1; i < len(a.flightPath); i++ {
-		p1 := a.flightPath[i-1]
-		p2 := a.flightPath[i]
-
-		x1, y1 := LatLonToPixel(p1.lat, p1.lon, a.zoom)
-		x2, y2 := LatLonToPixel(p2.lat, p2.lon, a.zoom)
-
-		sx1 := float32(x1 - centerPx + float64(a.width)/2)
-		sy1 := float32(y1 - centerPy + float64(a.height)/2)
-		sx2 := float32(x2 - centerPx + float64(a.width)/2)
-		sy2 := float32(y2 - centerPy + float64(a.height)/2)
-
-		// Gradient color based on age
-		alpha := uint8(100 + 155*i/len(a.flightPath))
-		vector.StrokeLine(screen, sx1, sy1, sx2, sy2, 2, color.RGBA{255, 200, 0, alpha}, false)
+	if a.selectedPort >= len(ports) {
+		a.selectedPort = 0
 	}
 }
 
-func (a *App) drawHomeMarker(screen *ebiten.Image) {
-	if !a.homeSet {
+// toggleLink starts the link on the selected port or stops it, and tells
+// the user why when that fails
+func (a *App) toggleLink() {
+	if a.client.IsLinkStarted() {
+		if err := a.client.StopLink(); err != nil {
+			a.notify(logTelem, LevelError, "Could not stop link: %s", describeLinkError(err, "", a.client.Address()))
+		}
 		return
 	}
-
-	centerPx, centerPy := LatLonToPixel(a.centerLat, a.centerLon, a.zoom)
-	hx, hy := LatLonToPixel(a.homeLat, a.homeLon, a.zoom)
-
-	sx := float32(hx - centerPx + float64(a.width)/2)
-	sy := float32(hy - centerPy + float64(a.height)/2)
-
-	// Draw home icon (house shape)
-	vector.DrawFilledCircle(screen, sx, sy, 8, color.RGBA{0, 255, 0, 255}, false)
-	vector.StrokeCircle(screen, sx, sy, 12, 2, color.RGBA{0, 200, 0, 255}, false)
-
-	// Draw "H" label
-	ebitenutil.DebugPrintAt(screen, "H", int(sx)-4, int(sy)-6)
-}
-
-func (a *App) drawAircraft(screen *ebiten.Image) {
-	state := a.client.GetState()
-	if !state.HasGPS || (state.Latitude == 0 && state.Longitude == 0) {
+	if len(a.ports) == 0 || a.selectedPort >= len(a.ports) {
+		a.notify(logTelem, LevelWarn, "No transmitter found: plug in the TX and pick its port (P)")
 		return
 	}
-
-	centerPx, centerPy := LatLonToPixel(a.centerLat, a.centerLon, a.zoom)
-	ax, ay := LatLonToPixel(float64(state.Latitude), float64(state.Longitude), a.zoom)
-
-	sx := float32(ax - centerPx + float64(a.width)/2)
-	sy := float32(ay - centerPy + float64(a.height)/2)
-
-	// Draw aircraft triangle pointing in heading direction
-	heading := float64(state.Heading) * math.Pi / 180
-
-	// Triangle points
-	size := float32(12)
-	p1x := sx + size*float32(math.Sin(heading))
-	p1y := sy - size*float32(math.Cos(heading))
-	p2x := sx + size*0.5*float32(math.Sin(heading+2.5))
-	p2y := sy - size*0.5*float32(math.Cos(heading+2.5))
-	p3x := sx + size*0.5*float32(math.Sin(heading-2.5))
-	p3y := sy - size*0.5*float32(math.Cos(heading-2.5))
-
-	// Fill triangle
-	path := vector.Path{}
-	path.MoveTo(p1x, p1y)
-	path.LineTo(p2x, p2y)
-	path.LineTo(p3x, p3y)
-	path.Close()
-
-	vs, is := path.AppendVerticesAndIndicesForFilling(nil, nil)
-	for i := range vs {
-		vs[i].SrcX = 1
-		vs[i].SrcY = 1
-		vs[i].ColorR = 1
-		vs[i].ColorG = 0
-		vs[i].ColorB = 0
-		vs[i].ColorA = 1
-	}
-
-	op := &ebiten.DrawTrianglesOptions{}
-	screen.DrawTriangles(vs, is, emptyImage, op)
-
-	// Outline
-	vector.StrokeLine(screen, p1x, p1y, p2x, p2y, 2, color.RGBA{255, 100, 100, 255}, false)
-	vector.StrokeLine(screen, p2x, p2y, p3x, p3y, 2, color.RGBA{255, 100, 100, 255}, false)
-	vector.StrokeLine(screen, p3x, p3y, p1x, p1y, 2, color.RGBA{255, 100, 100, 255}, false)
+	port := a.ports[a.selectedPort]
+	if err := a.client.StartLink(port, a.baudRate); err != nil {
+		a.notify(logTelem, LevelError, "Could not start link: %s", describeLinkError(err, port, a.client.Address()))
+	}
 }
 
 var emptyImage = func() *ebiten.Image {
@@ -709,57 +1127,6 @@ func (a *App) drawTelemetry(screen *ebiten.Image) {
 	}
 }
 
-func (a *App) drawStatusBar(screen *ebiten.Image) {
-	// Bottom status bar
-	barH := 24
-	barY := a.height - barH
-
-	vector.DrawFilledRect(screen, 0, float32(barY), float32(a.width), float32(barH), color.RGBA{0, 0, 0, 200}, false)
-
-	// Connection status
-	connStatus := "Disconnected"
-	connColor := color.RGBA{255, 100, 100, 255}
-	if a.client.IsConnected() {
-		connStatus = "Connected"
-		connColor = color.RGBA{100, 255, 100, 255}
-	}
-
-	// Link status
-	linkStatus := "Link: OFF"
-	if a.client.IsLinkStarted() {
-		linkStatus = "Link: ON"
-	}
-
-	// Port
-	portStr := "No ports"
-	if len(a.ports) > 0 && a.selectedPort < len(a.ports) {
-		portStr = a.ports[a.selectedPort]
-	}
-
-	// Follow status
-	followStr := "Manual"
-	if a.followAircraft {
-		followStr = "Follow"
-	}
-
-	// HUD status
-	hudStr := "HUD:MAP"
-	switch a.hudMode {
-	case 1:
-		hudStr = "HUD:OSD"
-	case 2:
-		hudStr = "HUD:PANEL"
-	}
-
-	// Map source
-	mapStr := a.tileManager.SourceName()
-
-	status := fmt.Sprintf(" %s | %s | Port: %s | Zoom: %d | %s | %s | %s | F1=Help", connStatus, linkStatus, portStr, a.zoom, followStr, mapStr, hudStr)
-	_ = connColor // Would use for colored indicator
-
-	ebitenutil.DebugPrintAt(screen, status, 5, barY+5)
-}
-
 func (a *App) drawHelp(screen *ebiten.Image) {
 	help := []string{
 		"=== ELRS Ground Station ===",
@@ -767,15 +1134,29 @@ func (a *App) drawHelp(screen *ebiten.Image) {
 		"+/-     Zoom in/out",
 		"Scroll  Zoom",
 		"Drag    Pan map",
+		"Click   Inspect a point on the trail",
 		"WASD    Pan map",
+		"Z       Zoom to fit (Shift: zero altitude)",
+		"G       Center on aircraft",
+		"B       Center on home (Shift: drag it)",
 		"F       Toggle follow aircraft",
-		"H       Set home position",
+		"H       Set home (Shift: pilot at center)",
 		"C       Clear flight path",
-		"V       Cycle HUD (Map/OSD/Panel)",
+		"3       Toggle 2.5D flight path",
+		"U       Cycle grid (lat/lon, MGRS)",
+		"N       Next waypoint (Shift: back)",
+		"R       Toggle rally point lines",
+		"X       Find aircraft (beeper)",
+		"V       Cycle HUD (Map/OSD/Panel/Split/Big)",
 		"M       Toggle map (street/sat)",
 		"T       Toggle touch buttons",
 		"L       Start/stop link",
-		"P       Cycle ports",
+		"P       Port/baud menu",
+		"O       Settings",
+		"E       Weather (Shift: elevation profile)",
+		"K       Logbook",
+		"I       Connection details",
+		"F3      Performance overlay",
 		"F11     Toggle fullscreen",
 		"F1/?    Toggle this help",
 		"Q/Esc   Quit",
@@ -796,32 +1177,9 @@ func (a *App) drawHelp(screen *ebiten.Image) {
 }
 
 func (a *App) calculateDistance(lat1, lon1, lat2, lon2 float64) float64 {
-	// Haversine formula
-	R := 6371000.0 // Earth radius in meters
-
-	dLat := (lat2 - lat1) * math.Pi / 180
-	dLon := (lon2 - lon1) * math.Pi / 180
-
-	a1 := math.Sin(dLat/2)*math.Sin(dLat/2) +
-		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*
-			math.Sin(dLon/2)*math.Sin(dLon/2)
-
-	c := 2 * math.Atan2(math.Sqrt(a1), math.Sqrt(1-a1))
-	return R * c
+	return DistanceMeters(lat1, lon1, lat2, lon2)
 }
 
 func (a *App) calculateBearing(lat1, lon1, lat2, lon2 float64) float64 {
-	// Calculate bearing from point 1 to point 2
-	lat1Rad := lat1 * math.Pi / 180
-	lat2Rad := lat2 * math.Pi / 180
-	dLon := (lon2 - lon1) * math.Pi / 180
-
-	x := math.Sin(dLon) * math.Cos(lat2Rad)
-	y := math.Cos(lat1Rad)*math.Sin(lat2Rad) - math.Sin(lat1Rad)*math.Cos(lat2Rad)*math.Cos(dLon)
-
-	bearing := math.Atan2(x, y) * 180 / math.Pi
-
-	// Normalize to 0-360
-	bearing = math.Mod(bearing+360, 360)
-	return bearing
+	return BearingDegrees(lat1, lon1, lat2, lon2)
 }
@@ -0,0 +1,86 @@
+//go:build !headless
+
+package main
+
+import (
+	"image/color"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// attributionCorners are the map.attribution settings
+var attributionCorners = []string{"bottom-right", "bottom-left", "top-left", "top-right"}
+
+// attributionLayout wraps the tile source's credit to the map's width and
+// places it in the configured corner, bottom ones above the status bar
+func (a *App) attributionLayout(offsetX, mapWidth int) (lines []string, x, y, w, h int) {
+	lines = wrapWords(a.tileManager.Attribution(), max(20, (mapWidth-10)/6))
+	if len(lines) == 0 {
+		return nil, 0, 0, 0, 0
+	}
+	for _, l := range lines {
+		w = max(w, len(l)*6+6)
+	}
+	h = 16 * len(lines)
+	corner := a.config.Map.Attribution
+	x = offsetX
+	if !strings.HasSuffix(corner, "-left") {
+		x = offsetX + mapWidth - w
+	}
+	y = 0
+	if !strings.HasPrefix(corner, "top-") {
+		y = a.height - 24 - h
+		if a.config.Display.LQTimeline {
+			y -= lqTimelineHeight
+		}
+	}
+	return lines, x, y, w, h
+}
+
+// attributionInset is how far boxes in a bottom corner of the map move up
+// to stay clear of the credit when it shares their corner
+func (a *App) attributionInset(right bool, offsetX, mapWidth int) int {
+	corner := a.config.Map.Attribution
+	if strings.HasPrefix(corner, "top-") || strings.HasSuffix(corner, "-left") == right {
+		return 0
+	}
+	_, _, _, _, h := a.attributionLayout(offsetX, mapWidth)
+	return h
+}
+
+// drawAttributionWithOffset credits the map tiles, as their terms require
+func (a *App) drawAttributionWithOffset(screen *ebiten.Image, offsetX, mapWidth int) {
+	lines, x, y, w, h := a.attributionLayout(offsetX, mapWidth)
+	if len(lines) == 0 {
+		return
+	}
+	vector.DrawFilledRect(screen, float32(x), float32(y), float32(w), float32(h), color.RGBA{0, 0, 0, 140}, false)
+	for i, l := range lines {
+		ebitenutil.DebugPrintAt(screen, l, x+3, y+16*i)
+	}
+}
+
+// wrapWords breaks text into lines of at most width characters, at spaces
+// where it can
+func wrapWords(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		switch {
+		case line == "":
+			line = word
+		case len(line)+1+len(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
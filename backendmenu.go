@@ -0,0 +1,142 @@
+//go:build !headless
+
+package main
+
+import "strings"
+
+// discoverBackends browses for backends in the background. Results arrive
+// in Update through a.discovered; with autoPick a single backend is used
+// right away and several open the selector.
+func (a *App) discoverBackends(autoPick bool) {
+	if a.discovering {
+		return
+	}
+	a.discovering = true
+	a.autoPick = autoPick
+	go func() {
+		found, err := DiscoverBackends()
+		if err != nil {
+			logTelem.Warnf("Backend discovery failed: %v", err)
+		}
+		a.discovered <- found
+	}()
+}
+
+// handleDiscovery applies finished discovery results
+func (a *App) handleDiscovery() {
+	var found []MDNSService
+	select {
+	case found = <-a.discovered:
+	default:
+		return
+	}
+	a.discovering = false
+	a.backends = found
+	for _, s := range found {
+		logTelem.Infof("Found backend %s", s)
+	}
+
+	switch {
+	case !a.autoPick:
+	case len(found) == 1:
+		a.switchBackend(found[0].HostPort())
+	case len(found) > 1:
+		a.backendMenu.Open()
+	default:
+		logTelem.Infof("No backends found on the LAN")
+	}
+	if a.backendMenu.IsOpen() {
+		a.backendMenu.Rebuild(a.backendMenu)
+	}
+	if a.wizardMenu.IsOpen() && a.wizardPage == wizardBackend {
+		a.wizardMenu.Rebuild(a.wizardMenu)
+	}
+}
+
+// switchBackend connects to the backend at addr and remembers it
+func (a *App) switchBackend(addr string) {
+	if addr == a.client.Address() && a.client.IsConnected() {
+		return
+	}
+	a.config.Backend.Address = addr
+	logTelem.Infof("Connecting to gRPC backend at %s", addr)
+	go func() {
+		if a.client.IsLinkStarted() {
+			a.client.StopLink()
+		}
+		a.client.SetAddress(addr)
+		if err := a.client.Connect(); err != nil {
+			a.notify(logTelem, LevelWarn, "Could not connect to backend at %s: %v", addr, err)
+			return
+		}
+		a.client.StartTelemetryStream()
+		a.warnOldBackend()
+	}()
+}
+
+// warnOldBackend tells the user which features the backend just connected
+// is too old for, rather than leaving them to fail one by one. Connect
+// has logged it already.
+func (a *App) warnOldBackend() {
+	if missing := a.client.Caps().Missing(); len(missing) > 0 {
+		a.toasts.Add(LevelWarn, "Backend too old for %s: update elrs-joystick-control", strings.Join(missing, " and "))
+	}
+}
+
+// handleFailover restarts the link on the backend the failover moved to
+func (a *App) handleFailover() {
+	var addr string
+	select {
+	case addr = <-a.failedOver:
+	default:
+		return
+	}
+	port := a.config.Backend.Port
+	if port == "" {
+		return
+	}
+	if err := a.client.StartLink(port, a.config.Backend.BaudRate); err != nil {
+		a.notify(logTelem, LevelError, "Could not start link: %s", describeLinkError(err, port, addr))
+	}
+}
+
+// newBackendMenu builds the backend selector listing discovered backends
+func (a *App) newBackendMenu() *Menu {
+	m := NewMenu("Backend")
+	m.Rebuild = func(m *Menu) {
+		m.Items = m.Items[:0]
+		m.Items = append(m.Items, MenuItem{
+			Label: "Current",
+			Value: func() string {
+				if !a.client.IsConnected() {
+					return a.client.Address() + " (down)"
+				}
+				return a.client.Address()
+			},
+		})
+
+		for _, s := range a.backends {
+			addr := s.HostPort()
+			m.Items = append(m.Items, MenuItem{
+				Label:    s.Instance,
+				Value:    func() string { return addr },
+				Active:   func() bool { return addr == a.client.Address() },
+				OnSelect: func() { a.switchBackend(addr) },
+			})
+		}
+		if a.discovering {
+			m.Items = append(m.Items, MenuItem{Label: "Searching..."})
+		} else if len(a.backends) == 0 {
+			m.Items = append(m.Items, MenuItem{Label: "No backends found"})
+		}
+
+		m.Items = append(m.Items,
+			MenuItem{Label: "Search again", OnSelect: func() {
+				a.discoverBackends(false)
+				m.Rebuild(m)
+			}},
+			MenuItem{Label: "Close", OnSelect: m.Close},
+		)
+	}
+	return m
+}
@@ -0,0 +1,234 @@
+package main
+
+import "math"
+
+// Chemistry is how a cell's resting voltage maps to its charge, with the
+// per-cell voltages to warn and to land at
+type Chemistry struct {
+	Name  string
+	Label string
+	Full  float64 // Resting voltage of a charged cell
+	Warn  float64 // Head home
+	Land  float64 // Land now
+	// Typical internal resistance of one cell in milliohms, for the sag
+	// under load
+	Resistance float64
+	// Resting voltage to remaining charge, rising
+	Curve []cellPoint
+}
+
+type cellPoint struct {
+	Volts   float64
+	Percent float64
+}
+
+// chemistries are the cell types the presets are built from
+var chemistries = []Chemistry{
+	{
+		Name: "lipo", Label: "LiPo", Full: 4.20, Warn: 3.50, Land: 3.30, Resistance: 5,
+		Curve: []cellPoint{{3.30, 0}, {3.50, 5}, {3.68, 20}, {3.74, 40}, {3.80, 60}, {3.90, 75}, {4.00, 85}, {4.20, 100}},
+	},
+	{
+		Name: "lihv", Label: "LiHV", Full: 4.35, Warn: 3.50, Land: 3.30, Resistance: 5,
+		Curve: []cellPoint{{3.30, 0}, {3.50, 5}, {3.72, 20}, {3.80, 40}, {3.88, 60}, {4.00, 75}, {4.12, 85}, {4.35, 100}},
+	},
+	{
+		Name: "liion", Label: "Li-ion", Full: 4.20, Warn: 3.10, Land: 2.90, Resistance: 20,
+		Curve: []cellPoint{{2.80, 0}, {3.00, 5}, {3.30, 20}, {3.50, 40}, {3.65, 60}, {3.80, 75}, {4.00, 90}, {4.20, 100}},
+	},
+}
+
+// findChemistry returns the named chemistry, or LiPo
+func findChemistry(name string) Chemistry {
+	for _, c := range chemistries {
+		if c.Name == name {
+			return c
+		}
+	}
+	return chemistries[0]
+}
+
+// BatteryPreset is a common pack: a cell count and chemistry
+type BatteryPreset struct {
+	Name      string
+	Label     string
+	Cells     int
+	Chemistry string
+}
+
+// batteryPresets are selectable from settings; "custom" leaves the pack
+// settings as they are
+var batteryPresets = []BatteryPreset{
+	{Name: "custom", Label: "Custom"},
+	{Name: "3s-lipo", Label: "3S LiPo", Cells: 3, Chemistry: "lipo"},
+	{Name: "4s-lipo", Label: "4S LiPo", Cells: 4, Chemistry: "lipo"},
+	{Name: "6s-lipo", Label: "6S LiPo", Cells: 6, Chemistry: "lipo"},
+	{Name: "4s-lihv", Label: "4S LiHV", Cells: 4, Chemistry: "lihv"},
+	{Name: "6s-lihv", Label: "6S LiHV", Cells: 6, Chemistry: "lihv"},
+	{Name: "3s-liion", Label: "3S Li-ion", Cells: 3, Chemistry: "liion"},
+	{Name: "4s-liion", Label: "4S Li-ion", Cells: 4, Chemistry: "liion"},
+	{Name: "6s-liion", Label: "6S Li-ion", Cells: 6, Chemistry: "liion"},
+}
+
+// findBatteryPreset returns the index of the named preset, or 0 (custom)
+func findBatteryPreset(name string) int {
+	for i, p := range batteryPresets {
+		if p.Name == name {
+			return i
+		}
+	}
+	return 0
+}
+
+// batteryPresetNames returns the preset names in menu order
+func batteryPresetNames() []string {
+	names := make([]string, len(batteryPresets))
+	for i, p := range batteryPresets {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// ApplyBatteryPreset sets the cell count and chemistry from the named
+// preset, with that chemistry's alarm voltages. Unknown names and "custom"
+// keep the configured values.
+func ApplyBatteryPreset(b *BatteryConfig, name string) {
+	p := batteryPresets[findBatteryPreset(name)]
+	b.Preset = p.Name
+	if p.Cells > 0 {
+		b.Cells, b.Chemistry = p.Cells, p.Chemistry
+		b.WarnCell, b.LandCell = 0, 0
+	}
+}
+
+// BatteryLevel is how urgent the battery is
+type BatteryLevel int
+
+const (
+	BatteryUnknown BatteryLevel = iota
+	BatteryOK
+	BatteryLow  // Below the warning voltage or remaining capacity
+	BatteryLand // Below the land-now voltage
+)
+
+// BatteryStatus is the pack judged against its chemistry
+type BatteryStatus struct {
+	Cells   int
+	CellV   float64 // Under load, as measured
+	RestV   float64 // Per cell with the sag from the current added back
+	Percent int     // From RestV on the chemistry's curve, -1 if unknown
+	Level   BatteryLevel
+}
+
+// packCells returns the configured cell count, or works it out from the
+// pack voltage at plug-in as the count that puts a rested cell nearest
+// full. It is never taken from the live voltage: a pack sagging under load
+// or nearly flat would read as one cell fewer. 0 means unknown.
+func packCells(state TelemetryState, cells int, chem Chemistry) int {
+	if cells > 0 {
+		return cells
+	}
+	v := state.PackVoltage
+	if v == 0 {
+		v = state.Voltage // Not from a client, e.g. a restored state
+	}
+	if v < 2 {
+		return 0
+	}
+	return max(1, int(math.Round(float64(v)/chem.Full)))
+}
+
+// Evaluate judges the pack. The voltage sags under load by the current
+// times the internal resistance, so that is added back before comparing:
+// a punch-out shouldn't sound the alarm, a tired pack at cruise should.
+// The flight controller's remaining capacity below lowPct also warns.
+func (b BatteryConfig) Evaluate(state TelemetryState, lowPct int) BatteryStatus {
+	s := BatteryStatus{Percent: -1}
+	if state.Remaining > 0 && int(state.Remaining) < lowPct {
+		s.Level = BatteryLow
+	}
+	chem := findChemistry(b.Chemistry)
+	s.Cells = packCells(state, b.Cells, chem)
+	if s.Cells == 0 {
+		if s.Level == BatteryUnknown && state.Remaining > 0 {
+			s.Level = BatteryOK
+		}
+		return s
+	}
+
+	s.CellV = float64(state.Voltage) / float64(s.Cells)
+	s.RestV = s.CellV
+	if b.SagCompensation {
+		// Pack resistance in milliohms, or the chemistry's per cell
+		r := b.ResistanceMohm
+		if r <= 0 {
+			r = chem.Resistance * float64(s.Cells)
+		}
+		s.RestV += float64(state.Current) * r / 1000 / float64(s.Cells)
+		s.RestV = math.Min(s.RestV, chem.Full)
+	}
+	s.Percent = int(math.Round(cellPercent(chem.Curve, s.RestV)))
+
+	warn, land := chem.Warn, chem.Land
+	if b.WarnCell > 0 {
+		warn = b.WarnCell
+	}
+	if b.LandCell > 0 {
+		land = b.LandCell
+	}
+	switch {
+	case s.RestV < land:
+		s.Level = BatteryLand
+	case s.RestV < warn:
+		s.Level = BatteryLow
+	case s.Level == BatteryUnknown:
+		s.Level = BatteryOK
+	}
+	return s
+}
+
+// Remaining is the charge left in percent: the flight controller's, from
+// its capacity sensor, or without one the estimate from the resting voltage
+func (s BatteryStatus) Remaining(state TelemetryState) uint32 {
+	if state.Remaining == 0 && s.Percent >= 0 {
+		return uint32(s.Percent)
+	}
+	return state.Remaining
+}
+
+// Charge is the charge left in percent, estimated from the resting
+// voltage when the flight controller has no capacity sensor
+func (b BatteryConfig) Charge(state TelemetryState) uint32 {
+	return b.Evaluate(state, 0).Remaining(state)
+}
+
+// Apply corrects a reading
+func (c SensorCalibration) Apply(v float32) float32 {
+	return float32(float64(v)*c.scale() + c.Offset)
+}
+
+func (c SensorCalibration) scale() float64 {
+	if c.Scale == 0 {
+		return 1
+	}
+	return c.Scale
+}
+
+// Identity reports whether the calibration leaves readings as they are
+func (c SensorCalibration) Identity() bool {
+	return (c.Scale == 0 || c.Scale == 1) && c.Offset == 0
+}
+
+// cellPercent interpolates a cell voltage on a discharge curve
+func cellPercent(curve []cellPoint, v float64) float64 {
+	if v <= curve[0].Volts {
+		return curve[0].Percent
+	}
+	for i := 1; i < len(curve); i++ {
+		if v <= curve[i].Volts {
+			lo, hi := curve[i-1], curve[i]
+			return lo.Percent + (v-lo.Volts)/(hi.Volts-lo.Volts)*(hi.Percent-lo.Percent)
+		}
+	}
+	return curve[len(curve)-1].Percent
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestEvaluateKeepsPluggedInCellCount(t *testing.T) {
+	b := BatteryConfig{Chemistry: "lipo"}
+	tests := []struct {
+		name  string
+		pack  float32 // At plug-in
+		now   float32
+		cells int
+		level BatteryLevel
+	}{
+		{"6S charged", 25.2, 25.2, 6, BatteryOK},
+		{"6S at 3.6 V a cell", 25.2, 21.6, 6, BatteryOK},
+		{"6S at 3.4 V a cell", 25.2, 20.4, 6, BatteryLow},
+		{"4S at 3.2 V a cell", 16.8, 12.8, 4, BatteryLand},
+		{"4S plugged in part used", 15.4, 15.0, 4, BatteryOK},
+		{"No voltage", 0, 0, 0, BatteryUnknown},
+	}
+	for _, tt := range tests {
+		s := b.Evaluate(TelemetryState{PackVoltage: tt.pack, Voltage: tt.now}, 20)
+		if s.Cells != tt.cells || s.Level != tt.level {
+			t.Errorf("%s: cells %d level %d, want %d and %d", tt.name, s.Cells, s.Level, tt.cells, tt.level)
+		}
+	}
+}
+
+func TestEvaluateConfiguredCells(t *testing.T) {
+	b := BatteryConfig{Cells: 4, Chemistry: "lipo"}
+	if s := b.Evaluate(TelemetryState{PackVoltage: 25.2, Voltage: 16.8}, 20); s.Cells != 4 {
+		t.Errorf("cells = %d, want the configured 4", s.Cells)
+	}
+}
@@ -0,0 +1,126 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// HUD modes, cycled with V
+const (
+	hudMap = iota
+	hudOSD
+	hudPanel
+	hudSplit
+	hudBig
+	hudModes // How many there are
+)
+
+// hudNames label the HUD modes in the status bar
+var hudNames = [hudModes]string{"MAP", "OSD", "PANEL", "SPLIT", "BIG"}
+
+// hudModeNames are the -hud names of the HUD modes
+var hudModeNames = map[string]int{"map": hudMap, "osd": hudOSD, "panel": hudPanel, "split": hudSplit, "big": hudBig}
+
+// Debug font glyph size, which big text is scaled up from
+const (
+	glyphW = 6
+	glyphH = 16
+)
+
+var (
+	bigLabelColor = color.RGBA{170, 170, 170, 255}
+	bigValueColor = color.RGBA{255, 255, 255, 255}
+	bigAlertColor = color.RGBA{255, 60, 60, 255}
+	bigGridColor  = color.RGBA{60, 60, 60, 255}
+)
+
+// bigNumber is one of the big view's readouts
+type bigNumber struct {
+	label, value string
+	alert        bool
+}
+
+// drawBigNumbers fills the screen above the status bar with four readouts
+// in digits big enough to read from the flight line in sunlight: white on
+// black, red when they need attention.
+func (a *App) drawBigNumbers(screen *ebiten.Image, state TelemetryState, refSet bool, homeDist float64) {
+	units := a.config.Display.Units
+	alerts := a.config.Alerts
+	alt := relativeAltitude(state)
+	batt := a.config.Battery.Evaluate(state, alerts.BatteryLowPct)
+
+	dist := bigNumber{label: "DIST", value: "---"}
+	if refSet && state.HasGPS {
+		dist.value = units.FormatDistance(homeDist)
+		dist.alert = alerts.MaxDistance > 0 && homeDist > alerts.MaxDistance
+	}
+	numbers := [4]bigNumber{
+		dist,
+		{
+			label: "ALT " + units.AltitudeLabel(),
+			value: fmt.Sprintf("%.0f", units.Altitude(alt)),
+			alert: alerts.MaxAltitude > 0 && alt > alerts.MaxAltitude,
+		},
+		{
+			label: "LQ %",
+			value: fmt.Sprintf("%d", state.LinkQuality),
+			alert: state.LinkStarted && int(state.LinkQuality) < alerts.LQLowPct,
+		},
+		{
+			label: "VOLT",
+			value: fmt.Sprintf("%.1f", state.Voltage),
+			alert: batt.Level >= BatteryLow,
+		},
+	}
+
+	w, h := float32(a.width), float32(a.height-statusBarHeight)
+	vector.DrawFilledRect(screen, 0, 0, w, h, color.RGBA{0, 0, 0, 255}, false)
+	vector.StrokeLine(screen, w/2, 0, w/2, h, 2, bigGridColor, false)
+	vector.StrokeLine(screen, 0, h/2, w, h/2, 2, bigGridColor, false)
+
+	cellW, cellH := a.width/2, (a.height-statusBarHeight)/2
+	for i, n := range numbers {
+		x, y := (i%2)*cellW, (i/2)*cellH
+		a.drawBigText(screen, n.label, x+10, y+8, 2, bigLabelColor)
+
+		// As big as fits the cell below the label, allowing for 6 characters
+		// so the digits don't change size as the value grows
+		room := cellH - 2*glyphH - 16
+		scale := min((cellW-20)/(glyphW*max(6, len(n.value))), room/glyphH)
+		if scale < 1 {
+			continue
+		}
+		clr := bigValueColor
+		if n.alert {
+			clr = bigAlertColor
+		}
+		tw, th := len(n.value)*glyphW*scale, glyphH*scale
+		a.drawBigText(screen, n.value, x+(cellW-tw)/2, y+2*glyphH+8+(room-th)/2, scale, clr)
+	}
+}
+
+// drawBigText draws text from the debug font scaled up by a whole factor,
+// which keeps its pixels sharp
+func (a *App) drawBigText(screen *ebiten.Image, text string, x, y, scale int, clr color.Color) {
+	w := len(text)*glyphW + 2
+	if a.bigText == nil || a.bigText.Bounds().Dx() < w {
+		if a.bigText != nil {
+			a.bigText.Dispose()
+		}
+		a.bigText = ebiten.NewImage(max(w, 64), glyphH)
+	}
+	a.bigText.Clear()
+	ebitenutil.DebugPrint(a.bigText, text)
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(float64(scale), float64(scale))
+	op.GeoM.Translate(float64(x), float64(y))
+	op.ColorScale.ScaleWithColor(clr)
+	screen.DrawImage(a.bigText, op)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// buddyService is the DNS-SD service type ground stations advertise
+const buddyService = "_elrs-map._udp"
+
+const (
+	buddySendInterval   = 500 * time.Millisecond
+	buddyBrowseInterval = 15 * time.Second
+	buddyBrowseTimeout  = 2 * time.Second
+	buddyTimeout        = 10 * time.Second // Drop buddies not heard from for this long
+)
+
+// buddyPacket is the position message sent to other ground stations
+type buddyPacket struct {
+	ID      string  `json:"id"` // Random per session, to ignore our own echoes
+	Name    string  `json:"name"`
+	Lat     float32 `json:"lat"`
+	Lon     float32 `json:"lon"`
+	Alt     int32   `json:"alt"`
+	Heading float32 `json:"heading"`
+	Speed   float32 `json:"speed"`
+}
+
+// Buddy is another ground station's aircraft
+type Buddy struct {
+	Name    string
+	Lat     float64
+	Lon     float64
+	Alt     int32
+	Heading float32
+	Speed   float32
+	Seen    time.Time
+}
+
+// BuddyShare exchanges aircraft positions with other elrs-map instances on
+// the LAN. Peers are found over mDNS and positions go out as small UDP
+// datagrams, so a clubmate's model shows up on everyone's map.
+type BuddyShare struct {
+	client *GRPCClient
+	config *BuddyConfig
+	id     string
+
+	conn      *net.UDPConn
+	responder *MDNSResponder
+
+	mu      sync.Mutex
+	peers   map[string]*net.UDPAddr // Session id -> address
+	buddies map[string]*Buddy       // Session id -> last position
+
+	running  bool
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewBuddyShare creates a position share using cfg
+func NewBuddyShare(client *GRPCClient, cfg *BuddyConfig) *BuddyShare {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return &BuddyShare{
+		client:  client,
+		config:  cfg,
+		id:      hex.EncodeToString(id),
+		peers:   make(map[string]*net.UDPAddr),
+		buddies: make(map[string]*Buddy),
+	}
+}
+
+// Start opens the UDP port, advertises it and begins exchanging positions
+func (b *BuddyShare) Start() error {
+	if b.running {
+		return nil
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: b.config.Port})
+	if err != nil {
+		return fmt.Errorf("listen on port %d: %w", b.config.Port, err)
+	}
+	b.responder = NewMDNSResponder(buddyService, b.name(), b.config.Port, []string{"id=" + b.id})
+	if err := b.responder.Start(); err != nil {
+		// Others can still find us when we find them, since they learn our
+		// address from the packets we send
+		logBuddy.Warnf("Could not advertise over mDNS: %v", err)
+	}
+
+	b.conn = conn
+	b.stopChan = make(chan struct{})
+	b.running = true
+	b.wg.Add(3)
+	go b.receive()
+	go b.browse()
+	go b.send()
+	logBuddy.Infof("Sharing position as %q on UDP port %d", b.name(), b.config.Port)
+	return nil
+}
+
+// Stop stops sharing and forgets all buddies
+func (b *BuddyShare) Stop() {
+	if !b.running {
+		return
+	}
+	b.running = false
+	close(b.stopChan)
+	b.responder.Stop()
+	b.conn.Close()
+	b.wg.Wait()
+
+	b.mu.Lock()
+	b.peers = make(map[string]*net.UDPAddr)
+	b.buddies = make(map[string]*Buddy)
+	b.mu.Unlock()
+}
+
+// IsRunning returns true while positions are being shared
+func (b *BuddyShare) IsRunning() bool {
+	return b.running
+}
+
+// Buddies returns the positions heard recently, sorted by name
+func (b *BuddyShare) Buddies() []Buddy {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var list []Buddy
+	for _, buddy := range b.buddies {
+		if time.Since(buddy.Seen) < buddyTimeout {
+			list = append(list, *buddy)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// name returns the label others see, falling back to the hostname
+func (b *BuddyShare) name() string {
+	if b.config.Name != "" {
+		return b.config.Name
+	}
+	return localHostname()
+}
+
+// receive reads position packets from peers
+func (b *BuddyShare) receive() {
+	defer b.wg.Done()
+	buf := make([]byte, 1500)
+	for {
+		n, src, err := b.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-b.stopChan:
+				return
+			default:
+			}
+			logBuddy.Debugf("Receive error: %v", err)
+			continue
+		}
+
+		var p buddyPacket
+		if err := json.Unmarshal(buf[:n], &p); err != nil || p.ID == "" || p.ID == b.id {
+			continue
+		}
+
+		b.mu.Lock()
+		if _, known := b.buddies[p.ID]; !known {
+			logBuddy.Infof("Buddy %q joined from %s", p.Name, src)
+		}
+		// Reply to whatever address the packets come from; that also covers
+		// peers whose mDNS answers we never saw
+		b.peers[p.ID] = src
+		b.buddies[p.ID] = &Buddy{
+			Name:    p.Name,
+			Lat:     float64(p.Lat),
+			Lon:     float64(p.Lon),
+			Alt:     p.Alt,
+			Heading: p.Heading,
+			Speed:   p.Speed,
+			Seen:    time.Now(),
+		}
+		b.mu.Unlock()
+	}
+}
+
+// browse looks for other ground stations until stopped
+func (b *BuddyShare) browse() {
+	defer b.wg.Done()
+	for {
+		services, err := BrowseMDNS(buddyService, buddyBrowseTimeout)
+		if err != nil {
+			logBuddy.Debugf("mDNS browse error: %v", err)
+		}
+		b.mu.Lock()
+		for _, s := range services {
+			id := mdnsTextValue(s.Text, "id")
+			if id == "" || id == b.id || s.Addr == nil {
+				continue
+			}
+			if _, known := b.peers[id]; !known {
+				logBuddy.Debugf("Found %s", s)
+				b.peers[id] = &net.UDPAddr{IP: s.Addr, Port: s.Port}
+			}
+		}
+		b.mu.Unlock()
+
+		select {
+		case <-b.stopChan:
+			return
+		case <-time.After(buddyBrowseInterval):
+		}
+	}
+}
+
+// send pushes our aircraft position to every known peer while we have a fix
+func (b *BuddyShare) send() {
+	defer b.wg.Done()
+	ticker := time.NewTicker(buddySendInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stopChan:
+			return
+		case <-ticker.C:
+		}
+
+		state := b.client.GetState()
+		if !state.HasGPS || (state.Latitude == 0 && state.Longitude == 0) || time.Since(state.LastUpdate) > telemetryTimeout {
+			continue
+		}
+		data, err := json.Marshal(buddyPacket{
+			ID:      b.id,
+			Name:    b.name(),
+			Lat:     state.Latitude,
+			Lon:     state.Longitude,
+			Alt:     state.Altitude,
+			Heading: state.Heading,
+			Speed:   state.GroundSpeed,
+		})
+		if err != nil {
+			continue
+		}
+
+		b.mu.Lock()
+		for id, addr := range b.peers {
+			// Forget peers that went quiet, mDNS will bring them back
+			if buddy, ok := b.buddies[id]; ok && time.Since(buddy.Seen) > 6*buddyTimeout {
+				delete(b.peers, id)
+				delete(b.buddies, id)
+				continue
+			}
+			b.conn.WriteToUDP(data, addr)
+		}
+		b.mu.Unlock()
+	}
+}
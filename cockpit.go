@@ -1,3 +1,5 @@
+//go:build !headless
+
 package main
 
 import (
@@ -23,6 +25,10 @@ type CockpitHUD struct {
 	warningColor color.RGBA
 	accentColor  color.RGBA
 	bgColor      color.RGBA
+
+	// Settings
+	Alerts  AlertConfig
+	Battery BatteryConfig
 }
 
 // NewCockpitHUD creates a new cockpit HUD
@@ -62,7 +68,7 @@ func (h *CockpitHUD) Draw(screen *ebiten.Image, state TelemetryState, homeSet bo
 	vsiW := 25
 	h.drawVSI(screen, h.screenW-vsiW, tapeY+tapeH/2, vsiW, tapeH, state.VerticalSpeed)
 	// Altitude tape next to VSI
-	h.drawAltitudeTape(screen, h.screenW-vsiW-tapeW-5, tapeY+tapeH/2, tapeW, tapeH, float32(state.Altitude))
+	h.drawAltitudeTape(screen, h.screenW-vsiW-tapeW-5, tapeY+tapeH/2, tapeW, tapeH, float32(relativeAltitude(state)))
 
 	// === BOTTOM LEFT: Artificial Horizon ===
 	ahSize := 130
@@ -77,6 +83,48 @@ func (h *CockpitHUD) Draw(screen *ebiten.Image, state TelemetryState, homeSet bo
 	h.drawCompass(screen, compassX, compassY, compassR, state.Heading)
 }
 
+// DrawPane renders the instruments filling an image of their own, for the
+// split layout: tapes at the edges, a large horizon over the compass in the
+// middle, and the status boxes along the bottom
+func (h *CockpitHUD) DrawPane(screen *ebiten.Image, state TelemetryState, homeSet bool, homeDist, homeBearing float64) {
+	h.screenW, h.screenH = screen.Bounds().Dx(), screen.Bounds().Dy()
+	screen.Fill(color.RGBA{20, 20, 24, 255})
+
+	// Status boxes, two rows of two
+	boxGap, boxH := 6, 64
+	boxW := (h.screenW - 3*boxGap) / 2
+	boxY := h.screenH - 2*boxH - 2*boxGap
+	h.drawBatteryGauge(screen, boxGap, boxY, boxW, boxH, state)
+	h.drawLinkQuality(screen, 2*boxGap+boxW, boxY, boxW, boxH, state)
+	h.drawGPSStatus(screen, boxGap, boxY+boxH+boxGap, boxW, boxH, state)
+	h.drawHomeInfo(screen, 2*boxGap+boxW, boxY+boxH+boxGap, boxW, boxH, state, homeSet, homeDist, homeBearing)
+
+	// Tapes over the full height above the boxes
+	tapeW, vsiW := 50, 25
+	tapeH := boxY - 2*boxGap
+	h.drawSpeedTape(screen, 0, boxGap+tapeH/2, tapeW, tapeH, state.GroundSpeed)
+	h.drawVSI(screen, h.screenW-vsiW, boxGap+tapeH/2, vsiW, tapeH, state.VerticalSpeed)
+	h.drawAltitudeTape(screen, h.screenW-vsiW-tapeW-5, boxGap+tapeH/2, tapeW, tapeH, float32(relativeAltitude(state)))
+
+	// Horizon and compass between the tapes, the horizon twice the size
+	free := h.screenW - 2*(tapeW+vsiW+15)
+	ahSize := max(60, min(free, tapeH*2/3-20))
+	compassR := max(30, min(free/2, tapeH-ahSize-30)/2)
+	cx := h.screenW / 2
+	h.drawArtificialHorizon(screen, cx, boxGap+10+ahSize/2, ahSize, state.Pitch, state.Roll)
+	h.drawCompass(screen, cx, boxGap+ahSize+20+compassR, compassR, state.Heading)
+}
+
+// DrawMini renders a small horizon and compass filling the image, the
+// picture-in-picture instrument of the map-only mode
+func (h *CockpitHUD) DrawMini(screen *ebiten.Image, state TelemetryState) {
+	w, ht := screen.Bounds().Dx(), screen.Bounds().Dy()
+	screen.Fill(h.bgColor)
+	size := min(w/2, ht) - 10
+	h.drawArtificialHorizon(screen, w/4, ht/2, size, state.Pitch, state.Roll)
+	h.drawCompass(screen, w*3/4, ht/2, size/2, state.Heading)
+}
+
 // drawTopBar renders compact status bar at top with readable text
 func (h *CockpitHUD) drawTopBar(screen *ebiten.Image, state TelemetryState, homeSet bool, homeDist, homeBearing float64) {
 	// Full width semi-transparent background
@@ -86,8 +134,9 @@ func (h *CockpitHUD) drawTopBar(screen *ebiten.Image, state TelemetryState, home
 	y := 5
 
 	// Battery
-	battStr := fmt.Sprintf("BAT: %.1fV %.1fA %d%%", state.Voltage, state.Current, state.Remaining)
-	if state.Remaining < 20 {
+	batt := h.Battery.Evaluate(state, h.Alerts.BatteryLowPct)
+	battStr := fmt.Sprintf("BAT: %.1fV %.1fA %d%%", state.Voltage, state.Current, batt.Remaining(state))
+	if batt.Level >= BatteryLow {
 		h.drawTextWithBg(screen, battStr, 10, y, h.warningColor)
 	} else {
 		ebitenutil.DebugPrintAt(screen, battStr, 10, y)
@@ -505,7 +554,10 @@ func (h *CockpitHUD) drawVSI(screen *ebiten.Image, x, y, width, height int, vspe
 }
 
 // drawBatteryGauge renders the battery status
-func (h *CockpitHUD) drawBatteryGauge(screen *ebiten.Image, x, y, width, height int, voltage, current float32, remaining uint32) {
+func (h *CockpitHUD) drawBatteryGauge(screen *ebiten.Image, x, y, width, height int, state TelemetryState) {
+	batt := h.Battery.Evaluate(state, h.Alerts.BatteryLowPct)
+	remaining := batt.Remaining(state)
+
 	// Background
 	vector.DrawFilledRect(screen, float32(x), float32(y), float32(width), float32(height), h.bgColor, true)
 
@@ -515,9 +567,9 @@ func (h *CockpitHUD) drawBatteryGauge(screen *ebiten.Image, x, y, width, height
 	battW := 30
 	battH := 15
 
-	// Determine color based on remaining
+	// Color by the alarm level, yellow when getting there
 	battColor := h.textColor
-	if remaining < 20 {
+	if batt.Level >= BatteryLow {
 		battColor = h.warningColor
 	} else if remaining < 40 {
 		battColor = h.accentColor
@@ -535,12 +587,19 @@ func (h *CockpitHUD) drawBatteryGauge(screen *ebiten.Image, x, y, width, height
 	}
 
 	// Text info
-	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%.1fV", voltage), x+45, y+5)
-	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%.1fA", current), x+95, y+5)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%.1fV", state.Voltage), x+45, y+5)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%.1fA", state.Current), x+95, y+5)
 	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%d%%", remaining), x+45, y+25)
+	if batt.Cells > 0 {
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%dS %.2fV", batt.Cells, batt.CellV), x+95, y+25)
+	}
 
 	// Label
-	ebitenutil.DebugPrintAt(screen, "BATTERY", x+5, y+height-15)
+	label := "BATTERY"
+	if batt.Level == BatteryLand {
+		label = "LAND NOW"
+	}
+	ebitenutil.DebugPrintAt(screen, label, x+5, y+height-15)
 
 	// Border
 	vector.StrokeRect(screen, float32(x), float32(y), float32(width), float32(height), 1, h.lineColor, true)
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// TrackPoint is one sample of a recorded flight
+type TrackPoint struct {
+	Lat, Lon float64
+	Alt      float64 // Meters
+	LQ       int     // Link quality %, -1 if the log has none
+}
+
+// LoadTrack reads the positions of a telemetry log or a raw CRSF dump,
+// gzipped or not
+func LoadTrack(path string) ([]TrackPoint, error) {
+	f, err := openLog(path)
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, 256)
+	n, _ := io.ReadFull(f, head)
+	f.Close()
+	if !isCRSFDump(head[:n]) {
+		return LoadTrackCSV(path)
+	}
+	samples, err := LoadCRSFDump(path, time.Time{}, DefaultCRSFFrameGap)
+	if err != nil {
+		return nil, err
+	}
+	track := replayTrack(samples)
+	if len(track) == 0 {
+		return nil, fmt.Errorf("%s: no positions", path)
+	}
+	return track, nil
+}
+
+// LoadTrackCSV reads the positions of a telemetry log written by the
+// recorder, skipping rows without a fix
+func LoadTrackCSV(path string) ([]TrackPoint, error) {
+	f, err := openLog(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	col := map[string]int{}
+	for i, name := range header {
+		col[name] = i
+	}
+	if _, ok := col["lat"]; !ok {
+		return nil, fmt.Errorf("%s: not a telemetry log (no lat/lon columns)", path)
+	}
+	field := func(row []string, name string) (float64, bool) {
+		i, ok := col[name]
+		if !ok || i >= len(row) {
+			return 0, false
+		}
+		v, err := strconv.ParseFloat(row[i], 64)
+		return v, err == nil
+	}
+
+	var track []TrackPoint
+	lq := -1 // Carried over rows where the link wasn't recorded
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if v, ok := field(row, "lq"); ok {
+			lq = int(v)
+		}
+		p := TrackPoint{LQ: lq}
+		var ok bool
+		if p.Lat, ok = field(row, "lat"); !ok {
+			continue
+		}
+		if p.Lon, ok = field(row, "lon"); !ok || (p.Lat == 0 && p.Lon == 0) {
+			continue
+		}
+		p.Alt, _ = field(row, "alt_m")
+		track = append(track, p)
+	}
+	if len(track) == 0 {
+		return nil, fmt.Errorf("%s: no positions", path)
+	}
+	return track, nil
+}
+
+// recentLogs returns up to n telemetry logs in dir, newest first
+func recentLogs(dir string, n int) []string {
+	paths := recordings(dir)
+	slices.Reverse(paths)
+	if len(paths) > n {
+		paths = paths[:n]
+	}
+	return paths
+}
@@ -0,0 +1,66 @@
+//go:build !headless
+
+package main
+
+import (
+	"image/color"
+	"path/filepath"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// compareStep is the shortest segment drawn, in pixels, so long logs
+// don't cost a draw call per sample
+const compareStep = 4
+
+// loadCompareTrack loads the configured past flight, if any
+func (a *App) loadCompareTrack() {
+	a.compareTrack = nil
+	if a.config.Map.CompareTrack == "" {
+		return
+	}
+	track, err := LoadTrack(a.config.Map.CompareTrack)
+	if err != nil {
+		logApp.Errorf("Could not load comparison track: %v", err)
+		return
+	}
+	logApp.Infof("Comparing with %s (%d points)", filepath.Base(a.config.Map.CompareTrack), len(track))
+	a.compareTrack = track
+}
+
+// compareColor colors a past flight by its link quality, so weak spots
+// stand out against the live track
+func compareColor(lq int) color.RGBA {
+	switch {
+	case lq < 0 || lq >= 70:
+		return color.RGBA{80, 170, 255, 170}
+	case lq >= 40:
+		return color.RGBA{255, 140, 0, 190}
+	default:
+		return color.RGBA{255, 40, 40, 210}
+	}
+}
+
+// drawCompareTrackWithOffset draws the past flight under the live track
+func (a *App) drawCompareTrackWithOffset(screen *ebiten.Image, v MapView) {
+	if len(a.compareTrack) < 2 {
+		return
+	}
+
+	toScreen := func(p TrackPoint) (float32, float32) {
+		x, y := v.LatLonToScreen(p.Lat, p.Lon)
+		return float32(x), float32(y)
+	}
+
+	x1, y1 := toScreen(a.compareTrack[0])
+	for i, p := range a.compareTrack[1:] {
+		x2, y2 := toScreen(p)
+		dx, dy := x2-x1, y2-y1
+		if dx*dx+dy*dy < compareStep*compareStep && i < len(a.compareTrack)-2 {
+			continue
+		}
+		vector.StrokeLine(screen, x1, y1, x2, y2, 2, compareColor(p.LQ), true)
+		x1, y1 = x2, y2
+	}
+}
@@ -0,0 +1,765 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds user settings loaded from the config file.
+// Command line flags override the file, and the file is rewritten on
+// change and at exit so the app boots into its last state.
+type Config struct {
+	Backend  BackendConfig     `yaml:"backend"`
+	Window   WindowConfig      `yaml:"window"`
+	CacheDir string            `yaml:"cache_dir"`
+	Tiles    TileConfig        `yaml:"tiles"`
+	Display  DisplayConfig     `yaml:"display"`
+	Alerts   AlertConfig       `yaml:"alerts"`
+	Battery  BatteryConfig     `yaml:"battery"`
+	Map      MapConfig         `yaml:"map"`
+	Aircraft AircraftConfig    `yaml:"aircraft"`
+	Mission  MissionConfig     `yaml:"mission"`
+	Rally    []RallyPoint      `yaml:"rally"`
+	Find     FindConfig        `yaml:"find"`
+	Touch    TouchConfig       `yaml:"touch"`
+	Input    InputConfig       `yaml:"input"`
+	Record   RecordConfig      `yaml:"record"`
+	Web      WebConfig         `yaml:"web"`
+	Log      LogConfig         `yaml:"log"`
+	Tracker  TrackerConfig     `yaml:"tracker"`
+	Buddy    BuddyConfig       `yaml:"buddy"`
+	MQTT     MQTTConfig        `yaml:"mqtt"`
+	Share    ShareConfig       `yaml:"share"`
+	Status   StatusLineConfig  `yaml:"status_line"`
+	Weather  WeatherConfig     `yaml:"weather"`
+	DEM      DEMConfig         `yaml:"dem"`
+	Upload   UploadConfig      `yaml:"upload"`
+	OSDOut   DisplayPortConfig `yaml:"osd_out"`
+	GPS      GPSConfig         `yaml:"gps"`
+	Heading  HeadingConfig     `yaml:"heading"`
+	Altitude AltitudeConfig    `yaml:"altitude"`
+	Plugins  []PluginConfig    `yaml:"plugins"`
+	State    StateConfig       `yaml:"state"`
+}
+
+// RecordConfig controls the telemetry CSV logs
+type RecordConfig struct {
+	Enabled  bool               `yaml:"enabled"` // Always on in headless mode
+	Dir      string             `yaml:"dir"`
+	Interval time.Duration      `yaml:"interval"`
+	Rates    map[string]float64 `yaml:"rates_hz"` // Lower rates for channels (gps, attitude, battery, link, baro, mode); others every interval
+	Gzip     bool               `yaml:"gzip"`     // Compress logs as they are written (.csv.gz)
+
+	SyncInterval time.Duration `yaml:"sync_interval"` // Force written rows to disk this often; 0 = only at the end
+	MaxSizeMB    int           `yaml:"max_size_mb"`   // Start a new log when one reaches this size; 0 = never
+}
+
+// UploadConfig sends finished logs somewhere once there is internet
+type UploadConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Kind      string        `yaml:"kind"` // webdav (PUT into a folder), s3, http (multipart POST)
+	URL       string        `yaml:"url"`  // WebDAV folder, S3 endpoint, or the POST endpoint
+	Username  string        `yaml:"username"`
+	Password  string        `yaml:"password"`
+	Bucket    string        `yaml:"bucket"` // S3 only
+	Prefix    string        `yaml:"prefix"` // S3 only: folder in the bucket
+	Region    string        `yaml:"region"` // S3 only
+	AccessKey string        `yaml:"access_key"`
+	SecretKey string        `yaml:"secret_key"`
+	Interval  time.Duration `yaml:"interval"` // How often to try
+}
+
+// DEMConfig points at the ground elevation data
+type DEMConfig struct {
+	Dir string `yaml:"dir"` // SRTM .hgt tiles, e.g. S23W048.hgt
+}
+
+// BackendConfig holds the gRPC backend and link settings
+type BackendConfig struct {
+	Source   string `yaml:"source"` // Where telemetry comes from: grpc (elrs-joystick-control), sim, web (another elrs-map at address)
+	Address  string `yaml:"address"`
+	Port     string `yaml:"port,omitempty"` // Last used serial port
+	BaudRate int32  `yaml:"baud_rate"`
+	Discover bool   `yaml:"discover"` // Browse the LAN over mDNS when address doesn't answer
+
+	Backups       []string      `yaml:"backups,omitempty"` // Backends to fail over to, in order
+	FailoverAfter time.Duration `yaml:"failover_after"`    // How long the backend may be down or silent first
+	StallTimeout  time.Duration `yaml:"stall_timeout"`     // Restart a telemetry stream quiet this long with the link up (0 = never)
+}
+
+// WindowConfig holds the window geometry
+type WindowConfig struct {
+	Width      int  `yaml:"width"`
+	Height     int  `yaml:"height"`
+	Fullscreen bool `yaml:"fullscreen"`
+	Monitor    int  `yaml:"monitor"` // Display to open on, from 1; 0 = the primary
+}
+
+// TileConfig controls how map tiles are downloaded. Field internet is
+// usually a metered phone hotspot, hence the proxy and rate cap.
+type TileConfig struct {
+	Proxy     string        `yaml:"proxy"`       // http://, https:// or socks5://host:port; empty = $HTTPS_PROXY
+	MaxRateKB int           `yaml:"max_rate_kb"` // KB/s for all downloads together, 0 = unlimited
+	MaxAge    time.Duration `yaml:"max_age"`     // Recheck cached tiles older than this, 0 = never
+	Cache     string        `yaml:"cache"`       // dir, mbtiles
+
+	PrefetchRadius float64 `yaml:"prefetch_radius_m"`  // Download the tiles this far around home once it is set; 0 = off
+	PrefetchMax    int     `yaml:"prefetch_max_tiles"` // At most this many tiles each time
+}
+
+// WebConfig controls the built-in browser map for spotters
+type WebConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Listen  string `yaml:"listen"`  // host:port, e.g. :8080
+	Tiles   string `yaml:"tiles"`   // Serve the tile cache under /tiles/: off, cache, passthrough (download missing tiles)
+	Metrics bool   `yaml:"metrics"` // Prometheus metrics at /metrics
+
+	RelayToken  string `yaml:"relay_token"`  // Relay other stations' live shares under /share/; empty = off
+	ActionToken string `yaml:"action_token"` // Run actions with POST /api/action/{name}; empty = off
+}
+
+// ShareConfig pushes the flight to a relay for a live link
+type ShareConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Relay    string        `yaml:"relay"`    // Base URL of an elrs-map web server with web.relay_token set
+	Token    string        `yaml:"token"`    // The relay's web.relay_token
+	ID       string        `yaml:"id"`       // The link's name; empty = a new random one each session
+	Interval time.Duration `yaml:"interval"` // How often to push
+}
+
+// LogConfig controls the application log
+type LogConfig struct {
+	Level     string `yaml:"level"` // debug, info, warn, error
+	Dir       string `yaml:"dir"`   // Empty = data directory
+	MaxSizeMB int    `yaml:"max_size_mb"`
+	MaxFiles  int    `yaml:"max_files"` // Rotated files kept
+}
+
+// TrackerConfig holds the antenna tracker outputs and servo calibration.
+// Pulse widths are in microseconds; a negative us_per_deg reverses a servo.
+type TrackerConfig struct {
+	Enabled      bool    `yaml:"enabled"`
+	PWMChip      int     `yaml:"pwm_chip"`
+	PanChannel   int     `yaml:"pan_channel"`
+	TiltChannel  int     `yaml:"tilt_channel"`
+	Heading      float64 `yaml:"heading"` // Bearing the tracker faces at pan center
+	PanCenter    int     `yaml:"pan_center_us"`
+	PanUsPerDeg  float64 `yaml:"pan_us_per_deg"`
+	PanRange     float64 `yaml:"pan_range"` // Degrees each side of center
+	TiltLevel    int     `yaml:"tilt_level_us"`
+	TiltUsPerDeg float64 `yaml:"tilt_us_per_deg"`
+	TiltMax      float64 `yaml:"tilt_max"` // 90, or 180 to flip over behind the pan range
+}
+
+// BuddyConfig controls position sharing with other ground stations
+type BuddyConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Name    string `yaml:"name"` // Label others see, empty = hostname
+	Port    int    `yaml:"port"` // UDP
+}
+
+// MQTTConfig publishes telemetry to an MQTT broker
+type MQTTConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	Broker         string        `yaml:"broker"`    // host:port
+	ClientID       string        `yaml:"client_id"` // Empty = elrs-map-<hostname>
+	Username       string        `yaml:"username"`
+	Password       string        `yaml:"password"`
+	TelemetryTopic string        `yaml:"telemetry_topic"` // JSON, as the web map's /api/telemetry
+	StatusTopic    string        `yaml:"status_topic"`    // Retained "online"/"offline", the last will
+	QoS            int           `yaml:"qos"`             // 0 or 1
+	Interval       time.Duration `yaml:"interval"`        // At most one telemetry message per interval
+}
+
+// StatusLineConfig writes a one-line status every interval
+type StatusLineConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Output   string        `yaml:"output"` // "-" for stdout, or a file, FIFO or serial device
+	Interval time.Duration `yaml:"interval"`
+}
+
+// WeatherConfig controls the forecast for the flying site
+type WeatherConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	MetarStation string        `yaml:"metar_station"` // ICAO code, e.g. SBKP; empty = none
+	Refresh      time.Duration `yaml:"refresh"`
+}
+
+// DisplayPortConfig controls the MSP DisplayPort OSD output
+type DisplayPortConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Target   string `yaml:"target"` // Serial device, or udp:host:port
+	BaudRate int    `yaml:"baud_rate"`
+	Columns  int    `yaml:"columns"` // 50x18 HD, 30x16 analog PAL
+	Rows     int    `yaml:"rows"`
+	Rate     int    `yaml:"rate"` // Frames per second
+}
+
+// GPSConfig sets up the ground station's own GPS receiver
+type GPSConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Source   string `yaml:"source"` // gpsd:host:port, or an NMEA serial device
+	BaudRate int    `yaml:"baud_rate"`
+}
+
+// HeadingConfig picks where the aircraft's heading comes from and how
+// magnetic headings are turned true
+type HeadingConfig struct {
+	Source      string  `yaml:"source"`      // gps (course over ground), mag (magnetometer yaw)
+	WMMFile     string  `yaml:"wmm_file"`    // NOAA WMM.COF to compute the declination from
+	Declination float64 `yaml:"declination"` // Degrees east, used without a model file
+}
+
+// AltitudeConfig picks the altitude the tapes and alerts use
+type AltitudeConfig struct {
+	Source    string `yaml:"source"`      // auto (baro, else GPS), gps, baro, fused (baro held to GPS)
+	ZeroOnArm bool   `yaml:"zero_on_arm"` // Zero it when a flight begins
+}
+
+// StateConfig holds runtime state restored at the next launch
+type StateConfig struct {
+	Zoom      int        `yaml:"zoom"`
+	CenterLat float64    `yaml:"center_lat"`
+	CenterLon float64    `yaml:"center_lon"`
+	HUDMode   int        `yaml:"hud_mode"`
+	Follow    bool       `yaml:"follow"`
+	Home      HomeConfig `yaml:"home"`
+	HomeSetAt time.Time  `yaml:"home_set_at,omitempty"`
+	Pilot     HomeConfig `yaml:"pilot"` // Where the pilot stands
+}
+
+// HomeConfig is a saved home position
+type HomeConfig struct {
+	Set bool    `yaml:"set" json:"set"`
+	Lat float64 `yaml:"lat" json:"lat"`
+	Lon float64 `yaml:"lon" json:"lon"`
+}
+
+// DisplayConfig holds look-and-feel settings
+type DisplayConfig struct {
+	Theme     string `yaml:"theme"`      // dark, black
+	Units     Units  `yaml:"units"`      // metric, imperial
+	PanelSide string `yaml:"panel_side"` // left, right
+
+	// Map's share of the width in the split map and cockpit layout
+	SplitRatio float64 `yaml:"split_ratio"`
+
+	// Power save caps the frame rate and only redraws when something changed
+	PowerSave    bool `yaml:"power_save"`
+	PowerSaveFPS int  `yaml:"power_save_fps"`
+
+	LatencyGraph bool `yaml:"latency_graph"`   // Link timing history on the map
+	LQTimeline   bool `yaml:"lq_timeline"`     // Link quality over the flight under the map
+	MaxTXPower   int  `yaml:"max_tx_power_mw"` // The TX's top power, flagged on the trail and timeline; 0 = the highest seen
+	MiniAttitude bool `yaml:"mini_attitude"`   // Horizon and compass in the map-only mode
+
+	OSD       OSDElements     `yaml:"osd"` // What the OSD HUD mode shows
+	StatusBar StatusBarConfig `yaml:"status_bar"`
+}
+
+// StatusBarConfig picks the fields along the bottom of the screen
+type StatusBarConfig struct {
+	// In order, from conn, link, lq, port, zoom, follow, map, hud, clock, elev, help
+	Fields  []string `yaml:"fields"`
+	Compact bool     `yaml:"compact"` // Short labels; also used when the full ones don't fit
+}
+
+// OSDElements picks the readouts drawn in the OSD HUD mode
+type OSDElements struct {
+	Coords   bool `yaml:"coords"`
+	Heading  bool `yaml:"heading"` // Heading bar across the top
+	Sats     bool `yaml:"sats"`
+	Speed    bool `yaml:"speed"`
+	Altitude bool `yaml:"altitude"`
+	Home     bool `yaml:"home"`     // Home arrow and distance
+	Guidance bool `yaml:"guidance"` // Waypoint and rally point
+	Battery  bool `yaml:"battery"`
+	Current  bool `yaml:"current"`
+	LQ       bool `yaml:"lq"`
+	Attitude bool `yaml:"attitude"`
+}
+
+// AlertConfig holds the thresholds that turn readouts red
+type AlertConfig struct {
+	BatteryLowPct int         `yaml:"battery_low_pct" json:"battery_low_pct"`
+	LQLowPct      int         `yaml:"lq_low_pct" json:"lq_low_pct"`
+	MinSats       int         `yaml:"min_sats" json:"min_sats"`
+	MaxDistance   float64     `yaml:"max_distance_m" json:"max_distance_m"`
+	MaxAltitude   float64     `yaml:"max_altitude_m" json:"max_altitude_m"` // Above takeoff, 0 for none
+	Limits        string      `yaml:"limits" json:"limits"`                 // Legal limits preset, see limitPresets
+	MaxLatencyMs  int         `yaml:"max_latency_ms" json:"max_latency_ms"` // Link frame gap, 0 for none
+	Gauges        GaugeConfig `yaml:"gauges" json:"-"`
+}
+
+// GaugeConfig holds the Panel gauges' thresholds, in the unit each shows
+type GaugeConfig struct {
+	Battery GaugeThresholds `yaml:"battery"` // % remaining
+	LQ      GaugeThresholds `yaml:"lq"`      // %
+	RSSI    GaugeThresholds `yaml:"rssi"`    // dBm
+	SNR     GaugeThresholds `yaml:"snr"`     // dB
+}
+
+// GaugeThresholds turn a gauge yellow past Warn and red, blinking, past
+// Critical. Warn above Critical means higher is better, as for LQ; below
+// it, lower is better.
+type GaugeThresholds struct {
+	Warn     float64 `yaml:"warn"`
+	Critical float64 `yaml:"critical"`
+}
+
+// Gauge levels, from GaugeThresholds.Level
+const (
+	GaugeGood = iota
+	GaugeWarn
+	GaugeCritical
+)
+
+// Level returns how bad value is
+func (t GaugeThresholds) Level(value float64) int {
+	inverted := t.Warn < t.Critical
+	switch {
+	case inverted && value >= t.Critical, !inverted && value <= t.Critical:
+		return GaugeCritical
+	case inverted && value >= t.Warn, !inverted && value <= t.Warn:
+		return GaugeWarn
+	}
+	return GaugeGood
+}
+
+// BatteryConfig describes the flight pack and when to warn about it
+type BatteryConfig struct {
+	Preset          string  `yaml:"preset"`      // Battery preset, see batteryPresets
+	Cells           int     `yaml:"cells"`       // Series cells, 0 to work it out from the voltage at plug-in
+	Chemistry       string  `yaml:"chemistry"`   // lipo, lihv, liion
+	WarnCell        float64 `yaml:"warn_cell_v"` // Per cell, 0 for the chemistry's
+	LandCell        float64 `yaml:"land_cell_v"`
+	SagCompensation bool    `yaml:"sag_compensation"` // Add back the sag from the current draw
+	ResistanceMohm  float64 `yaml:"resistance_mohm"`  // Whole pack, 0 for the chemistry's typical
+}
+
+// MapConfig holds map behavior settings
+type MapConfig struct {
+	Source        string   `yaml:"source"`      // street, satellite
+	Attribution   string   `yaml:"attribution"` // Corner of the tile source's credit: bottom-right, bottom-left, top-left, top-right
+	Brightness    float64  `yaml:"brightness"`  // Added to the tiles' colors, -0.5 to 0.5; 0 = as downloaded
+	Contrast      float64  `yaml:"contrast"`    // Around mid gray, 0.5 to 2; 1 = as downloaded
+	Saturation    float64  `yaml:"saturation"`  // 0 = gray, 1 = as downloaded, up to 2
+	Grid          string   `yaml:"grid"`        // Coordinate grid over the map: off, latlon, mgrs
+	FollowOnStart bool     `yaml:"follow_on_start"`
+	FollowOffset  float64  `yaml:"follow_offset"`       // Following, the aircraft sits this far from the middle toward the edge behind it, 0 to 0.8
+	FollowAhead   float64  `yaml:"follow_look_ahead_m"` // and the map's middle this many more meters ahead along its track
+	DefaultLat    float64  `yaml:"default_lat"`         // Used before the first GPS fix
+	DefaultLon    float64  `yaml:"default_lon"`
+	Preheat       bool     `yaml:"preheat"`       // Load the last view's and home's tiles at startup
+	HomeLine      bool     `yaml:"home_line"`     // Line from home to the aircraft with distance and bearing
+	DistanceFrom  string   `yaml:"distance_from"` // home, pilot
+	RallyLines    bool     `yaml:"rally_lines"`   // Lines from the aircraft to every rally point
+	CompareTrack  string   `yaml:"compare_track"` // Telemetry log drawn under the live track; empty = none
+	Overlays      []string `yaml:"overlays"`      // GeoJSON files drawn over the tiles
+	ShowOverlays  bool     `yaml:"show_overlays"`
+	Path3D        bool     `yaml:"path_3d"`          // Lift the flight path by its altitude, with drop lines
+	Path3DScale   float64  `yaml:"path_3d_scale"`    // Vertical exaggeration of the lifted path
+	DistanceMarks float64  `yaml:"distance_marks_m"` // Label the distance flown along the path every this many meters; 0 = off
+	ClearOnArm    string   `yaml:"clear_on_arm"`     // What a new arm or takeoff does with the trail: off, clear, archive
+
+	FixMinSats int           `yaml:"fix_min_sats"` // Satellites for a good fix; the trail is grayed below it
+	FixSettle  time.Duration `yaml:"fix_settle"`   // How long the fix must be good before the trail starts
+	AutoHome   bool          `yaml:"auto_home"`    // Set home from the first good, settled fix
+
+	Layers map[string]LayerConfig `yaml:"layers,omitempty"` // Per map layer; those left out are shown in the default order
+}
+
+// LayerConfig hides a map layer or moves it up or down the stack
+type LayerConfig struct {
+	Hide bool `yaml:"hide"`
+	Z    int  `yaml:"z"` // Drawn above lower z; 0 keeps the default (tiles 0, then 10 apart)
+}
+
+// MissionConfig selects the route shown on the map and flown in the HUD
+type MissionConfig struct {
+	File   string  `yaml:"file"`            // .waypoints, INAV .mission or GPX; empty = none
+	Radius float64 `yaml:"accept_radius_m"` // A waypoint counts as reached this close
+}
+
+// FindConfig sets up the "find my plane" beeper
+type FindConfig struct {
+	Command string `yaml:"command"` // Command parameter of the FC or receiver that starts its beeper
+}
+
+// RallyPoint is an alternate landing site
+type RallyPoint struct {
+	Name string  `yaml:"name"`
+	Lat  float64 `yaml:"lat"`
+	Lon  float64 `yaml:"lon"`
+}
+
+// AircraftIcon is how an aircraft is drawn on the map, and the calibration
+// of its flight controller's battery sensors
+type AircraftIcon struct {
+	Icon    string            `yaml:"icon"`  // plane, wing, quad, arrow, triangle, or a PNG pointing north
+	Color   string            `yaml:"color"` // #rrggbb tint; empty keeps a PNG's own colors
+	Size    int               `yaml:"size"`  // Pixels across
+	Voltage SensorCalibration `yaml:"voltage"`
+	Current SensorCalibration `yaml:"current"`
+
+	SlowKmh     float64 `yaml:"slow_kmh"`     // Below this ground speed the GPS course is noise; 0 = always use it
+	SlowHeading string  `yaml:"slow_heading"` // There: hold (the last heading from faster), yaw (magnetometer)
+}
+
+// SensorCalibration corrects a sensor reading as reading*scale + offset
+type SensorCalibration struct {
+	Scale  float64 `yaml:"scale"`  // 0 is taken as 1
+	Offset float64 `yaml:"offset"` // Volts or amps
+}
+
+// AircraftConfig holds the icon profiles and which one is in use
+type AircraftConfig struct {
+	Profile  string                  `yaml:"profile"`
+	Profiles map[string]AircraftIcon `yaml:"profiles"`
+}
+
+// Calibration returns the battery sensor calibration of the profile in use
+func (c AircraftConfig) Calibration() (voltage, current SensorCalibration) {
+	p := c.Profiles[c.Profile]
+	return p.Voltage, p.Current
+}
+
+// SlowHeading returns the low speed heading settings of the profile in use
+func (c AircraftConfig) SlowHeading() (kmh float64, yaw bool) {
+	p := c.Profiles[c.Profile]
+	return p.SlowKmh, p.SlowHeading == "yaw"
+}
+
+// DefaultTouchIdleTimeout hides the touch buttons after this long without a tap
+const DefaultTouchIdleTimeout = 10 * time.Second
+
+// TouchConfig describes the on-screen touch buttons
+type TouchConfig struct {
+	Enabled      bool                `yaml:"enabled"`
+	IdleTimeout  time.Duration       `yaml:"idle_timeout"`
+	Opacity      float64             `yaml:"opacity"`
+	ButtonWidth  int                 `yaml:"button_width"`
+	ButtonHeight int                 `yaml:"button_height"`
+	Margin       int                 `yaml:"margin"`
+	Buttons      []TouchButtonConfig `yaml:"buttons"`
+}
+
+// TouchButtonConfig places a single touch button on the layout grid.
+// Anchor is one of top-left, top, top-right, left, center, right,
+// bottom-left, bottom, bottom-right; Col/Row count grid cells away from
+// the anchor (negative values move the other way).
+type TouchButtonConfig struct {
+	Label  string `yaml:"label"`
+	Action string `yaml:"action"`
+	Anchor string `yaml:"anchor"`
+	Col    int    `yaml:"col"`
+	Row    int    `yaml:"row"`
+	Width  int    `yaml:"width,omitempty"`  // Pixels, 0 = button_width
+	Height int    `yaml:"height,omitempty"` // Pixels, 0 = button_height
+}
+
+// PluginConfig runs a program that gets telemetry on its stdin as JSON
+// lines and answers with widgets, alerts and actions on its stdout
+type PluginConfig struct {
+	Name     string        `yaml:"name"`    // In the log; empty = the command
+	Command  string        `yaml:"command"` // Program to run
+	Args     []string      `yaml:"args,omitempty"`
+	Interval time.Duration `yaml:"interval"` // How often it gets telemetry; 0 = every second
+}
+
+// InputConfig binds inputs other than the keyboard to actions
+type InputConfig struct {
+	Gamepad  map[string]string `yaml:"gamepad"`   // Button (a, b, x, y, lb, rb, lt, rt, back, start, up, down, left, right, ls, rs) to action
+	PanSpeed float64           `yaml:"pan_speed"` // Arrow keys and WASD move the map this many screen pixels a second
+	PanFast  float64           `yaml:"pan_fast"`  // Times faster with Shift held
+}
+
+// DefaultConfig returns the built-in settings
+func DefaultConfig() *Config {
+	return &Config{
+		Backend: BackendConfig{
+			Source:        "grpc",
+			Address:       "localhost:10000",
+			BaudRate:      DefaultBaudRate,
+			Discover:      true,
+			FailoverAfter: 5 * time.Second,
+			StallTimeout:  10 * time.Second,
+		},
+		Window: WindowConfig{
+			Width:  1024,
+			Height: 600,
+		},
+		CacheDir: "tiles",
+		Tiles: TileConfig{
+			MaxAge:         30 * 24 * time.Hour,
+			Cache:          TileCacheDir,
+			PrefetchRadius: 3000,
+			PrefetchMax:    1500,
+		},
+		Display: DisplayConfig{
+			Theme:        "dark",
+			Units:        UnitsMetric,
+			PanelSide:    "left",
+			SplitRatio:   0.5,
+			PowerSaveFPS: 20,
+			LQTimeline:   true,
+			MiniAttitude: true,
+			OSD: OSDElements{
+				Coords: true, Heading: true, Sats: true, Speed: true, Altitude: true, Home: true,
+				Guidance: true, Battery: true, Current: true, LQ: true, Attitude: true,
+			},
+			StatusBar: StatusBarConfig{
+				Fields: []string{"conn", "link", "port", "zoom", "follow", "map", "hud", "help"},
+			},
+		},
+		Battery: BatteryConfig{
+			Preset:          "custom",
+			Chemistry:       "lipo",
+			SagCompensation: true,
+		},
+		Alerts: AlertConfig{
+			BatteryLowPct: 20,
+			LQLowPct:      50,
+			MinSats:       4,
+			MaxDistance:   5000,
+			Limits:        "custom",
+			MaxLatencyMs:  500,
+			Gauges: GaugeConfig{
+				Battery: GaugeThresholds{Warn: 40, Critical: 20},
+				LQ:      GaugeThresholds{Warn: 70, Critical: 50},
+				RSSI:    GaugeThresholds{Warn: -90, Critical: -105},
+				SNR:     GaugeThresholds{Warn: 5, Critical: 0},
+			},
+		},
+		Map: MapConfig{
+			Source:        "satellite",
+			Attribution:   "bottom-right",
+			Contrast:      1,
+			Saturation:    1,
+			Grid:          "off",
+			FollowOnStart: true,
+			DefaultLat:    -22.9064, // Campinas, Brazil
+			DefaultLon:    -47.0616,
+			Preheat:       true,
+			DistanceFrom:  "home",
+			ShowOverlays:  true,
+			Path3DScale:   1,
+			ClearOnArm:    "off",
+			FixMinSats:    6,
+			FixSettle:     5 * time.Second,
+		},
+		Aircraft: AircraftConfig{
+			Profile: "plane",
+			Profiles: map[string]AircraftIcon{
+				"plane":    {Icon: "plane", Color: "#ff6464", Size: 32, SlowKmh: 5},
+				"wing":     {Icon: "wing", Color: "#ff6464", Size: 32, SlowKmh: 5},
+				"quad":     {Icon: "quad", Color: "#ff6464", Size: 30, SlowKmh: 3, SlowHeading: "yaw"},
+				"arrow":    {Icon: "arrow", Color: "#ff6464", Size: 28, SlowKmh: 3},
+				"triangle": {Icon: "triangle", SlowKmh: 3},
+			},
+		},
+		Mission: MissionConfig{
+			Radius: 30,
+		},
+		Find: FindConfig{
+			Command: "Beeper",
+		},
+		Touch: TouchConfig{
+			IdleTimeout:  DefaultTouchIdleTimeout,
+			Opacity:      1.0,
+			ButtonWidth:  60,
+			ButtonHeight: 45,
+			Margin:       5,
+			Buttons: []TouchButtonConfig{
+				{Label: "ZOOM+", Action: "zoom_in", Anchor: "bottom-left", Col: 0, Row: 1},
+				{Label: "ZOOM-", Action: "zoom_out", Anchor: "bottom-left", Col: 0, Row: 0},
+				{Label: "HOME", Action: "set_home", Anchor: "bottom-left", Col: 1, Row: 1},
+				{Label: "FLLW", Action: "follow", Anchor: "bottom-left", Col: 1, Row: 0},
+				{Label: "HUD", Action: "hud", Anchor: "bottom-left", Col: 2, Row: 1},
+				{Label: "CLR", Action: "clear_path", Anchor: "bottom-left", Col: 2, Row: 0},
+				{Label: "LINK", Action: "link", Anchor: "top", Col: 0, Row: 0, Width: 80},
+				{Label: "PORT", Action: "port", Anchor: "top", Col: -1, Row: 0},
+			},
+		},
+		Input: InputConfig{
+			Gamepad: map[string]string{
+				"a":     "follow",
+				"b":     "center_home",
+				"x":     "center_aircraft",
+				"y":     "fit",
+				"lb":    "zoom_out",
+				"rb":    "zoom_in",
+				"back":  "hud",
+				"start": "settings",
+			},
+			PanSpeed: 600,
+			PanFast:  4,
+		},
+		Record: RecordConfig{
+			Dir:          "logs",
+			Interval:     DefaultRecordInterval,
+			SyncInterval: 5 * time.Second,
+			MaxSizeMB:    100,
+		},
+		DEM: DEMConfig{
+			Dir: "dem",
+		},
+		Upload: UploadConfig{
+			Kind:     "webdav",
+			Region:   "us-east-1",
+			Interval: time.Minute,
+		},
+		Web: WebConfig{
+			Listen:  ":8080",
+			Tiles:   "cache",
+			Metrics: true,
+		},
+		Log: LogConfig{
+			Level:     "info",
+			MaxSizeMB: 5,
+			MaxFiles:  3,
+		},
+		Tracker: TrackerConfig{
+			PWMChip:      0,
+			PanChannel:   0, // GPIO 18
+			TiltChannel:  1, // GPIO 19
+			PanCenter:    1500,
+			PanUsPerDeg:  10,
+			PanRange:     90,
+			TiltLevel:    1000,
+			TiltUsPerDeg: 10,
+			TiltMax:      90,
+		},
+		Buddy: BuddyConfig{
+			Port: 5601,
+		},
+		MQTT: MQTTConfig{
+			Broker:         "localhost:1883",
+			TelemetryTopic: "elrs-map/telemetry",
+			StatusTopic:    "elrs-map/status",
+			Interval:       time.Second,
+		},
+		Share: ShareConfig{
+			Interval: time.Second,
+		},
+		Status: StatusLineConfig{
+			Output:   "-",
+			Interval: 5 * time.Second,
+		},
+		Weather: WeatherConfig{
+			Enabled: true,
+			Refresh: 15 * time.Minute,
+		},
+		OSDOut: DisplayPortConfig{
+			BaudRate: 115200,
+			Columns:  50,
+			Rows:     18,
+			Rate:     10,
+		},
+		GPS: GPSConfig{
+			Source:   "gpsd:localhost:2947",
+			BaudRate: 9600,
+		},
+		Heading: HeadingConfig{
+			Source: "gps",
+		},
+		Altitude: AltitudeConfig{
+			Source:    "auto",
+			ZeroOnArm: true,
+		},
+		State: StateConfig{
+			Zoom:    DefaultZoom,
+			HUDMode: 2, // Panel+map
+		},
+	}
+}
+
+// DefaultConfigPath returns ~/.config/elrs-map/config.yaml (or the OS equivalent)
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "config.yaml"
+	}
+	return filepath.Join(dir, "elrs-map", "config.yaml")
+}
+
+// DefaultDataDir returns where logs and other app data live:
+// $XDG_DATA_HOME/elrs-map or ~/.local/share/elrs-map on Linux, next to the
+// config file elsewhere
+func DefaultDataDir() string {
+	if runtime.GOOS == "linux" {
+		if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+			return filepath.Join(dir, "elrs-map")
+		}
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, ".local", "share", "elrs-map")
+		}
+	}
+	return filepath.Dir(DefaultConfigPath())
+}
+
+// LoadConfig reads the config file, falling back to defaults if it doesn't exist
+func LoadConfig(path string) (*Config, error) {
+	cfg := DefaultConfig()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+
+	// Decode over the defaults so missing keys keep their default values
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return DefaultConfig(), fmt.Errorf("parse %s: %w", path, err)
+	}
+	// A named preset sets the altitude and distance limits
+	ApplyLimitPreset(&cfg.Alerts, cfg.Alerts.Limits)
+	// and a battery preset the cells and chemistry
+	ApplyBatteryPreset(&cfg.Battery, cfg.Battery.Preset)
+	return cfg, nil
+}
+
+// SaveConfig writes the config file, replacing it atomically
+func SaveConfig(path string, cfg *Config) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic writes to a temp file and renames it over path, so a
+// power cut never leaves a half-written file behind
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
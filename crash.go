@@ -0,0 +1,155 @@
+//go:build !headless
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+)
+
+// Snapshot is the flight state saved when the app crashes, so the track and
+// last known aircraft position survive a GUI panic mid-flight
+type Snapshot struct {
+	Time       time.Time       `json:"time"`
+	Reason     string          `json:"reason"`
+	Home       HomeConfig      `json:"home"`
+	FlightPath []SnapshotPoint `json:"flight_path"`
+	Telemetry  TelemetryState  `json:"telemetry"`
+}
+
+// SnapshotPoint is one flight path point
+type SnapshotPoint struct {
+	Lat      float64   `json:"lat"`
+	Lon      float64   `json:"lon"`
+	Alt      float64   `json:"alt,omitempty"` // Meters above takeoff
+	At       time.Time `json:"at"`
+	Flight   int       `json:"flight,omitempty"`    // Which of the session's flights, from 1
+	Poor     bool      `json:"poor,omitempty"`      // Flown on a poor GPS fix
+	MaxPower bool      `json:"max_power,omitempty"` // Flown at full TX power
+}
+
+// SnapshotPath returns where the crash snapshot is kept
+func SnapshotPath() string {
+	return filepath.Join(DefaultDataDir(), "crash-snapshot.json")
+}
+
+// LoadSnapshot reads a crash snapshot; it returns nil if there is none
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	snap := &Snapshot{}
+	if err := json.Unmarshal(data, snap); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return snap, nil
+}
+
+// SaveSnapshot writes a crash snapshot, replacing any previous one
+func SaveSnapshot(path string, snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// recoverPanic saves a snapshot and flushes the log before a panic takes the
+// app down. Ebiten calls Update and Draw on its own goroutine, so it must be
+// deferred there rather than around RunGame.
+func (a *App) recoverPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	logApp.Errorf("Panic: %v\n%s", r, debug.Stack())
+	if a.secondScreen {
+		CloseLogging()
+		panic(r)
+	}
+
+	snap := a.snapshot(fmt.Sprint(r))
+	if err := SaveSnapshot(SnapshotPath(), snap); err != nil {
+		logApp.Errorf("Could not save crash snapshot: %v", err)
+	} else {
+		logApp.Infof("Saved %d track points to %s", len(snap.FlightPath), SnapshotPath())
+	}
+	a.saveConfig()
+	CloseLogging()
+	panic(r)
+}
+
+// snapshot captures the flight path, home, and last telemetry
+func (a *App) snapshot(reason string) *Snapshot {
+	snap := &Snapshot{
+		Time:      time.Now(),
+		Reason:    reason,
+		Home:      HomeConfig{Set: a.homeSet, Lat: a.homeLat, Lon: a.homeLon},
+		Telemetry: a.client.GetState(),
+	}
+	for _, p := range a.flightPath {
+		snap.FlightPath = append(snap.FlightPath, SnapshotPoint{Lat: p.lat, Lon: p.lon, Alt: p.alt, At: p.at, Flight: p.flight, Poor: p.poor, MaxPower: p.maxPower})
+	}
+	return snap
+}
+
+// restoreSnapshot puts a crashed session's track, home, and last known
+// aircraft position back on the map
+func (a *App) restoreSnapshot(snap *Snapshot) {
+	a.flightPath = a.flightPath[:0]
+	for _, p := range snap.FlightPath {
+		a.flightPath = appendPathPoint(a.flightPath, pathPoint{lat: p.Lat, lon: p.Lon, alt: p.Alt, at: p.At, flight: max(1, p.Flight), poor: p.Poor, maxPower: p.MaxPower})
+		a.flightNum = max(1, p.Flight)
+	}
+	// The restored trail is a flight, to be archived before the next clears it
+	a.flightSeen = len(a.flightPath) > 0
+	if snap.Home.Set {
+		a.setHome(snap.Home.Lat, snap.Home.Lon)
+		a.homeSetAt = snap.Time
+	}
+	a.client.RestoreState(&snap.Telemetry)
+	if snap.Telemetry.HasGPS {
+		a.centerLat = float64(snap.Telemetry.Latitude)
+		a.centerLon = float64(snap.Telemetry.Longitude)
+	}
+	logApp.Infof("Restored %d track points from %s", len(snap.FlightPath), snap.Time.Format(time.RFC3339))
+}
+
+// newRestoreMenu offers to restore the session saved by the last crash.
+// The snapshot is removed once the menu is closed either way.
+func (a *App) newRestoreMenu(snap *Snapshot) *Menu {
+	m := NewMenu("Restore crashed session?")
+	m.OnClose = func() {
+		if err := os.Remove(SnapshotPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logApp.Warnf("Could not remove crash snapshot: %v", err)
+		}
+	}
+
+	lastPos := "no GPS"
+	if snap.Telemetry.HasGPS {
+		lastPos = fmt.Sprintf("%.6f, %.6f", snap.Telemetry.Latitude, snap.Telemetry.Longitude)
+	}
+	m.Items = []MenuItem{
+		{Label: "Crashed " + snap.Time.Format("2006-01-02 15:04:05")},
+		{Label: fmt.Sprintf("%d track points", len(snap.FlightPath))},
+		{Label: "Last position", Value: func() string { return lastPos }},
+		{
+			Label: "Restore",
+			OnSelect: func() {
+				a.restoreSnapshot(snap)
+				m.Close()
+			},
+		},
+		{Label: "Discard", OnSelect: m.Close},
+	}
+	return m
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// CRSF device addresses the backend reports devices under
+const (
+	crsfAddrFC = 0xC8
+	crsfAddrRX = 0xEC
+	crsfAddrTX = 0xEE
+)
+
+// CRSF parameter types (the low 7 bits of the type byte; bit 7 hides it)
+const (
+	crsfTypeUint8      = 0
+	crsfTypeInt8       = 1
+	crsfTypeUint16     = 2
+	crsfTypeInt16      = 3
+	crsfTypeTextSelect = 9
+	crsfTypeString     = 10
+	crsfTypeFolder     = 11
+	crsfTypeInfo       = 12
+	crsfTypeCommand    = 13
+)
+
+// CRSF command states, written to start a command and read back as it runs
+const (
+	crsfCmdReady   = 0
+	crsfCmdStart   = 1
+	crsfCmdRunning = 2
+	crsfCmdAsk     = 3 // Waiting for crsfCmdConfirm
+	crsfCmdConfirm = 4
+)
+
+// crsfRole names a device by its address
+func crsfRole(addr uint32) string {
+	switch addr {
+	case crsfAddrTX:
+		return "TX module"
+	case crsfAddrRX:
+		return "Receiver"
+	case crsfAddrFC:
+		return "Flight controller"
+	}
+	return fmt.Sprintf("Device 0x%02X", addr)
+}
+
+// CRSFDevice is a device on the CRSF bus and its parameters
+type CRSFDevice struct {
+	ID     uint32
+	Name   string
+	Params []CRSFParam
+}
+
+// Role names what the device is
+func (d CRSFDevice) Role() string {
+	return crsfRole(d.ID)
+}
+
+// Param returns the first visible parameter named name
+func (d CRSFDevice) Param(name string) (CRSFParam, bool) {
+	for _, p := range d.Params {
+		if p.Name == name && !p.Hidden {
+			return p, true
+		}
+	}
+	return CRSFParam{}, false
+}
+
+// CRSFParam is one entry of a device's parameter menu, the one the radio's
+// Lua script shows
+type CRSFParam struct {
+	ID     uint32
+	Parent uint8
+	Type   uint8
+	Hidden bool
+	Name   string
+
+	Value    string   // Info and string value, or the selected option
+	Options  []string // Text selection choices
+	Selected int      // Index into Options, or the number value
+	Status   uint8    // Command progress
+	Info     string   // Command status text
+}
+
+// parseCRSFParam decodes a parameter entry as the backend hands it over,
+// with its chunks joined: parent folder, type, name, then the type's fields
+func parseCRSFParam(id uint32, data []byte) (CRSFParam, error) {
+	if len(data) < 3 {
+		return CRSFParam{}, fmt.Errorf("field %d: short entry", id)
+	}
+	p := CRSFParam{ID: id, Parent: data[0], Type: data[1] & 0x7F, Hidden: data[1]&0x80 != 0}
+	rest := data[2:]
+	cstr := func() string {
+		i := bytes.IndexByte(rest, 0)
+		if i < 0 {
+			s := string(rest)
+			rest = nil
+			return s
+		}
+		s := string(rest[:i])
+		rest = rest[i+1:]
+		return s
+	}
+	u8 := func() int {
+		if len(rest) == 0 {
+			return 0
+		}
+		v := int(rest[0])
+		rest = rest[1:]
+		return v
+	}
+	p.Name = cstr()
+
+	switch p.Type {
+	case crsfTypeUint8, crsfTypeInt8:
+		p.Selected = u8()
+		if p.Type == crsfTypeInt8 {
+			p.Selected = int(int8(p.Selected))
+		}
+		p.Value = fmt.Sprint(p.Selected)
+	case crsfTypeUint16, crsfTypeInt16:
+		p.Selected = u8()<<8 | u8()
+		if p.Type == crsfTypeInt16 {
+			p.Selected = int(int16(p.Selected))
+		}
+		p.Value = fmt.Sprint(p.Selected)
+	case crsfTypeTextSelect:
+		p.Options = strings.Split(cstr(), ";")
+		p.Selected = u8()
+		if p.Selected < len(p.Options) {
+			p.Value = p.Options[p.Selected]
+		}
+	case crsfTypeString, crsfTypeInfo:
+		p.Value = cstr()
+	case crsfTypeCommand:
+		p.Status = uint8(u8())
+		u8() // Timeout
+		p.Info = cstr()
+	}
+	return p, nil
+}
+
+// encodeCRSFParam is the inverse of parseCRSFParam, for the simulator
+func encodeCRSFParam(p CRSFParam) []byte {
+	typ := p.Type
+	if p.Hidden {
+		typ |= 0x80
+	}
+	b := []byte{p.Parent, typ}
+	b = append(append(b, p.Name...), 0)
+	switch p.Type {
+	case crsfTypeUint8, crsfTypeInt8:
+		b = append(b, byte(p.Selected))
+	case crsfTypeUint16, crsfTypeInt16:
+		b = append(b, byte(p.Selected>>8), byte(p.Selected))
+	case crsfTypeTextSelect:
+		b = append(append(b, strings.Join(p.Options, ";")...), 0)
+		b = append(b, byte(p.Selected), 0, byte(len(p.Options)-1), 0, 0)
+	case crsfTypeString, crsfTypeInfo:
+		b = append(append(b, p.Value...), 0)
+	case crsfTypeCommand:
+		b = append(b, p.Status, 200)
+		b = append(append(b, p.Info...), 0)
+	}
+	return b
+}
+
+// simDevices is what the simulator reports as the CRSF bus
+func simDevices() []CRSFDevice {
+	return []CRSFDevice{
+		{ID: crsfAddrTX, Name: "ELRS Sim TX", Params: []CRSFParam{
+			{ID: 1, Type: crsfTypeTextSelect, Name: "Packet Rate", Options: []string{"50Hz", "150Hz", "250Hz", "500Hz"}, Selected: 2},
+			{ID: 2, Type: crsfTypeTextSelect, Name: "Telem Ratio", Options: []string{"Std", "Off", "1:128", "1:64", "1:32", "1:16", "1:8", "1:4", "1:2"}, Selected: 0},
+			{ID: 3, Type: crsfTypeCommand, Name: "Bind"},
+			{ID: 4, Type: crsfTypeFolder, Name: "WiFi Connectivity"},
+			{ID: 5, Parent: 4, Type: crsfTypeCommand, Name: "Enable WiFi"},
+			{ID: 6, Parent: 4, Type: crsfTypeCommand, Name: "Enable Rx WiFi"},
+			{ID: 7, Type: crsfTypeFolder, Name: "VTX Administrator"},
+			{ID: 8, Parent: 7, Type: crsfTypeTextSelect, Name: "Band", Options: vtxBandOptions, Selected: 5},
+			{ID: 9, Parent: 7, Type: crsfTypeTextSelect, Name: "Channel", Options: []string{"1", "2", "3", "4", "5", "6", "7", "8"}, Selected: 0},
+			{ID: 10, Parent: 7, Type: crsfTypeTextSelect, Name: "Pwr Lvl", Options: []string{"-", "1", "2", "3", "4", "5", "6", "7", "8"}, Selected: 1},
+			{ID: 11, Parent: 7, Type: crsfTypeTextSelect, Name: "Pitmode", Options: []string{"Off", "On"}},
+			{ID: 12, Parent: 7, Type: crsfTypeCommand, Name: "Send VTx"},
+			{ID: 13, Type: crsfTypeInfo, Name: "Version", Value: "3.4.3 ISM2G4"},
+		}},
+		{ID: crsfAddrRX, Name: "ELRS Sim RX", Params: []CRSFParam{
+			{ID: 1, Type: crsfTypeInfo, Name: "Version", Value: "3.4.3 ISM2G4"},
+		}},
+		{ID: crsfAddrFC, Name: "Sim FC", Params: []CRSFParam{
+			{ID: 1, Type: crsfTypeCommand, Name: "Beeper"},
+		}},
+	}
+}
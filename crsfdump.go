@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// DefaultCRSFFrameGap is the time a raw dump is taken to advance per
+// telemetry frame, as the dumps carry no timestamps: about right for ELRS
+// at 250 Hz with a 1:16 telemetry ratio
+const DefaultCRSFFrameGap = 64 * time.Millisecond
+
+// CRSF telemetry frame types
+const (
+	crsfFrameGPS        = 0x02
+	crsfFrameVario      = 0x07
+	crsfFrameBattery    = 0x08
+	crsfFrameBaro       = 0x09
+	crsfFrameLinkStats  = 0x14
+	crsfFrameAttitude   = 0x1E
+	crsfFrameFlightMode = 0x21
+)
+
+// crsfTxPowers are the link statistics' TX power values in mW
+var crsfTxPowers = []uint32{0, 10, 25, 100, 500, 1000, 2000, 250, 50}
+
+// isCRSFDump reports whether the start of a file holds a CRSF frame. A
+// telemetry log is plain text, which never has the frames' sync bytes.
+func isCRSFDump(head []byte) bool {
+	frame, _ := nextCRSFFrame(head)
+	return frame != nil
+}
+
+// LoadCRSFDump decodes a raw CRSF capture, such as a serial logger's or
+// one saved by the backend, into replay samples: one per telemetry frame,
+// frameGap apart. Bytes that aren't a frame with a good CRC are skipped,
+// so a capture of the whole serial line with RC frames mixed in works.
+func LoadCRSFDump(path string, start time.Time, frameGap time.Duration) ([]ReplaySample, error) {
+	data, err := readLog(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var state TelemetryState
+	var samples []ReplaySample
+	at := start
+	for len(data) > 0 {
+		frame, n := nextCRSFFrame(data)
+		data = data[n:]
+		if frame == nil || !decodeCRSFTelemetry(frame, &state) {
+			continue
+		}
+		samples = append(samples, ReplaySample{At: at, State: state})
+		at = at.Add(frameGap)
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("%s: no CRSF telemetry frames", path)
+	}
+	return samples, nil
+}
+
+// nextCRSFFrame finds the next frame in data, returning its type and
+// payload (nil if there is none) and how many bytes to consume
+func nextCRSFFrame(data []byte) ([]byte, int) {
+	for i := 0; i+4 <= len(data); i++ {
+		switch data[i] {
+		case crsfAddrFC, crsfAddrRX, crsfAddrTX, 0xEA:
+		default:
+			continue
+		}
+		// Length covers the type, payload and CRC
+		n := int(data[i+1])
+		if n < 2 || n > 62 || i+2+n > len(data) {
+			continue
+		}
+		body := data[i+2 : i+1+n]
+		if crsfCRC8(body) != data[i+1+n] {
+			continue
+		}
+		return body, i + 2 + n
+	}
+	return nil, len(data)
+}
+
+// crsfCRC8 is CRC-8/DVB-S2 over the type and payload
+func crsfCRC8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0xD5
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// decodeCRSFTelemetry applies a frame (type and payload) to the state,
+// reporting whether it was telemetry
+func decodeCRSFTelemetry(frame []byte, s *TelemetryState) bool {
+	p := frame[1:]
+	be := binary.BigEndian
+	switch frame[0] {
+	case crsfFrameGPS:
+		if len(p) < 15 {
+			return false
+		}
+		s.Latitude = float32(float64(int32(be.Uint32(p[0:]))) / 1e7)
+		s.Longitude = float32(float64(int32(be.Uint32(p[4:]))) / 1e7)
+		s.GroundSpeed = float32(be.Uint16(p[8:])) / 10
+		s.Heading = float32(be.Uint16(p[10:])) / 100
+		s.Altitude = int32(be.Uint16(p[12:])) - 1000
+		s.Satellites = uint32(p[14])
+		s.HasGPS = s.Latitude != 0 || s.Longitude != 0
+
+	case crsfFrameVario:
+		if len(p) < 2 {
+			return false
+		}
+		s.VerticalSpeed = float32(int16(be.Uint16(p))) / 100
+
+	case crsfFrameBattery:
+		if len(p) < 8 {
+			return false
+		}
+		s.Voltage = float32(be.Uint16(p[0:])) / 10
+		s.Current = float32(be.Uint16(p[2:])) / 10
+		s.Capacity = uint32(p[4])<<16 | uint32(p[5])<<8 | uint32(p[6])
+		s.Remaining = uint32(p[7])
+
+	case crsfFrameBaro:
+		if len(p) < 2 {
+			return false
+		}
+		// Decimeters above -1000 m, or whole meters with the top bit set
+		if alt := be.Uint16(p); alt&0x8000 != 0 {
+			s.BaroAltitude = float32(alt & 0x7FFF)
+		} else {
+			s.BaroAltitude = float32(int(alt)-10000) / 10
+		}
+		if len(p) >= 4 {
+			s.VerticalSpeed = float32(int16(be.Uint16(p[2:]))) / 100
+		}
+
+	case crsfFrameLinkStats:
+		if len(p) < 10 {
+			return false
+		}
+		s.RSSI1, s.RSSI2 = -int32(p[0]), -int32(p[1])
+		s.LinkQuality = uint32(p[2])
+		s.SNR = int32(int8(p[3]))
+		if int(p[6]) < len(crsfTxPowers) {
+			s.TXPower = crsfTxPowers[p[6]]
+		}
+
+	case crsfFrameAttitude:
+		if len(p) < 6 {
+			return false
+		}
+		// Radians * 10000
+		deg := func(b []byte) float32 { return float32(float64(int16(be.Uint16(b))) / 10000 * 180 / math.Pi) }
+		s.Pitch, s.Roll, s.Yaw = deg(p[0:]), deg(p[2:]), deg(p[4:])
+
+	case crsfFrameFlightMode:
+		mode, _, _ := bytes.Cut(p, []byte{0})
+		s.FlightMode = string(mode)
+
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// demVoid marks a sample with no data in an SRTM tile
+const demVoid = -32768
+
+// DEM looks up ground elevation in SRTM .hgt tiles kept in a folder, such
+// as those from USGS EarthExplorer or viewfinderpanoramas.org, so it works
+// without network at the field. Tiles load on first use and stay in memory.
+type DEM struct {
+	dir   string
+	mu    sync.Mutex
+	tiles map[string]*demTile // nil for a tile that isn't in the folder
+}
+
+// demTile is one 1°x1° tile: size x size big-endian samples, the first row
+// along its north edge. Rows and columns overlap the next tile's by one.
+type demTile struct {
+	size int
+	data []byte
+}
+
+// ElevationSample is the ground elevation at a point along a profile
+type ElevationSample struct {
+	Lat, Lon  float64
+	Dist      float64 // Meters from the profile's start
+	Elevation float64 // Meters above sea level
+	OK        bool    // The DEM has data here
+}
+
+// NewDEM looks for tiles in dir
+func NewDEM(dir string) *DEM {
+	return &DEM{dir: dir, tiles: make(map[string]*demTile)}
+}
+
+// Available reports whether the folder holds any tiles at all
+func (d *DEM) Available() bool {
+	names, _ := filepath.Glob(filepath.Join(d.dir, "*.[hH][gG][tT]"))
+	return len(names) > 0
+}
+
+// Dir returns the folder tiles are read from
+func (d *DEM) Dir() string {
+	return d.dir
+}
+
+// demTileName is the SRTM name of the tile holding a position, named after
+// its south-west corner, e.g. S23W048.hgt
+func demTileName(lat, lon float64) string {
+	la, lo := int(math.Floor(lat)), int(math.Floor(lon))
+	ns, ew := 'N', 'E'
+	if la < 0 {
+		ns, la = 'S', -la
+	}
+	if lo < 0 {
+		ew, lo = 'W', -lo
+	}
+	return fmt.Sprintf("%c%02d%c%03d.hgt", ns, la, ew, lo)
+}
+
+// tile returns the tile holding a position, loading it the first time
+func (d *DEM) tile(lat, lon float64) *demTile {
+	name := demTileName(lat, lon)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if t, ok := d.tiles[name]; ok {
+		return t
+	}
+	var t *demTile
+	for _, n := range []string{name, strings.ToLower(name)} {
+		data, err := os.ReadFile(filepath.Join(d.dir, n))
+		if err != nil {
+			continue
+		}
+		size := int(math.Sqrt(float64(len(data) / 2)))
+		if size < 2 || size*size*2 != len(data) {
+			logApp.Warnf("Elevation tile %s is not an SRTM .hgt file", n)
+			break
+		}
+		t = &demTile{size: size, data: data}
+		break
+	}
+	d.tiles[name] = t
+	return t
+}
+
+func (t *demTile) at(row, col int) int16 {
+	return int16(binary.BigEndian.Uint16(t.data[2*(row*t.size+col):]))
+}
+
+// Elevation returns the ground elevation in meters above sea level,
+// interpolated between the four nearest samples, or false where there is
+// no tile or the tile has a hole
+func (d *DEM) Elevation(lat, lon float64) (float64, bool) {
+	t := d.tile(lat, lon)
+	if t == nil {
+		return 0, false
+	}
+	last := float64(t.size - 1)
+	y := (math.Floor(lat) + 1 - lat) * last
+	x := (lon - math.Floor(lon)) * last
+	r, c := min(int(y), t.size-2), min(int(x), t.size-2)
+	fy, fx := y-float64(r), x-float64(c)
+
+	h00, h01, h10, h11 := t.at(r, c), t.at(r, c+1), t.at(r+1, c), t.at(r+1, c+1)
+	if h00 == demVoid || h01 == demVoid || h10 == demVoid || h11 == demVoid {
+		return 0, false
+	}
+	top := float64(h00)*(1-fx) + float64(h01)*fx
+	bottom := float64(h10)*(1-fx) + float64(h11)*fx
+	return top*(1-fy) + bottom*fy, true
+}
+
+// Profile samples the ground along a route of [lat, lon] points, n samples
+// evenly spaced by distance from its start to its end
+func (d *DEM) Profile(route [][2]float64, n int) []ElevationSample {
+	if len(route) < 2 || n < 2 {
+		return nil
+	}
+	legs := make([]float64, len(route)-1)
+	total := 0.0
+	for i := range legs {
+		legs[i] = DistanceMeters(route[i][0], route[i][1], route[i+1][0], route[i+1][1])
+		total += legs[i]
+	}
+
+	samples := make([]ElevationSample, 0, n)
+	leg, start := 0, 0.0 // Leg the sample falls on, and the distance where it begins
+	for i := 0; i < n; i++ {
+		dist := total * float64(i) / float64(n-1)
+		for leg < len(legs)-1 && dist > start+legs[leg] {
+			start += legs[leg]
+			leg++
+		}
+		f := 0.0
+		if legs[leg] > 0 {
+			f = math.Min(1, (dist-start)/legs[leg])
+		}
+		a, b := route[leg], route[leg+1]
+		s := ElevationSample{Lat: a[0] + (b[0]-a[0])*f, Lon: a[1] + (b[1]-a[1])*f, Dist: dist}
+		s.Elevation, s.OK = d.Elevation(s.Lat, s.Lon)
+		samples = append(samples, s)
+	}
+	return samples
+}
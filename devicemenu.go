@@ -0,0 +1,182 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// deviceInfo is the result of a background device query
+type deviceInfo struct {
+	version string
+	devices []CRSFDevice
+	err     error
+}
+
+// fetchDevices queries the backend and devices in the background. Results
+// arrive in Update through a.deviceInfo.
+func (a *App) fetchDevices() {
+	if a.fetchingDevices {
+		return
+	}
+	a.fetchingDevices = true
+	go func() {
+		var info deviceInfo
+		info.version, info.err = a.client.AppInfo()
+		if info.err == nil {
+			info.devices, info.err = a.client.Devices()
+		}
+		a.deviceInfo <- info
+	}()
+}
+
+// deviceCommands are the TX module commands offered in the connection
+// screen, by parameter name
+var deviceCommands = []struct{ param, label string }{
+	{"Bind", "Bind receiver"},
+	{"Enable WiFi", "TX WiFi update mode"},
+	{"Enable Rx WiFi", "RX WiFi update mode"},
+}
+
+// runDeviceCommand starts a device command in the background; the outcome
+// shows in the connection screen
+func (a *App) runDeviceCommand(d CRSFDevice, p CRSFParam, label string) {
+	a.deviceStatus = label + "..."
+	go func() {
+		if err := a.client.RunCommand(d.ID, p.ID); err != nil {
+			logTelem.Errorf("%s: %v", label, err)
+			a.commandDone <- label + " failed: " + err.Error()
+			return
+		}
+		logTelem.Infof("%s: sent to %s", label, d.Name)
+		a.commandDone <- label + ": sent"
+	}()
+}
+
+// handleDeviceInfo applies a finished device query or command
+func (a *App) handleDeviceInfo() {
+	var info deviceInfo
+	select {
+	case info = <-a.deviceInfo:
+	case a.deviceStatus = <-a.commandDone:
+		return
+	default:
+		return
+	}
+	a.fetchingDevices = false
+	a.devices = info
+	if info.err != nil {
+		logTelem.Warnf("Device query failed: %v", info.err)
+	}
+	for _, d := range info.devices {
+		logTelem.Infof("%s: %s", d.Role(), d.Name)
+	}
+	for _, m := range []*Menu{a.deviceMenu, a.vtxMenu} {
+		if m.IsOpen() {
+			m.Rebuild(m)
+		}
+	}
+}
+
+// openDeviceMenu shows the connection details, refreshing them
+func (a *App) openDeviceMenu() {
+	a.fetchDevices()
+	a.deviceMenu.Open()
+}
+
+// newDeviceMenu builds the connection details screen: the backend, and
+// the TX module and receiver with their firmware, so the right model can
+// be confirmed before launch
+func (a *App) newDeviceMenu() *Menu {
+	m := NewMenu("Connection")
+	m.Rebuild = func(m *Menu) {
+		m.Items = []MenuItem{
+			{Label: "Backend", Value: func() string { return a.client.Address() }},
+			{Label: "Version", Value: func() string {
+				if a.devices.version == "" {
+					return "-"
+				}
+				return a.devices.version
+			}},
+		}
+
+		rxSeen := false
+		for _, d := range a.devices.devices {
+			m.Items = append(m.Items, MenuItem{Label: d.Role(), Value: func() string { return d.Name }})
+			for _, p := range d.Params {
+				if p.Hidden {
+					continue
+				}
+				switch {
+				case p.Type == crsfTypeInfo:
+				case d.ID == crsfAddrTX && (p.Name == "Packet Rate" || p.Name == "Telem Ratio"):
+				default:
+					continue
+				}
+				m.Items = append(m.Items, MenuItem{Label: "  " + p.Name, Value: func() string { return p.Value }})
+			}
+			rxSeen = rxSeen || d.ID == crsfAddrRX
+		}
+		if !rxSeen && a.devices.err == nil && !a.fetchingDevices {
+			m.Items = append(m.Items, MenuItem{Label: "Receiver", Value: func() string { return "not seen" }})
+		}
+
+		// Bind and WiFi update, instead of the radio's Lua script
+		for _, d := range a.devices.devices {
+			if d.ID != crsfAddrTX {
+				continue
+			}
+			for _, c := range deviceCommands {
+				if p, ok := d.Param(c.param); ok && p.Type == crsfTypeCommand {
+					m.Items = append(m.Items, MenuItem{Label: c.label, OnSelect: func() { a.runDeviceCommand(d, p, c.label) }})
+				}
+			}
+		}
+		if _, _, _, ok := a.vtxParams(); ok {
+			m.Items = append(m.Items, MenuItem{Label: "VTX...", OnSelect: func() {
+				m.Close()
+				a.openVTXMenu()
+			}})
+		}
+		m.Items = append(m.Items, MenuItem{Label: "Status", Value: func() string {
+			if a.deviceStatus == "" {
+				return "-"
+			}
+			return a.deviceStatus
+		}})
+
+		if a.config.GPS.Enabled {
+			m.Items = append(m.Items, MenuItem{Label: "Local GPS", Value: a.localGPS.Status})
+		}
+
+		m.Items = append(m.Items, MenuItem{Label: "RX link", Value: func() string {
+			state := a.client.GetState()
+			switch {
+			case !state.LinkStarted:
+				return "link off"
+			case state.LastUpdate.IsZero() || time.Since(state.LastUpdate) > telemetryTimeout:
+				return "no telemetry"
+			default:
+				return fmt.Sprintf("connected, LQ %d%%", state.LinkQuality)
+			}
+		}})
+
+		switch {
+		case a.fetchingDevices:
+			m.Items = append(m.Items, MenuItem{Label: "Querying devices..."})
+		case a.devices.err != nil:
+			err := a.devices.err
+			m.Items = append(m.Items, MenuItem{Label: "Query failed", Value: func() string { return err.Error() }})
+		}
+
+		m.Items = append(m.Items,
+			MenuItem{Label: "Refresh", OnSelect: func() {
+				a.fetchDevices()
+				m.Rebuild(m)
+			}},
+			MenuItem{Label: "Close", OnSelect: m.Close},
+		)
+	}
+	return m
+}
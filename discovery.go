@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+// backendService is the DNS-SD service type of elrs-joystick-control. The
+// backend doesn't advertise itself yet; an avahi service file does it (see
+// README), and "elrs-map sim" advertises its simulated backend.
+const backendService = "_elrs-joystick._tcp"
+
+// backendBrowseTimeout is how long to wait for answers when discovering
+const backendBrowseTimeout = 2 * time.Second
+
+// DiscoverBackends looks for gRPC backends on the LAN
+func DiscoverBackends() ([]MDNSService, error) {
+	return BrowseMDNS(backendService, backendBrowseTimeout)
+}
+
+// HostPort returns the service address as host:port
+func (s MDNSService) HostPort() string {
+	return net.JoinHostPort(s.Addr.String(), strconv.Itoa(s.Port))
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MSP DisplayPort (MSP v1 command 182) lets a flight controller draw a
+// character OSD on an HDZero/Walksnail VTX, goggles, or an MSP-OSD board.
+// We speak the same protocol so those devices can show the ground station's
+// telemetry instead.
+const (
+	mspDisplayPort = 182
+
+	dpHeartbeat   = 0
+	dpClearScreen = 2
+	dpWriteString = 3
+	dpDrawScreen  = 4
+)
+
+// DisplayPort streams a character OSD canvas over serial or UDP
+type DisplayPort struct {
+	client *GRPCClient
+	config *DisplayPortConfig
+
+	// Home returns the home position, if one is set
+	Home func() (lat, lon float64, ok bool)
+	// Units picks how speeds, altitudes and distances are shown
+	Units func() Units
+	// Charge returns the battery left in percent, see BatteryConfig.Charge
+	Charge func(TelemetryState) uint32
+
+	mu       sync.Mutex
+	out      io.WriteCloser
+	stopChan chan struct{}
+	done     chan struct{}
+}
+
+// NewDisplayPort creates a DisplayPort output using cfg
+func NewDisplayPort(client *GRPCClient, cfg *DisplayPortConfig) *DisplayPort {
+	return &DisplayPort{
+		client: client,
+		config: cfg,
+		Home:   func() (float64, float64, bool) { return 0, 0, false },
+		Units:  func() Units { return UnitsMetric },
+		Charge: func(s TelemetryState) uint32 { return s.Remaining },
+	}
+}
+
+// Start opens the output and begins sending frames
+func (d *DisplayPort) Start() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.out != nil {
+		return nil
+	}
+
+	out, err := openDisplayPortTarget(d.config.Target, d.config.BaudRate)
+	if err != nil {
+		return err
+	}
+	d.out = out
+	d.stopChan = make(chan struct{})
+	d.done = make(chan struct{})
+	go d.run(out, d.stopChan, d.done)
+	logOSDOut.Infof("DisplayPort OSD on %s (%dx%d)", d.config.Target, d.config.Columns, d.config.Rows)
+	return nil
+}
+
+// Stop stops sending and closes the output
+func (d *DisplayPort) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.out == nil {
+		return
+	}
+	close(d.stopChan)
+	<-d.done
+	d.out.Close()
+	d.out = nil
+}
+
+// openDisplayPortTarget opens "udp:host:port" or a serial device path
+func openDisplayPortTarget(target string, baud int) (io.WriteCloser, error) {
+	if addr, ok := strings.CutPrefix(target, "udp:"); ok {
+		return net.Dial("udp", addr)
+	}
+	if target == "" {
+		return nil, fmt.Errorf("no target set")
+	}
+	return openSerial(target, baud)
+}
+
+func (d *DisplayPort) run(out io.Writer, stop, done chan struct{}) {
+	defer close(done)
+	rate := d.config.Rate
+	if rate <= 0 {
+		rate = 10
+	}
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+
+	var failing bool
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		_, err := out.Write(d.frame(d.render()))
+		if err != nil && !failing {
+			logOSDOut.Warnf("DisplayPort write error: %v", err)
+		}
+		failing = err != nil
+	}
+}
+
+// render lays out the telemetry on a Columns x Rows character canvas.
+// Only ASCII is used so it reads the same with any OSD font.
+func (d *DisplayPort) render() [][]byte {
+	cols, rows := d.config.Columns, d.config.Rows
+	canvas := make([][]byte, rows)
+	for i := range canvas {
+		canvas[i] = bytes.Repeat([]byte{' '}, cols)
+	}
+	put := func(row, col int, s string) {
+		if row < 0 || row >= rows {
+			return
+		}
+		if col < 0 {
+			col = cols + col - len(s) + 1 // Negative columns right-align
+		}
+		for i := 0; i < len(s) && col+i < cols; i++ {
+			if col+i >= 0 {
+				canvas[row][col+i] = s[i]
+			}
+		}
+	}
+
+	state := d.client.GetState()
+	units := d.Units()
+	if state.LastUpdate.IsZero() || time.Since(state.LastUpdate) > telemetryTimeout {
+		put(rows/2, (cols-12)/2, "NO TELEMETRY")
+		return canvas
+	}
+
+	// Top: battery, link, satellites
+	put(0, 1, fmt.Sprintf("%.1fV %d%%", state.Voltage, d.Charge(state)))
+	put(0, (cols-8)/2, fmt.Sprintf("LQ %3d%%", state.LinkQuality))
+	put(0, -2, fmt.Sprintf("SAT %d", state.Satellites))
+	put(1, 1, fmt.Sprintf("%.1fA %dMAH", state.Current, state.Capacity))
+	put(1, -2, fmt.Sprintf("%ddBm", state.RSSI1))
+
+	// Middle: speed left, altitude right, heading top center
+	mid := rows / 2
+	put(2, (cols-3)/2, fmt.Sprintf("%03.0f", state.Heading))
+	put(mid, 1, fmt.Sprintf("%.0f%s", units.Speed(float64(state.GroundSpeed)), strings.ToUpper(units.SpeedLabel())))
+	put(mid, -2, fmt.Sprintf("%.0f%s", units.Altitude(relativeAltitude(state)), strings.ToUpper(units.AltitudeLabel())))
+	put(mid+1, -2, fmt.Sprintf("%+.1f", state.VerticalSpeed))
+
+	// Bottom: home distance and bearing, flight mode, position
+	if lat, lon, ok := d.Home(); ok && state.HasGPS {
+		aLat, aLon := float64(state.Latitude), float64(state.Longitude)
+		dist := DistanceMeters(lat, lon, aLat, aLon)
+		put(rows-2, 1, fmt.Sprintf("H %s %03.0f", strings.ToUpper(units.FormatDistance(dist)), BearingDegrees(aLat, aLon, lat, lon)))
+	}
+	put(rows-2, -2, strings.ToUpper(state.FlightMode))
+	put(rows-1, 1, fmt.Sprintf("%.5f %.5f", state.Latitude, state.Longitude))
+	return canvas
+}
+
+// frame encodes one full screen: heartbeat, clear, a write per row, draw
+func (d *DisplayPort) frame(canvas [][]byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(mspFrame(mspDisplayPort, []byte{dpHeartbeat}))
+	buf.Write(mspFrame(mspDisplayPort, []byte{dpClearScreen}))
+	for row, line := range canvas {
+		// Send each run of text on its own; blanks are already cleared
+		for col := 0; col < len(line); {
+			if line[col] == ' ' {
+				col++
+				continue
+			}
+			end := col
+			for end < len(line) && !(line[end] == ' ' && (end+1 >= len(line) || line[end+1] == ' ')) {
+				end++
+			}
+			payload := append([]byte{dpWriteString, byte(row), byte(col), 0}, line[col:end]...)
+			buf.Write(mspFrame(mspDisplayPort, payload))
+			col = end
+		}
+	}
+	buf.Write(mspFrame(mspDisplayPort, []byte{dpDrawScreen}))
+	return buf.Bytes()
+}
+
+// mspFrame wraps a payload in an MSP v1 response frame ($M>)
+func mspFrame(cmd byte, payload []byte) []byte {
+	frame := make([]byte, 0, len(payload)+6)
+	frame = append(frame, '$', 'M', '>', byte(len(payload)), cmd)
+	frame = append(frame, payload...)
+	checksum := byte(len(payload)) ^ cmd
+	for _, b := range payload {
+		checksum ^= b
+	}
+	return append(frame, checksum)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// failoverCheckInterval is how often the backend's health is checked
+	failoverCheckInterval = time.Second
+	// failbackProbeInterval is how often the primary is tried while a
+	// backup feeds telemetry
+	failbackProbeInterval = 30 * time.Second
+)
+
+// BackendFailover moves the client to a backup backend when the one in use
+// stops answering, or stops delivering telemetry while the link is up, and
+// back to the primary once it answers again. The primary is
+// backend.address; backend.backups are tried in order after it.
+type BackendFailover struct {
+	client *GRPCClient
+	config *BackendConfig
+
+	// OnSwitch is called, from the failover goroutine, after the client
+	// moved to another backend, for the caller to restart the link there
+	OnSwitch func(addr, role string, linkWasStarted bool)
+
+	mu        sync.Mutex
+	index     int       // Backend in use: 0 the primary, then the backups
+	since     time.Time // When it was picked
+	delivered bool      // It has delivered telemetry since
+	lastProbe time.Time
+
+	running  bool
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewBackendFailover creates a failover for client's backends
+func NewBackendFailover(client *GRPCClient, cfg *BackendConfig) *BackendFailover {
+	return &BackendFailover{client: client, config: cfg, since: time.Now()}
+}
+
+// Start watches the backend in the background until Stop. Without backups
+// there is nothing to do.
+func (f *BackendFailover) Start() {
+	if f.running || len(f.config.Backups) == 0 {
+		return
+	}
+	f.running = true
+	f.stopChan = make(chan struct{})
+	f.wg.Add(1)
+	go f.run()
+}
+
+// Stop ends the watch
+func (f *BackendFailover) Stop() {
+	if !f.running {
+		return
+	}
+	f.running = false
+	close(f.stopChan)
+	f.wg.Wait()
+}
+
+// Role names the backend in use: "primary" or "backup N". It is empty
+// without backups, when there is nothing to tell apart.
+func (f *BackendFailover) Role() string {
+	if len(f.config.Backups) == 0 {
+		return ""
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return backendRole(f.index)
+}
+
+func backendRole(index int) string {
+	if index == 0 {
+		return "primary"
+	}
+	return fmt.Sprintf("backup %d", index)
+}
+
+// addresses lists the primary then the backups
+func (f *BackendFailover) addresses() []string {
+	return append([]string{f.config.Address}, f.config.Backups...)
+}
+
+func (f *BackendFailover) run() {
+	defer f.wg.Done()
+	ticker := time.NewTicker(failoverCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stopChan:
+			return
+		case <-ticker.C:
+		}
+		f.check()
+	}
+}
+
+// check moves to the next backend if the one in use failed, or back to the
+// primary if it answers again
+func (f *BackendFailover) check() {
+	addrs := f.addresses()
+	f.mu.Lock()
+	// The address changes under us when the user picks another backend;
+	// that one becomes the one in use
+	if cur := f.client.Address(); f.index >= len(addrs) || addrs[f.index] != cur {
+		f.index = 0
+		for i, a := range addrs {
+			if a == cur {
+				f.index = i
+				break
+			}
+		}
+		f.since, f.delivered = time.Now(), false
+	}
+	index, since := f.index, f.since
+	state := f.client.GetState()
+	if state.LastUpdate.After(since) {
+		f.delivered = true
+	}
+	delivered := f.delivered
+	f.mu.Unlock()
+
+	reason := ""
+	switch {
+	case !state.Connected || !f.client.Reachable():
+		// Connecting takes a while; give a new backend that long
+		if time.Since(since) > f.config.FailoverAfter {
+			reason = "not answering"
+		}
+	case state.LinkStarted && delivered && time.Since(state.LastUpdate) > f.config.FailoverAfter:
+		// Only one that has delivered since it was picked, so a silent
+		// aircraft doesn't send us round all the backends
+		reason = "no telemetry"
+	}
+
+	if reason == "" {
+		if index > 0 && time.Since(f.lastProbe) > failbackProbeInterval {
+			f.lastProbe = time.Now()
+			if probeBackend(addrs[0]) {
+				logTelem.Infof("Primary backend %s answers again", addrs[0])
+				f.use(0, state.LinkStarted)
+			}
+		}
+		return
+	}
+
+	logTelem.Warnf("Backend %s (%s): %s, failing over", addrs[index], backendRole(index), reason)
+	for i := 1; i < len(addrs); i++ {
+		next := (index + i) % len(addrs)
+		if f.use(next, state.LinkStarted) {
+			return
+		}
+	}
+	// None answered; stay, and try them all again next time
+	f.mu.Lock()
+	f.since = time.Now()
+	f.mu.Unlock()
+}
+
+// use moves the client to the backend at index, returning false if it
+// can't be reached
+func (f *BackendFailover) use(index int, linkWasStarted bool) bool {
+	addr := f.addresses()[index]
+	f.client.SetAddress(addr)
+	err := f.client.Connect()
+	f.mu.Lock()
+	f.index, f.since, f.delivered = index, time.Now(), false
+	f.mu.Unlock()
+	if err != nil {
+		logTelem.Warnf("Backend %s (%s): %v", addr, backendRole(index), err)
+		return false
+	}
+	f.client.StartTelemetryStream()
+	logTelem.Infof("Telemetry now from %s (%s)", addr, backendRole(index))
+	if f.OnSwitch != nil {
+		f.OnSwitch(addr, backendRole(index), linkWasStarted)
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Failsafe states, as classified from the flight mode
+const (
+	modeNormal   = ""
+	modeFailsafe = "FAILSAFE"
+	modeRTH      = "RTH"
+)
+
+// rthModes are the return-to-home names Betaflight, INAV and ArduPilot send
+var rthModes = map[string]bool{
+	"RTH": true, "RTL": true, "SMRTL": true, "QRTL": true, "AUTORTL": true, "HOME": true,
+}
+
+// classifyFlightMode tells a failsafe or return to home apart from the
+// modes a pilot flies in. Betaflight appends '*' while disarmed and reports
+// failsafe as "!FS!"; ArduPilot and INAV spell it out.
+func classifyFlightMode(mode string) string {
+	m := strings.ToUpper(strings.TrimSpace(strings.TrimSuffix(mode, "*")))
+	switch {
+	case m == "":
+		return modeNormal
+	case strings.Contains(m, "!FS") || strings.Contains(m, "FAILSAFE"):
+		return modeFailsafe
+	case rthModes[strings.ReplaceAll(m, " ", "")]:
+		return modeRTH
+	}
+	return modeNormal
+}
+
+// inavDisarmed are the flight modes INAV sends while disarmed
+var inavDisarmed = map[string]bool{"OK": true, "WAIT": true, "!ERR": true}
+
+// armWatch follows arming through the flight mode. Betaflight marks a
+// disarmed mode with '*' and INAV has its own names for it; other flight
+// controllers don't say, so arming is only reported once a disarmed mode
+// has been seen.
+type armWatch struct {
+	disarmedSeen bool
+	armed        bool
+}
+
+// Update reports whether the aircraft was just armed
+func (w *armWatch) Update(mode string) bool {
+	m := strings.ToUpper(strings.TrimSpace(mode))
+	if m == "" {
+		return false
+	}
+	if strings.HasSuffix(m, "*") || inavDisarmed[m] {
+		w.disarmedSeen, w.armed = true, false
+		return false
+	}
+	armed := w.disarmedSeen && !w.armed
+	w.armed = true
+	return armed
+}
+
+// Reports tells whether the flight mode has shown arming at all
+func (w *armWatch) Reports() bool {
+	return w.disarmedSeen
+}
+
+// FailsafeMonitor follows the flight mode and times how long the aircraft
+// has been in failsafe or returning home
+type FailsafeMonitor struct {
+	mode  string
+	since time.Time
+}
+
+// Update classifies the current flight mode, logging changes, and returns
+// the failsafe state and how long it has lasted
+func (f *FailsafeMonitor) Update(state TelemetryState) (string, time.Duration) {
+	mode := classifyFlightMode(state.FlightMode)
+	if mode != f.mode {
+		switch {
+		case mode != modeNormal:
+			logTelem.Warnf("%s (flight mode %q)", mode, state.FlightMode)
+		case f.mode != modeNormal:
+			logTelem.Infof("%s ended after %s", f.mode, FormatETE(time.Since(f.since)))
+		}
+		f.mode, f.since = mode, time.Now()
+	}
+	if mode == modeNormal {
+		return mode, 0
+	}
+	return mode, time.Since(f.since)
+}
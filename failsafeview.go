@@ -0,0 +1,38 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// drawFailsafeWithOffset draws a banner across the map while the aircraft
+// is in failsafe (red, flashing) or returning home (orange)
+func (a *App) drawFailsafeWithOffset(screen *ebiten.Image, offsetX, mapWidth int) {
+	if a.failsafeMode == modeNormal {
+		return
+	}
+	c := color.RGBA{255, 140, 0, 230}
+	msg := "RETURNING HOME"
+	if a.failsafeMode == modeFailsafe {
+		c = color.RGBA{220, 0, 0, 230}
+		if time.Now().UnixMilli()%1000 < 250 {
+			c = color.RGBA{120, 0, 0, 230}
+		}
+		msg = "FAILSAFE"
+	}
+	msg = fmt.Sprintf("%s  %s", msg, FormatETE(a.failsafeTime))
+	if mode := a.client.GetState().FlightMode; mode != "" {
+		msg += "  (" + mode + ")"
+	}
+
+	y := 68 // Under the legal limit warning
+	vector.DrawFilledRect(screen, float32(offsetX), float32(y), float32(mapWidth), 26, c, false)
+	ebitenutil.DebugPrintAt(screen, msg, offsetX+mapWidth/2-len(msg)*3, y+5)
+}
@@ -0,0 +1,89 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// findStatusTime is how long the beeper result stays under the marker
+const findStatusTime = 8 * time.Second
+
+// findAircraft starts the aircraft's beeper and centers the map on where
+// it was last seen
+func (a *App) findAircraft() {
+	a.centerOnAircraft()
+	a.findStatus, a.findUntil = "Starting beeper...", time.Now().Add(findStatusTime)
+	command := a.config.Find.Command
+	go func() {
+		if err := a.client.RunNamedCommand(command); err != nil {
+			logTelem.Warnf("Find: %v", err)
+			a.findDone <- "No beeper: " + err.Error()
+			return
+		}
+		logTelem.Infof("Find: beeper started")
+		a.findDone <- "Beeper started"
+	}()
+}
+
+// handleFind shows a finished beeper request
+func (a *App) handleFind() {
+	select {
+	case a.findStatus = <-a.findDone:
+		a.findUntil = time.Now().Add(findStatusTime)
+	default:
+	}
+}
+
+// drawLastKnownWithOffset marks where the aircraft was when telemetry
+// stopped, with how long ago that was, to walk to when recovering it
+func (a *App) drawLastKnownWithOffset(screen *ebiten.Image, v MapView) {
+	state := a.client.GetState()
+	if !state.HasGPS || (state.Latitude == 0 && state.Longitude == 0) {
+		return
+	}
+	age := time.Since(state.LastUpdate)
+	finding := time.Now().Before(a.findUntil)
+	if !finding && (state.LastUpdate.IsZero() || age < telemetryTimeout) {
+		return
+	}
+
+	ax, ay := v.LatLonToScreen(float64(state.Latitude), float64(state.Longitude))
+	if !v.ContainsX(ax) {
+		return
+	}
+	sx, sy := float32(ax), float32(ay)
+
+	// Ring pulsing once a second
+	phase := float32(time.Now().UnixMilli()%1000) / 1000
+	vector.StrokeCircle(screen, sx, sy, 14+10*phase, 2, color.RGBA{255, 60, 60, uint8(255 * (1 - phase))}, true)
+	vector.StrokeCircle(screen, sx, sy, 14, 2, color.RGBA{255, 60, 60, 255}, true)
+
+	lines := []string{}
+	if !state.LastUpdate.IsZero() && age >= telemetryTimeout {
+		lines = append(lines, "LAST SEEN "+formatAge(age))
+		if a.homeSet {
+			dist := DistanceMeters(a.homeLat, a.homeLon, float64(state.Latitude), float64(state.Longitude))
+			bearing := BearingDegrees(a.homeLat, a.homeLon, float64(state.Latitude), float64(state.Longitude))
+			lines = append(lines, fmt.Sprintf("%s %03.0f° from home", a.config.Display.Units.FormatDistance(dist), bearing))
+		}
+	}
+	if finding {
+		lines = append(lines, a.findStatus)
+	}
+	w := 0
+	for _, l := range lines {
+		w = max(w, len([]rune(l))*6+8)
+	}
+	for i, l := range lines {
+		y := int(sy) + 20 + 16*i
+		vector.DrawFilledRect(screen, sx-float32(w)/2, float32(y), float32(w), 16, color.RGBA{0, 0, 0, 190}, false)
+		ebitenutil.DebugPrintAt(screen, l, int(sx)-w/2+4, y)
+	}
+}
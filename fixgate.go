@@ -0,0 +1,43 @@
+//go:build !headless
+
+package main
+
+import "time"
+
+// fixGate judges the GPS fix for the trail and auto home. A cold GPS
+// wanders for a while with few satellites, so the trail only starts once
+// the fix has been good for map.fix_settle; after that the fix dropping
+// below map.fix_min_sats marks the trail instead of stopping it.
+type fixGate struct {
+	goodSince time.Time
+	settled   bool
+}
+
+// Update returns whether the fix is good now, and whether it has settled
+// since the session began
+func (g *fixGate) Update(state TelemetryState, cfg *MapConfig) (good, settled bool) {
+	good = state.HasGPS && (state.Latitude != 0 || state.Longitude != 0) &&
+		int(state.Satellites) >= cfg.FixMinSats
+	if !good {
+		g.goodSince = time.Time{}
+		return false, g.settled
+	}
+	if g.goodSince.IsZero() {
+		g.goodSince = time.Now()
+	}
+	if time.Since(g.goodSince) >= cfg.FixSettle {
+		g.settled = true
+	}
+	return true, g.settled
+}
+
+// autoHome sets home from the first good, settled fix, if map.auto_home
+// is on and home isn't set yet
+func (a *App) autoHome(state TelemetryState, ready bool) {
+	if !a.config.Map.AutoHome || a.homeSet || !ready {
+		return
+	}
+	a.setHome(float64(state.Latitude), float64(state.Longitude))
+	a.notify(logApp, LevelInfo, "Home set from the first good fix (%d sats): %.6f, %.6f",
+		state.Satellites, a.homeLat, a.homeLon)
+}
@@ -0,0 +1,72 @@
+//go:build !headless
+
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// gamepadButtons names the buttons of a standard gamepad for input.gamepad
+var gamepadButtons = map[string]ebiten.StandardGamepadButton{
+	"a":     ebiten.StandardGamepadButtonRightBottom,
+	"b":     ebiten.StandardGamepadButtonRightRight,
+	"x":     ebiten.StandardGamepadButtonRightLeft,
+	"y":     ebiten.StandardGamepadButtonRightTop,
+	"lb":    ebiten.StandardGamepadButtonFrontTopLeft,
+	"rb":    ebiten.StandardGamepadButtonFrontTopRight,
+	"lt":    ebiten.StandardGamepadButtonFrontBottomLeft,
+	"rt":    ebiten.StandardGamepadButtonFrontBottomRight,
+	"back":  ebiten.StandardGamepadButtonCenterLeft,
+	"start": ebiten.StandardGamepadButtonCenterRight,
+	"up":    ebiten.StandardGamepadButtonLeftTop,
+	"down":  ebiten.StandardGamepadButtonLeftBottom,
+	"left":  ebiten.StandardGamepadButtonLeftLeft,
+	"right": ebiten.StandardGamepadButtonLeftRight,
+	"ls":    ebiten.StandardGamepadButtonLeftStick,
+	"rs":    ebiten.StandardGamepadButtonRightStick,
+}
+
+// gamepadJustPressed tells whether a button was just pressed on any
+// gamepad with the standard layout
+func gamepadJustPressed(button ebiten.StandardGamepadButton) bool {
+	for _, id := range ebiten.AppendGamepadIDs(nil) {
+		if ebiten.IsStandardGamepadLayoutAvailable(id) && inpututil.IsStandardGamepadButtonJustPressed(id, button) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleGamepad runs the actions input.gamepad binds to the buttons just
+// pressed; an empty action unbinds a default
+func (a *App) handleGamepad() {
+	for name, action := range a.config.Input.Gamepad {
+		button, ok := gamepadButtons[name]
+		if !ok || action == "" || !gamepadJustPressed(button) {
+			continue
+		}
+		if !a.runAction(action) {
+			logApp.Warnf("Gamepad button %s: unknown action %q", name, action)
+		}
+	}
+}
+
+// handleGamepadMenu drives an open menu from the d-pad, A to select and B
+// to go back
+func (a *App) handleGamepadMenu(menu *Menu) {
+	switch {
+	case gamepadJustPressed(ebiten.StandardGamepadButtonLeftTop):
+		menu.Move(-1)
+	case gamepadJustPressed(ebiten.StandardGamepadButtonLeftBottom):
+		menu.Move(1)
+	case gamepadJustPressed(ebiten.StandardGamepadButtonLeftLeft):
+		menu.Adjust(-1)
+	case gamepadJustPressed(ebiten.StandardGamepadButtonLeftRight):
+		menu.Adjust(1)
+	case gamepadJustPressed(ebiten.StandardGamepadButtonRightBottom):
+		menu.Select()
+	case gamepadJustPressed(ebiten.StandardGamepadButtonRightRight):
+		menu.Close()
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import "math"
+
+// Web Mercator tile constants, shared with the config and headless code
+const (
+	TileSize    = 256
+	MaxZoom     = 19
+	MinZoom     = 1
+	DefaultZoom = 15
+)
+
+// earthRadius is the mean Earth radius in meters
+const earthRadius = 6371000.0
+
+// MaxLat is as far north and south as the Web Mercator tiles go; the
+// projection runs off to infinity at the poles
+const MaxLat = 85.05112878
+
+// clampLat keeps a latitude on the tiles
+func clampLat(lat float64) float64 {
+	return math.Max(-MaxLat, math.Min(MaxLat, lat))
+}
+
+// wrapLon brings a longitude into -180 to 180, for positions panned or
+// dragged across the ±180° seam
+func wrapLon(lon float64) float64 {
+	if lon >= -180 && lon < 180 {
+		return lon
+	}
+	lon = math.Mod(lon+180, 360)
+	if lon < 0 {
+		lon += 360
+	}
+	return lon - 180
+}
+
+// wrapNear returns the copy of world pixel x, the world being size pixels
+// around, that is nearest ref, so things either side of the ±180° seam are
+// drawn next to each other rather than a world apart
+func wrapNear(x, ref, size float64) float64 {
+	return x - size*math.Round((x-ref)/size)
+}
+
+// DistanceMeters returns the great-circle distance between two points
+func DistanceMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	// Haversine formula
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLon := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*
+			math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+	return earthRadius * c
+}
+
+// BearingDegrees returns the initial bearing from point 1 to point 2 (0-360)
+func BearingDegrees(lat1, lon1, lat2, lon2 float64) float64 {
+	lat1Rad := lat1 * math.Pi / 180
+	lat2Rad := lat2 * math.Pi / 180
+	dLon := (lon2 - lon1) * math.Pi / 180
+
+	x := math.Sin(dLon) * math.Cos(lat2Rad)
+	y := math.Cos(lat1Rad)*math.Sin(lat2Rad) - math.Sin(lat1Rad)*math.Cos(lat2Rad)*math.Cos(dLon)
+
+	bearing := math.Atan2(x, y) * 180 / math.Pi
+
+	// Normalize to 0-360
+	return math.Mod(bearing+360, 360)
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestWrapLon(t *testing.T) {
+	tests := []struct {
+		lon, want float64
+	}{
+		{0, 0},
+		{179.5, 179.5},
+		{-180, -180},
+		{180, -180},
+		{181, -179},
+		{-181, 179},
+		{359, -1},
+		{540, -180},
+		{-540, -180},
+		{720.5, 0.5},
+	}
+	for _, tt := range tests {
+		if got := wrapLon(tt.lon); got != tt.want {
+			t.Errorf("wrapLon(%v) = %v, want %v", tt.lon, got, tt.want)
+		}
+	}
+}
+
+func TestWrapNear(t *testing.T) {
+	const size = 1024
+	tests := []struct {
+		x, ref, want float64
+	}{
+		{500, 512, 500},       // Already nearest
+		{10, 1000, 1034},      // Just past the seam, drawn after it
+		{1000, 10, -24},       // Just before the seam, drawn before it
+		{10 + 3*size, 10, 10}, // Several worlds away
+		{-2000, 0, 48},
+	}
+	for _, tt := range tests {
+		if got := wrapNear(tt.x, tt.ref, size); got != tt.want {
+			t.Errorf("wrapNear(%v, %v) = %v, want %v", tt.x, tt.ref, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+//go:build !headless
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"strings"
+)
+
+// GeoStyle is how a feature is drawn, from the simplestyle properties
+// (stroke, stroke-width, stroke-opacity, fill, fill-opacity, marker-color)
+// that geojson.io and most GIS exports write
+type GeoStyle struct {
+	Stroke      color.RGBA
+	StrokeWidth float32
+	Fill        color.RGBA // Alpha 0 for none
+	Marker      color.RGBA
+}
+
+// GeoFeature is one drawable shape of a GeoJSON file. Coordinates are
+// [lon, lat] as in the file.
+type GeoFeature struct {
+	Kind  string         // Point, LineString or Polygon; multi-geometries are split
+	Rings [][][2]float64 // One ring for points and lines; outer then holes for polygons
+	Name  string
+	Style GeoStyle
+
+	MinLat, MinLon, MaxLat, MaxLon float64
+}
+
+// GeoLayer is a loaded GeoJSON file
+type GeoLayer struct {
+	Name     string
+	Features []GeoFeature
+}
+
+// defaultGeoStyle is used where a feature has no style properties
+var defaultGeoStyle = GeoStyle{
+	Stroke:      color.RGBA{255, 120, 0, 230},
+	StrokeWidth: 2,
+	Fill:        color.RGBA{255, 120, 0, 50},
+	Marker:      color.RGBA{255, 120, 0, 255},
+}
+
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Features    []geoJSON       `json:"features"`
+	Geometry    *geoJSON        `json:"geometry"`
+	Geometries  []geoJSON       `json:"geometries"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Properties  map[string]any  `json:"properties"`
+}
+
+// LoadGeoJSON reads a FeatureCollection, Feature or bare geometry
+func LoadGeoJSON(path string) (*GeoLayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc geoJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	layer := &GeoLayer{Name: path}
+	if err := layer.add(doc, nil); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(layer.Features) == 0 {
+		return nil, fmt.Errorf("%s: no features", path)
+	}
+	return layer, nil
+}
+
+// add appends the shapes of obj, styled by props from its Feature
+func (l *GeoLayer) add(obj geoJSON, props map[string]any) error {
+	switch obj.Type {
+	case "FeatureCollection":
+		for _, f := range obj.Features {
+			if err := l.add(f, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "Feature":
+		if obj.Geometry == nil {
+			return nil
+		}
+		return l.add(*obj.Geometry, obj.Properties)
+	case "GeometryCollection":
+		for _, g := range obj.Geometries {
+			if err := l.add(g, props); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var rings [][][][2]float64 // Per shape
+	var kind string
+	var err error
+	switch obj.Type {
+	case "Point":
+		var p [2]float64
+		err = json.Unmarshal(obj.Coordinates, &p)
+		kind, rings = "Point", [][][][2]float64{{{p}}}
+	case "MultiPoint", "LineString":
+		var line [][2]float64
+		err = json.Unmarshal(obj.Coordinates, &line)
+		if obj.Type == "LineString" {
+			kind, rings = "LineString", [][][][2]float64{{line}}
+		} else {
+			kind = "Point"
+			for _, p := range line {
+				rings = append(rings, [][][2]float64{{p}})
+			}
+		}
+	case "MultiLineString":
+		var lines [][][2]float64
+		err = json.Unmarshal(obj.Coordinates, &lines)
+		kind = "LineString"
+		for _, line := range lines {
+			rings = append(rings, [][][2]float64{line})
+		}
+	case "Polygon":
+		var poly [][][2]float64
+		err = json.Unmarshal(obj.Coordinates, &poly)
+		kind, rings = "Polygon", [][][][2]float64{poly}
+	case "MultiPolygon":
+		err = json.Unmarshal(obj.Coordinates, &rings)
+		kind = "Polygon"
+	default:
+		return fmt.Errorf("unsupported type %q", obj.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", obj.Type, err)
+	}
+
+	style := geoStyle(props)
+	name := geoName(props)
+	for _, r := range rings {
+		if len(r) == 0 || len(r[0]) == 0 {
+			continue
+		}
+		f := GeoFeature{Kind: kind, Rings: r, Name: name, Style: style,
+			MinLat: math.Inf(1), MinLon: math.Inf(1), MaxLat: math.Inf(-1), MaxLon: math.Inf(-1)}
+		for _, p := range r[0] {
+			f.MinLon, f.MaxLon = math.Min(f.MinLon, p[0]), math.Max(f.MaxLon, p[0])
+			f.MinLat, f.MaxLat = math.Min(f.MinLat, p[1]), math.Max(f.MaxLat, p[1])
+		}
+		l.Features = append(l.Features, f)
+	}
+	return nil
+}
+
+// geoStyle reads the simplestyle properties over the default style
+func geoStyle(props map[string]any) GeoStyle {
+	s := defaultGeoStyle
+	str := func(key string) string { v, _ := props[key].(string); return v }
+	num := func(key string) (float64, bool) { v, ok := props[key].(float64); return v, ok }
+
+	if c, err := parseHexColor(expandHex(str("stroke"))); err == nil {
+		s.Stroke = c
+	}
+	if w, ok := num("stroke-width"); ok {
+		s.StrokeWidth = float32(w)
+	}
+	if o, ok := num("stroke-opacity"); ok {
+		s.Stroke.A = uint8(math.Max(0, math.Min(1, o)) * 255)
+	}
+	if c, err := parseHexColor(expandHex(str("fill"))); err == nil {
+		c.A = s.Fill.A
+		s.Fill = c
+	}
+	if o, ok := num("fill-opacity"); ok {
+		s.Fill.A = uint8(math.Max(0, math.Min(1, o)) * 255)
+	}
+	if c, err := parseHexColor(expandHex(str("marker-color"))); err == nil {
+		s.Marker = c
+	}
+	return s
+}
+
+// expandHex turns "#f80" into "#ff8800", as simplestyle allows both
+func expandHex(s string) string {
+	if len(s) == 4 && s[0] == '#' {
+		return "#" + strings.Repeat(s[1:2], 2) + strings.Repeat(s[2:3], 2) + strings.Repeat(s[3:4], 2)
+	}
+	return s
+}
+
+// geoName returns the feature's label, from the properties tools commonly use
+func geoName(props map[string]any) string {
+	for _, key := range []string{"title", "name", "Name", "NAME"} {
+		if v, ok := props[key].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+//go:build !headless
+
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// geoMaxVertices is the most a single DrawTriangles call takes
+const geoMaxVertices = 65535
+
+// loadOverlays loads the configured GeoJSON files, skipping bad ones
+func (a *App) loadOverlays() {
+	a.overlays = nil
+	for _, path := range a.config.Map.Overlays {
+		layer, err := LoadGeoJSON(path)
+		if err != nil {
+			logApp.Errorf("Could not load overlay: %v", err)
+			continue
+		}
+		logApp.Infof("Overlay %s: %d features", path, len(layer.Features))
+		a.overlays = append(a.overlays, layer)
+	}
+}
+
+// drawOverlaysWithOffset draws the GeoJSON layers over the tiles
+func (a *App) drawOverlaysWithOffset(screen *ebiten.Image, v MapView) {
+	if !a.config.Map.ShowOverlays || len(a.overlays) == 0 {
+		return
+	}
+
+	toScreen := func(lat, lon float64) (float32, float32) {
+		x, y := v.LatLonToScreen(lat, lon)
+		return float32(x), float32(y)
+	}
+	left, right := float32(v.X), float32(v.X+v.Width)
+	bottom := float32(v.Height)
+
+	for _, layer := range a.overlays {
+		for i := range layer.Features {
+			f := &layer.Features[i]
+			// Skip features wholly off screen, going by the corners of
+			// their bounds as the map may be turned
+			x1, y1 := float32(math.Inf(1)), float32(math.Inf(1))
+			x2, y2 := float32(math.Inf(-1)), float32(math.Inf(-1))
+			for _, c := range [4][2]float64{{f.MaxLat, f.MinLon}, {f.MaxLat, f.MaxLon}, {f.MinLat, f.MinLon}, {f.MinLat, f.MaxLon}} {
+				x, y := toScreen(c[0], c[1])
+				x1, y1, x2, y2 = min(x1, x), min(y1, y), max(x2, x), max(y2, y)
+			}
+			if x2 < left || x1 > right || y2 < 0 || y1 > bottom {
+				continue
+			}
+
+			switch f.Kind {
+			case "Point":
+				x, y := toScreen(f.Rings[0][0][1], f.Rings[0][0][0])
+				vector.DrawFilledCircle(screen, x, y, 5, f.Style.Marker, true)
+				vector.StrokeCircle(screen, x, y, 5, 1, color.RGBA{0, 0, 0, 200}, true)
+				if f.Name != "" {
+					ebitenutil.DebugPrintAt(screen, f.Name, int(x)+8, int(y)-8)
+				}
+			case "LineString", "Polygon":
+				a.drawGeoShape(screen, f, toScreen)
+			}
+		}
+	}
+}
+
+// drawGeoShape fills (for polygons) and strokes a line or polygon
+func (a *App) drawGeoShape(screen *ebiten.Image, f *GeoFeature, toScreen func(lat, lon float64) (float32, float32)) {
+	polygon := f.Kind == "Polygon"
+	var path vector.Path
+	for _, ring := range f.Rings {
+		var lastX, lastY float32
+		for i, p := range ring {
+			x, y := toScreen(p[1], p[0])
+			// Points closer than a pixel add vertices without showing
+			if i > 0 && i < len(ring)-1 {
+				if dx, dy := x-lastX, y-lastY; dx*dx+dy*dy < 1 {
+					continue
+				}
+			}
+			if i == 0 {
+				path.MoveTo(x, y)
+			} else {
+				path.LineTo(x, y)
+			}
+			lastX, lastY = x, y
+		}
+		if polygon {
+			path.Close()
+		}
+	}
+
+	if polygon && f.Style.Fill.A > 0 {
+		vs, is := path.AppendVerticesAndIndicesForFilling(nil, nil)
+		if len(vs) > 0 && len(vs) <= geoMaxVertices {
+			drawVertices(screen, vs, is, f.Style.Fill)
+		}
+	}
+	if f.Style.StrokeWidth > 0 && f.Style.Stroke.A > 0 {
+		vs, is := path.AppendVerticesAndIndicesForStroke(nil, nil, &vector.StrokeOptions{Width: f.Style.StrokeWidth, LineJoin: vector.LineJoinRound})
+		if len(vs) > 0 && len(vs) <= geoMaxVertices {
+			drawVertices(screen, vs, is, f.Style.Stroke)
+		}
+	}
+}
@@ -3,8 +3,8 @@ package main
 import (
 	"bufio"
 	"fmt"
-	"log"
 	"os"
+	"runtime"
 	"sync"
 	"time"
 )
@@ -19,8 +19,22 @@ const (
 	GPIO_BTN_FOLLOW  = 24 // Pin 18
 	GPIO_BTN_CLEAR   = 25 // Pin 22
 	GPIO_BTN_MAP     = 5  // Pin 29 - Toggle map source
+	GPIO_BTN_FIND    = 26 // Pin 37 - Start the aircraft's beeper
+
+	// Optional rotary encoder for menu navigation
+	GPIO_ENC_A   = 6  // Pin 31
+	GPIO_ENC_B   = 13 // Pin 33
+	GPIO_ENC_BTN = 19 // Pin 35 - Encoder push switch
+
+	// Status outputs (active high, drive LEDs/buzzer through a resistor or transistor)
+	GPIO_LED_LINK = 16 // Pin 36 - On while telemetry is flowing
+	GPIO_LED_GPS  = 20 // Pin 38 - On with a GPS fix, blinks without
+	GPIO_BUZZER   = 21 // Pin 40 - Beeps on low battery, low LQ, or lost telemetry; fast on failsafe
 )
 
+// telemetryTimeout is how old telemetry may get before the link counts as lost
+const telemetryTimeout = 3 * time.Second
+
 // GPIOButton represents a single GPIO button
 type GPIOButton struct {
 	pin        int
@@ -31,9 +45,22 @@ type GPIOButton struct {
 	onPress    func()
 }
 
-// GPIOController manages GPIO button inputs
+// GPIOEncoder is a quadrature rotary encoder on two GPIO pins
+type GPIOEncoder struct {
+	pinA, pinB int
+	lastAB     int
+	steps      int // Quarter steps since the last detent
+	onTurn     func(delta int)
+}
+
+// encoderTransitions maps (previous AB << 2 | current AB) to a quarter step
+var encoderTransitions = [16]int{0, -1, 1, 0, 1, 0, 0, -1, -1, 0, 0, 1, 0, 1, -1, 0}
+
+// GPIOController manages GPIO button inputs and status outputs
 type GPIOController struct {
 	buttons  []*GPIOButton
+	encoder  *GPIOEncoder
+	outputs  map[int]bool // Pin -> last written level
 	enabled  bool
 	mu       sync.Mutex
 	stopChan chan struct{}
@@ -43,6 +70,7 @@ type GPIOController struct {
 func NewGPIOController() *GPIOController {
 	return &GPIOController{
 		buttons:  make([]*GPIOButton, 0),
+		outputs:  map[int]bool{GPIO_LED_LINK: false, GPIO_LED_GPS: false, GPIO_BUZZER: false},
 		stopChan: make(chan struct{}),
 	}
 }
@@ -57,81 +85,50 @@ func (g *GPIOController) AddButton(pin int, name string, onPress func()) {
 	})
 }
 
-// SetupDefaultButtons configures standard button mappings
-func (g *GPIOController) SetupDefaultButtons(app *App) {
-	g.AddButton(GPIO_BTN_HOME, "HOME", func() {
-		state := app.client.GetState()
-		if state.HasGPS && (state.Latitude != 0 || state.Longitude != 0) {
-			app.homeLat = float64(state.Latitude)
-			app.homeLon = float64(state.Longitude)
-			app.homeSet = true
-			log.Printf("Home set: %.6f, %.6f", app.homeLat, app.homeLon)
-		}
-	})
-
-	g.AddButton(GPIO_BTN_LINK, "LINK", func() {
-		if app.client.IsLinkStarted() {
-			app.client.StopLink()
-		} else if len(app.ports) > 0 && app.selectedPort < len(app.ports) {
-			app.client.StartLink(app.ports[app.selectedPort], 420000)
-		}
-	})
-
-	g.AddButton(GPIO_BTN_ZOOMIN, "ZOOM+", func() {
-		if app.zoom < MaxZoom {
-			app.zoom++
-		}
-	})
-
-	g.AddButton(GPIO_BTN_ZOOMOUT, "ZOOM-", func() {
-		if app.zoom > MinZoom {
-			app.zoom--
-		}
-	})
-
-	g.AddButton(GPIO_BTN_FOLLOW, "FOLLOW", func() {
-		app.followAircraft = !app.followAircraft
-		log.Printf("Follow mode: %v", app.followAircraft)
-	})
-
-	g.AddButton(GPIO_BTN_CLEAR, "CLEAR", func() {
-		app.flightPath = nil
-		log.Println("Flight path cleared")
-	})
-
-	g.AddButton(GPIO_BTN_MAP, "MAP", func() {
-		source := app.tileManager.ToggleSource()
-		log.Printf("Map source: %s", app.tileManager.SourceName())
-		_ = source
-	})
+// SetEncoder configures a rotary encoder; onTurn receives +1/-1 per detent
+func (g *GPIOController) SetEncoder(pinA, pinB int, onTurn func(delta int)) {
+	g.encoder = &GPIOEncoder{pinA: pinA, pinB: pinB, lastAB: 3, onTurn: onTurn}
 }
 
 // Start begins polling GPIO pins
 func (g *GPIOController) Start() error {
 	// Check if we're on a Raspberry Pi by checking for GPIO sysfs
+	if runtime.GOOS != "linux" {
+		logGPIO.Infof("GPIO is only available on Linux - GPIO buttons disabled")
+		return nil
+	}
 	if _, err := os.Stat("/sys/class/gpio"); os.IsNotExist(err) {
-		log.Println("GPIO not available (not running on Pi?) - GPIO buttons disabled")
+		logGPIO.Infof("GPIO not available (not running on Pi?) - GPIO buttons disabled")
 		return nil
 	}
 
 	// Export and configure pins
-	for _, btn := range g.buttons {
-		if err := g.exportPin(btn.pin); err != nil {
-			log.Printf("Warning: Could not export GPIO %d: %v", btn.pin, err)
+	for _, pin := range g.inputPins() {
+		if err := g.exportPin(pin); err != nil {
+			logGPIO.Warnf("Could not export GPIO %d: %v", pin, err)
 			continue
 		}
-		if err := g.setDirection(btn.pin, "in"); err != nil {
-			log.Printf("Warning: Could not set GPIO %d direction: %v", btn.pin, err)
+		if err := g.setDirection(pin, "in"); err != nil {
+			logGPIO.Warnf("Could not set GPIO %d direction: %v", pin, err)
 			continue
 		}
 		// Enable pull-up (buttons connect to ground)
 		// Note: This requires /sys/class/gpio/gpioX/active_low or device tree config
 		// For simplicity, we assume active-low buttons (pressed = 0)
 	}
+	for pin := range g.outputs {
+		if err := g.exportPin(pin); err != nil {
+			logGPIO.Warnf("Could not export GPIO %d: %v", pin, err)
+			continue
+		}
+		if err := g.setDirection(pin, "low"); err != nil {
+			logGPIO.Warnf("Could not set GPIO %d direction: %v", pin, err)
+		}
+	}
 
 	g.enabled = true
 	go g.pollLoop()
-	log.Println("GPIO controller started")
+	logGPIO.Infof("GPIO controller started")
 	return nil
 }
 
@@ -142,9 +139,76 @@ func (g *GPIOController) Stop() {
 		g.enabled = false
 
 		// Unexport pins
-		for _, btn := range g.buttons {
-			g.unexportPin(btn.pin)
+		for _, pin := range g.inputPins() {
+			g.unexportPin(pin)
 		}
+		for pin := range g.outputs {
+			g.writePin(pin, false)
+			g.unexportPin(pin)
+		}
+	}
+}
+
+// inputPins returns every pin used by buttons and the encoder
+func (g *GPIOController) inputPins() []int {
+	var pins []int
+	for _, btn := range g.buttons {
+		pins = append(pins, btn.pin)
+	}
+	if g.encoder != nil {
+		pins = append(pins, g.encoder.pinA, g.encoder.pinB)
+	}
+	return pins
+}
+
+// UpdateIndicators drives the status LEDs and buzzer from telemetry
+func (g *GPIOController) UpdateIndicators(state TelemetryState, alerts AlertConfig, battery BatteryConfig) {
+	if !g.enabled {
+		return
+	}
+
+	now := time.Now()
+	receiving := state.Connected && !state.LastUpdate.IsZero() && now.Sub(state.LastUpdate) < telemetryTimeout
+	blink := now.UnixMilli()%1000 < 500
+	beep := now.UnixMilli()%1000 < 150
+
+	alarm := false
+	if state.LinkStarted && !receiving {
+		alarm = true
+	}
+	if receiving && state.LinkQuality < uint32(alerts.LQLowPct) {
+		alarm = true
+	}
+	batt := battery.Evaluate(state, alerts.BatteryLowPct)
+	if receiving && batt.Level >= BatteryLow {
+		alarm = true
+	}
+	if receiving && alerts.MaxAltitude > 0 && relativeAltitude(state) > alerts.MaxAltitude {
+		alarm = true
+	}
+
+	buzz := alarm && beep
+	// Failsafe, return to home and a pack to land now beep fast, over the
+	// other warnings
+	if receiving && (classifyFlightMode(state.FlightMode) != modeNormal || batt.Level == BatteryLand) {
+		buzz = now.UnixMilli()%300 < 150
+	}
+
+	g.setOutput(GPIO_LED_LINK, receiving)
+	g.setOutput(GPIO_LED_GPS, receiving && (state.HasGPS && state.Satellites >= uint32(alerts.MinSats) || blink))
+	g.setOutput(GPIO_BUZZER, buzz)
+}
+
+// setOutput writes an output pin, skipping the write if the level is unchanged
+func (g *GPIOController) setOutput(pin int, on bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if last, ok := g.outputs[pin]; ok && last == on {
+		return
+	}
+	if err := g.writePin(pin, on); err == nil {
+		g.outputs[pin] = on
 	}
 }
 
@@ -181,12 +245,43 @@ func (g *GPIOController) pollButtons() {
 				btn.lastChange = now
 
 				// Trigger on press (not release)
+				if pressed {
+					logGPIO.Debugf("%s pressed (GPIO %d)", btn.name, btn.pin)
+				}
 				if pressed && btn.onPress != nil {
 					btn.onPress()
 				}
 			}
 		}
 	}
+
+	g.pollEncoder()
+}
+
+func (g *GPIOController) pollEncoder() {
+	enc := g.encoder
+	if enc == nil {
+		return
+	}
+	a, errA := g.readPin(enc.pinA)
+	b, errB := g.readPin(enc.pinB)
+	if errA != nil || errB != nil {
+		return
+	}
+
+	ab := a<<1 | b
+	enc.steps += encoderTransitions[enc.lastAB<<2|ab]
+	enc.lastAB = ab
+
+	// Most encoders click once per four quarter steps, resting at AB=11
+	if ab == 3 && enc.steps != 0 {
+		if enc.steps >= 2 && enc.onTurn != nil {
+			enc.onTurn(1)
+		} else if enc.steps <= -2 && enc.onTurn != nil {
+			enc.onTurn(-1)
+		}
+		enc.steps = 0
+	}
 }
 
 // GPIO sysfs helpers
@@ -230,6 +325,15 @@ func (g *GPIOController) setDirection(pin int, direction string) error {
 	return os.WriteFile(path, []byte(direction), 0644)
 }
 
+func (g *GPIOController) writePin(pin int, on bool) error {
+	path := fmt.Sprintf("/sys/class/gpio/gpio%d/value", pin)
+	value := "0"
+	if on {
+		value = "1"
+	}
+	return os.WriteFile(path, []byte(value), 0644)
+}
+
 func (g *GPIOController) readPin(pin int) (int, error) {
 	path := fmt.Sprintf("/sys/class/gpio/gpio%d/value", pin)
 	f, err := os.Open(path)
@@ -251,6 +355,9 @@ func (g *GPIOController) readPin(pin int) (int, error) {
 
 // IsAvailable returns true if GPIO is available on this system
 func (g *GPIOController) IsAvailable() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
 	_, err := os.Stat("/sys/class/gpio")
 	return err == nil
 }
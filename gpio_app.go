@@ -0,0 +1,49 @@
+//go:build !headless
+
+package main
+
+// SetupDefaultButtons configures standard button mappings
+func (g *GPIOController) SetupDefaultButtons(app *App) {
+	// Buttons are read on the poll goroutine, so their actions wait for
+	// the game loop
+	for _, b := range []struct {
+		pin          int
+		name, action string
+	}{
+		{GPIO_BTN_HOME, "HOME", "set_home"},
+		{GPIO_BTN_LINK, "LINK", "link"},
+		{GPIO_BTN_ZOOMIN, "ZOOM+", "zoom_in"},
+		{GPIO_BTN_ZOOMOUT, "ZOOM-", "zoom_out"},
+		{GPIO_BTN_FOLLOW, "FOLLOW", "follow"},
+		{GPIO_BTN_CLEAR, "CLEAR", "clear_path"},
+		{GPIO_BTN_MAP, "MAP", "map_source"},
+		{GPIO_BTN_FIND, "FIND", "find"},
+	} {
+		b := b
+		g.AddButton(b.pin, b.name, func() {
+			if err := app.queueAction(b.action); err != nil {
+				logGPIO.Warnf("%s: %v", b.name, err)
+			}
+		})
+	}
+
+	// Encoder: push opens settings or selects, turning scrolls or zooms
+	g.AddButton(GPIO_ENC_BTN, "ENC", func() {
+		if err := app.queueAction("menu_select"); err != nil {
+			logGPIO.Warnf("ENC: %v", err)
+		}
+	})
+
+	g.SetEncoder(GPIO_ENC_A, GPIO_ENC_B, func(delta int) {
+		action := "menu_down"
+		if delta < 0 {
+			action, delta = "menu_up", -delta
+		}
+		for ; delta > 0; delta-- {
+			if err := app.queueAction(action); err != nil {
+				logGPIO.Warnf("ENC: %v", err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,216 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	// gridMinSpacing is the closest grid lines get, in pixels; the grid
+	// steps up to coarser spacing before that
+	gridMinSpacing = 100
+
+	// gridSegments is how many straight pieces draw each UTM grid line,
+	// which curves slightly on the Mercator map
+	gridSegments = 16
+
+	// gridMaxLines caps the lines drawn each way, for a zoomed-out world map
+	gridMaxLines = 100
+)
+
+// gridModes are the map.grid settings, in the order the key cycles them
+var gridModes = []string{"off", "latlon", "mgrs"}
+
+var (
+	// graticuleSteps are the lat/lon grid spacings in degrees
+	graticuleSteps = []float64{30, 10, 5, 2, 1, 0.5, 0.2, 0.1, 0.05, 0.02, 0.01, 0.005, 0.002, 0.001, 0.0005, 0.0002, 0.0001}
+
+	// utmGridSteps are the MGRS grid spacings in meters, each a figure
+	// more of the grid reference
+	utmGridSteps = []float64{100000, 10000, 1000, 100}
+
+	gridLineColor  = color.RGBA{255, 255, 255, 110}
+	gridLabelColor = color.RGBA{0, 0, 0, 150}
+)
+
+// drawGridWithOffset draws the coordinate grid chosen with map.grid over
+// the tiles, with a legend giving its spacing and the aircraft's position
+// on it, for calling positions over the radio
+func (a *App) drawGridWithOffset(screen *ebiten.Image, v MapView) {
+	var legend []string
+	switch a.config.Map.Grid {
+	case "latlon":
+		legend = a.drawGraticule(screen, v)
+	case "mgrs":
+		legend = a.drawUTMGrid(screen, v)
+	}
+	if len(legend) == 0 {
+		return
+	}
+
+	w := 0
+	for _, l := range legend {
+		w = max(w, len([]rune(l))*6+8)
+	}
+	x, y := v.X+v.Width-w-5, 5
+	if a.config.Map.Attribution == "top-right" {
+		_, _, _, _, h := a.attributionLayout(v.X, v.Width)
+		y += h
+	}
+	vector.DrawFilledRect(screen, float32(x), float32(y), float32(w), float32(16*len(legend)+4), color.RGBA{0, 0, 0, 180}, false)
+	for i, l := range legend {
+		ebitenutil.DebugPrintAt(screen, l, x+4, y+2+16*i)
+	}
+}
+
+// drawGraticule draws meridians and parallels at a round spacing in degrees
+func (a *App) drawGraticule(screen *ebiten.Image, v MapView) []string {
+	degPerPixel := 360 / v.WorldSize()
+	step := graticuleSteps[0]
+	for _, s := range graticuleSteps {
+		if s/degPerPixel < gridMinSpacing {
+			break
+		}
+		step = s
+	}
+	decimals := max(0, int(math.Ceil(-math.Log10(step)-1e-9)))
+
+	// The lat/lon the map area spans, from its corners as it may be turned
+	left, top, right, bottom := v.visible()
+	north, west := math.Inf(-1), math.Inf(1)
+	south, east := math.Inf(1), math.Inf(-1)
+	for _, c := range [4][2]float64{{left, top}, {right, top}, {left, bottom}, {right, bottom}} {
+		lat, lon := v.ScreenToLatLon(c[0], c[1])
+		north, south = math.Max(north, lat), math.Min(south, lat)
+		east, west = math.Max(east, lon), math.Min(west, lon)
+	}
+	for k := math.Ceil(west / step); k*step <= east && k-math.Ceil(west/step) < gridMaxLines; k++ {
+		lon := k * step
+		gridLine(screen, v, formatGridDegrees(lon, decimals, "E", "W"), 1, func(t float64) (float64, float64) {
+			return north + (south-north)*t, lon
+		})
+	}
+	for k := math.Ceil(south / step); k*step <= north && k-math.Ceil(south/step) < gridMaxLines; k++ {
+		lat := k * step
+		gridLine(screen, v, formatGridDegrees(lat, decimals, "N", "S"), 1, func(t float64) (float64, float64) {
+			return lat, west + (east-west)*t
+		})
+	}
+
+	legend := []string{fmt.Sprintf("Grid %g°", step)}
+	if state := a.client.GetState(); state.HasGPS {
+		legend = append(legend, fmt.Sprintf("AC %.5f %.5f", state.Latitude, state.Longitude))
+	}
+	return legend
+}
+
+// drawUTMGrid draws the MGRS grid of the zone at the map's center, labeled
+// with the figures each line adds to a grid reference
+func (a *App) drawUTMGrid(screen *ebiten.Image, v MapView) []string {
+	if mgrsBand(a.centerLat) == 0 {
+		return []string{"No MGRS grid near the poles"}
+	}
+	zone, south := utmZone(a.centerLat, a.centerLon), a.centerLat < 0
+
+	// The map area's extent on the grid, from points around its edge
+	left, top, right, bottom := v.visible()
+	minE, minN := math.Inf(1), math.Inf(1)
+	maxE, maxN := math.Inf(-1), math.Inf(-1)
+	for i := 0; i <= 2; i++ {
+		for j := 0; j <= 2; j++ {
+			lat, lon := v.ScreenToLatLon(left+(right-left)*float64(i)/2, top+(bottom-top)*float64(j)/2)
+			u := toUTMZone(lat, lon, zone, south)
+			minE, maxE = math.Min(minE, u.Easting), math.Max(maxE, u.Easting)
+			minN, maxN = math.Min(minN, u.Northing), math.Max(maxN, u.Northing)
+		}
+	}
+
+	metersPerPixel := v.MetersPerPixel(a.centerLat)
+	step := utmGridSteps[0]
+	for _, s := range utmGridSteps {
+		if s/metersPerPixel < gridMinSpacing {
+			break
+		}
+		step = s
+	}
+	if (maxE-minE)/step > gridMaxLines || (maxN-minN)/step > gridMaxLines {
+		return nil
+	}
+
+	// Eastings from the north, northings from the west, so each is labeled
+	// where it enters the map on that side
+	for e := math.Ceil(minE/step) * step; e <= maxE; e += step {
+		gridLine(screen, v, utmGridLabel(e, step), gridSegments, func(t float64) (float64, float64) {
+			return UTM{Zone: zone, South: south, Easting: e, Northing: maxN - (maxN-minN)*t}.LatLon()
+		})
+	}
+	for n := math.Ceil(minN/step) * step; n <= maxN; n += step {
+		gridLine(screen, v, utmGridLabel(n, step), gridSegments, func(t float64) (float64, float64) {
+			return UTM{Zone: zone, South: south, Easting: minE + (maxE-minE)*t, Northing: n}.LatLon()
+		})
+	}
+
+	spacing := fmt.Sprintf("%g m", step)
+	if step >= 1000 {
+		spacing = fmt.Sprintf("%g km", step/1000)
+	}
+	legend := []string{fmt.Sprintf("MGRS %s  %s grid", FormatMGRS(a.centerLat, a.centerLon, 0), spacing)}
+	if state := a.client.GetState(); state.HasGPS {
+		legend = append(legend, "AC "+FormatMGRS(float64(state.Latitude), float64(state.Longitude), 5))
+	}
+	return legend
+}
+
+// gridLine draws a grid line through at(t) for t from 0 to 1 in segments
+// straight pieces, labeled where it first enters the map
+func gridLine(screen *ebiten.Image, v MapView, label string, segments int, at func(t float64) (lat, lon float64)) {
+	var px, py float64
+	labeled := false
+	for i := 0; i <= segments; i++ {
+		x, y := v.LatLonToScreen(at(float64(i) / float64(segments)))
+		if i > 0 {
+			vector.StrokeLine(screen, float32(px), float32(py), float32(x), float32(y), 1, gridLineColor, false)
+		}
+		// Rounded, as a line starting on the edge may land a hair outside
+		if !labeled && v.Contains(math.Round(x), math.Round(y)) {
+			gridLabel(screen, label, x+3, y+3)
+			labeled = true
+		}
+		px, py = x, y
+	}
+}
+
+// utmGridLabel is the part of a grid reference a line at v gives at this
+// spacing: "3" at 10 km, "34" at 1 km, "345" at 100 m. 100 km lines are
+// labeled in km, as the square letters already say which they are.
+func utmGridLabel(v, step float64) string {
+	digits := int(math.Round(math.Log10(100000 / step)))
+	if digits == 0 {
+		return fmt.Sprintf("%.0fkm", v/1000)
+	}
+	return fmt.Sprintf("%0*d", digits, int(v)%100000/int(step))
+}
+
+// formatGridDegrees writes a graticule line's latitude or longitude with
+// its hemisphere, e.g. 47.05°W
+func formatGridDegrees(v float64, decimals int, pos, neg string) string {
+	hemi := pos
+	if v < 0 {
+		hemi = neg
+	}
+	return fmt.Sprintf("%.*f°%s", decimals, math.Abs(v), hemi)
+}
+
+// gridLabel prints a grid line's label on a dark box so it reads over imagery
+func gridLabel(screen *ebiten.Image, text string, x, y float64) {
+	w := len([]rune(text))*6 + 4
+	vector.DrawFilledRect(screen, float32(x), float32(y), float32(w), 16, gridLabelColor, false)
+	ebitenutil.DebugPrintAt(screen, text, int(x)+2, int(y))
+}
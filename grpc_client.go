@@ -2,21 +2,58 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	pb "elrs-map/proto"
-
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
-// TelemetryState holds the latest telemetry data
-type TelemetryState struct {
-	sync.RWMutex
+// DefaultBaudRate is the CRSF rate used by ELRS TX modules
+const DefaultBaudRate = 420000
+
+// errNotConnected is returned by requests made before Connect
+var errNotConnected = errors.New("not connected to the backend")
+
+// errBackendTooOld is returned by requests for a feature the backend
+// doesn't have
+var errBackendTooOld = errors.New("the backend is too old for this: update elrs-joystick-control")
+
+// BackendCaps is what the connected backend can do, found out at connect
+// time so optional features can be turned off instead of failing on
+// requests it doesn't know
+type BackendCaps struct {
+	Version      string // Empty when it can't tell
+	DeviceParams bool   // Reading and setting CRSF device parameters: bind, VTX, beeper
+	Telemetry    bool   // The telemetry stream
+}
+
+// Missing lists the features the backend lacks
+func (b BackendCaps) Missing() []string {
+	var missing []string
+	if !b.Telemetry {
+		missing = append(missing, "telemetry")
+	}
+	if !b.DeviceParams {
+		missing = append(missing, "device parameters")
+	}
+	return missing
+}
+
+// allCaps is a backend with every feature, such as the simulator
+var allCaps = BackendCaps{Version: "simulator", DeviceParams: true, Telemetry: true}
 
+// BaudRates lists the selectable serial speeds
+var BaudRates = []int32{115200, 400000, 420000, 921600, 1870000, 3750000, 5250000}
+
+// TelemetryState holds the latest telemetry data. The client publishes
+// it as an immutable snapshot, so readers never wait on the telemetry
+// goroutine.
+type TelemetryState struct {
 	// GPS
 	Latitude    float32
 	Longitude   float32
@@ -36,6 +73,9 @@ type TelemetryState struct {
 	Current   float32
 	Capacity  uint32
 	Remaining uint32
+	// Pack voltage at plug-in: the first reading after connecting or a
+	// pack change, which the cell count is worked out from
+	PackVoltage float32
 
 	// Link stats
 	RSSI1       int32
@@ -51,143 +91,295 @@ type TelemetryState struct {
 	// Flight mode
 	FlightMode string
 
+	// Height the tapes and alerts use, from the source SetAltitudeSource
+	// picked, less the last ZeroAltitude. Empty RefSource where no client
+	// worked it out, e.g. in a replayed log.
+	RefAltitude float32
+	RefSource   string
+
 	// Connection state
 	Connected   bool
 	LinkStarted bool
 	LastUpdate  time.Time
 }
 
-// GRPCClient manages connection to the elrs-joystick-control backend
+// GRPCClient is the app's telemetry client. It connects to a
+// TelemetrySource, by default the elrs-joystick-control backend over gRPC,
+// and keeps the telemetry state from the frames it streams.
 type GRPCClient struct {
-	addr   string
-	conn   *grpc.ClientConn
-	client pb.JoystickControlClient
-
-	state     *TelemetryState
+	addr      string
+	source    TelemetrySource
+	newSource func(addr string) TelemetrySource // For SetAddress
+	connected bool
+
+	state     *TelemetryState                // Working copy, under stateMu
+	snapshot  atomic.Pointer[TelemetryState] // Last published state, never modified
+	stateMu   sync.Mutex
+	caps      BackendCaps
 	ctx       context.Context
 	cancel    context.CancelFunc
 	streaming bool
 	mu        sync.Mutex
+
+	// Telemetry stream watchdog, under mu
+	stallTimeout time.Duration
+	restarts     int64
+	// OnStreamRestart is called when a stalled stream was restarted,
+	// with how long it had been quiet. Set it before streaming.
+	OnStreamRestart func(quiet time.Duration)
+
+	frames   map[string]int64 // Frames received per kind, under the state lock
+	lastLink time.Time        // Last link statistics frame, under the state lock
+	linkGap  time.Duration    // Longest gap between them since TakeLinkGap
+
+	// Heading from the magnetometer instead of GPS course, under the state lock
+	magHeading  bool
+	declination float64 // Degrees east, added to the magnetometer yaw
+
+	// Heading at low speed, where the GPS course is noise, under the state
+	// lock: the last one from above slowKmh, or the yaw with slowYaw
+	slowKmh     float32
+	slowYaw     bool
+	slow        bool
+	heldHeading float32
+
+	// Altitude for the tapes and alerts, under the state lock
+	altitude altitudeRef
+
+	// Battery sensor calibration and the consumed capacity recounted with
+	// it, under the state lock
+	voltageCal, currentCal SensorCalibration
+	rawMAh                 uint32 // Last capacity the FC reported
+	hasRawMAh              bool
+	usedMAh                float64
+	lastBattery            time.Time
 }
 
-// NewGRPCClient creates a new gRPC client
-func NewGRPCClient(addr string) *GRPCClient {
+// newClient creates a client fed by source
+func newClient(addr string, source TelemetrySource) *GRPCClient {
 	return &GRPCClient{
-		addr:  addr,
-		state: &TelemetryState{},
+		addr:   addr,
+		source: source,
+		state:  &TelemetryState{},
 	}
 }
 
-// Connect establishes connection to the gRPC server
+// NewReplayClient creates a client fed with a recorded flight through Feed
+func NewReplayClient(name string) *GRPCClient {
+	return newClient(name, replaySource{})
+}
+
+// Connect reaches the telemetry source
 func (c *GRPCClient) Connect() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-
-	if c.conn != nil {
-		return nil // Already connected
+	if c.connected {
+		return nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	conn, err := grpc.DialContext(ctx, c.addr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithBlock(),
-	)
+	caps, err := c.source.Connect()
 	if err != nil {
 		return err
 	}
-
-	c.conn = conn
-	c.client = pb.NewJoystickControlClient(conn)
+	c.caps, c.connected = caps, true
+	c.stateMu.Lock()
 	c.state.Connected = true
-	log.Printf("Connected to gRPC server at %s", c.addr)
+	c.state.PackVoltage = 0 // Maybe another aircraft, count its cells again
+	c.publish()
+	c.stateMu.Unlock()
 	return nil
 }
 
-// Disconnect closes the gRPC connection
-func (c *GRPCClient) Disconnect() {
+// Caps returns what the connected backend can do
+func (c *GRPCClient) Caps() BackendCaps {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.caps
+}
+
+// commands returns what takes link commands, or why there is nothing
+func (c *GRPCClient) commands() (LinkCommands, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if !c.connected {
+		return nil, errNotConnected
+	}
+	if cmds := c.source.Commands(); cmds != nil {
+		return cmds, nil
+	}
+	return nil, errNoCommands
+}
 
+// deviceCommands returns what takes device parameter requests, or why
+// there is nothing
+func (c *GRPCClient) deviceCommands() (LinkCommands, error) {
+	cmds, err := c.commands()
+	if err == nil && !c.Caps().DeviceParams {
+		return nil, errBackendTooOld
+	}
+	return cmds, err
+}
+
+// Disconnect closes the connection to the telemetry source
+func (c *GRPCClient) Disconnect() {
 	c.StopTelemetryStream()
 
-	if c.conn != nil {
-		c.conn.Close()
-		c.conn = nil
-		c.client = nil
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.connected {
+		c.source.Close()
+		c.connected = false
 	}
+	c.stateMu.Lock()
 	c.state.Connected = false
+	c.publish()
+	c.stateMu.Unlock()
+}
+
+// Reachable returns false once a connected source stops answering, e.g. a
+// backend gRPC is still reconnecting to
+func (c *GRPCClient) Reachable() bool {
+	c.mu.Lock()
+	source := c.source
+	c.mu.Unlock()
+	return source.Reachable()
+}
+
+// Address returns the backend address
+func (c *GRPCClient) Address() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.addr
+}
+
+// SetAddress switches to another backend. The current one is disconnected;
+// call Connect to reach the new one.
+func (c *GRPCClient) SetAddress(addr string) {
+	c.Disconnect()
+	c.mu.Lock()
+	c.addr = addr
+	if c.newSource != nil {
+		c.source = c.newSource(addr)
+	}
+	c.mu.Unlock()
+}
+
+// HasSerialPorts tells whether the link goes through a TX module on a
+// serial port, which a linked source such as the simulator only pretends
+func (c *GRPCClient) HasSerialPorts() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, linked := c.source.(linkedSource)
+	return !linked
 }
 
 // GetTransmitters returns available serial ports
 func (c *GRPCClient) GetTransmitters() ([]string, error) {
-	if c.client == nil {
-		return nil, nil
+	cmds, err := c.commands()
+	if err != nil {
+		return nil, err
 	}
+	return cmds.Transmitters()
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// AppInfo returns the backend's version
+func (c *GRPCClient) AppInfo() (string, error) {
+	cmds, err := c.commands()
+	if err != nil {
+		return "", err
+	}
+	return cmds.AppInfo()
+}
 
-	resp, err := c.client.GetTransmitters(ctx, &pb.Empty{})
+// Devices returns the CRSF devices the backend found (the TX module, and
+// the receiver and flight controller while the link is up) with their
+// parameters
+func (c *GRPCClient) Devices() ([]CRSFDevice, error) {
+	cmds, err := c.deviceCommands()
 	if err != nil {
 		return nil, err
 	}
+	return cmds.Devices()
+}
 
-	var ports []string
-	for _, t := range resp.Transmitters {
-		ports = append(ports, t.Port)
+// RunCommand starts a device's command parameter, such as the TX module's
+// Bind, confirming it if the device asks
+func (c *GRPCClient) RunCommand(deviceID, fieldID uint32) error {
+	cmds, err := c.deviceCommands()
+	if err != nil {
+		return err
 	}
-	return ports, nil
+	return cmds.RunCommand(deviceID, fieldID)
 }
 
-// StartLink begins communication with the ELRS TX
-func (c *GRPCClient) StartLink(port string, baudRate int32) error {
-	if c.client == nil {
-		return nil
+// RunNamedCommand runs the command parameter called name on the first
+// device past the TX module that has one, e.g. a beeper the flight
+// controller or receiver offers over CRSF
+func (c *GRPCClient) RunNamedCommand(name string) error {
+	devices, err := c.Devices()
+	if err != nil {
+		return err
+	}
+	for _, d := range devices {
+		if d.ID == crsfAddrTX {
+			continue
+		}
+		if p, ok := d.Param(name); ok && p.Type == crsfTypeCommand {
+			return c.RunCommand(d.ID, p.ID)
+		}
 	}
+	return fmt.Errorf("no device on the link offers a %q command", name)
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// SetParam sets a device's selection or number parameter
+func (c *GRPCClient) SetParam(deviceID, fieldID uint32, value byte) error {
+	cmds, err := c.deviceCommands()
+	if err != nil {
+		return err
+	}
+	return cmds.SetParam(deviceID, fieldID, value)
+}
 
-	_, err := c.client.StartLink(ctx, &pb.StartLinkReq{
-		Port:     port,
-		BaudRate: baudRate,
-	})
+// StartLink begins communication with the ELRS TX
+func (c *GRPCClient) StartLink(port string, baudRate int32) error {
+	cmds, err := c.commands()
 	if err != nil {
 		return err
 	}
+	if err := cmds.StartLink(port, baudRate); err != nil {
+		return err
+	}
 
-	c.state.Lock()
-	c.state.LinkStarted = true
-	c.state.Unlock()
+	c.setLinkStarted(true)
 
-	log.Printf("Link started on %s @ %d baud", port, baudRate)
+	logTelem.Infof("Link started on %s @ %d baud", port, baudRate)
 	return nil
 }
 
 // StopLink stops communication with the ELRS TX
 func (c *GRPCClient) StopLink() error {
-	if c.client == nil {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err := c.client.StopLink(ctx, &pb.Empty{})
+	cmds, err := c.commands()
 	if err != nil {
 		return err
 	}
+	if err := cmds.StopLink(); err != nil {
+		return err
+	}
 
-	c.state.Lock()
-	c.state.LinkStarted = false
-	c.state.Unlock()
+	c.setLinkStarted(false)
 
-	log.Println("Link stopped")
+	logTelem.Infof("Link stopped")
 	return nil
 }
 
+func (c *GRPCClient) setLinkStarted(started bool) {
+	c.stateMu.Lock()
+	c.state.LinkStarted = started
+	c.publish()
+	c.stateMu.Unlock()
+}
+
 // StartTelemetryStream begins streaming telemetry data
 func (c *GRPCClient) StartTelemetryStream() error {
 	c.mu.Lock()
@@ -197,8 +389,12 @@ func (c *GRPCClient) StartTelemetryStream() error {
 	}
 	c.streaming = true
 	c.ctx, c.cancel = context.WithCancel(context.Background())
+	source := c.source
 	c.mu.Unlock()
 
+	if l, ok := source.(linkedSource); ok && l.LinkUp() {
+		c.setLinkStarted(true)
+	}
 	go c.streamTelemetry()
 	return nil
 }
@@ -217,47 +413,116 @@ func (c *GRPCClient) StopTelemetryStream() {
 func (c *GRPCClient) streamTelemetry() {
 	for {
 		c.mu.Lock()
-		if !c.streaming || c.client == nil {
+		if !c.streaming || !c.connected {
 			c.mu.Unlock()
 			return
 		}
 		ctx := c.ctx
-		client := c.client
+		source := c.source
 		c.mu.Unlock()
 
-		stream, err := client.GetTelemetryStream(ctx, &pb.Empty{})
-		if err != nil {
-			log.Printf("Telemetry stream error: %v", err)
+		stalled, err := c.readStream(ctx, source)
+		switch {
+		case ctx.Err() != nil:
+			return // Context cancelled, exit gracefully
+		case errors.Is(err, errBackendTooOld):
+			// Retrying won't help
+			c.mu.Lock()
+			c.caps.Telemetry = false
+			c.mu.Unlock()
+			logTelem.Errorf("Backend at %s has no telemetry stream: update elrs-joystick-control", c.Address())
+			return
+		case stalled > 0:
+			c.mu.Lock()
+			c.restarts++
+			c.mu.Unlock()
+			logTelem.Warnf("Telemetry stream stalled, no frames for %s: restarting it", stalled.Round(time.Second))
+			if c.OnStreamRestart != nil {
+				c.OnStreamRestart(stalled)
+			}
+		default:
+			if err != io.EOF {
+				logTelem.Warnf("Telemetry stream error: %v", err)
+			}
+			// Don't spin on a backend that ends every stream at once
 			time.Sleep(time.Second)
-			continue
 		}
+	}
+}
 
-		for {
-			telem, err := stream.Recv()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				if ctx.Err() != nil {
-					return // Context cancelled, exit gracefully
+// readStream streams the source's telemetry until the stream ends. A
+// stream that delivered frames and then goes quiet for the stall timeout
+// while the link is up is torn down; a wedged backend stream would
+// otherwise freeze the data for good. It returns how long such a stream
+// had been quiet.
+func (c *GRPCClient) readStream(ctx context.Context, source TelemetrySource) (time.Duration, error) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var lastFrame, stalled atomic.Int64 // Unix nanoseconds, and a duration
+	c.mu.Lock()
+	timeout := c.stallTimeout
+	c.mu.Unlock()
+	if timeout > 0 {
+		go func() {
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-streamCtx.Done():
+					return
+				case <-ticker.C:
+				}
+				// A silent aircraft on a fresh stream isn't a stall
+				last := lastFrame.Load()
+				if last == 0 || !c.IsLinkStarted() {
+					continue
+				}
+				if quiet := time.Since(time.Unix(0, last)); quiet > timeout {
+					stalled.Store(int64(quiet))
+					cancel()
+					return
 				}
-				log.Printf("Telemetry recv error: %v", err)
-				break
 			}
+		}()
+	}
 
-			c.processTelemetry(telem)
-		}
+	err := source.Stream(streamCtx, func(t *pb.Telemetry) {
+		lastFrame.Store(time.Now().UnixNano())
+		c.processTelemetry(t)
+	})
+	if quiet := stalled.Load(); quiet > 0 {
+		return time.Duration(quiet), nil
 	}
+	return 0, err
+}
+
+// SetStallTimeout sets how long a telemetry stream that was delivering may
+// go quiet, with the link up, before it is restarted; 0 never restarts it
+func (c *GRPCClient) SetStallTimeout(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stallTimeout = d
+}
+
+// StreamRestarts returns how many stalled telemetry streams were restarted
+func (c *GRPCClient) StreamRestarts() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.restarts
 }
 
 func (c *GRPCClient) processTelemetry(t *pb.Telemetry) {
-	c.state.Lock()
-	defer c.state.Unlock()
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	defer c.publish()
 
 	c.state.LastUpdate = time.Now()
 
+	var kind string
 	switch data := t.Data.(type) {
 	case *pb.Telemetry_Gps:
+		kind = "gps"
 		c.state.Latitude = data.Gps.Latitude
 		c.state.Longitude = data.Gps.Longitude
 		c.state.Altitude = data.Gps.Altitude
@@ -267,17 +532,26 @@ func (c *GRPCClient) processTelemetry(t *pb.Telemetry) {
 		c.state.HasGPS = true
 
 	case *pb.Telemetry_Attitude:
+		kind = "attitude"
 		c.state.Pitch = data.Attitude.Pitch
 		c.state.Roll = data.Attitude.Roll
 		c.state.Yaw = data.Attitude.Yaw
 
 	case *pb.Telemetry_Battery:
-		c.state.Voltage = data.Battery.Voltage
-		c.state.Current = data.Battery.Current
-		c.state.Capacity = data.Battery.Capacity
+		kind = "battery"
+		newPack := c.hasRawMAh && data.Battery.Capacity < c.rawMAh
+		c.state.Voltage = c.voltageCal.Apply(data.Battery.Voltage)
+		c.notePack(newPack)
+		c.state.Current = max(0, c.currentCal.Apply(data.Battery.Current))
+		c.state.Capacity = c.calibratedMAh(data.Battery.Capacity)
 		c.state.Remaining = data.Battery.Remaining
 
 	case *pb.Telemetry_LinkStats:
+		kind = "link"
+		if !c.lastLink.IsZero() {
+			c.linkGap = max(c.linkGap, c.state.LastUpdate.Sub(c.lastLink))
+		}
+		c.lastLink = c.state.LastUpdate
 		c.state.RSSI1 = data.LinkStats.Rssi1
 		c.state.RSSI2 = data.LinkStats.Rssi2
 		c.state.LinkQuality = data.LinkStats.LinkQuality
@@ -285,37 +559,212 @@ func (c *GRPCClient) processTelemetry(t *pb.Telemetry) {
 		c.state.TXPower = data.LinkStats.TxPower
 
 	case *pb.Telemetry_Barometer:
+		kind = "baro"
 		c.state.BaroAltitude = data.Barometer.Altitude
 
 	case *pb.Telemetry_Variometer:
+		kind = "vario"
 		c.state.VerticalSpeed = data.Variometer.VerticalSpeed
 
 	case *pb.Telemetry_BarometerVariometer:
+		kind = "baro"
 		c.state.BaroAltitude = data.BarometerVariometer.Altitude
 		c.state.VerticalSpeed = data.BarometerVariometer.VerticalSpeed
 
 	case *pb.Telemetry_FlightMode:
+		kind = "mode"
 		c.state.FlightMode = data.FlightMode.Mode
 	}
+	if kind != "" {
+		if c.frames == nil {
+			c.frames = make(map[string]int64)
+		}
+		c.frames[kind]++
+	}
+}
+
+// TakeLinkGap returns the longest wait between link statistics frames since
+// the last call, counting the current wait if none arrived since. ELRS sends
+// them at a fixed rate, so the gap grows as packets are lost.
+func (c *GRPCClient) TakeLinkGap() time.Duration {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	gap := c.linkGap
+	if !c.lastLink.IsZero() {
+		gap = max(gap, time.Since(c.lastLink))
+	}
+	c.linkGap = 0
+	return gap
+}
+
+// Ping measures the round trip of a request to the backend
+func (c *GRPCClient) Ping() (time.Duration, error) {
+	start := time.Now()
+	if _, err := c.AppInfo(); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// FrameCounts returns how many telemetry frames of each kind have arrived
+func (c *GRPCClient) FrameCounts() map[string]int64 {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	counts := make(map[string]int64, len(c.frames))
+	for k, n := range c.frames {
+		counts[k] = n
+	}
+	return counts
 }
 
-// GetState returns a copy of the current telemetry state
+// publish makes the working copy the state readers see, with the heading
+// picked by SetHeading and SetSlowHeading and the altitude by
+// SetAltitudeSource. Called under stateMu after every change.
+func (c *GRPCClient) publish() {
+	s := *c.state
+	c.altitude.update(&s)
+	c.altitude.apply(&s)
+	// Slow below the threshold, fast again only well above it, so a speed
+	// around it doesn't flip the heading back and forth
+	switch {
+	case c.slowKmh <= 0:
+		c.slow = false
+	case s.GroundSpeed < c.slowKmh:
+		c.slow = true
+	case s.GroundSpeed > c.slowKmh*1.5:
+		c.slow = false
+	}
+	if !c.slow {
+		c.heldHeading = s.Heading
+	}
+	switch {
+	case c.magHeading || (c.slow && c.slowYaw):
+		s.Heading = float32(math.Mod(float64(s.Yaw)+c.declination+720, 360))
+	case c.slow:
+		s.Heading = c.heldHeading
+	}
+	c.snapshot.Store(&s)
+}
+
+// published returns the last published state; it must not be modified
+func (c *GRPCClient) published() *TelemetryState {
+	if s := c.snapshot.Load(); s != nil {
+		return s
+	}
+	return &TelemetryState{}
+}
+
+// GetState returns a copy of the current telemetry state. It takes no
+// lock, so the draw loop can call it as often as it likes.
 func (c *GRPCClient) GetState() TelemetryState {
-	c.state.RLock()
-	defer c.state.RUnlock()
-	return *c.state
+	return *c.published()
+}
+
+// SetHeading picks the heading GetState reports: GPS course, or the
+// magnetometer yaw made true with declination
+func (c *GRPCClient) SetHeading(magnetometer bool, declination float64) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.magHeading == magnetometer && c.declination == declination {
+		return
+	}
+	c.magHeading, c.declination = magnetometer, declination
+	c.publish()
+}
+
+// SetSlowHeading picks the heading below kmh ground speed, where the GPS
+// course is noise and would spin the icon: the last heading from faster,
+// or with yaw the magnetometer's. 0 keeps the GPS course at any speed.
+func (c *GRPCClient) SetSlowHeading(kmh float64, yaw bool) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.slowKmh == float32(kmh) && c.slowYaw == yaw {
+		return
+	}
+	c.slowKmh, c.slowYaw = float32(kmh), yaw
+	c.publish()
+}
+
+// SetBatteryCalibration corrects the voltage and current the FC reports
+// from now on
+func (c *GRPCClient) SetBatteryCalibration(voltage, current SensorCalibration) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.voltageCal, c.currentCal = voltage, current
+}
+
+// calibratedMAh recounts the consumed capacity with the current
+// calibration: the FC's count since the last frame is scaled, and the
+// offset added over the time between them. The FC's count going down is a
+// new pack. Called under the state lock.
+func (c *GRPCClient) calibratedMAh(raw uint32) uint32 {
+	now := time.Now()
+	if !c.hasRawMAh || raw < c.rawMAh {
+		c.usedMAh = float64(raw) * c.currentCal.scale()
+	} else {
+		c.usedMAh += float64(raw-c.rawMAh)*c.currentCal.scale() + c.currentCal.Offset*now.Sub(c.lastBattery).Hours()*1000
+	}
+	c.rawMAh, c.hasRawMAh, c.lastBattery = raw, true, now
+	if c.currentCal.Identity() {
+		return raw
+	}
+	return uint32(max(0, c.usedMAh))
+}
+
+// notePack keeps the voltage the pack was plugged in at, taking the
+// current one when there was none yet or newPack says the pack was
+// swapped. Called under the state lock.
+func (c *GRPCClient) notePack(newPack bool) {
+	switch {
+	case c.state.Voltage < 2:
+		c.state.PackVoltage = 0 // Unplugged, or no voltage sensor
+	case c.state.PackVoltage == 0 || newPack:
+		c.state.PackVoltage = c.state.Voltage
+	}
+}
+
+// RestoreState loads telemetry saved by an earlier session, so the last known
+// position shows until fresh data arrives. Connection state is untouched.
+func (c *GRPCClient) RestoreState(s *TelemetryState) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	if !c.state.LastUpdate.IsZero() {
+		return // Live telemetry already arrived
+	}
+	defer c.publish()
+	c.state.Latitude, c.state.Longitude, c.state.Altitude = s.Latitude, s.Longitude, s.Altitude
+	c.state.GroundSpeed, c.state.Heading, c.state.Satellites, c.state.HasGPS = s.GroundSpeed, s.Heading, s.Satellites, s.HasGPS
+	c.state.Pitch, c.state.Roll, c.state.Yaw = s.Pitch, s.Roll, s.Yaw
+	c.state.Voltage, c.state.Current, c.state.Capacity, c.state.Remaining = s.Voltage, s.Current, s.Capacity, s.Remaining
+	c.state.RSSI1, c.state.RSSI2, c.state.LinkQuality, c.state.SNR, c.state.TXPower = s.RSSI1, s.RSSI2, s.LinkQuality, s.SNR, s.TXPower
+	c.state.BaroAltitude, c.state.VerticalSpeed, c.state.FlightMode = s.BaroAltitude, s.VerticalSpeed, s.FlightMode
+}
+
+// Feed replaces the telemetry with a recorded sample, as if it had just
+// arrived over a started link
+func (c *GRPCClient) Feed(s TelemetryState) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	defer c.publish()
+	c.state.Latitude, c.state.Longitude, c.state.Altitude = s.Latitude, s.Longitude, s.Altitude
+	c.state.GroundSpeed, c.state.Heading, c.state.Satellites, c.state.HasGPS = s.GroundSpeed, s.Heading, s.Satellites, s.HasGPS
+	c.state.Pitch, c.state.Roll, c.state.Yaw = s.Pitch, s.Roll, s.Yaw
+	newPack := s.Capacity < c.state.Capacity
+	c.state.Voltage, c.state.Current, c.state.Capacity, c.state.Remaining = s.Voltage, s.Current, s.Capacity, s.Remaining
+	c.notePack(newPack)
+	c.state.RSSI1, c.state.RSSI2, c.state.LinkQuality, c.state.SNR, c.state.TXPower = s.RSSI1, s.RSSI2, s.LinkQuality, s.SNR, s.TXPower
+	c.state.BaroAltitude, c.state.VerticalSpeed, c.state.FlightMode = s.BaroAltitude, s.VerticalSpeed, s.FlightMode
+	c.state.Connected, c.state.LinkStarted = true, true
+	c.state.LastUpdate = time.Now()
 }
 
 // IsConnected returns true if connected to the gRPC server
 func (c *GRPCClient) IsConnected() bool {
-	c.state.RLock()
-	defer c.state.RUnlock()
-	return c.state.Connected
+	return c.published().Connected
 }
 
 // IsLinkStarted returns true if the ELRS link is active
 func (c *GRPCClient) IsLinkStarted() bool {
-	c.state.RLock()
-	defer c.state.RUnlock()
-	return c.state.LinkStarted
+	return c.published().LinkStarted
 }
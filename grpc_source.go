@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	pb "elrs-map/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// grpcSource is elrs-joystick-control, which owns the TX module's serial
+// port and serves its telemetry and commands over gRPC
+type grpcSource struct {
+	addr string
+
+	mu     sync.Mutex
+	conn   *grpc.ClientConn
+	client pb.JoystickControlClient
+}
+
+func newGRPCSource(addr string) *grpcSource {
+	return &grpcSource{addr: addr}
+}
+
+// Connect dials the backend and finds out what it can do
+func (g *grpcSource) Connect() (BackendCaps, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.conn != nil {
+		return negotiate(g.client), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, g.addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		return BackendCaps{}, err
+	}
+
+	g.conn = conn
+	g.client = pb.NewJoystickControlClient(conn)
+	caps := negotiate(g.client)
+	version := caps.Version
+	if version == "" {
+		version = "unknown version"
+	}
+	logTelem.Infof("Connected to gRPC server at %s (%s)", g.addr, version)
+	if missing := caps.Missing(); len(missing) > 0 {
+		logTelem.Warnf("Backend at %s is too old for %s: update elrs-joystick-control", g.addr, strings.Join(missing, " and "))
+	}
+	return caps, nil
+}
+
+// negotiate asks a backend its version and tries the optional requests,
+// a backend without one answering Unimplemented
+func negotiate(client pb.JoystickControlClient) BackendCaps {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	caps := BackendCaps{DeviceParams: true, Telemetry: true}
+	if info, err := client.GetAppInfo(ctx, &pb.Empty{}); err == nil {
+		caps.Version = info.Version
+	}
+	if _, err := client.GetCRSFDevices(ctx, &pb.Empty{}); status.Code(err) == codes.Unimplemented {
+		caps.DeviceParams = false
+	}
+	// The telemetry stream is only known once it is opened
+	return caps
+}
+
+// Close closes the connection
+func (g *grpcSource) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.conn != nil {
+		g.conn.Close()
+		g.conn = nil
+		g.client = nil
+	}
+}
+
+// Reachable returns false once a connected backend stops answering. gRPC
+// keeps reconnecting underneath, so the client stays connected meanwhile.
+func (g *grpcSource) Reachable() bool {
+	g.mu.Lock()
+	conn := g.conn
+	g.mu.Unlock()
+	if conn == nil {
+		return false
+	}
+	s := conn.GetState()
+	return s != connectivity.TransientFailure && s != connectivity.Shutdown
+}
+
+// probeBackend tells whether the backend at addr answers, on a connection
+// of its own
+func probeBackend(addr string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	_, err = pb.NewJoystickControlClient(conn).GetAppInfo(ctx, &pb.Empty{})
+	return err == nil
+}
+
+// rpc returns the client for a request
+func (g *grpcSource) rpc() (pb.JoystickControlClient, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.client == nil {
+		return nil, errNotConnected
+	}
+	return g.client, nil
+}
+
+// Stream reads the backend's telemetry stream until it ends. A backend
+// without one is too old.
+func (g *grpcSource) Stream(ctx context.Context, frame func(*pb.Telemetry)) error {
+	client, err := g.rpc()
+	if err != nil {
+		return err
+	}
+	stream, err := client.GetTelemetryStream(ctx, &pb.Empty{})
+	if err != nil {
+		return streamError(err)
+	}
+	logTelem.Debugf("Telemetry stream opened")
+	for {
+		telem, err := stream.Recv()
+		if err != nil {
+			return streamError(err)
+		}
+		frame(telem)
+	}
+}
+
+// streamError tells a backend without the stream from other failures
+func streamError(err error) error {
+	if status.Code(err) == codes.Unimplemented {
+		return fmt.Errorf("telemetry stream: %w", errBackendTooOld)
+	}
+	return err
+}
+
+// Commands returns the backend, which passes commands on to the TX module
+func (g *grpcSource) Commands() LinkCommands {
+	return g
+}
+
+// Transmitters returns the serial ports with a TX module
+func (g *grpcSource) Transmitters() ([]string, error) {
+	client, err := g.rpc()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := client.GetTransmitters(ctx, &pb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+
+	var ports []string
+	for _, t := range resp.Transmitters {
+		ports = append(ports, t.Port)
+	}
+	return ports, nil
+}
+
+// AppInfo returns the backend's version
+func (g *grpcSource) AppInfo() (string, error) {
+	client, err := g.rpc()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := client.GetAppInfo(ctx, &pb.Empty{})
+	if err != nil {
+		return "", err
+	}
+	if resp.BuildTime != "" {
+		return resp.Version + " (" + resp.BuildTime + ")", nil
+	}
+	return resp.Version, nil
+}
+
+// StartLink opens the TX module's serial port
+func (g *grpcSource) StartLink(port string, baudRate int32) error {
+	client, err := g.rpc()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = client.StartLink(ctx, &pb.StartLinkReq{
+		Port:     port,
+		BaudRate: baudRate,
+	})
+	return err
+}
+
+// StopLink closes the TX module's serial port
+func (g *grpcSource) StopLink() error {
+	client, err := g.rpc()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = client.StopLink(ctx, &pb.Empty{})
+	return err
+}
+
+// Devices returns the CRSF devices the backend found with their parameters
+func (g *grpcSource) Devices() ([]CRSFDevice, error) {
+	client, err := g.rpc()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := client.GetCRSFDevices(ctx, &pb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	var devices []CRSFDevice
+	for _, d := range resp.Devices {
+		dev := CRSFDevice{ID: d.DeviceId, Name: d.Name}
+		fields, err := client.GetCRSFDeviceFields(ctx, &pb.GetCRSFDeviceFieldsReq{DeviceId: d.DeviceId})
+		if err != nil {
+			logTelem.Warnf("Could not read parameters of %s: %v", d.Name, err)
+		} else {
+			for _, f := range fields.Fields {
+				if p, err := parseCRSFParam(f.FieldId, f.Data); err == nil {
+					dev.Params = append(dev.Params, p)
+				}
+			}
+		}
+		devices = append(devices, dev)
+	}
+	return devices, nil
+}
+
+// RunCommand starts a command parameter, confirming it if the device asks
+func (g *grpcSource) RunCommand(deviceID, fieldID uint32) error {
+	client, err := g.rpc()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := setField(ctx, client, deviceID, fieldID, crsfCmdStart); err != nil {
+		return err
+	}
+
+	resp, err := client.GetCRSFDeviceField(ctx, &pb.GetCRSFDeviceFieldReq{DeviceId: deviceID, FieldId: fieldID})
+	if err != nil || resp.Field == nil {
+		return nil // Started; some commands finish before they can be read back
+	}
+	if p, err := parseCRSFParam(fieldID, resp.Field.Data); err == nil && p.Status == crsfCmdAsk {
+		return setField(ctx, client, deviceID, fieldID, crsfCmdConfirm)
+	}
+	return nil
+}
+
+// SetParam sets a selection or number parameter
+func (g *grpcSource) SetParam(deviceID, fieldID uint32, value byte) error {
+	client, err := g.rpc()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return setField(ctx, client, deviceID, fieldID, value)
+}
+
+func setField(ctx context.Context, client pb.JoystickControlClient, deviceID, fieldID uint32, value byte) error {
+	resp, err := client.SetCRSFDeviceField(ctx, &pb.SetCRSFDeviceFieldReq{DeviceId: deviceID, FieldId: fieldID, Data: []byte{value}})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("device 0x%02X refused field %d", deviceID, fieldID)
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Headless runs the ground station without a display: it keeps the backend
+// connected, records telemetry, serves the web map (if enabled), and drives
+// the GPIO LEDs/buzzer. Useful for a small logger box or a Pi Zero with no
+// screen attached.
+type Headless struct {
+	client         *GRPCClient
+	config         *Config
+	recorder       *Recorder
+	uploader       *Uploader
+	logbook        *Logbook
+	webServer      *WebServer
+	tiles          *TileCacheReader
+	buddyShare     *BuddyShare
+	failover       *BackendFailover
+	mqtt           *MQTTPublisher
+	share          *SharePublisher
+	statusLine     *StatusLine
+	plugins        *PluginHost
+	displayPort    *DisplayPort
+	gpioController *GPIOController
+	failsafe       FailsafeMonitor
+	heading        *HeadingCorrector
+	armWatch       armWatch
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewHeadless creates a headless runner
+func NewHeadless(client *GRPCClient, cfg *Config) *Headless {
+	h := &Headless{
+		client:         client,
+		config:         cfg,
+		recorder:       NewRecorder(client, &cfg.Record),
+		uploader:       NewUploader(&cfg.Upload, &cfg.Record),
+		logbook:        NewLogbook(LogbookPath()),
+		webServer:      NewWebServer(client, cfg),
+		tiles:          NewTileCacheReader(cfg.Tiles.Cache, cfg.CacheDir),
+		buddyShare:     NewBuddyShare(client, &cfg.Buddy),
+		failover:       NewBackendFailover(client, &cfg.Backend),
+		mqtt:           NewMQTTPublisher(client, &cfg.MQTT),
+		statusLine:     NewStatusLine(client, cfg),
+		plugins:        NewPluginHost(client, cfg),
+		displayPort:    NewDisplayPort(client, &cfg.OSDOut),
+		gpioController: NewGPIOController(),
+		heading:        NewHeadingCorrector(&cfg.Heading),
+		stopChan:       make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	h.webServer.Tiles = h.tiles
+	h.displayPort.Charge = func(s TelemetryState) uint32 { return cfg.Battery.Charge(s) }
+	h.mqtt.Charge = h.displayPort.Charge
+	h.share = NewSharePublisher(h.webServer, &cfg.Share)
+	h.failover.OnSwitch = func(addr, role string, linkWasStarted bool) {
+		if linkWasStarted {
+			h.startLink()
+		}
+	}
+	h.uploader.Active = h.recorder.Active
+	h.logbook.Log = h.recorder.Active
+	h.setupButtons()
+	return h
+}
+
+// setupButtons maps the GPIO buttons that make sense without a screen
+func (h *Headless) setupButtons() {
+	h.gpioController.AddButton(GPIO_BTN_LINK, "LINK", func() {
+		if h.client.IsLinkStarted() {
+			h.client.StopLink()
+		} else {
+			h.startLink()
+		}
+	})
+	h.gpioController.AddButton(GPIO_BTN_FIND, "FIND", func() {
+		go func() {
+			if err := h.client.RunNamedCommand(h.config.Find.Command); err != nil {
+				logGPIO.Warnf("Find: %v", err)
+			} else {
+				logGPIO.Infof("Find: beeper started")
+			}
+		}()
+	})
+}
+
+// Run blocks until Shutdown is called
+func (h *Headless) Run() error {
+	defer close(h.done)
+	defer h.release()
+
+	if err := h.gpioController.Start(); err != nil {
+		logGPIO.Errorf("GPIO controller error: %v", err)
+	}
+	if err := h.recorder.Start(); err != nil {
+		logRec.Errorf("Could not start recorder: %v", err)
+	}
+	if h.config.Web.Enabled {
+		h.webServer.Start()
+	}
+	if h.config.Buddy.Enabled {
+		if err := h.buddyShare.Start(); err != nil {
+			logBuddy.Errorf("Could not start position sharing: %v", err)
+		}
+	}
+	if h.config.MQTT.Enabled {
+		h.mqtt.Start()
+	}
+	h.failover.Start()
+	if h.config.Share.Enabled {
+		h.share.Start()
+	}
+	if h.config.Status.Enabled {
+		h.statusLine.Start()
+	}
+	h.plugins.Start()
+	if h.config.Upload.Enabled {
+		h.uploader.Start()
+	}
+	if h.config.OSDOut.Enabled {
+		if err := h.displayPort.Start(); err != nil {
+			logOSDOut.Errorf("Could not start DisplayPort output: %v", err)
+		}
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	var lastConnect, lastStatus time.Time
+
+	for {
+		select {
+		case <-h.stopChan:
+			return nil
+		case <-ticker.C:
+		}
+
+		// Keep retrying the backend; the logger box may boot before it
+		if !h.client.IsConnected() && time.Since(lastConnect) > 5*time.Second {
+			if err := h.client.Connect(); err != nil {
+				logTelem.Warnf("Could not connect to backend: %v", err)
+				if h.config.Backend.Discover {
+					h.discoverBackend()
+				}
+			} else {
+				h.client.StartTelemetryStream()
+				h.startLink()
+			}
+			// Connect blocks for up to its timeout, so wait from when it returned
+			lastConnect = time.Now()
+		}
+
+		state := h.client.GetState()
+		h.gpioController.UpdateIndicators(state, h.config.Alerts, h.config.Battery)
+		h.logbook.Update(state, h.config.Aircraft.Profile)
+		h.failsafe.Update(state)
+		h.heading.Update(h.client, state, h.config.Map.DefaultLat, h.config.Map.DefaultLon)
+		h.client.SetBatteryCalibration(h.config.Aircraft.Calibration())
+		h.client.SetSlowHeading(h.config.Aircraft.SlowHeading())
+		h.client.SetAltitudeSource(h.config.Altitude.Source)
+		if h.armWatch.Update(state.FlightMode) && h.config.Altitude.ZeroOnArm {
+			h.client.ZeroAltitude(false)
+		}
+
+		if time.Since(lastStatus) > 10*time.Second {
+			lastStatus = time.Now()
+			h.logStatus(state)
+		}
+	}
+}
+
+// discoverBackend switches to the first backend found on the LAN; the
+// next retry connects to it
+func (h *Headless) discoverBackend() {
+	found, err := DiscoverBackends()
+	if err != nil {
+		logTelem.Warnf("Backend discovery failed: %v", err)
+		return
+	}
+	if len(found) == 0 {
+		return
+	}
+	if len(found) > 1 {
+		logTelem.Infof("Found %d backends, using the first", len(found))
+	}
+	addr := found[0].HostPort()
+	if addr != h.client.Address() {
+		logTelem.Infof("Using backend %s", found[0])
+		h.client.SetAddress(addr)
+		h.config.Backend.Address = addr
+	}
+}
+
+// startLink starts the link on the saved port, or the first one found
+func (h *Headless) startLink() {
+	ports, err := h.client.GetTransmitters()
+	if err != nil {
+		logTelem.Errorf("Could not list transmitters: %s", describeLinkError(err, "", h.client.Address()))
+		return
+	}
+	if len(ports) == 0 {
+		logTelem.Warnf("No transmitters found")
+		return
+	}
+
+	port := ports[0]
+	for _, p := range ports {
+		if p == h.config.Backend.Port {
+			port = p
+			break
+		}
+	}
+	if err := h.client.StartLink(port, h.config.Backend.BaudRate); err != nil {
+		logTelem.Errorf("Could not start link on %s: %s", port, describeLinkError(err, port, h.client.Address()))
+	}
+}
+
+// logStatus prints a one-line summary so the console shows signs of life
+func (h *Headless) logStatus(state TelemetryState) {
+	if !state.Connected {
+		logApp.Infof("Status: backend disconnected")
+		return
+	}
+	if role := h.failover.Role(); role != "" {
+		logApp.Infof("Status: telemetry from %s (%s backend)", h.client.Address(), role)
+	}
+	if state.LastUpdate.IsZero() || time.Since(state.LastUpdate) > telemetryTimeout {
+		logApp.Infof("Status: link=%v, no telemetry", state.LinkStarted)
+		return
+	}
+	logApp.Infof("Status: LQ %d%% RSSI %d dBm, %.2fV %d%%, GPS %v (%d sats) %.6f,%.6f alt %dm",
+		state.LinkQuality, state.RSSI1, state.Voltage, h.config.Battery.Charge(state),
+		state.HasGPS, state.Satellites, state.Latitude, state.Longitude, state.Altitude)
+}
+
+// Shutdown stops the run loop and waits for it to release everything
+func (h *Headless) Shutdown() {
+	h.stopOnce.Do(func() { close(h.stopChan) })
+	<-h.done
+}
+
+// release disconnects GPIO and the backend, then stops recording, in the
+// same order as the UI's Shutdown
+func (h *Headless) release() {
+	h.gpioController.Stop()
+	h.buddyShare.Stop()
+	h.failover.Stop()
+	h.mqtt.Stop()
+	h.share.Stop()
+	h.statusLine.Stop()
+	h.plugins.Stop()
+	h.uploader.Stop()
+	h.displayPort.Stop()
+	if h.config.Web.Enabled {
+		h.webServer.Stop()
+	}
+	h.tiles.Close()
+	h.client.StopLink()
+	h.client.Disconnect()
+	h.recorder.Stop()
+	h.logbook.Close()
+}
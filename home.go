@@ -0,0 +1,147 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// setHome moves home to lat, lon, noting when for the restart prompt
+func (a *App) setHome(lat, lon float64) {
+	a.homeLat, a.homeLon, a.homeSet = lat, lon, true
+	a.homeSetAt = time.Now()
+}
+
+// publishHome shares home with the other goroutines, if it changed
+func (a *App) publishHome() {
+	home := HomeConfig{Set: a.homeSet, Lat: a.homeLat, Lon: a.homeLon}
+	if cur := a.sharedHome.Load(); cur != nil && *cur == home {
+		return
+	}
+	a.sharedHome.Store(&home)
+}
+
+// loadHome returns home as last published; safe from any goroutine
+func (a *App) loadHome() (lat, lon float64, ok bool) {
+	home := a.sharedHome.Load()
+	if home == nil {
+		return 0, 0, false
+	}
+	return home.Lat, home.Lon, home.Set
+}
+
+// zeroAltitude makes the altitude read 0 where the aircraft is, for it to
+// read the height above home
+func (a *App) zeroAltitude() {
+	a.client.ZeroAltitude(true)
+	a.notify(logApp, LevelInfo, "Altitude zeroed (%s)", a.client.GetState().RefSource)
+}
+
+// homeGrabRadius is how close to the home marker, in pixels, a press picks
+// it up while moving home
+const homeGrabRadius = 16
+
+// startMovingHome lets the home marker be dragged to where home really is,
+// as the fix it was set from can be tens of meters off
+func (a *App) startMovingHome() {
+	if !a.homeSet {
+		a.toasts.Add(LevelWarn, "No home to move; set it with H first")
+		return
+	}
+	a.movingHome = true
+	a.homeBefore = [2]float64{a.homeLat, a.homeLon}
+	a.toasts.Add(LevelInfo, "Drag the home marker into place: Enter keeps it, Esc puts it back")
+}
+
+// finishMovingHome keeps the moved home, or puts it back where it was
+func (a *App) finishMovingHome(keep bool) {
+	a.movingHome, a.draggingHome = false, false
+	if !keep {
+		a.homeLat, a.homeLon = a.homeBefore[0], a.homeBefore[1]
+		a.toasts.Add(LevelInfo, "Home left where it was")
+		return
+	}
+	moved := DistanceMeters(a.homeBefore[0], a.homeBefore[1], a.homeLat, a.homeLon)
+	a.notify(logApp, LevelInfo, "Home moved %s to %.6f, %.6f",
+		a.config.Display.Units.FormatDistance(moved), a.homeLat, a.homeLon)
+	a.saveConfig()
+}
+
+// dragHome moves home with the mouse while it is being moved, reporting
+// whether the mouse is busy with the marker rather than the map
+func (a *App) dragHome(x, y int) bool {
+	if !a.movingHome {
+		return false
+	}
+	v := a.currentMapView()
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		hx, hy := v.LatLonToScreen(a.homeLat, a.homeLon)
+		dx, dy := hx-float64(x), hy-float64(y)
+		if dx*dx+dy*dy <= homeGrabRadius*homeGrabRadius {
+			a.draggingHome = true
+			a.homeGrabX, a.homeGrabY = dx, dy
+		}
+	}
+	if !a.draggingHome {
+		return false
+	}
+	if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		a.draggingHome = false
+		return true
+	}
+	// Every distance and bearing reads home afresh, so they follow along
+	a.homeLat, a.homeLon = v.ScreenToLatLon(float64(x)+a.homeGrabX, float64(y)+a.homeGrabY)
+	return true
+}
+
+// newHomeMenu offers the last session's home and pilot positions at
+// startup. Restarting mid-flight should keep them, but a new field needs
+// new ones, so they are only put back when confirmed.
+func (a *App) newHomeMenu(st StateConfig) *Menu {
+	m := NewMenu("Restore home position?")
+	home, setAt, pilot := st.Home, st.HomeSetAt, st.Pilot
+	set := "Saved home"
+	if !setAt.IsZero() {
+		set = "Set " + setAt.Format("2006-01-02 15:04") + ", " + formatAge(time.Since(setAt))
+	}
+	position := func(p HomeConfig) func() string {
+		return func() string {
+			if !p.Set {
+				return "-"
+			}
+			return fmt.Sprintf("%.6f, %.6f", p.Lat, p.Lon)
+		}
+	}
+	m.Items = []MenuItem{
+		{Label: set},
+		{Label: "Home", Value: position(home)},
+		{Label: "Pilot", Value: position(pilot)},
+		{Label: "Aircraft", Value: func() string {
+			state := a.client.GetState()
+			if !home.Set || !state.HasGPS || (state.Latitude == 0 && state.Longitude == 0) {
+				return "no GPS"
+			}
+			dist := DistanceMeters(home.Lat, home.Lon, float64(state.Latitude), float64(state.Longitude))
+			return a.config.Display.Units.FormatDistance(dist) + " from home"
+		}},
+		{
+			Label: "Restore",
+			OnSelect: func() {
+				if home.Set {
+					a.homeLat, a.homeLon, a.homeSet, a.homeSetAt = home.Lat, home.Lon, true, setAt
+					a.notify(logApp, LevelInfo, "Home restored to %.6f, %.6f", home.Lat, home.Lon)
+				}
+				if pilot.Set {
+					a.setPilot(pilot.Lat, pilot.Lon)
+				}
+				m.Close()
+			},
+		},
+		{Label: "Discard", OnSelect: m.Close},
+	}
+	return m
+}
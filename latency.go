@@ -0,0 +1,82 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// latencyHistory is how many one second samples the graph keeps
+const latencyHistory = 120
+
+// LatencySample is one second of link timing
+type LatencySample struct {
+	Gap time.Duration // Longest wait between link statistics frames
+	RTT time.Duration // Backend round trip, 0 if the ping failed
+}
+
+// LatencyMonitor samples link timing once a second. Neither ELRS nor the
+// backend report the RF latency itself, so it keeps what can be measured
+// here: the gap between link statistics frames, which ELRS sends at a fixed
+// rate and which stretches as packets are lost, and the backend round trip.
+type LatencyMonitor struct {
+	client *GRPCClient
+
+	mu      sync.Mutex
+	samples []LatencySample
+	rtt     time.Duration // Last backend round trip
+	pinging bool
+	last    time.Time
+}
+
+// NewLatencyMonitor creates a monitor for the client's link
+func NewLatencyMonitor(client *GRPCClient) *LatencyMonitor {
+	return &LatencyMonitor{client: client}
+}
+
+// Update takes a sample once a second; call it every frame
+func (m *LatencyMonitor) Update() {
+	if time.Since(m.last) < time.Second {
+		return
+	}
+	m.last = time.Now()
+	gap := m.client.TakeLinkGap()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.samples = append(m.samples, LatencySample{Gap: gap, RTT: m.rtt})
+	if len(m.samples) > latencyHistory {
+		m.samples = m.samples[len(m.samples)-latencyHistory:]
+	}
+	if !m.pinging && m.client.IsConnected() {
+		m.pinging = true
+		go m.ping()
+	}
+}
+
+// ping measures the backend round trip for the next sample
+func (m *LatencyMonitor) ping() {
+	rtt, err := m.client.Ping()
+	if err != nil {
+		rtt = 0
+	}
+	m.mu.Lock()
+	m.rtt, m.pinging = rtt, false
+	m.mu.Unlock()
+}
+
+// Samples returns the history, oldest first
+func (m *LatencyMonitor) Samples() []LatencySample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]LatencySample(nil), m.samples...)
+}
+
+// Latest returns the newest sample, zero before the first
+func (m *LatencyMonitor) Latest() LatencySample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.samples) == 0 {
+		return LatencySample{}
+	}
+	return m.samples[len(m.samples)-1]
+}
@@ -0,0 +1,75 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// latencyOver reports whether a frame gap passes the warning threshold
+func (a *App) latencyOver(gap time.Duration) bool {
+	limit := a.config.Alerts.MaxLatencyMs
+	return limit > 0 && gap > time.Duration(limit)*time.Millisecond
+}
+
+// drawLatencyWithOffset draws the link timing history in the map's bottom
+// left corner: the link statistics frame gap in green (red past the
+// threshold) and the backend round trip in blue
+func (a *App) drawLatencyWithOffset(screen *ebiten.Image, offsetX, mapWidth int) {
+	if !a.config.Display.LatencyGraph {
+		return
+	}
+	samples := a.latency.Samples()
+
+	boxW, boxH := 2*latencyHistory+10, 80
+	x := offsetX + 5
+	y := a.height - 24 - boxH - 5 - a.attributionInset(false, offsetX, mapWidth) // Above the status bar
+	vector.DrawFilledRect(screen, float32(x), float32(y), float32(boxW), float32(boxH), color.RGBA{0, 0, 0, 180}, true)
+
+	latest := a.latency.Latest()
+	label := "LINK -"
+	if len(samples) > 0 {
+		label = fmt.Sprintf("LINK %dms RTT %dms", latest.Gap.Milliseconds(), latest.RTT.Milliseconds())
+	}
+	if a.latencyOver(latest.Gap) {
+		vector.DrawFilledRect(screen, float32(x), float32(y), float32(boxW), 18, color.RGBA{200, 0, 0, 200}, true)
+	}
+	ebitenutil.DebugPrintAt(screen, label, x+5, y+2)
+
+	// Scale to the threshold, or the worst sample if higher
+	graphX, graphY, graphH := float32(x+5), float32(y+boxH-5), float32(boxH-28)
+	scale := time.Duration(max(a.config.Alerts.MaxLatencyMs, 100)) * time.Millisecond * 5 / 4
+	for _, s := range samples {
+		scale = max(scale, s.Gap, s.RTT)
+	}
+	toY := func(d time.Duration) float32 {
+		return graphY - graphH*float32(d)/float32(scale)
+	}
+
+	if limit := a.config.Alerts.MaxLatencyMs; limit > 0 {
+		ly := toY(time.Duration(limit) * time.Millisecond)
+		for lx := graphX; lx < graphX+2*latencyHistory; lx += 8 {
+			vector.StrokeLine(screen, lx, ly, lx+4, ly, 1, color.RGBA{255, 60, 60, 200}, false)
+		}
+	}
+
+	// Newest at the right edge
+	start := graphX + float32(2*(latencyHistory-len(samples)))
+	for i, s := range samples {
+		bx := start + float32(2*i)
+		gapColor := color.RGBA{80, 220, 80, 255}
+		if a.latencyOver(s.Gap) {
+			gapColor = color.RGBA{255, 60, 60, 255}
+		}
+		vector.DrawFilledRect(screen, bx, toY(s.Gap), 2, graphY-toY(s.Gap), gapColor, false)
+		if s.RTT > 0 {
+			vector.DrawFilledRect(screen, bx, toY(s.RTT)-1, 2, 2, color.RGBA{120, 200, 255, 255}, false)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+//go:build !headless
+
+package main
+
+import (
+	"sort"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// MapLayer is one thing drawn on the map, such as the tiles, the trail or
+// the aircraft. Layers are stacked in a LayerStack, which draws them in
+// order and can hide them, so a new overlay is a new layer rather than
+// another line in App.Draw.
+type MapLayer interface {
+	Name() string
+	Update()
+	Draw(screen *ebiten.Image, view MapView)
+}
+
+// funcLayer makes a layer of a draw function
+type funcLayer struct {
+	name   string
+	draw   func(screen *ebiten.Image, view MapView)
+	update func() // May be nil
+}
+
+func (l *funcLayer) Name() string { return l.name }
+
+func (l *funcLayer) Update() {
+	if l.update != nil {
+		l.update()
+	}
+}
+
+func (l *funcLayer) Draw(screen *ebiten.Image, view MapView) {
+	l.draw(screen, view)
+}
+
+// stackedLayer is a layer with its place in the stack
+type stackedLayer struct {
+	layer       MapLayer
+	z, defaultZ int
+	hidden      bool
+}
+
+// LayerStack draws map layers from the lowest z up
+type LayerStack struct {
+	layers []*stackedLayer
+}
+
+// Add puts a layer in the stack at z; layers added later draw on top of
+// those at the same z
+func (s *LayerStack) Add(layer MapLayer, z int) {
+	s.layers = append(s.layers, &stackedLayer{layer: layer, z: z, defaultZ: z})
+	s.sort()
+}
+
+func (s *LayerStack) sort() {
+	sort.SliceStable(s.layers, func(i, j int) bool { return s.layers[i].z < s.layers[j].z })
+}
+
+func (s *LayerStack) find(name string) *stackedLayer {
+	for _, l := range s.layers {
+		if l.layer.Name() == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// Names lists the layers from the bottom up
+func (s *LayerStack) Names() []string {
+	names := make([]string, len(s.layers))
+	for i, l := range s.layers {
+		names[i] = l.layer.Name()
+	}
+	return names
+}
+
+// Visible tells whether a layer is drawn
+func (s *LayerStack) Visible(name string) bool {
+	l := s.find(name)
+	return l != nil && !l.hidden
+}
+
+// SetVisible shows or hides a layer
+func (s *LayerStack) SetVisible(name string, on bool) {
+	if l := s.find(name); l != nil {
+		l.hidden = !on
+	}
+}
+
+// Configure applies map.layers: what to hide and where to draw it. Layers
+// it leaves out are shown at their default z.
+func (s *LayerStack) Configure(layers map[string]LayerConfig) {
+	for _, l := range s.layers {
+		c := layers[l.layer.Name()]
+		l.hidden = c.Hide
+		l.z = l.defaultZ
+		if c.Z != 0 {
+			l.z = c.Z
+		}
+	}
+	s.sort()
+}
+
+// Update updates every layer, shown or not, so a hidden one is current
+// when shown again
+func (s *LayerStack) Update() {
+	for _, l := range s.layers {
+		l.layer.Update()
+	}
+}
+
+// Draw draws the shown layers in order
+func (s *LayerStack) Draw(screen *ebiten.Image, view MapView) {
+	for _, l := range s.layers {
+		if !l.hidden {
+			l.layer.Draw(screen, view)
+		}
+	}
+}
+
+// newMapLayers stacks the app's map layers. The defaults are 10 apart, so
+// map.layers can slot one in between two others.
+func (a *App) newMapLayers() *LayerStack {
+	s := &LayerStack{}
+	for i, l := range []struct {
+		name string
+		draw func(screen *ebiten.Image, v MapView)
+	}{
+		{"tiles", a.drawMapWithOffset},
+		{"overlays", a.drawOverlaysWithOffset},
+		{"grid", a.drawGridWithOffset},
+		{"compare", a.drawCompareTrackWithOffset}, // Past flight under the live one
+		{"path", a.drawFlightPathWithOffset},
+		{"distance_marks", a.drawDistanceMarksWithOffset},
+		{"legend", a.drawFlightLegendWithOffset},
+		{"mission", a.drawMissionWithOffset},
+		{"rally", a.drawRallyWithOffset},
+		{"limit", a.drawLimitCircleWithOffset},
+		{"home_line", a.drawHomeLineWithOffset}, // Under the markers
+		{"home", a.drawHomeMarkerWithOffset},
+		{"pilot", a.drawPilotMarkerWithOffset},
+		{"buddies", a.drawBuddiesWithOffset}, // Under our own aircraft
+		{"aircraft", a.drawAircraftWithOffset},
+		{"last_known", a.drawLastKnownWithOffset},
+		{"inspection", a.drawInspectionWithOffset},
+		{"plugins", a.drawPluginWidgetsWithOffset},
+	} {
+		s.Add(&funcLayer{name: l.name, draw: l.draw}, i*10)
+	}
+	return s
+}
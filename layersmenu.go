@@ -0,0 +1,34 @@
+//go:build !headless
+
+package main
+
+// newLayersMenu builds the screen that shows or hides each map layer, in
+// the order they are drawn
+func (a *App) newLayersMenu() *Menu {
+	m := NewMenu("Map Layers")
+	m.OnClose = a.saveConfig
+
+	for _, name := range a.layers.Names() {
+		name := name
+		m.Items = append(m.Items, MenuItem{
+			Label: name,
+			Value: func() string { return onOff(a.layers.Visible(name)) },
+			OnAdjust: func(int) {
+				on := !a.layers.Visible(name)
+				a.layers.SetVisible(name, on)
+				if a.config.Map.Layers == nil {
+					a.config.Map.Layers = make(map[string]LayerConfig)
+				}
+				c := a.config.Map.Layers[name]
+				c.Hide = !on
+				if c == (LayerConfig{}) {
+					delete(a.config.Map.Layers, name)
+				} else {
+					a.config.Map.Layers[name] = c
+				}
+			},
+		})
+	}
+	m.Items = append(m.Items, MenuItem{Label: "Save & close", OnSelect: m.Close})
+	return m
+}
@@ -0,0 +1,96 @@
+package main
+
+import "fmt"
+
+// LimitPreset is a jurisdiction's limits for open-category flying. The
+// distance is a nominal visual line of sight, which no rule puts a number
+// on; adjust it to what can actually be seen of the aircraft.
+type LimitPreset struct {
+	Name        string
+	Label       string
+	MaxAltitude float64 // Meters above takeoff
+	MaxDistance float64 // Meters from home
+}
+
+// limitPresets are selectable from settings; "custom" leaves the limits
+// as they are
+var limitPresets = []LimitPreset{
+	{Name: "custom", Label: "Custom"},
+	{Name: "eu", Label: "EU 120m", MaxAltitude: 120, MaxDistance: 500},
+	{Name: "uk", Label: "UK 120m", MaxAltitude: 120, MaxDistance: 500},
+	{Name: "us", Label: "US 400ft", MaxAltitude: 122, MaxDistance: 500},
+	{Name: "ca", Label: "Canada 122m", MaxAltitude: 122, MaxDistance: 500},
+	{Name: "br", Label: "Brazil 120m", MaxAltitude: 120, MaxDistance: 500},
+	{Name: "au", Label: "Australia 120m", MaxAltitude: 120, MaxDistance: 500},
+	{Name: "nz", Label: "NZ 120m", MaxAltitude: 120, MaxDistance: 500},
+}
+
+// findLimitPreset returns the index of the named preset, or 0 (custom)
+func findLimitPreset(name string) int {
+	for i, p := range limitPresets {
+		if p.Name == name {
+			return i
+		}
+	}
+	return 0
+}
+
+// limitPresetNames returns the preset names in menu order
+func limitPresetNames() []string {
+	names := make([]string, len(limitPresets))
+	for i, p := range limitPresets {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// ApplyLimitPreset sets the altitude and distance alerts from the named
+// preset. Unknown names and "custom" keep the configured values.
+func ApplyLimitPreset(alerts *AlertConfig, name string) {
+	p := limitPresets[findLimitPreset(name)]
+	alerts.Limits = p.Name
+	if p.MaxAltitude > 0 {
+		alerts.MaxAltitude = p.MaxAltitude
+		alerts.MaxDistance = p.MaxDistance
+	}
+}
+
+// LimitStatus is which legal limits the aircraft is past
+type LimitStatus struct {
+	Altitude float64 // Meters above takeoff
+	Distance float64 // Meters from home, 0 without one
+	OverAlt  bool
+	OverDist bool
+}
+
+// Exceeded reports whether any limit is exceeded
+func (s LimitStatus) Exceeded() bool {
+	return s.OverAlt || s.OverDist
+}
+
+// checkLimits compares the aircraft against the altitude and distance
+// limits. homeDist is only checked when homeSet.
+func checkLimits(state TelemetryState, alerts AlertConfig, homeSet bool, homeDist float64) LimitStatus {
+	s := LimitStatus{Altitude: relativeAltitude(state)}
+	if alerts.MaxAltitude > 0 && s.Altitude > alerts.MaxAltitude {
+		s.OverAlt = true
+	}
+	if homeSet && state.HasGPS {
+		s.Distance = homeDist
+		s.OverDist = alerts.MaxDistance > 0 && homeDist > alerts.MaxDistance
+	}
+	return s
+}
+
+// limitWarning returns the banner text for exceeded limits, or ""
+func limitWarning(s LimitStatus, alerts AlertConfig, units Units) string {
+	switch {
+	case s.OverAlt && s.OverDist:
+		return "ALTITUDE AND DISTANCE LIMITS"
+	case s.OverAlt:
+		return fmt.Sprintf("ALTITUDE LIMIT %.0f/%.0f%s", units.Altitude(s.Altitude), units.Altitude(alerts.MaxAltitude), units.AltitudeLabel())
+	case s.OverDist:
+		return fmt.Sprintf("DISTANCE LIMIT %s/%s", units.FormatDistance(s.Distance), units.FormatDistance(alerts.MaxDistance))
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+//go:build !headless
+
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+var limitColor = color.RGBA{255, 60, 60, 255}
+
+// drawLimitCircleWithOffset draws the max distance around home, or the
+// pilot when distances are measured from there
+func (a *App) drawLimitCircleWithOffset(screen *ebiten.Image, v MapView) {
+	maxDist := a.config.Alerts.MaxDistance
+	refLat, refLon, refSet, _ := a.distanceRef()
+	if !refSet || maxDist <= 0 {
+		return
+	}
+
+	sx, sy := v.LatLonToScreen(refLat, refLon)
+	// Radius from a point due north, as Mercator scale varies with latitude
+	nx, ny := v.LatLonToScreen(refLat+maxDist/earthRadius*180/math.Pi, refLon)
+	r := math.Hypot(nx-sx, ny-sy)
+
+	// Skip when the view is wholly inside or outside the circle
+	left, right := float64(v.X), float64(v.X+v.Width)
+	bottom := float64(v.Height)
+	cornerDist := 0.0
+	for _, c := range [][2]float64{{left, 0}, {right, 0}, {left, bottom}, {right, bottom}} {
+		cornerDist = math.Max(cornerDist, math.Hypot(c[0]-sx, c[1]-sy))
+	}
+	nearX := math.Max(left, math.Min(sx, right))
+	nearY := math.Max(0, math.Min(sy, bottom))
+	if cornerDist < r || math.Hypot(nearX-sx, nearY-sy) > r || r < 4 {
+		return
+	}
+
+	c := limitColor
+	c.A = 180
+	vector.StrokeCircle(screen, float32(sx), float32(sy), float32(r), 2, c, true)
+}
+
+// drawLimitWarning draws a banner across the top of the map while a legal
+// limit is exceeded
+func (a *App) drawLimitWarning(screen *ebiten.Image, limits LimitStatus, offsetX, mapWidth int) {
+	msg := limitWarning(limits, a.config.Alerts, a.config.Display.Units)
+	if msg == "" {
+		return
+	}
+	w := len(msg)*6 + 16
+	x := offsetX + mapWidth/2 - w/2
+	y := 44
+	vector.DrawFilledRect(screen, float32(x), float32(y), float32(w), 20, limitColor, false)
+	ebitenutil.DebugPrintAt(screen, msg, x+8, y+2)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// describeLinkError turns a backend or serial port failure into a message
+// that says what to do about it. port may be empty for calls that don't
+// open one.
+func describeLinkError(err error, port, addr string) string {
+	if errors.Is(err, errNotConnected) {
+		return fmt.Sprintf("Backend not connected at %s: is it running?", addr)
+	}
+	if errors.Is(err, errBackendTooOld) {
+		return fmt.Sprintf("Backend at %s is too old for this: update elrs-joystick-control", addr)
+	}
+	st := status.Convert(err)
+	switch st.Code() {
+	case codes.Unimplemented:
+		return fmt.Sprintf("Backend at %s is too old for this: update elrs-joystick-control", addr)
+	case codes.Unavailable:
+		return fmt.Sprintf("Backend unreachable at %s: is it running?", addr)
+	case codes.DeadlineExceeded:
+		return fmt.Sprintf("Backend at %s did not answer in time", addr)
+	}
+
+	// The backend passes the serial port's error text through
+	msg := st.Message()
+	lower := strings.ToLower(msg)
+	switch {
+	case port == "":
+	case strings.Contains(lower, "busy") || strings.Contains(lower, "in use") ||
+		strings.Contains(lower, "access is denied"): // Windows, for a port another app holds
+		return fmt.Sprintf("Port %s is busy: close other apps using it", port)
+	case strings.Contains(lower, "permission denied"):
+		return fmt.Sprintf("No permission for %s: add your user to the dialout group", port)
+	case strings.Contains(lower, "no such file") || strings.Contains(lower, "not found") ||
+		strings.Contains(lower, "cannot find"):
+		return fmt.Sprintf("Port %s not found: is the TX plugged in?", port)
+	}
+	return msg
+}
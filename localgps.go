@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// localFixTimeout is how long a ground station fix stays valid without news
+const localFixTimeout = 5 * time.Second
+
+// GPSFix is a position from the ground station's own GPS receiver
+type GPSFix struct {
+	Lat, Lon float64
+	Alt      float64 // Meters above sea level
+	Sats     int
+	Time     time.Time
+}
+
+// LocalGPS reads the ground station's position from gpsd or straight from
+// an NMEA receiver on a serial port, reconnecting until stopped
+type LocalGPS struct {
+	config *GPSConfig
+
+	mu        sync.Mutex
+	fix       GPSFix
+	connected bool
+	in        io.Closer
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewLocalGPS creates a reader for the receiver in cfg
+func NewLocalGPS(cfg *GPSConfig) *LocalGPS {
+	return &LocalGPS{config: cfg, stopChan: make(chan struct{})}
+}
+
+// Start reads in the background until Stop
+func (g *LocalGPS) Start() {
+	logGPS.Infof("Local GPS on %s", g.config.Source)
+	go g.run()
+}
+
+// Stop ends reading and closes the receiver
+func (g *LocalGPS) Stop() {
+	g.stopOnce.Do(func() {
+		close(g.stopChan)
+		g.mu.Lock()
+		if g.in != nil {
+			g.in.Close()
+		}
+		g.mu.Unlock()
+	})
+}
+
+// Fix returns the last position, and whether it is recent
+func (g *LocalGPS) Fix() (GPSFix, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.fix, !g.fix.Time.IsZero() && time.Since(g.fix.Time) < localFixTimeout
+}
+
+// Status describes the receiver for the connection screen
+func (g *LocalGPS) Status() string {
+	fix, ok := g.Fix()
+	g.mu.Lock()
+	connected := g.connected
+	g.mu.Unlock()
+	switch {
+	case ok && fix.Sats > 0:
+		return fmt.Sprintf("fix, %d sats", fix.Sats)
+	case ok:
+		return "fix"
+	case connected:
+		return "no fix"
+	}
+	return "not connected"
+}
+
+func (g *LocalGPS) run() {
+	for {
+		if err := g.read(); err != nil {
+			logGPS.Warnf("Local GPS: %v", err)
+		}
+		select {
+		case <-g.stopChan:
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// read opens the source and reads positions until it fails or is closed
+func (g *LocalGPS) read() error {
+	source := g.config.Source
+	var in io.ReadWriteCloser
+	var err error
+	gpsd := false
+	if addr, ok := strings.CutPrefix(source, "gpsd:"); ok {
+		in, err = net.DialTimeout("tcp", addr, 5*time.Second)
+		gpsd = true
+	} else if source == "" {
+		return fmt.Errorf("no source set")
+	} else {
+		in, err = openSerial(source, g.config.BaudRate)
+	}
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	select {
+	case <-g.stopChan:
+		g.mu.Unlock()
+		in.Close()
+		return nil
+	default:
+	}
+	g.in, g.connected = in, true
+	g.mu.Unlock()
+	defer func() {
+		g.mu.Lock()
+		g.in, g.connected = nil, false
+		g.mu.Unlock()
+		in.Close()
+	}()
+
+	if gpsd {
+		if _, err := io.WriteString(in, `?WATCH={"enable":true,"json":true};`+"\n"); err != nil {
+			return err
+		}
+	}
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if gpsd {
+			g.parseGPSD(line)
+		} else {
+			g.parseNMEA(line)
+		}
+	}
+	select {
+	case <-g.stopChan:
+		return nil
+	default:
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// parseGPSD takes the position from gpsd's TPV reports and the satellites
+// in use from its SKY reports
+func (g *LocalGPS) parseGPSD(line string) {
+	var msg struct {
+		Class  string  `json:"class"`
+		Mode   int     `json:"mode"` // 2 = 2D, 3 = 3D fix
+		Lat    float64 `json:"lat"`
+		Lon    float64 `json:"lon"`
+		Alt    float64 `json:"altMSL"`
+		OldAlt float64 `json:"alt"` // Before gpsd 3.20
+		USat   int     `json:"uSat"`
+		Sats   []struct {
+			Used bool `json:"used"`
+		} `json:"satellites"`
+	}
+	if json.Unmarshal([]byte(line), &msg) != nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch msg.Class {
+	case "TPV":
+		if msg.Mode < 2 {
+			return
+		}
+		alt := msg.Alt
+		if alt == 0 {
+			alt = msg.OldAlt
+		}
+		g.fix.Lat, g.fix.Lon, g.fix.Alt, g.fix.Time = msg.Lat, msg.Lon, alt, time.Now()
+	case "SKY":
+		used := msg.USat
+		if used == 0 {
+			for _, s := range msg.Sats {
+				if s.Used {
+					used++
+				}
+			}
+		}
+		g.fix.Sats = used
+	}
+}
+
+// parseNMEA takes the position from GGA sentences, from any talker
+func (g *LocalGPS) parseNMEA(line string) {
+	fields, ok := nmeaFields(line)
+	if !ok || len(fields) < 10 || !strings.HasSuffix(fields[0], "GGA") {
+		return
+	}
+	// Fix quality 0 is no fix
+	if fields[6] == "" || fields[6] == "0" {
+		return
+	}
+	lat, ok1 := nmeaCoord(fields[2], fields[3])
+	lon, ok2 := nmeaCoord(fields[4], fields[5])
+	if !ok1 || !ok2 {
+		return
+	}
+	sats, _ := strconv.Atoi(fields[7])
+	alt, _ := strconv.ParseFloat(fields[9], 64)
+
+	g.mu.Lock()
+	g.fix = GPSFix{Lat: lat, Lon: lon, Alt: alt, Sats: sats, Time: time.Now()}
+	g.mu.Unlock()
+}
+
+// nmeaFields checks a sentence's checksum and splits it at the commas
+func nmeaFields(line string) ([]string, bool) {
+	line = strings.TrimSpace(line)
+	body, sum, found := strings.Cut(strings.TrimPrefix(line, "$"), "*")
+	if !found || !strings.HasPrefix(line, "$") {
+		return nil, false
+	}
+	want, err := strconv.ParseUint(sum, 16, 8)
+	if err != nil {
+		return nil, false
+	}
+	var got byte
+	for i := 0; i < len(body); i++ {
+		got ^= body[i]
+	}
+	if got != byte(want) {
+		return nil, false
+	}
+	return strings.Split(body, ","), true
+}
+
+// nmeaCoord converts NMEA's ddmm.mmmm (dddmm.mmmm for longitude) and
+// hemisphere to signed degrees
+func nmeaCoord(value, hemi string) (float64, bool) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || value == "" {
+		return 0, false
+	}
+	deg := float64(int(v/100)) + (v-float64(int(v/100))*100)/60
+	if hemi == "S" || hemi == "W" {
+		deg = -deg
+	}
+	return deg, true
+}
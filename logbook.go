@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Takeoff is moving faster than this, or climbing this far above where
+	// the aircraft sat, for takeoffHold
+	takeoffSpeed = 10.0 // km/h
+	takeoffClimb = 5.0  // Meters
+	takeoffHold  = 3 * time.Second
+
+	// Landing is sitting still near the takeoff altitude for landedHold, or
+	// losing telemetry for lostHold
+	landedSpeed = 3.0 // km/h
+	landedClimb = 3.0 // Meters
+	landedHold  = 20 * time.Second
+	lostHold    = time.Minute
+
+	// minFlight drops carrying the aircraft to the field and similar blips
+	minFlight = 20 * time.Second
+)
+
+// Flight is the summary of one flight in the logbook
+type Flight struct {
+	Profile  string    `json:"profile"`
+	Start    time.Time `json:"start"`
+	Airtime  float64   `json:"airtime_s"`
+	Distance float64   `json:"distance_m"` // Flown, not from home
+	MaxAlt   float64   `json:"max_alt_m"`  // Above takeoff
+	MaxDist  float64   `json:"max_dist_m"` // From the takeoff point
+	MaxSpeed float64   `json:"max_speed_kmh"`
+	UsedMAh  int       `json:"used_mah"`
+	NewPack  bool      `json:"new_pack"`      // Started on a fresh battery
+	Log      string    `json:"log,omitempty"` // Telemetry log of its session, if recorded
+}
+
+// LogbookRecord is a best and the flight it was set on
+type LogbookRecord struct {
+	Value  float64 // Seconds, meters or km/h
+	Flight Flight
+	Set    bool
+}
+
+// LogbookRecords are the bests over a set of flights
+type LogbookRecords struct {
+	Longest  LogbookRecord // Airtime
+	Farthest LogbookRecord // From the takeoff point
+	Highest  LogbookRecord // Above takeoff
+	Distance LogbookRecord // Flown in one flight
+	Fastest  LogbookRecord
+}
+
+// LogbookTotals are lifetime totals over a set of flights; the bests are
+// in LogbookRecords
+type LogbookTotals struct {
+	Flights  int
+	Airtime  time.Duration
+	Distance float64 // Meters
+	Packs    int
+	UsedMAh  int
+}
+
+// Logbook detects takeoffs and landings from telemetry and keeps a
+// summary of every flight in a JSON file, for totals like a
+// transmitter's flight counter
+type Logbook struct {
+	path string
+
+	// Log returns the telemetry log being written, if any, to tell which
+	// session a flight was in
+	Log func() string
+	// OnRecord is told of the records a flight just set, when it lands
+	OnRecord func(profile string, records []string)
+
+	mu      sync.Mutex
+	flights []Flight
+
+	// Flight detection
+	flying     bool
+	current    Flight
+	groundAlt  float64 // Altitude while sitting on the ground
+	hasGround  bool
+	startAlt   float64
+	startLat   float64
+	startLon   float64
+	startMAh   uint32
+	lastMAh    uint32
+	hasLastMAh bool
+	lastLat    float64
+	lastLon    float64
+	lastUpdate time.Time
+	candidate  time.Time // When takeoff or landing conditions started
+}
+
+// LogbookPath returns where the logbook is kept
+func LogbookPath() string {
+	return filepath.Join(DefaultDataDir(), "logbook.json")
+}
+
+// NewLogbook loads the logbook at path, starting an empty one if it
+// doesn't exist
+func NewLogbook(path string) *Logbook {
+	l := &Logbook{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logRec.Errorf("Could not read logbook: %v", err)
+		}
+		return l
+	}
+	if err := json.Unmarshal(data, &l.flights); err != nil {
+		logRec.Errorf("Could not parse logbook %s: %v", path, err)
+	}
+	return l
+}
+
+// Update advances flight detection with the latest telemetry. profile is
+// the aircraft profile a new flight is logged under.
+func (l *Logbook) Update(state TelemetryState, profile string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if state.LastUpdate.IsZero() || !state.LastUpdate.After(l.lastUpdate) {
+		if l.flying && !l.lastUpdate.IsZero() && now.Sub(l.lastUpdate) > lostHold {
+			l.land(l.lastUpdate)
+		}
+		return
+	}
+	l.lastUpdate = state.LastUpdate
+
+	alt := relativeAltitude(state)
+	speed := float64(state.GroundSpeed)
+	hasPos := state.HasGPS && (state.Latitude != 0 || state.Longitude != 0)
+	lat, lon := float64(state.Latitude), float64(state.Longitude)
+
+	if !l.flying {
+		moving := (hasPos && speed > takeoffSpeed) || (l.hasGround && alt-l.groundAlt > takeoffClimb)
+		if !moving {
+			l.groundAlt, l.hasGround = alt, true
+			l.candidate = time.Time{}
+			return
+		}
+		if l.candidate.IsZero() {
+			l.candidate = state.LastUpdate
+		}
+		if state.LastUpdate.Sub(l.candidate) < takeoffHold {
+			return
+		}
+		l.takeoff(state, profile, lat, lon, hasPos)
+	}
+
+	c := &l.current
+	c.MaxAlt = max(c.MaxAlt, alt-l.startAlt)
+	c.MaxSpeed = max(c.MaxSpeed, speed)
+	if hasPos {
+		if l.startLat == 0 && l.startLon == 0 {
+			l.startLat, l.startLon = lat, lon
+		}
+		if l.lastLat != 0 || l.lastLon != 0 {
+			c.Distance += DistanceMeters(l.lastLat, l.lastLon, lat, lon)
+		}
+		l.lastLat, l.lastLon = lat, lon
+		c.MaxDist = max(c.MaxDist, DistanceMeters(l.startLat, l.startLon, lat, lon))
+	}
+	if state.Capacity >= l.startMAh {
+		c.UsedMAh = int(state.Capacity - l.startMAh)
+	}
+	l.lastMAh, l.hasLastMAh = state.Capacity, true
+
+	still := speed < landedSpeed && alt-l.startAlt < landedClimb
+	if !still {
+		l.candidate = time.Time{}
+		return
+	}
+	if l.candidate.IsZero() {
+		l.candidate = state.LastUpdate
+	}
+	if state.LastUpdate.Sub(l.candidate) >= landedHold {
+		l.land(l.candidate)
+	}
+}
+
+func (l *Logbook) takeoff(state TelemetryState, profile string, lat, lon float64, hasPos bool) {
+	l.flying = true
+	l.current = Flight{
+		Profile: profile,
+		Start:   l.candidate,
+		// The mAh counter only goes back down when the FC restarts, which
+		// is usually a battery swap
+		NewPack: !l.hasLastMAh || state.Capacity < l.lastMAh,
+	}
+	if l.Log != nil {
+		if log := l.Log(); log != "" {
+			l.current.Log = filepath.Base(log)
+		}
+	}
+	l.startAlt = l.groundAlt
+	l.startMAh = state.Capacity
+	l.startLat, l.startLon = 0, 0
+	l.lastLat, l.lastLon = 0, 0
+	if hasPos {
+		l.startLat, l.startLon = lat, lon
+	}
+	l.candidate = time.Time{}
+	logRec.Infof("Takeoff (%s)", profile)
+}
+
+// land ends the current flight at end and logs it if it was long enough
+func (l *Logbook) land(end time.Time) {
+	l.flying = false
+	l.candidate = time.Time{}
+	l.hasGround = false
+
+	c := l.current
+	c.Airtime = end.Sub(c.Start).Seconds()
+	if end.Sub(c.Start) < minFlight {
+		return
+	}
+	before := l.records(c.Profile)
+	l.flights = append(l.flights, c)
+	logRec.Infof("Landed after %s, %.1f km", FormatETE(end.Sub(c.Start)), c.Distance/1000)
+	if beaten := l.records(c.Profile).beaten(before); len(beaten) > 0 {
+		logRec.Infof("New records for %s: %s", c.Profile, strings.Join(beaten, ", "))
+		if l.OnRecord != nil {
+			l.OnRecord(c.Profile, beaten)
+		}
+	}
+	if err := l.save(); err != nil {
+		logRec.Errorf("Could not save logbook: %v", err)
+	}
+}
+
+func (l *Logbook) save() error {
+	if l.path == "" {
+		return nil // Kept in memory only, as while rendering a replay
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(l.flights, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(l.path, data)
+}
+
+// Close logs a flight still in progress, as if it landed now
+func (l *Logbook) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.flying {
+		l.land(l.lastUpdate)
+	}
+}
+
+// Flying reports whether a flight is in progress, and since when
+func (l *Logbook) Flying() (bool, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.flying, l.current.Start
+}
+
+// LastFlight returns the most recent logged flight
+func (l *Logbook) LastFlight() (Flight, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.flights) == 0 {
+		return Flight{}, false
+	}
+	return l.flights[len(l.flights)-1], true
+}
+
+// Profiles returns the profiles with logged flights, sorted
+func (l *Logbook) Profiles() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	seen := map[string]bool{}
+	var names []string
+	for _, f := range l.flights {
+		if !seen[f.Profile] {
+			seen[f.Profile] = true
+			names = append(names, f.Profile)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Totals sums the flights of profile, or all flights if profile is ""
+func (l *Logbook) Totals(profile string) LogbookTotals {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var t LogbookTotals
+	for _, f := range l.flights {
+		if profile != "" && f.Profile != profile {
+			continue
+		}
+		airtime := time.Duration(f.Airtime * float64(time.Second))
+		t.Flights++
+		t.Airtime += airtime
+		t.Distance += f.Distance
+		t.UsedMAh += f.UsedMAh
+		if f.NewPack {
+			t.Packs++
+		}
+	}
+	return t
+}
+
+// Records returns the bests of profile, or of all flights if profile is ""
+func (l *Logbook) Records(profile string) LogbookRecords {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.records(profile)
+}
+
+func (l *Logbook) records(profile string) LogbookRecords {
+	var r LogbookRecords
+	for _, f := range l.flights {
+		if profile != "" && f.Profile != profile {
+			continue
+		}
+		r.Longest.consider(f.Airtime, f)
+		r.Farthest.consider(f.MaxDist, f)
+		r.Highest.consider(f.MaxAlt, f)
+		r.Distance.consider(f.Distance, f)
+		r.Fastest.consider(f.MaxSpeed, f)
+	}
+	return r
+}
+
+// consider makes f the record if it beats it; a tie keeps the first
+func (r *LogbookRecord) consider(value float64, f Flight) {
+	if value > 0 && (!r.Set || value > r.Value) {
+		*r = LogbookRecord{Value: value, Flight: f, Set: true}
+	}
+}
+
+// beaten names the records that are better than in before; a profile's
+// first flight sets them all, which is not news
+func (r LogbookRecords) beaten(before LogbookRecords) []string {
+	var names []string
+	for _, c := range []struct {
+		name        string
+		now, before LogbookRecord
+	}{
+		{"longest flight", r.Longest, before.Longest},
+		{"farthest", r.Farthest, before.Farthest},
+		{"highest", r.Highest, before.Highest},
+		{"distance", r.Distance, before.Distance},
+		{"fastest", r.Fastest, before.Fastest},
+	} {
+		if c.before.Set && c.now.Value > c.before.Value {
+			names = append(names, c.name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,94 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// newLogbookMenu builds the lifetime flight stats screen
+func (a *App) newLogbookMenu() *Menu {
+	m := NewMenu("Logbook")
+	profile := "" // All profiles
+	m.Rebuild = func(m *Menu) {
+		profiles := append([]string{""}, a.logbook.Profiles()...)
+		units := a.config.Display.Units
+		totals := func() LogbookTotals { return a.logbook.Totals(profile) }
+
+		m.Items = []MenuItem{
+			{
+				Label: "Profile",
+				Value: func() string {
+					if profile == "" {
+						return "All"
+					}
+					return profile
+				},
+				OnAdjust: func(d int) { profile = cycle(profiles, profile, d) },
+			},
+			{Label: "Flights", Value: func() string { return fmt.Sprintf("%d", totals().Flights) }},
+			{Label: "Airtime", Value: func() string { return FormatETE(totals().Airtime) }},
+			{Label: "Distance flown", Value: func() string { return units.FormatDistance(totals().Distance) }},
+			{Label: "Packs used", Value: func() string {
+				t := totals()
+				return fmt.Sprintf("%d (%d mAh)", t.Packs, t.UsedMAh)
+			}},
+		}
+		// Personal records, with when they were set; select one for its
+		// session
+		records := func() LogbookRecords { return a.logbook.Records(profile) }
+		for _, r := range []struct {
+			label  string
+			record func(LogbookRecords) LogbookRecord
+			format func(float64) string
+		}{
+			{"Record: longest", func(r LogbookRecords) LogbookRecord { return r.Longest },
+				func(v float64) string { return FormatETE(time.Duration(v * float64(time.Second))) }},
+			{"Record: farthest", func(r LogbookRecords) LogbookRecord { return r.Farthest }, units.FormatDistance},
+			{"Record: highest", func(r LogbookRecords) LogbookRecord { return r.Highest },
+				func(v float64) string { return fmt.Sprintf("%.0f%s", units.Altitude(v), units.AltitudeLabel()) }},
+			{"Record: distance", func(r LogbookRecords) LogbookRecord { return r.Distance }, units.FormatDistance},
+			{"Record: fastest", func(r LogbookRecords) LogbookRecord { return r.Fastest },
+				func(v float64) string { return fmt.Sprintf("%.0f%s", units.Speed(v), units.SpeedLabel()) }},
+		} {
+			r := r
+			m.Items = append(m.Items, MenuItem{
+				Label: r.label,
+				Value: func() string {
+					rec := r.record(records())
+					if !rec.Set {
+						return "-"
+					}
+					return fmt.Sprintf("%s %s", r.format(rec.Value), rec.Flight.Start.Local().Format("Jan 2 2006"))
+				},
+				OnSelect: func() {
+					rec := r.record(records())
+					if !rec.Set {
+						return
+					}
+					session := rec.Flight.Log
+					if session == "" {
+						session = "not recorded"
+					}
+					a.toasts.Add(LevelInfo, "%s on %s (%s), log: %s", r.format(rec.Value), rec.Flight.Profile,
+						rec.Flight.Start.Local().Format("Jan 2 2006 15:04"), session)
+				},
+			})
+		}
+		if flying, since := a.logbook.Flying(); flying {
+			m.Items = append(m.Items, MenuItem{Label: "Flying", Value: func() string { return FormatETE(time.Since(since)) }})
+		}
+		if f, ok := a.logbook.LastFlight(); ok {
+			m.Items = append(m.Items, MenuItem{
+				Label: "Last " + f.Start.Local().Format("Jan 2 15:04"),
+				Value: func() string {
+					airtime := time.Duration(f.Airtime * float64(time.Second))
+					return fmt.Sprintf("%s %s", FormatETE(airtime), units.FormatDistance(f.Distance))
+				},
+			})
+		}
+		m.Items = append(m.Items, MenuItem{Label: "Close", OnSelect: m.Close})
+	}
+	return m
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// LogLevel orders log messages by severity
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[LogLevel]string{
+	LevelDebug: "DEBUG",
+	LevelInfo:  "INFO",
+	LevelWarn:  "WARN",
+	LevelError: "ERROR",
+}
+
+// ParseLogLevel returns the level for a config name (debug, info, warn, error)
+func ParseLogLevel(name string) (LogLevel, bool) {
+	for l, n := range levelNames {
+		if strings.EqualFold(n, name) {
+			return l, true
+		}
+	}
+	return LevelInfo, false
+}
+
+// Logging output shared by every Logger; console only until SetupLogging
+var (
+	logMu    sync.Mutex
+	logLevel = LevelInfo
+	logFile  *rotatingFile
+	logOut   = log.New(os.Stderr, "", log.LstdFlags)
+)
+
+// Subsystem loggers
+var (
+	logApp     = NewLogger("app")
+	logConfig  = NewLogger("config")
+	logTelem   = NewLogger("telemetry")
+	logTile    = NewLogger("tile")
+	logGPIO    = NewLogger("gpio")
+	logRec     = NewLogger("recorder")
+	logWeb     = NewLogger("web")
+	logSim     = NewLogger("sim")
+	logTracker = NewLogger("tracker")
+	logBuddy   = NewLogger("buddy")
+	logMQTT    = NewLogger("mqtt")
+	logMDNS    = NewLogger("mdns")
+	logWeather = NewLogger("weather")
+	logOSDOut  = NewLogger("osdout")
+	logGPS     = NewLogger("gps")
+	logVideo   = NewLogger("video")
+	logPlugin  = NewLogger("plugin")
+)
+
+// Logger writes leveled messages tagged with a subsystem name
+type Logger struct {
+	tag string
+}
+
+// NewLogger creates a logger for a subsystem
+func NewLogger(tag string) *Logger {
+	return &Logger{tag: tag}
+}
+
+func (l *Logger) logf(level LogLevel, format string, args ...interface{}) {
+	logMu.Lock()
+	min := logLevel
+	logMu.Unlock()
+	if level < min {
+		return
+	}
+	logOut.Printf("%-5s [%s] %s", levelNames[level], l.tag, fmt.Sprintf(format, args...))
+}
+
+// Debugf logs detail that is only useful when chasing a problem
+func (l *Logger) Debugf(format string, args ...interface{}) { l.logf(LevelDebug, format, args...) }
+
+// Infof logs normal operation
+func (l *Logger) Infof(format string, args ...interface{}) { l.logf(LevelInfo, format, args...) }
+
+// Warnf logs a problem the app recovers from
+func (l *Logger) Warnf(format string, args ...interface{}) { l.logf(LevelWarn, format, args...) }
+
+// Errorf logs a failure of a feature
+func (l *Logger) Errorf(format string, args ...interface{}) { l.logf(LevelError, format, args...) }
+
+// Fatalf logs an error, flushes the log file, and exits
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	l.logf(LevelError, format, args...)
+	CloseLogging()
+	os.Exit(1)
+}
+
+// SetupLogging sets the level and adds the rotating log file. Messages from
+// the standard log package (gRPC, Ebiten) go to the same place.
+func SetupLogging(cfg LogConfig) error {
+	level, ok := ParseLogLevel(cfg.Level)
+	if !ok {
+		logConfig.Warnf("Unknown log level %q, using info", cfg.Level)
+	}
+
+	dir := cfg.Dir
+	if dir == "" {
+		dir = DefaultDataDir()
+	}
+	f, err := openRotatingFile(filepath.Join(dir, "elrs-map.log"), int64(cfg.MaxSizeMB)<<20, cfg.MaxFiles)
+
+	logMu.Lock()
+	defer logMu.Unlock()
+	logLevel = level
+	if err != nil {
+		return err
+	}
+	logFile = f
+	w := io.MultiWriter(os.Stderr, f)
+	logOut.SetOutput(w)
+	log.SetOutput(w)
+	return nil
+}
+
+// CloseLogging flushes and closes the log file
+func CloseLogging() {
+	logMu.Lock()
+	defer logMu.Unlock()
+	if logFile == nil {
+		return
+	}
+	logOut.SetOutput(os.Stderr)
+	log.SetOutput(os.Stderr)
+	logFile.Close()
+	logFile = nil
+}
+
+// rotatingFile is a log file that rolls over to name.1, name.2, ... when it
+// reaches maxSize, keeping at most maxFiles old files
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	r := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxSize > 0 && r.size+int64(len(p)) > r.maxSize {
+		r.rotate()
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() {
+	r.file.Close()
+	for i := r.maxFiles - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if r.maxFiles > 0 {
+		os.Rename(r.path, r.path+".1")
+	} else {
+		os.Remove(r.path)
+	}
+	if err := r.open(); err != nil {
+		// Carry on with console output only
+		fmt.Fprintf(os.Stderr, "Could not reopen log file: %v\n", err)
+		r.file, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	}
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.file.Sync()
+	return r.file.Close()
+}
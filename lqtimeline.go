@@ -0,0 +1,82 @@
+package main
+
+import "time"
+
+// lqTimelineBuckets is the timeline's resolution; when a flight outgrows
+// it, neighbouring buckets merge and each covers twice the time
+const lqTimelineBuckets = 512
+
+// lqNoSample marks a bucket nothing was recorded in
+const lqNoSample = 255
+
+// LQTimeline keeps the worst link quality over a whole flight in a fixed
+// number of buckets, so a dip stays visible however long the flight gets.
+// It also marks the buckets in which TX power was at its top.
+type LQTimeline struct {
+	start    time.Time
+	step     time.Duration // Time per bucket
+	buckets  []uint8       // Lowest LQ seen in each, or lqNoSample
+	maxPower []bool        // TX power at its top in each
+}
+
+// Reset starts a new timeline at start
+func (t *LQTimeline) Reset(start time.Time) {
+	t.start = start
+	t.step = time.Second
+	t.buckets = t.buckets[:0]
+	t.maxPower = t.maxPower[:0]
+}
+
+// Start returns when the timeline begins, zero before the first sample
+func (t *LQTimeline) Start() time.Time {
+	return t.start
+}
+
+// Add records the link quality at now, and whether TX power was at its
+// top; an lq of 0 marks lost telemetry
+func (t *LQTimeline) Add(now time.Time, lq int, maxPower bool) {
+	if t.start.IsZero() {
+		t.Reset(now)
+	}
+	if now.Before(t.start) {
+		return
+	}
+	i := int(now.Sub(t.start) / t.step)
+	for i >= lqTimelineBuckets {
+		t.compress()
+		i = int(now.Sub(t.start) / t.step)
+	}
+	for len(t.buckets) <= i {
+		t.buckets = append(t.buckets, lqNoSample)
+		t.maxPower = append(t.maxPower, false)
+	}
+	t.buckets[i] = min(t.buckets[i], uint8(max(0, min(lq, 100))))
+	t.maxPower[i] = t.maxPower[i] || maxPower
+}
+
+// compress halves the resolution, keeping the worst of each pair
+func (t *LQTimeline) compress() {
+	n := (len(t.buckets) + 1) / 2
+	for i := 0; i < n; i++ {
+		v, p := t.buckets[2*i], t.maxPower[2*i]
+		if 2*i+1 < len(t.buckets) {
+			v = min(v, t.buckets[2*i+1])
+			p = p || t.maxPower[2*i+1]
+		}
+		t.buckets[i], t.maxPower[i] = v, p
+	}
+	t.buckets = t.buckets[:n]
+	t.maxPower = t.maxPower[:n]
+	t.step *= 2
+}
+
+// Buckets returns the lowest LQ per bucket, oldest first, and the time
+// each covers
+func (t *LQTimeline) Buckets() ([]uint8, time.Duration) {
+	return t.buckets, t.step
+}
+
+// MaxPower returns, per bucket, whether TX power reached its top in it
+func (t *LQTimeline) MaxPower() []bool {
+	return t.maxPower
+}
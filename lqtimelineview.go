@@ -0,0 +1,70 @@
+//go:build !headless
+
+package main
+
+import (
+	"image/color"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// lqTimelineHeight is the bar's height, between the map and status bar
+const lqTimelineHeight = 5
+
+// recordLQ adds the current link quality and TX power to the timeline,
+// restarting it at each takeoff so it always shows the flight in progress
+// or the last one
+func (a *App) recordLQ(state TelemetryState) {
+	if flying, start := a.logbook.Flying(); flying && !start.Equal(a.lqTimeline.Start()) {
+		a.lqTimeline.Reset(start)
+	}
+	if state.LastUpdate.IsZero() {
+		return
+	}
+	lq := int(state.LinkQuality)
+	maxPower := a.txPower.Update(state.TXPower, uint32(a.config.Display.MaxTXPower))
+	if time.Since(state.LastUpdate) > telemetryTimeout {
+		lq, maxPower = 0, false
+	}
+	a.lqTimeline.Add(time.Now(), lq, maxPower)
+	if maxPower && a.txPower.Warn(time.Now()) {
+		a.notify(logTelem, LevelWarn, "TX power at its maximum (%d mW), the link is near its limit", state.TXPower)
+	}
+}
+
+// txMaxPowerColor marks where TX power was at its top, on the timeline and
+// around the trail
+var txMaxPowerColor = color.RGBA{255, 40, 200, 255}
+
+// lqColor runs from red at 50% and below through yellow to green at 100%
+func lqColor(lq uint8) color.RGBA {
+	t := max(0, min(1, (float32(lq)-50)/50))
+	return color.RGBA{uint8(255 * min(1, 2*(1-t))), uint8(220 * min(1, 2*t)), 40, 255}
+}
+
+// drawLQTimelineWithOffset draws the flight's link quality as a bar along
+// the bottom of the map, oldest at the left, squeezed to fit its width, with
+// a tick over the stretches flown at full TX power
+func (a *App) drawLQTimelineWithOffset(screen *ebiten.Image, offsetX, mapWidth int) {
+	buckets, _ := a.lqTimeline.Buckets()
+	if !a.config.Display.LQTimeline || len(buckets) == 0 {
+		return
+	}
+	y := float32(a.height - 24 - lqTimelineHeight) // On top of the status bar
+	vector.DrawFilledRect(screen, float32(offsetX), y, float32(mapWidth), lqTimelineHeight, color.RGBA{40, 40, 40, 200}, false)
+	w := float32(mapWidth) / float32(len(buckets))
+	for i, lq := range buckets {
+		if lq == lqNoSample {
+			continue
+		}
+		// Overlap by a pixel so narrow buckets leave no seams
+		vector.DrawFilledRect(screen, float32(offsetX)+w*float32(i), y, w+1, lqTimelineHeight, lqColor(lq), false)
+	}
+	for i, on := range a.lqTimeline.MaxPower() {
+		if on {
+			vector.DrawFilledRect(screen, float32(offsetX)+w*float32(i), y-2, w+1, 2, txMaxPowerColor, false)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MagneticModel is a World Magnetic Model loaded from NOAA's WMM.COF, the
+// spherical harmonic coefficients of the main field and their yearly change
+type MagneticModel struct {
+	Name  string
+	Epoch float64 // Decimal year the coefficients are for
+	maxN  int
+	g, h  [][]float64 // nT, by degree n and order m
+	dg    [][]float64 // nT per year
+	dh    [][]float64
+}
+
+// LoadWMM reads a WMM.COF coefficient file
+func LoadWMM(path string) (*MagneticModel, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := &MagneticModel{}
+	type coef struct {
+		n, m         int
+		g, h, dg, dh float64
+	}
+	var coefs []coef
+	scanner := bufio.NewScanner(f)
+	for line := 0; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if line == 0 {
+			// "2025.0  WMM-2025  11/13/2024"
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("%s: bad header", path)
+			}
+			if m.Epoch, err = strconv.ParseFloat(fields[0], 64); err != nil {
+				return nil, fmt.Errorf("%s: bad epoch %q", path, fields[0])
+			}
+			m.Name = fields[1]
+			continue
+		}
+		if len(fields) < 6 {
+			break // The 9999... trailer
+		}
+		var c coef
+		var vals [6]float64
+		for i := range vals {
+			if vals[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, line+1, err)
+			}
+		}
+		c.n, c.m = int(vals[0]), int(vals[1])
+		c.g, c.h, c.dg, c.dh = vals[2], vals[3], vals[4], vals[5]
+		if c.n < 1 || c.m < 0 || c.m > c.n {
+			return nil, fmt.Errorf("%s:%d: bad degree/order %d/%d", path, line+1, c.n, c.m)
+		}
+		m.maxN = max(m.maxN, c.n)
+		coefs = append(coefs, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(coefs) == 0 {
+		return nil, fmt.Errorf("%s: no coefficients", path)
+	}
+
+	table := func() [][]float64 {
+		t := make([][]float64, m.maxN+1)
+		for n := range t {
+			t[n] = make([]float64, n+1)
+		}
+		return t
+	}
+	m.g, m.h, m.dg, m.dh = table(), table(), table(), table()
+	for _, c := range coefs {
+		m.g[c.n][c.m], m.h[c.n][c.m] = c.g, c.h
+		m.dg[c.n][c.m], m.dh[c.n][c.m] = c.dg, c.dh
+	}
+	return m, nil
+}
+
+// decimalYear converts t to a year with a fraction, as the model's epoch is
+func decimalYear(t time.Time) float64 {
+	start := time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+	return float64(t.Year()) + float64(t.Sub(start))/float64(end.Sub(start))
+}
+
+// Declination returns the magnetic declination in degrees, east positive,
+// at a geodetic position and height above the ellipsoid in meters
+func (m *MagneticModel) Declination(lat, lon, height float64, t time.Time) float64 {
+	const (
+		wgsA    = 6378.137 // km
+		wgsF    = 1 / 298.257223563
+		refR    = 6371.2 // Model reference radius, km
+		minSinT = 1e-10  // Keeps the poles finite
+	)
+	dt := decimalYear(t) - m.Epoch
+
+	// Geodetic to geocentric spherical
+	phi := lat * math.Pi / 180
+	lambda := lon * math.Pi / 180
+	e2 := wgsF * (2 - wgsF)
+	rc := wgsA / math.Sqrt(1-e2*math.Sin(phi)*math.Sin(phi))
+	hKm := height / 1000
+	p := (rc + hKm) * math.Cos(phi)
+	z := (rc*(1-e2) + hKm) * math.Sin(phi)
+	r := math.Hypot(p, z)
+	phiC := math.Asin(z / r)
+
+	// Gauss-normalized Legendre functions of the colatitude and their
+	// derivatives, with the Schmidt factors to match the coefficients
+	theta := math.Pi/2 - phiC
+	cosT, sinT := math.Cos(theta), math.Max(math.Sin(theta), minSinT)
+	N := m.maxN
+	P := make([][]float64, N+1)
+	dP := make([][]float64, N+1)
+	S := make([][]float64, N+1)
+	for n := 0; n <= N; n++ {
+		P[n], dP[n], S[n] = make([]float64, n+1), make([]float64, n+1), make([]float64, n+1)
+	}
+	P[0][0], S[0][0] = 1, 1
+	for n := 1; n <= N; n++ {
+		S[n][0] = S[n-1][0] * float64(2*n-1) / float64(n)
+		for mm := 0; mm <= n; mm++ {
+			if mm > 0 {
+				delta := 0.0
+				if mm == 1 {
+					delta = 1
+				}
+				S[n][mm] = S[n][mm-1] * math.Sqrt(float64(n-mm+1)*(delta+1)/float64(n+mm))
+			}
+			switch {
+			case mm == n:
+				P[n][mm] = sinT * P[n-1][mm-1]
+				dP[n][mm] = sinT*dP[n-1][mm-1] + cosT*P[n-1][mm-1]
+			case n == 1:
+				P[n][mm] = cosT * P[n-1][mm]
+				dP[n][mm] = cosT*dP[n-1][mm] - sinT*P[n-1][mm]
+			default:
+				k := float64((n-1)*(n-1)-mm*mm) / float64((2*n-1)*(2*n-3))
+				km2p, km2d := 0.0, 0.0
+				if mm <= n-2 {
+					km2p, km2d = P[n-2][mm], dP[n-2][mm]
+				}
+				P[n][mm] = cosT*P[n-1][mm] - k*km2p
+				dP[n][mm] = cosT*dP[n-1][mm] - sinT*P[n-1][mm] - k*km2d
+			}
+		}
+	}
+
+	// Field in the geocentric frame: north (X) and east (Y)
+	var x, y, zDown float64
+	for n := 1; n <= N; n++ {
+		ar := math.Pow(refR/r, float64(n+2))
+		for mm := 0; mm <= n; mm++ {
+			g := S[n][mm] * (m.g[n][mm] + dt*m.dg[n][mm])
+			h := S[n][mm] * (m.h[n][mm] + dt*m.dh[n][mm])
+			cosM, sinM := math.Cos(float64(mm)*lambda), math.Sin(float64(mm)*lambda)
+			x += ar * (g*cosM + h*sinM) * dP[n][mm]
+			y += ar * float64(mm) * (g*sinM - h*cosM) * P[n][mm] / sinT
+			zDown -= ar * float64(n+1) * (g*cosM + h*sinM) * P[n][mm]
+		}
+	}
+
+	// Rotate north into the geodetic frame; east is unchanged
+	psi := phiC - phi
+	xGeodetic := x*math.Cos(psi) - zDown*math.Sin(psi)
+	return math.Atan2(y, xGeodetic) * 180 / math.Pi
+}
+
+// HeadingCorrector keeps the client's heading source and declination up to
+// date: GPS course is already true, magnetometer yaw needs the declination
+// added so the compass, home bearing and map icon agree
+type HeadingCorrector struct {
+	config *HeadingConfig
+	model  *MagneticModel
+
+	declination float64
+	lat, lon    float64
+	computed    bool
+	last        time.Time
+}
+
+// NewHeadingCorrector loads the magnetic model named in cfg, if any
+func NewHeadingCorrector(cfg *HeadingConfig) *HeadingCorrector {
+	h := &HeadingCorrector{config: cfg}
+	if cfg.WMMFile != "" {
+		model, err := LoadWMM(cfg.WMMFile)
+		if err != nil {
+			logTelem.Errorf("Could not load magnetic model: %v", err)
+		} else {
+			logTelem.Infof("Magnetic model %s (epoch %.1f)", model.Name, model.Epoch)
+			if age := decimalYear(time.Now()) - model.Epoch; age < 0 || age > 5 {
+				logTelem.Warnf("Magnetic model %s is outside its 5 year validity", model.Name)
+			}
+			h.model = model
+		}
+	}
+	return h
+}
+
+// Declination returns the declination in use and whether it came from the
+// magnetic model rather than the configured value
+func (h *HeadingCorrector) Declination() (float64, bool) {
+	if h.model != nil && h.computed {
+		return h.declination, true
+	}
+	return h.config.Declination, false
+}
+
+// Update recomputes the declination where the aircraft is (or at lat, lon
+// before it has a fix) when it has moved far enough to matter, and passes
+// the heading settings to the client
+func (h *HeadingCorrector) Update(client *GRPCClient, state TelemetryState, lat, lon float64) {
+	if time.Since(h.last) < time.Second {
+		return
+	}
+	h.last = time.Now()
+	if state.HasGPS && (state.Latitude != 0 || state.Longitude != 0) {
+		lat, lon = float64(state.Latitude), float64(state.Longitude)
+	}
+	// Declination changes by well under a degree over 10 km
+	if h.model != nil && (!h.computed || DistanceMeters(h.lat, h.lon, lat, lon) > 10000) {
+		h.declination = h.model.Declination(lat, lon, 0, time.Now())
+		h.lat, h.lon, h.computed = lat, lon, true
+		logTelem.Debugf("Declination %.1f° at %.4f, %.4f", h.declination, lat, lon)
+	}
+	decl, _ := h.Declination()
+	client.SetHeading(h.config.Source == "mag", decl)
+}
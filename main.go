@@ -2,53 +2,218 @@ package main
 
 import (
 	"flag"
-	"log"
+	"math"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 )
 
 func main() {
-	// Command line flags
-	grpcAddr := flag.String("grpc", "localhost:10000", "gRPC server address")
-	cacheDir := flag.String("cache", "tiles", "Tile cache directory")
-	fullscreen := flag.Bool("fullscreen", false, "Start in fullscreen mode")
-	width := flag.Int("width", 1024, "Window width")
-	height := flag.Int("height", 600, "Window height")
-	touchBtns := flag.Bool("touch", false, "Enable on-screen touch buttons")
-	defaultLat := flag.Float64("lat", -22.9064, "Default latitude (used before GPS fix)")
-	defaultLon := flag.Float64("lon", -47.0616, "Default longitude (used before GPS fix)")
+	// "elrs-map sim" runs a simulated backend other instances can connect to
+	if len(os.Args) > 1 && os.Args[1] == "sim" {
+		runSimServer(os.Args[2:])
+		return
+	}
+	// "elrs-map video" renders a telemetry log to an MP4
+	if len(os.Args) > 1 && os.Args[1] == "video" {
+		runVideoCommand(os.Args[2:])
+		return
+	}
+	// "elrs-map tiles migrate" moves the tile cache into MBTiles files
+	if len(os.Args) > 1 && os.Args[1] == "tiles" {
+		runTilesCommand(os.Args[2:])
+		return
+	}
+
+	// Command line flags (defaults shown here are overridden by the config
+	// file, and explicitly set flags override the config file)
+	defaults := DefaultConfig()
+	configPath := flag.String("config", DefaultConfigPath(), "Config file path")
+	grpcAddr := flag.String("grpc", defaults.Backend.Address, "gRPC server address")
+	discover := flag.Bool("discover", defaults.Backend.Discover, "Look for the backend over mDNS if -grpc doesn't answer")
+	cacheDir := flag.String("cache", defaults.CacheDir, "Tile cache directory")
+	tileProxy := flag.String("tile-proxy", defaults.Tiles.Proxy, "Proxy for tile downloads (http://, socks5://host:port)")
+	tileRate := flag.Int("tile-rate", defaults.Tiles.MaxRateKB, "Max tile download rate in KB/s (0 = unlimited)")
+	fullscreen := flag.Bool("fullscreen", defaults.Window.Fullscreen, "Start in fullscreen mode")
+	width := flag.Int("width", defaults.Window.Width, "Window width")
+	height := flag.Int("height", defaults.Window.Height, "Window height")
+	monitor := flag.Int("monitor", defaults.Window.Monitor, "Display to open the window on, from 1 (0 = the primary)")
+	hud := flag.String("hud", "", "HUD mode to start in: map, osd, panel, split, big")
+	secondScreen := flag.Bool("second-screen", false, "Mirror the elrs-map already running here (with -web) on the second display")
+	powerSave := flag.Bool("power-save", defaults.Display.PowerSave, "Cap the frame rate and skip redraws when nothing changed")
+	touchBtns := flag.Bool("touch", defaults.Touch.Enabled, "Enable on-screen touch buttons")
+	touchIdle := flag.Duration("touch-idle", defaults.Touch.IdleTimeout, "Hide touch buttons after this idle period (0 = always visible)")
+	touchOpacity := flag.Float64("touch-opacity", defaults.Touch.Opacity, "Touch button opacity (0.1-1.0)")
+	defaultLat := flag.Float64("lat", defaults.Map.DefaultLat, "Default latitude (used before GPS fix)")
+	defaultLon := flag.Float64("lon", defaults.Map.DefaultLon, "Default longitude (used before GPS fix)")
+	mission := flag.String("mission", defaults.Mission.File, "Mission to show and navigate (.waypoints, INAV .mission, GPX)")
+	overlay := flag.String("overlay", "", "GeoJSON file to draw on the map, in addition to map.overlays")
+	compare := flag.String("compare", defaults.Map.CompareTrack, "Telemetry log of a past flight to draw under the live track")
+	record := flag.Bool("record", defaults.Record.Enabled, "Record telemetry to CSV logs")
+	recordDir := flag.String("record-dir", defaults.Record.Dir, "Telemetry log directory")
+	web := flag.Bool("web", defaults.Web.Enabled, "Serve the browser map for spotters")
+	webListen := flag.String("web-listen", defaults.Web.Listen, "Web map listen address")
+	buddy := flag.Bool("buddy", defaults.Buddy.Enabled, "Share aircraft positions with other ground stations on the LAN")
+	buddyName := flag.String("buddy-name", defaults.Buddy.Name, "Name shown to other ground stations (default: hostname)")
+	mqtt := flag.String("mqtt", "", "Publish telemetry to this MQTT broker (host:port)")
+	share := flag.String("share", "", "Share the flight live through this relay (URL)")
+	statusLine := flag.String("status-line", "", "Write a status line every few seconds to this file, FIFO or device (- for stdout)")
+	osdOut := flag.String("osd-out", defaults.OSDOut.Target, "Send the OSD as MSP DisplayPort to a serial device or udp:host:port")
+	source := flag.String("source", defaults.Backend.Source, "Telemetry source: "+strings.Join(SourceNames(), ", "))
+	sim := flag.Bool("sim", false, "Use simulated telemetry instead of the backend (-source sim)")
+	logLevel := flag.String("log-level", defaults.Log.Level, "Log level: debug, info, warn, error")
+	logDir := flag.String("log-dir", defaults.Log.Dir, "Log file directory (default: data directory)")
+	headless := flag.Bool("headless", false, "Run without a display (connect, record, drive GPIO LEDs/buzzer)")
 	flag.Parse()
 
-	log.Println("ELRS Ground Station Map")
-	log.Printf("Connecting to gRPC backend at %s", *grpcAddr)
-	log.Printf("Default location: %.4f, %.4f", *defaultLat, *defaultLon)
+	logApp.Infof("ELRS Ground Station Map")
+
+	cfg, configErr := LoadConfig(*configPath)
+	if configErr != nil {
+		logConfig.Warnf("Could not load config: %v (using defaults, not saving over it)", configErr)
+	}
 
-	// Create tile cache directory
-	if err := os.MkdirAll(*cacheDir, 0755); err != nil {
-		log.Fatalf("Failed to create cache directory: %v", err)
+	// Explicit flags win over the config file
+	grpcSet := false
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "grpc":
+			cfg.Backend.Address = *grpcAddr
+			grpcSet = true
+		case "source":
+			cfg.Backend.Source = *source
+		case "discover":
+			cfg.Backend.Discover = *discover
+		case "cache":
+			cfg.CacheDir = *cacheDir
+		case "tile-proxy":
+			cfg.Tiles.Proxy = *tileProxy
+		case "tile-rate":
+			cfg.Tiles.MaxRateKB = *tileRate
+		case "fullscreen":
+			cfg.Window.Fullscreen = *fullscreen
+		case "width":
+			cfg.Window.Width = *width
+		case "height":
+			cfg.Window.Height = *height
+		case "monitor":
+			cfg.Window.Monitor = *monitor
+		case "power-save":
+			cfg.Display.PowerSave = *powerSave
+		case "touch":
+			cfg.Touch.Enabled = *touchBtns
+		case "touch-idle":
+			cfg.Touch.IdleTimeout = *touchIdle
+		case "touch-opacity":
+			cfg.Touch.Opacity = *touchOpacity
+		case "lat", "lon":
+			// A new default location also recenters the saved view
+			cfg.Map.DefaultLat, cfg.Map.DefaultLon = *defaultLat, *defaultLon
+			cfg.State.CenterLat, cfg.State.CenterLon = *defaultLat, *defaultLon
+		case "mission":
+			cfg.Mission.File = *mission
+		case "overlay":
+			// Saved with the config, so don't add it twice
+			if !slices.Contains(cfg.Map.Overlays, *overlay) {
+				cfg.Map.Overlays = append(cfg.Map.Overlays, *overlay)
+			}
+		case "compare":
+			cfg.Map.CompareTrack = *compare
+		case "record":
+			cfg.Record.Enabled = *record
+		case "record-dir":
+			cfg.Record.Dir = *recordDir
+		case "web":
+			cfg.Web.Enabled = *web
+		case "web-listen":
+			cfg.Web.Listen = *webListen
+		case "buddy":
+			cfg.Buddy.Enabled = *buddy
+		case "buddy-name":
+			cfg.Buddy.Name = *buddyName
+		case "mqtt":
+			cfg.MQTT.Broker = *mqtt
+			cfg.MQTT.Enabled = *mqtt != ""
+		case "share":
+			cfg.Share.Relay = *share
+			cfg.Share.Enabled = *share != ""
+		case "status-line":
+			cfg.Status.Output = *statusLine
+			cfg.Status.Enabled = *statusLine != ""
+		case "osd-out":
+			cfg.OSDOut.Target = *osdOut
+			cfg.OSDOut.Enabled = *osdOut != ""
+		case "log-level":
+			cfg.Log.Level = *logLevel
+		case "log-dir":
+			cfg.Log.Dir = *logDir
+		}
+	})
+	cfg.Touch.Opacity = math.Max(0.1, math.Min(1.0, cfg.Touch.Opacity))
+	if *secondScreen {
+		// -grpc names the first elrs-map's web server when it is elsewhere
+		mirror := ""
+		if grpcSet {
+			mirror = *grpcAddr
+		}
+		secondScreenConfig(cfg, mirror)
 	}
 
-	// Initialize components
-	client := NewGRPCClient(*grpcAddr)
-	tileManager := NewTileManager(*cacheDir)
-	app := NewApp(client, tileManager, *width, *height, *fullscreen)
-	app.showTouchBtns = *touchBtns
-	app.centerLat = *defaultLat
-	app.centerLon = *defaultLon
+	if err := SetupLogging(cfg.Log); err != nil {
+		logApp.Warnf("Could not open log file: %v (console only)", err)
+	}
+	defer CloseLogging()
+
+	// -sim stays for the session; it isn't saved as backend.source
+	kind, addr := cfg.Backend.Source, cfg.Backend.Address
+	if *sim {
+		kind = "sim"
+	}
+	if kind == "sim" {
+		addr = "simulator"
+	}
+	client, err := NewClient(kind, addr, cfg)
+	if err != nil {
+		logApp.Fatalf("%v", err)
+	}
+	logTelem.Infof("Telemetry from %s (%s)", addr, kind)
+	client.SetStallTimeout(cfg.Backend.StallTimeout)
+
+	if *headless {
+		if *secondScreen {
+			logApp.Fatalf("-second-screen needs a display")
+		}
+		runHeadless(client, cfg)
+		return
+	}
+
+	runUI(client, cfg, *configPath, uiOptions{hud: *hud, secondScreen: *secondScreen, configErr: configErr})
+}
+
+// uiOptions are the session's settings for the window that aren't saved
+type uiOptions struct {
+	hud          string // HUD mode to start in, by name; empty = as saved
+	secondScreen bool   // Mirroring another elrs-map; see secondScreenConfig
+	configErr    error  // Why the config file didn't load; it is then never saved over
+}
+
+// runHeadless runs without the Ebiten UI until interrupted
+func runHeadless(client *GRPCClient, cfg *Config) {
+	logApp.Infof("Running headless")
+	h := NewHeadless(client, cfg)
 
-	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// Ctrl+C everywhere; SIGTERM from systemd, or closing the console on Windows
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-sigChan
-		log.Println("Shutting down...")
-		app.Shutdown()
+		logApp.Infof("Shutting down...")
+		h.Shutdown()
+		CloseLogging()
 		os.Exit(0)
 	}()
 
-	// Run the application
-	if err := app.Run(); err != nil {
-		log.Fatalf("Application error: %v", err)
-	}
+	h.Run()
 }
@@ -0,0 +1,30 @@
+//go:build !headless
+
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2/colorm"
+)
+
+// mapColorM is the brightness, contrast and saturation the tiles are drawn
+// with, and false when they are left as downloaded
+func mapColorM(cfg MapConfig) (colorm.ColorM, bool) {
+	var cm colorm.ColorM
+	if cfg.Brightness == 0 && cfg.Contrast == 1 && cfg.Saturation == 1 {
+		return cm, false
+	}
+	cm.ChangeHSV(0, cfg.Saturation, 1)
+	// Contrast pivots around mid gray, so it doesn't also brighten or darken
+	c := cfg.Contrast
+	cm.Scale(c, c, c, 1)
+	offset := (1-c)/2 + cfg.Brightness
+	cm.Translate(offset, offset, offset, 0)
+	return cm, true
+}
+
+// stepMapColor moves a map color setting by d tenths, within lo and hi
+func stepMapColor(v float64, d int, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, math.Round(v*10+float64(d))/10))
+}
@@ -0,0 +1,168 @@
+//go:build !headless
+
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// MapView is how the map lies on the screen: the area it fills, the point
+// at the middle of it, the zoom and which way is up. The tiles, everything
+// drawn over them and the clicks on the map all go through it, so they
+// agree on where a place is.
+type MapView struct {
+	X, Width, Height     int     // Map area on screen; the status bar covers its bottom
+	CenterLat, CenterLon float64 // At the middle of the map area
+	Zoom                 float64 // Between levels the tiles of the level below are scaled up
+	Rotation             float64 // Bearing at the top of the screen in degrees, 0 for north up
+}
+
+// mapView returns the view of the map area at offsetX, mapWidth wide
+func (a *App) mapView(offsetX, mapWidth int) MapView {
+	return MapView{
+		X: offsetX, Width: mapWidth, Height: a.height,
+		CenterLat: a.centerLat, CenterLon: a.centerLon,
+		Zoom: float64(a.zoom),
+	}
+}
+
+// currentMapView returns the view of the map as the HUD mode lays it out
+func (a *App) currentMapView() MapView {
+	return a.mapView(a.mapArea())
+}
+
+// TileZoom is the level of the tiles drawn: the zoom's whole part
+func (v MapView) TileZoom() int {
+	return int(math.Floor(v.Zoom))
+}
+
+// tileScale is how much the tiles are scaled up between levels
+func (v MapView) tileScale() float64 {
+	return math.Exp2(v.Zoom - math.Floor(v.Zoom))
+}
+
+// WorldSize is the length of the equator in screen pixels
+func (v MapView) WorldSize() float64 {
+	return TileSize * math.Exp2(v.Zoom)
+}
+
+// MetersPerPixel is the ground distance across a screen pixel at lat
+func (v MapView) MetersPerPixel(lat float64) float64 {
+	return 2 * math.Pi * earthRadius * math.Cos(lat*math.Pi/180) / v.WorldSize()
+}
+
+// middle returns the screen pixel at the middle of the map area
+func (v MapView) middle() (float64, float64) {
+	return float64(v.X + v.Width/2), float64(v.Height / 2)
+}
+
+// center returns the world pixel at the middle of the map area, at the
+// tile zoom
+func (v MapView) center() (float64, float64) {
+	return LatLonToPixel(v.CenterLat, v.CenterLon, v.TileZoom())
+}
+
+// WorldToScreen places a world pixel at the tile zoom on screen
+func (v MapView) WorldToScreen(wx, wy float64) (float64, float64) {
+	cx, cy := v.center()
+	s := v.tileScale()
+	dx, dy := (wx-cx)*s, (wy-cy)*s
+	if v.Rotation != 0 {
+		sin, cos := math.Sincos(v.Rotation * math.Pi / 180)
+		dx, dy = dx*cos+dy*sin, dy*cos-dx*sin
+	}
+	mx, my := v.middle()
+	return mx + dx, my + dy
+}
+
+// ScreenToWorld returns the world pixel at the tile zoom under a screen pixel
+func (v MapView) ScreenToWorld(x, y float64) (float64, float64) {
+	mx, my := v.middle()
+	dx, dy := x-mx, y-my
+	if v.Rotation != 0 {
+		sin, cos := math.Sincos(v.Rotation * math.Pi / 180)
+		dx, dy = dx*cos-dy*sin, dx*sin+dy*cos
+	}
+	cx, cy := v.center()
+	s := v.tileScale()
+	return cx + dx/s, cy + dy/s
+}
+
+// LatLonToScreen returns where a place is on screen. Across the ±180°
+// seam that is the copy of it nearest the middle of the map.
+func (v MapView) LatLonToScreen(lat, lon float64) (float64, float64) {
+	wx, wy := LatLonToPixel(lat, lon, v.TileZoom())
+	cx, _ := v.center()
+	return v.WorldToScreen(wrapNear(wx, cx, TileSize*math.Exp2(float64(v.TileZoom()))), wy)
+}
+
+// ScreenToLatLon returns the place under a screen pixel
+func (v MapView) ScreenToLatLon(x, y float64) (float64, float64) {
+	wx, wy := v.ScreenToWorld(x, y)
+	return PixelToLatLon(wx, wy, v.TileZoom())
+}
+
+// ScreenHeading turns a compass heading into the direction it points on
+// screen, in degrees clockwise from up
+func (v MapView) ScreenHeading(heading float64) float64 {
+	return heading - v.Rotation
+}
+
+// GeoM transforms world pixels at the tile zoom to the screen, for images
+// laid out in world pixels such as the tiles and the flight path layer
+func (v MapView) GeoM() ebiten.GeoM {
+	var g ebiten.GeoM
+	cx, cy := v.center()
+	g.Translate(-cx, -cy)
+	if s := v.tileScale(); s != 1 {
+		g.Scale(s, s)
+	}
+	if v.Rotation != 0 {
+		g.Rotate(-v.Rotation * math.Pi / 180)
+	}
+	mx, my := v.middle()
+	g.Translate(mx, my)
+	return g
+}
+
+// Scaled tells whether images in world pixels are resampled on the way to
+// the screen, rather than copied pixel for pixel
+func (v MapView) Scaled() bool {
+	return v.Rotation != 0 || v.tileScale() != 1
+}
+
+// WorldBounds returns the world pixels at the tile zoom that the map area
+// shows, as the box around it when the map is turned
+func (v MapView) WorldBounds() (left, top, width, height float64) {
+	x0, x1 := float64(v.X), float64(v.X+v.Width)
+	y0, y1 := 0.0, float64(v.Height)
+	left, top = math.Inf(1), math.Inf(1)
+	right, bottom := math.Inf(-1), math.Inf(-1)
+	for _, c := range [4][2]float64{{x0, y0}, {x1, y0}, {x0, y1}, {x1, y1}} {
+		wx, wy := v.ScreenToWorld(c[0], c[1])
+		left, right = math.Min(left, wx), math.Max(right, wx)
+		top, bottom = math.Min(top, wy), math.Max(bottom, wy)
+	}
+	return left, top, right - left, bottom - top
+}
+
+// visible returns the screen edges of the map left uncovered by the
+// status bar
+func (v MapView) visible() (left, top, right, bottom float64) {
+	return float64(v.X), 0, float64(v.X + v.Width), float64(v.Height - statusBarHeight)
+}
+
+// Contains tells whether a screen point is on the map and not under the
+// status bar
+func (v MapView) Contains(x, y float64) bool {
+	left, top, right, bottom := v.visible()
+	return x >= left && x < right && y >= top && y < bottom
+}
+
+// ContainsX tells whether a screen x is across the map area, for markers
+// that may sit partly under the status bar
+func (v MapView) ContainsX(x float64) bool {
+	return x > float64(v.X) && x < float64(v.X+v.Width)
+}
@@ -0,0 +1,63 @@
+//go:build !headless
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMapViewRoundTrip(t *testing.T) {
+	views := []MapView{
+		{Width: 800, Height: 600, CenterLat: -22.9, CenterLon: -47.06, Zoom: 15},
+		{X: 300, Width: 500, Height: 600, CenterLat: 51.5, CenterLon: -0.12, Zoom: 12.5, Rotation: 30},
+		{Width: 800, Height: 600, CenterLat: -16.5, CenterLon: 179.99, Zoom: 10},
+		{Width: 800, Height: 600, CenterLat: 65.6, CenterLon: -179.99, Zoom: 8.3, Rotation: -120},
+		{Width: 800, Height: 600, CenterLat: 84.5, CenterLon: 20, Zoom: 6},
+	}
+	for _, v := range views {
+		mx, my := v.middle()
+		for _, d := range [][2]float64{{0, 0}, {-250, 100}, {300, -200}, {399, 299}} {
+			lat, lon := v.ScreenToLatLon(mx+d[0], my+d[1])
+			if lon < -180 || lon >= 180 || math.Abs(lat) > MaxLat {
+				t.Errorf("%+v: pixel %v is at %v, %v, off the map", v, d, lat, lon)
+			}
+			x, y := v.LatLonToScreen(lat, lon)
+			if math.Abs(x-(mx+d[0])) > 1e-6 || math.Abs(y-(my+d[1])) > 1e-6 {
+				t.Errorf("%+v: pixel %v came back as %v, %v", v, d, x-mx, y-my)
+			}
+		}
+	}
+}
+
+func TestMapViewAcrossTheSeam(t *testing.T) {
+	v := MapView{Width: 800, Height: 600, CenterLat: -16.5, CenterLon: 179.99, Zoom: 10}
+	mx, my := v.middle()
+
+	// A place just east of the seam is drawn just right of the middle, not
+	// a world away
+	x, y := v.LatLonToScreen(-16.5, -179.99)
+	if x <= mx || x > mx+100 || math.Abs(y-my) > 1e-6 {
+		t.Errorf("-179.99 drawn at %v, %v, middle %v, %v", x, y, mx, my)
+	}
+
+	// Dragging the map left pans east across the seam
+	lat, lon := v.ScreenToLatLon(mx+300, my)
+	if lon >= 0 || lon < -180 || math.Abs(lat+16.5) > 1e-6 {
+		t.Errorf("panned east to %v, %v", lat, lon)
+	}
+}
+
+func TestMapViewNearThePole(t *testing.T) {
+	v := MapView{Width: 800, Height: 600, CenterLat: MaxLat, CenterLon: 0, Zoom: 3}
+	mx, my := v.middle()
+	// Above the top of the world clamps to it
+	if lat, _ := v.ScreenToLatLon(mx, my-200); lat != MaxLat {
+		t.Errorf("above the world at lat %v, want %v", lat, MaxLat)
+	}
+	// Past the pole is drawn where the top of the world is
+	_, y := v.LatLonToScreen(90, 0)
+	if math.Abs(y-my) > 1e-6 {
+		t.Errorf("pole drawn at y %v, want %v", y, my)
+	}
+}
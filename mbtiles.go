@@ -0,0 +1,129 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite" // Pure Go, so cross-compiling for the Pi needs no cgo
+)
+
+// MBTilesStore keeps tiles in a single SQLite file using the MBTiles schema,
+// so the cache can also be opened by QGIS, MBTiles servers, or phone apps.
+// Rows are TMS (flipped Y) as the spec requires. Validators and fetch times
+// live in an extra tile_meta table that other readers ignore.
+type MBTilesStore struct {
+	db *sql.DB
+}
+
+// OpenMBTiles opens or creates an MBTiles file for a map source
+func OpenMBTiles(path, name string) (*MBTilesStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// One connection serializes writers instead of failing with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	schema := []string{
+		// WAL batches writes into fewer flash erase blocks than a rollback journal
+		`PRAGMA journal_mode = WAL`,
+		`PRAGMA synchronous = NORMAL`,
+		`CREATE TABLE IF NOT EXISTS metadata (name TEXT PRIMARY KEY, value TEXT)`,
+		`CREATE TABLE IF NOT EXISTS tiles (
+			zoom_level INTEGER, tile_column INTEGER, tile_row INTEGER, tile_data BLOB,
+			PRIMARY KEY (zoom_level, tile_column, tile_row))`,
+		`CREATE TABLE IF NOT EXISTS tile_meta (
+			zoom_level INTEGER, tile_column INTEGER, tile_row INTEGER,
+			fetched INTEGER, etag TEXT, last_modified TEXT,
+			PRIMARY KEY (zoom_level, tile_column, tile_row))`,
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	// Satellite imagery is JPEG; street tiles are PNG, which readers sniff
+	for k, v := range map[string]string{"name": name, "type": "baselayer", "version": "1.0", "format": "jpg"} {
+		db.Exec(`INSERT OR IGNORE INTO metadata (name, value) VALUES (?, ?)`, k, v)
+	}
+	return &MBTilesStore{db: db}, nil
+}
+
+// tmsRow converts an XYZ row to the MBTiles (TMS) row
+func tmsRow(z, y int) int {
+	return (1 << z) - 1 - y
+}
+
+// Get reads a tile and its metadata
+func (s *MBTilesStore) Get(z, x, y int) ([]byte, time.Time, tileMeta, bool) {
+	var (
+		data    []byte
+		fetched sql.NullInt64
+		etag    sql.NullString
+		lastMod sql.NullString
+	)
+	err := s.db.QueryRow(`
+		SELECT t.tile_data, m.fetched, m.etag, m.last_modified
+		FROM tiles t LEFT JOIN tile_meta m
+		  ON m.zoom_level = t.zoom_level AND m.tile_column = t.tile_column AND m.tile_row = t.tile_row
+		WHERE t.zoom_level = ? AND t.tile_column = ? AND t.tile_row = ?`,
+		z, x, tmsRow(z, y)).Scan(&data, &fetched, &etag, &lastMod)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logTile.Warnf("MBTiles read error %d/%d/%d: %v", z, x, y, err)
+		}
+		return nil, time.Time{}, tileMeta{}, false
+	}
+	// Tiles imported from elsewhere have no fetch time; treat them as old
+	var when time.Time
+	if fetched.Valid {
+		when = time.Unix(fetched.Int64, 0)
+	}
+	return data, when, tileMeta{ETag: etag.String, LastModified: lastMod.String}, true
+}
+
+// Put stores a tile with its validators
+func (s *MBTilesStore) Put(z, x, y int, data []byte, meta tileMeta) error {
+	return s.put(z, x, y, data, meta, time.Now())
+}
+
+func (s *MBTilesStore) put(z, x, y int, data []byte, meta tileMeta, fetched time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	row := tmsRow(z, y)
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO tiles (zoom_level, tile_column, tile_row, tile_data) VALUES (?, ?, ?, ?)`,
+		z, x, row, data); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO tile_meta (zoom_level, tile_column, tile_row, fetched, etag, last_modified) VALUES (?, ?, ?, ?, ?, ?)`,
+		z, x, row, fetched.Unix(), meta.ETag, meta.LastModified); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Touch restarts a tile's max-age
+func (s *MBTilesStore) Touch(z, x, y int) error {
+	_, err := s.db.Exec(`INSERT INTO tile_meta (zoom_level, tile_column, tile_row, fetched) VALUES (?, ?, ?, ?)
+		ON CONFLICT (zoom_level, tile_column, tile_row) DO UPDATE SET fetched = excluded.fetched`,
+		z, x, tmsRow(z, y), time.Now().Unix())
+	return err
+}
+
+// Close closes the database
+func (s *MBTilesStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// mdnsGroup is the IPv4 multicast DNS group and port
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// mdnsTTL is how long peers may cache our records, in seconds
+const mdnsTTL = 120
+
+// MDNSService is a service instance found on the LAN
+type MDNSService struct {
+	Instance string // Without the service suffix, e.g. "Bob"
+	Addr     net.IP
+	Port     int
+	Text     []string
+}
+
+// MDNSResponder answers multicast DNS queries for a single service
+// instance, so other devices can find it without knowing its address.
+// Only the records DNS-SD browsing needs are served: PTR, SRV, TXT and A.
+type MDNSResponder struct {
+	service  string // e.g. "_elrs-map._udp.local."
+	instance string // e.g. "Bob._elrs-map._udp.local."
+	host     string // e.g. "raspberrypi.local."
+	port     int
+	text     []string
+
+	conn *net.UDPConn
+	done chan struct{}
+	once sync.Once
+}
+
+// NewMDNSResponder creates a responder advertising instance of service
+// (e.g. "_elrs-map._udp") on port
+func NewMDNSResponder(service, instance string, port int, text []string) *MDNSResponder {
+	host := localHostname()
+	return &MDNSResponder{
+		service:  service + ".local.",
+		instance: mdnsLabel(instance) + "." + service + ".local.",
+		host:     mdnsLabel(host) + ".local.",
+		port:     port,
+		text:     text,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start joins the mDNS group and answers queries in the background
+func (r *MDNSResponder) Start() error {
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return err
+	}
+	r.conn = conn
+	go r.serve()
+	return nil
+}
+
+// Stop closes the responder
+func (r *MDNSResponder) Stop() {
+	r.once.Do(func() {
+		close(r.done)
+		if r.conn != nil {
+			r.conn.Close()
+		}
+	})
+}
+
+func (r *MDNSResponder) serve() {
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-r.done:
+				return
+			default:
+			}
+			logMDNS.Debugf("mDNS read error: %v", err)
+			continue
+		}
+		r.handleQuery(buf[:n], src)
+	}
+}
+
+// handleQuery answers a PTR (browse) query for our service
+func (r *MDNSResponder) handleQuery(data []byte, src *net.UDPAddr) {
+	var p dnsmessage.Parser
+	h, err := p.Start(data)
+	if err != nil || h.Response {
+		return
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return
+	}
+	for _, q := range questions {
+		if (q.Type != dnsmessage.TypePTR && q.Type != dnsmessage.TypeALL) || !strings.EqualFold(q.Name.String(), r.service) {
+			continue
+		}
+		// Queries from a port other than 5353 are one-shot (legacy unicast)
+		// queries that expect a normal DNS reply sent straight back
+		legacy := src.Port != mdnsGroup.Port
+		msg, err := r.response(h.ID, q, legacy)
+		if err != nil {
+			logMDNS.Warnf("mDNS response error: %v", err)
+			return
+		}
+		dst := mdnsGroup
+		if legacy {
+			dst = src
+		}
+		r.conn.WriteToUDP(msg, dst)
+		return
+	}
+}
+
+// response builds the PTR answer with SRV, TXT and A records attached
+func (r *MDNSResponder) response(id uint16, q dnsmessage.Question, legacy bool) ([]byte, error) {
+	service, err := dnsmessage.NewName(r.service)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := dnsmessage.NewName(r.instance)
+	if err != nil {
+		return nil, err
+	}
+	host, err := dnsmessage.NewName(r.host)
+	if err != nil {
+		return nil, err
+	}
+
+	hdr := dnsmessage.Header{Response: true, Authoritative: true}
+	if legacy {
+		hdr.ID = id
+	}
+	b := dnsmessage.NewBuilder(nil, hdr)
+	b.EnableCompression()
+	if legacy {
+		b.StartQuestions()
+		q.Class = dnsmessage.ClassINET
+		b.Question(q)
+	}
+
+	rh := func(name dnsmessage.Name) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: mdnsTTL}
+	}
+	b.StartAnswers()
+	b.PTRResource(rh(service), dnsmessage.PTRResource{PTR: instance})
+	b.StartAdditionals()
+	b.SRVResource(rh(instance), dnsmessage.SRVResource{Target: host, Port: uint16(r.port)})
+	text := r.text
+	if len(text) == 0 {
+		text = []string{""} // A TXT record must hold at least one string
+	}
+	b.TXTResource(rh(instance), dnsmessage.TXTResource{TXT: text})
+	for _, ip := range localIPv4s() {
+		var a dnsmessage.AResource
+		copy(a.A[:], ip)
+		b.AResource(rh(host), a)
+	}
+	return b.Finish()
+}
+
+// BrowseMDNS asks the LAN for instances of service (e.g. "_elrs-map._udp")
+// and collects the answers that arrive within timeout
+func BrowseMDNS(service string, timeout time.Duration) ([]MDNSService, error) {
+	fqdn := service + ".local."
+	name, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sending from an ephemeral port makes responders reply to us directly
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: uint16(time.Now().UnixNano())})
+	b.StartQuestions()
+	b.Question(dnsmessage.Question{Name: name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET})
+	query, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteToUDP(query, mdnsGroup); err != nil {
+		return nil, err
+	}
+
+	type srv struct {
+		target string
+		port   int
+	}
+	var instances []string
+	srvs := map[string]srv{}
+	texts := map[string][]string{}
+	hosts := map[string]net.IP{}
+	sources := map[string]net.IP{} // Instance -> address the answer came from
+
+	buf := make([]byte, 9000)
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break // Deadline reached
+		}
+		var p dnsmessage.Parser
+		if h, err := p.Start(buf[:n]); err != nil || !h.Response {
+			continue
+		}
+		if err := p.SkipAllQuestions(); err != nil {
+			continue
+		}
+		answers, _ := p.AllAnswers()
+		p.SkipAllAuthorities()
+		extra, _ := p.AllAdditionals()
+
+		for _, rr := range append(answers, extra...) {
+			owner := strings.ToLower(rr.Header.Name.String())
+			switch body := rr.Body.(type) {
+			case *dnsmessage.PTRResource:
+				if owner == strings.ToLower(fqdn) {
+					inst := strings.ToLower(body.PTR.String())
+					if _, seen := sources[inst]; !seen {
+						instances = append(instances, inst)
+					}
+					sources[inst] = src.IP
+				}
+			case *dnsmessage.SRVResource:
+				srvs[owner] = srv{target: strings.ToLower(body.Target.String()), port: int(body.Port)}
+			case *dnsmessage.TXTResource:
+				texts[owner] = body.TXT
+			case *dnsmessage.AResource:
+				hosts[owner] = net.IP(body.A[:])
+			}
+		}
+	}
+
+	var found []MDNSService
+	suffix := "." + strings.ToLower(fqdn)
+	for _, inst := range instances {
+		s, ok := srvs[inst]
+		if !ok {
+			continue
+		}
+		addr := hosts[s.target]
+		if addr == nil {
+			addr = sources[inst]
+		}
+		found = append(found, MDNSService{
+			Instance: strings.TrimSuffix(inst, suffix),
+			Addr:     addr,
+			Port:     s.port,
+			Text:     texts[inst],
+		})
+	}
+	return found, nil
+}
+
+// mdnsLabel makes s usable as a single DNS label
+func mdnsLabel(s string) string {
+	s = strings.NewReplacer(".", "-", "\\", "-").Replace(s)
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	return s
+}
+
+// localHostname returns the machine name, or "elrs-map" if it is unknown
+func localHostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "elrs-map"
+	}
+	return name
+}
+
+// localIPv4s returns this machine's non-loopback IPv4 addresses
+func localIPv4s() []net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	var ips []net.IP
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
+			if ip4 := ipnet.IP.To4(); ip4 != nil {
+				ips = append(ips, ip4)
+			}
+		}
+	}
+	return ips
+}
+
+// mdnsTextValue returns the value of key in key=value TXT strings
+func mdnsTextValue(text []string, key string) string {
+	for _, t := range text {
+		if k, v, ok := strings.Cut(t, "="); ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// String formats the service for logs
+func (s MDNSService) String() string {
+	return fmt.Sprintf("%s (%s:%d)", s.Instance, s.Addr, s.Port)
+}
@@ -0,0 +1,215 @@
+//go:build !headless
+
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// MenuItem is a single row in a Menu
+type MenuItem struct {
+	Label    string
+	Value    func() string   // Optional value shown right-aligned
+	OnSelect func()          // Enter / tap / encoder push
+	OnAdjust func(delta int) // Left/right, for value rows
+	Active   func() bool     // Optional highlight (e.g. current port)
+}
+
+// Menu is a modal list usable with touch, keyboard, or a rotary encoder
+type Menu struct {
+	Title    string
+	Items    []MenuItem
+	selected int
+	open     bool
+
+	// Rebuild is called on Open so item lists can reflect current state
+	Rebuild func(m *Menu)
+	// OnClose is called when an open menu is closed
+	OnClose func()
+
+	// Layout (computed in Draw, used for hit testing)
+	x, y, w, rowH int
+
+	bgColor  color.RGBA
+	selColor color.RGBA
+	actColor color.RGBA
+}
+
+// NewMenu creates an empty closed menu
+func NewMenu(title string) *Menu {
+	return &Menu{
+		Title:    title,
+		rowH:     28, // Finger-sized rows
+		w:        340,
+		bgColor:  color.RGBA{20, 20, 25, 235},
+		selColor: color.RGBA{0, 120, 200, 255},
+		actColor: color.RGBA{0, 150, 0, 255},
+	}
+}
+
+// Open shows the menu
+func (m *Menu) Open() {
+	if m.Rebuild != nil {
+		m.Rebuild(m)
+	}
+	if m.selected >= len(m.Items) {
+		m.selected = 0
+	}
+	m.open = true
+}
+
+// Close hides the menu
+func (m *Menu) Close() {
+	if m.open && m.OnClose != nil {
+		m.OnClose()
+	}
+	m.open = false
+}
+
+// IsOpen returns true while the menu is shown
+func (m *Menu) IsOpen() bool {
+	return m.open
+}
+
+// Move changes the selected row by delta, wrapping around
+func (m *Menu) Move(delta int) {
+	if len(m.Items) == 0 {
+		return
+	}
+	n := len(m.Items)
+	m.selected = ((m.selected+delta)%n + n) % n
+}
+
+// Select activates the selected row; value rows without an OnSelect
+// step forward so a single encoder push can still change them
+func (m *Menu) Select() {
+	if m.selected >= len(m.Items) {
+		return
+	}
+	item := m.Items[m.selected]
+	if item.OnSelect != nil {
+		item.OnSelect()
+	} else if item.OnAdjust != nil {
+		item.OnAdjust(1)
+	}
+}
+
+// Adjust changes the value of the selected row
+func (m *Menu) Adjust(delta int) {
+	if m.selected < len(m.Items) && m.Items[m.selected].OnAdjust != nil {
+		m.Items[m.selected].OnAdjust(delta)
+	}
+}
+
+// Update handles keyboard and touch input while the menu is open
+func (m *Menu) Update() {
+	if !m.open {
+		return
+	}
+
+	// Keyboard
+	if inpututil.IsKeyJustPressed(ebiten.KeyUp) || inpututil.IsKeyJustPressed(ebiten.KeyW) {
+		m.Move(-1)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyDown) || inpututil.IsKeyJustPressed(ebiten.KeyS) {
+		m.Move(1)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyLeft) || inpututil.IsKeyJustPressed(ebiten.KeyA) {
+		m.Adjust(-1)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyRight) || inpututil.IsKeyJustPressed(ebiten.KeyD) {
+		m.Adjust(1)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) || inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		m.Select()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || inpututil.IsKeyJustPressed(ebiten.KeyBackspace) {
+		m.Close()
+		return
+	}
+
+	// Mouse / touch
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		mx, my := ebiten.CursorPosition()
+		m.handleTap(mx, my)
+	}
+	for _, id := range inpututil.AppendJustPressedTouchIDs(nil) {
+		tx, ty := ebiten.TouchPosition(id)
+		m.handleTap(tx, ty)
+	}
+}
+
+// handleTap selects the tapped row; tapping outside the menu closes it.
+// Tapping the left/right third of a value row adjusts it instead.
+func (m *Menu) handleTap(x, y int) {
+	top := m.y + m.rowH // Below the title row
+	bottom := top + len(m.Items)*m.rowH
+	if x < m.x || x > m.x+m.w || y < m.y || y >= bottom {
+		m.Close()
+		return
+	}
+	if y < top {
+		return // Title row
+	}
+
+	m.selected = (y - top) / m.rowH
+	item := m.Items[m.selected]
+	switch {
+	case item.OnAdjust != nil && x < m.x+m.w/3:
+		m.Adjust(-1)
+	case item.OnAdjust != nil && x > m.x+m.w*2/3:
+		m.Adjust(1)
+	default:
+		m.Select()
+	}
+}
+
+// Draw renders the menu centered on screen
+func (m *Menu) Draw(screen *ebiten.Image) {
+	if !m.open {
+		return
+	}
+
+	screenW, screenH := screen.Bounds().Dx(), screen.Bounds().Dy()
+	h := (len(m.Items) + 1) * m.rowH
+	m.x = (screenW - m.w) / 2
+	m.y = (screenH - h) / 2
+	if m.y < 0 {
+		m.y = 0
+	}
+
+	vector.DrawFilledRect(screen, float32(m.x), float32(m.y), float32(m.w), float32(h), m.bgColor, true)
+	vector.StrokeRect(screen, float32(m.x), float32(m.y), float32(m.w), float32(h), 2, color.RGBA{255, 255, 255, 255}, true)
+
+	// Title
+	ebitenutil.DebugPrintAt(screen, m.Title, m.x+10, m.y+m.rowH/2-7)
+	vector.StrokeLine(screen, float32(m.x), float32(m.y+m.rowH), float32(m.x+m.w), float32(m.y+m.rowH), 1, color.RGBA{80, 80, 90, 255}, true)
+
+	for i, item := range m.Items {
+		rowY := m.y + (i+1)*m.rowH
+		if i == m.selected {
+			vector.DrawFilledRect(screen, float32(m.x+2), float32(rowY+1), float32(m.w-4), float32(m.rowH-2), m.selColor, true)
+		} else if item.Active != nil && item.Active() {
+			vector.DrawFilledRect(screen, float32(m.x+2), float32(rowY+1), float32(m.w-4), float32(m.rowH-2), m.actColor, true)
+		}
+
+		label := item.Label
+		if item.Active != nil && item.Active() {
+			label = "* " + label
+		}
+		ebitenutil.DebugPrintAt(screen, label, m.x+10, rowY+m.rowH/2-7)
+
+		if item.Value != nil {
+			val := item.Value()
+			if item.OnAdjust != nil {
+				val = "< " + val + " >"
+			}
+			ebitenutil.DebugPrintAt(screen, val, m.x+m.w-10-len(val)*6, rowY+m.rowH/2-7)
+		}
+	}
+}
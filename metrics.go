@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricsWriter writes metrics in the Prometheus text format, which is
+// simple enough not to need the client library
+type metricsWriter struct {
+	w io.Writer
+}
+
+// family starts a metric: its type (gauge, counter) and help text
+func (m metricsWriter) family(name, kind, help string) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes one value of a metric, labels given as name, value pairs
+func (m metricsWriter) sample(name string, v float64, labels ...string) {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, "%s=%q", labels[i], labels[i+1])
+		}
+		b.WriteByte('}')
+	}
+	fmt.Fprintf(m.w, "%s %s\n", b.String(), strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+func (m metricsWriter) gauge(name, help string, v float64) {
+	m.family(name, "gauge", help)
+	m.sample(name, v)
+}
+
+func (m metricsWriter) counter(name, help string, v float64) {
+	m.family(name, "counter", help)
+	m.sample(name, v)
+}
+
+// boolMetric is 1 for true, as Prometheus has no booleans
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// handleMetrics serves /metrics for Prometheus, to graph long bench
+// sessions: telemetry frame counts, the link, the process, and whatever
+// the display adds (tile cache, frame times)
+func (w *WebServer) handleMetrics(rw http.ResponseWriter, r *http.Request) {
+	if !w.config.Web.Metrics {
+		http.NotFound(rw, r)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m := metricsWriter{rw}
+
+	state := w.client.GetState()
+	m.gauge("elrs_map_backend_connected", "Whether the backend is connected.", boolMetric(state.Connected))
+	m.gauge("elrs_map_link_started", "Whether the TX link is started.", boolMetric(state.LinkStarted))
+	m.gauge("elrs_map_telemetry_receiving", "Whether telemetry arrived in the last few seconds.",
+		boolMetric(!state.LastUpdate.IsZero() && time.Since(state.LastUpdate) < telemetryTimeout))
+
+	frames := w.client.FrameCounts()
+	kinds := make([]string, 0, len(frames))
+	for k := range frames {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	m.family("elrs_map_telemetry_frames_total", "counter", "Telemetry frames received, by kind.")
+	for _, k := range kinds {
+		m.sample("elrs_map_telemetry_frames_total", float64(frames[k]), "kind", k)
+	}
+
+	m.counter("elrs_map_telemetry_stream_restarts_total", "Stalled telemetry streams restarted.", float64(w.client.StreamRestarts()))
+
+	m.gauge("elrs_map_link_quality_percent", "Uplink link quality.", float64(state.LinkQuality))
+	m.family("elrs_map_rssi_dbm", "gauge", "Uplink RSSI, by receiver antenna.")
+	m.sample("elrs_map_rssi_dbm", float64(state.RSSI1), "antenna", "1")
+	m.sample("elrs_map_rssi_dbm", float64(state.RSSI2), "antenna", "2")
+	m.gauge("elrs_map_snr_db", "Uplink signal to noise ratio.", float64(state.SNR))
+	m.gauge("elrs_map_tx_power_mw", "Transmitter power.", float64(state.TXPower))
+	m.gauge("elrs_map_gps_satellites", "Satellites the aircraft's GPS uses.", float64(state.Satellites))
+	m.gauge("elrs_map_battery_volts", "Aircraft battery voltage.", float64(state.Voltage))
+
+	if w.Metrics != nil {
+		w.Metrics(m)
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	m.gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine()))
+	m.gauge("go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use.", float64(mem.HeapAlloc))
+	m.counter("go_gc_cycles_total", "Number of completed GC cycles.", float64(mem.NumGC))
+}
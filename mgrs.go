@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// WGS84 ellipsoid and UTM scale on the central meridian
+const (
+	wgs84A  = 6378137.0
+	wgs84F  = 1 / 298.257223563
+	utmK0   = 0.9996
+	utmE0   = 500000.0   // False easting
+	utmN0S  = 10000000.0 // False northing south of the equator
+	mgrsMin = -80.0      // MGRS covers these latitudes; UPS the poles
+	mgrsMax = 84.0
+)
+
+// mgrsBands are the 8° latitude bands from 80°S, X stretching to 84°N
+const mgrsBands = "CDEFGHJKLMNPQRSTUVWX"
+
+// mgrsColumns and mgrsRows letter the 100 km squares, skipping I and O
+const (
+	mgrsColumns = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	mgrsRows    = "ABCDEFGHJKLMNPQRSTUV"
+)
+
+// UTM is a position on the Universal Transverse Mercator grid
+type UTM struct {
+	Zone     int
+	South    bool
+	Easting  float64
+	Northing float64
+}
+
+// utmZone returns the zone of a position, with the exceptions around
+// Norway and Svalbard
+func utmZone(lat, lon float64) int {
+	zone := int(math.Floor((lon+180)/6)) + 1
+	switch {
+	case lat >= 56 && lat < 64 && lon >= 3 && lon < 12:
+		zone = 32
+	case lat >= 72 && lon >= 0 && lon < 9:
+		zone = 31
+	case lat >= 72 && lon >= 9 && lon < 21:
+		zone = 33
+	case lat >= 72 && lon >= 21 && lon < 33:
+		zone = 35
+	case lat >= 72 && lon >= 33 && lon < 42:
+		zone = 37
+	}
+	return max(1, min(60, zone))
+}
+
+// ToUTM converts a position to UTM in its own zone
+func ToUTM(lat, lon float64) UTM {
+	return toUTMZone(lat, lon, utmZone(lat, lon), lat < 0)
+}
+
+// toUTMZone converts a position to UTM in the given zone and hemisphere,
+// which may not be the position's own so a grid can run past a zone edge
+func toUTMZone(lat, lon float64, zone int, south bool) UTM {
+	e2 := wgs84F * (2 - wgs84F)
+	ep2 := e2 / (1 - e2)
+	phi := lat * math.Pi / 180
+	lon0 := float64((zone-1)*6-180+3) * math.Pi / 180
+
+	sin, cos, tan := math.Sin(phi), math.Cos(phi), math.Tan(phi)
+	n := wgs84A / math.Sqrt(1-e2*sin*sin)
+	t := tan * tan
+	c := ep2 * cos * cos
+	a := cos * (lon*math.Pi/180 - lon0)
+	m := utmMeridianArc(phi, e2)
+
+	u := UTM{Zone: zone, South: south}
+	u.Easting = utmE0 + utmK0*n*(a+(1-t+c)*math.Pow(a, 3)/6+
+		(5-18*t+t*t+72*c-58*ep2)*math.Pow(a, 5)/120)
+	u.Northing = utmK0 * (m + n*tan*(a*a/2+(5-t+9*c+4*c*c)*math.Pow(a, 4)/24+
+		(61-58*t+t*t+600*c-330*ep2)*math.Pow(a, 6)/720))
+	if south {
+		u.Northing += utmN0S
+	}
+	return u
+}
+
+// utmMeridianArc is the distance from the equator to latitude phi along
+// the meridian
+func utmMeridianArc(phi, e2 float64) float64 {
+	e4, e6 := e2*e2, e2*e2*e2
+	return wgs84A * ((1-e2/4-3*e4/64-5*e6/256)*phi -
+		(3*e2/8+3*e4/32+45*e6/1024)*math.Sin(2*phi) +
+		(15*e4/256+45*e6/1024)*math.Sin(4*phi) -
+		(35*e6/3072)*math.Sin(6*phi))
+}
+
+// LatLon converts a UTM position back to latitude and longitude
+func (u UTM) LatLon() (float64, float64) {
+	e2 := wgs84F * (2 - wgs84F)
+	ep2 := e2 / (1 - e2)
+	e4, e6 := e2*e2, e2*e2*e2
+	northing := u.Northing
+	if u.South {
+		northing -= utmN0S
+	}
+
+	mu := northing / utmK0 / (wgs84A * (1 - e2/4 - 3*e4/64 - 5*e6/256))
+	e1 := (1 - math.Sqrt(1-e2)) / (1 + math.Sqrt(1-e2))
+	phi1 := mu + (3*e1/2-27*math.Pow(e1, 3)/32)*math.Sin(2*mu) +
+		(21*e1*e1/16-55*math.Pow(e1, 4)/32)*math.Sin(4*mu) +
+		(151*math.Pow(e1, 3)/96)*math.Sin(6*mu) +
+		(1097*math.Pow(e1, 4)/512)*math.Sin(8*mu)
+
+	sin, cos, tan := math.Sin(phi1), math.Cos(phi1), math.Tan(phi1)
+	c1 := ep2 * cos * cos
+	t1 := tan * tan
+	n1 := wgs84A / math.Sqrt(1-e2*sin*sin)
+	r1 := wgs84A * (1 - e2) / math.Pow(1-e2*sin*sin, 1.5)
+	d := (u.Easting - utmE0) / (n1 * utmK0)
+
+	phi := phi1 - (n1*tan/r1)*(d*d/2-
+		(5+3*t1+10*c1-4*c1*c1-9*ep2)*math.Pow(d, 4)/24+
+		(61+90*t1+298*c1+45*t1*t1-252*ep2-3*c1*c1)*math.Pow(d, 6)/720)
+	lon := (d - (1+2*t1+c1)*math.Pow(d, 3)/6 +
+		(5-2*c1+28*t1-3*c1*c1+8*ep2+24*t1*t1)*math.Pow(d, 5)/120) / cos
+	return phi * 180 / math.Pi, float64((u.Zone-1)*6-180+3) + lon*180/math.Pi
+}
+
+// mgrsBand returns the latitude band letter, or 0 outside MGRS
+func mgrsBand(lat float64) byte {
+	if lat < mgrsMin || lat > mgrsMax {
+		return 0
+	}
+	return mgrsBands[min(len(mgrsBands)-1, int((lat-mgrsMin)/8))]
+}
+
+// mgrsSquare returns the two letters of the 100 km square holding a UTM
+// position
+func mgrsSquare(u UTM) string {
+	col := int(u.Easting/100000) - 1
+	row := int(math.Floor(u.Northing/100000)) % 20
+	if u.Zone%2 == 0 {
+		// Even zones' rows start five letters on
+		row = (row + 5) % 20
+	}
+	if col < 0 || col >= 8 || row < 0 {
+		return "??"
+	}
+	return string([]byte{mgrsColumns[(u.Zone-1)%3*8+col], mgrsRows[row]})
+}
+
+// FormatMGRS writes a position as an MGRS reference with digits figures
+// each of easting and northing, e.g. "23K LQ 12345 67890" to the meter at
+// 5, or just the 100 km square at 0. It is "" near the poles, which MGRS
+// leaves to UPS.
+func FormatMGRS(lat, lon float64, digits int) string {
+	band := mgrsBand(lat)
+	if band == 0 {
+		return ""
+	}
+	u := ToUTM(lat, lon)
+	if digits <= 0 {
+		return fmt.Sprintf("%d%c %s", u.Zone, band, mgrsSquare(u))
+	}
+	scale := math.Pow(10, float64(5-digits))
+	e := int(math.Mod(u.Easting, 100000) / scale)
+	n := int(math.Mod(u.Northing, 100000) / scale)
+	return fmt.Sprintf("%d%c %s %0*d %0*d", u.Zone, band, mgrsSquare(u), digits, e, digits, n)
+}
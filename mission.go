@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Waypoint is one point of a mission
+type Waypoint struct {
+	Name string
+	Lat  float64
+	Lon  float64
+	Alt  float64 // Meters, 0 if the file has none
+}
+
+// Mission is a route loaded from a planner file
+type Mission struct {
+	Name      string
+	Waypoints []Waypoint
+}
+
+// LoadMission reads a Mission Planner / QGC .waypoints file, an INAV
+// .mission file, or the route (or else the waypoints) of a GPX file
+func LoadMission(path string) (*Mission, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var wps []Waypoint
+	switch {
+	case bytes.HasPrefix(data, []byte("QGC WPL")):
+		wps, err = parseWPL(data)
+	case bytes.Contains(data, []byte("<mission")):
+		wps, err = parseINAVMission(data)
+	case bytes.Contains(data, []byte("<gpx")):
+		wps, err = parseGPXRoute(data)
+	default:
+		err = fmt.Errorf("unknown format (want .waypoints, INAV .mission or GPX)")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(wps) == 0 {
+		return nil, fmt.Errorf("%s: no waypoints", path)
+	}
+	for i := range wps {
+		if wps[i].Name == "" {
+			wps[i].Name = fmt.Sprintf("WP%d", i+1)
+		}
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return &Mission{Name: name, Waypoints: wps}, nil
+}
+
+// parseWPL reads the "QGC WPL 110" text format. Item 0 is home and is
+// skipped, as are commands that aren't places to fly to.
+func parseWPL(data []byte) ([]Waypoint, error) {
+	var wps []Waypoint
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Scan() // Header
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) < 11 {
+			continue
+		}
+		idx, _ := strconv.Atoi(f[0])
+		cmd, _ := strconv.Atoi(f[3])
+		if idx == 0 {
+			continue
+		}
+		switch cmd {
+		case 16, 17, 18, 19, 21, 82: // WAYPOINT, LOITER_*, LAND, SPLINE_WAYPOINT
+		default:
+			continue
+		}
+		lat, err1 := strconv.ParseFloat(f[8], 64)
+		lon, err2 := strconv.ParseFloat(f[9], 64)
+		alt, _ := strconv.ParseFloat(f[10], 64)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("item %d: bad position", idx)
+		}
+		if lat == 0 && lon == 0 {
+			continue
+		}
+		wps = append(wps, Waypoint{Lat: lat, Lon: lon, Alt: alt})
+	}
+	return wps, sc.Err()
+}
+
+// parseINAVMission reads an INAV Configurator mission
+func parseINAVMission(data []byte) ([]Waypoint, error) {
+	var doc struct {
+		Items []struct {
+			No     int     `xml:"no,attr"`
+			Action string  `xml:"action,attr"`
+			Lat    float64 `xml:"lat,attr"`
+			Lon    float64 `xml:"lon,attr"`
+			Alt    float64 `xml:"alt,attr"` // Centimeters
+		} `xml:"missionitem"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var wps []Waypoint
+	for _, it := range doc.Items {
+		switch strings.ToUpper(it.Action) {
+		case "WAYPOINT", "POSHOLD_UNLIM", "POSHOLD_TIME", "LAND":
+		default:
+			continue
+		}
+		lat, lon := it.Lat, it.Lon
+		// Older configurators wrote degrees * 1e7
+		if math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+			lat, lon = lat/1e7, lon/1e7
+		}
+		wps = append(wps, Waypoint{Name: fmt.Sprintf("WP%d", it.No), Lat: lat, Lon: lon, Alt: it.Alt / 100})
+	}
+	return wps, nil
+}
+
+// parseGPXRoute reads the first route of a GPX file, or its waypoints if
+// it has no route
+func parseGPXRoute(data []byte) ([]Waypoint, error) {
+	type gpxPoint struct {
+		Lat  float64 `xml:"lat,attr"`
+		Lon  float64 `xml:"lon,attr"`
+		Ele  float64 `xml:"ele"`
+		Name string  `xml:"name"`
+	}
+	var doc struct {
+		Waypoints []gpxPoint `xml:"wpt"`
+		Routes    []struct {
+			Points []gpxPoint `xml:"rtept"`
+		} `xml:"rte"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	points := doc.Waypoints
+	if len(doc.Routes) > 0 && len(doc.Routes[0].Points) > 0 {
+		points = doc.Routes[0].Points
+	}
+	wps := make([]Waypoint, 0, len(points))
+	for _, p := range points {
+		wps = append(wps, Waypoint{Name: p.Name, Lat: p.Lat, Lon: p.Lon, Alt: p.Ele})
+	}
+	return wps, nil
+}
+
+// NavInfo is the guidance to the active waypoint
+type NavInfo struct {
+	Index   int // Into Mission.Waypoints
+	Name    string
+	Dist    float64       // Meters
+	Bearing float64       // Degrees from the aircraft
+	ETE     time.Duration // 0 when too slow to estimate
+	XTE     float64       // Meters off the leg, positive right of it
+	HasXTE  bool          // False on the first leg, which has no start
+	Done    bool          // Every waypoint has been reached
+}
+
+// Navigator steps through a mission as the aircraft reaches each waypoint
+type Navigator struct {
+	Mission *Mission
+	Radius  float64 // Meters; closer than this counts as reached
+	active  int
+}
+
+// NewNavigator starts a mission at its first waypoint
+func NewNavigator(m *Mission, radius float64) *Navigator {
+	if radius <= 0 {
+		radius = 30
+	}
+	return &Navigator{Mission: m, Radius: radius}
+}
+
+// Active returns the index of the waypoint being flown to
+func (n *Navigator) Active() int {
+	return n.active
+}
+
+// Skip moves to the next (delta 1) or previous (-1) waypoint
+func (n *Navigator) Skip(delta int) {
+	n.active = max(0, min(len(n.Mission.Waypoints), n.active+delta))
+}
+
+// Update advances past reached waypoints and returns the guidance from
+// the aircraft at lat, lon flying at speed km/h
+func (n *Navigator) Update(lat, lon, speed float64) NavInfo {
+	wps := n.Mission.Waypoints
+	for n.active < len(wps) && DistanceMeters(lat, lon, wps[n.active].Lat, wps[n.active].Lon) < n.Radius {
+		n.active++
+	}
+	if n.active >= len(wps) {
+		return NavInfo{Index: len(wps), Done: true}
+	}
+
+	wp := wps[n.active]
+	info := NavInfo{
+		Index:   n.active,
+		Name:    wp.Name,
+		Dist:    DistanceMeters(lat, lon, wp.Lat, wp.Lon),
+		Bearing: BearingDegrees(lat, lon, wp.Lat, wp.Lon),
+	}
+	if mps := speed / 3.6; mps > 1 {
+		info.ETE = time.Duration(info.Dist / mps * float64(time.Second))
+	}
+	if n.active > 0 {
+		prev := wps[n.active-1]
+		info.XTE = CrossTrackMeters(prev.Lat, prev.Lon, wp.Lat, wp.Lon, lat, lon)
+		info.HasXTE = true
+	}
+	return info
+}
+
+// CrossTrackMeters returns how far point 3 is from the great circle from
+// point 1 to point 2, positive to the right of it
+func CrossTrackMeters(lat1, lon1, lat2, lon2, lat3, lon3 float64) float64 {
+	d13 := DistanceMeters(lat1, lon1, lat3, lon3) / earthRadius
+	b13 := BearingDegrees(lat1, lon1, lat3, lon3) * math.Pi / 180
+	b12 := BearingDegrees(lat1, lon1, lat2, lon2) * math.Pi / 180
+	return math.Asin(math.Sin(d13)*math.Sin(b13-b12)) * earthRadius
+}
+
+// FormatETE formats a time enroute as m:ss, or "--:--" without one
+func FormatETE(d time.Duration) string {
+	if d <= 0 {
+		return "--:--"
+	}
+	s := int(d.Round(time.Second).Seconds())
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
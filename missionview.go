@@ -0,0 +1,108 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+var (
+	missionColor  = color.RGBA{200, 80, 255, 255}
+	activeWPColor = color.RGBA{255, 255, 255, 255}
+)
+
+// loadMission loads the configured mission, if any
+func (a *App) loadMission() {
+	a.navigator, a.navInfo = nil, nil
+	if a.config.Mission.File == "" {
+		return
+	}
+	m, err := LoadMission(a.config.Mission.File)
+	if err != nil {
+		logApp.Errorf("Could not load mission: %v", err)
+		return
+	}
+	logApp.Infof("Mission %s: %d waypoints", m.Name, len(m.Waypoints))
+	a.navigator = NewNavigator(m, a.config.Mission.Radius)
+}
+
+// updateNav refreshes the guidance to the active waypoint
+func (a *App) updateNav(state TelemetryState) {
+	if a.navigator == nil || !state.HasGPS {
+		a.navInfo = nil
+		return
+	}
+	info := a.navigator.Update(float64(state.Latitude), float64(state.Longitude), float64(state.GroundSpeed))
+	a.navInfo = &info
+}
+
+// drawMissionWithOffset draws the mission legs and numbered waypoints
+func (a *App) drawMissionWithOffset(screen *ebiten.Image, v MapView) {
+	if a.navigator == nil {
+		return
+	}
+	wps := a.navigator.Mission.Waypoints
+	active := a.navigator.Active()
+
+	toScreen := func(wp Waypoint) (float32, float32) {
+		x, y := v.LatLonToScreen(wp.Lat, wp.Lon)
+		return float32(x), float32(y)
+	}
+
+	for i := 1; i < len(wps); i++ {
+		x1, y1 := toScreen(wps[i-1])
+		x2, y2 := toScreen(wps[i])
+		c := missionColor
+		if i < active {
+			c.A = 110 // Legs already flown
+		}
+		vector.StrokeLine(screen, x1, y1, x2, y2, 2, c, true)
+	}
+	for i, wp := range wps {
+		sx, sy := toScreen(wp)
+		if !v.ContainsX(float64(sx)) {
+			continue
+		}
+		c := missionColor
+		if i == active {
+			c = activeWPColor
+		}
+		vector.DrawFilledCircle(screen, sx, sy, 8, color.RGBA{0, 0, 0, 180}, true)
+		vector.StrokeCircle(screen, sx, sy, 8, 2, c, true)
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%d", i+1), int(sx)-3, int(sy)-8)
+	}
+}
+
+// navLines returns the waypoint guidance as two short lines for the HUD
+func navLines(nav *NavInfo, units Units) (string, string) {
+	if nav.Done {
+		return "MISSION DONE", ""
+	}
+	line1 := fmt.Sprintf("%s %s %03.0f°", nav.Name, units.FormatDistance(nav.Dist), nav.Bearing)
+	line2 := "ETE " + FormatETE(nav.ETE)
+	if nav.HasXTE {
+		line2 += " XTE " + units.FormatXTE(nav.XTE)
+	}
+	return line1, line2
+}
+
+// guidanceLines returns the HUD lines for the mission and rally point
+func guidanceLines(nav *NavInfo, rally *RallyInfo, units Units) []string {
+	var lines []string
+	if nav != nil {
+		line1, line2 := navLines(nav, units)
+		lines = append(lines, line1)
+		if line2 != "" {
+			lines = append(lines, line2)
+		}
+	}
+	if rally != nil {
+		lines = append(lines, rallyLine(rally, units))
+	}
+	return lines
+}
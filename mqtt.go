@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	mqttKeepAlive    = 30 * time.Second
+	mqttDialTimeout  = 5 * time.Second
+	mqttRetryDelay   = 10 * time.Second
+	mqttMaxInflight  = 100 // QoS 1 messages kept waiting for a PUBACK
+	mqttResendNewest = 10  // Of those, how many a reconnect sends again; older telemetry is stale
+	mqttStatusOnline = "online"
+	mqttStatusOff    = "offline"
+)
+
+// MQTT 3.1.1 packet types, in the high nibble of the first byte
+const (
+	mqttConnect    = 1
+	mqttConnack    = 2
+	mqttPublish    = 3
+	mqttPuback     = 4
+	mqttPingreq    = 12
+	mqttDisconnect = 14
+)
+
+// mqttMessage is a QoS 1 publish waiting for the broker's PUBACK
+type mqttMessage struct {
+	topic   string
+	payload []byte
+	retain  bool
+}
+
+// MQTTPublisher publishes decoded telemetry to an MQTT broker, for home
+// automation or logging stacks. The status topic holds "online" while the
+// ground station is up, and the broker sets it to "offline" (the last
+// will) if the connection drops without a goodbye. It speaks just enough
+// MQTT 3.1.1 for that: connect, publish at QoS 0 or 1, and ping.
+type MQTTPublisher struct {
+	client *GRPCClient
+	config *MQTTConfig
+
+	// Home returns the home position, if one is set
+	Home func() (lat, lon float64, ok bool)
+	// Charge returns the battery left in percent, see BatteryConfig.Charge
+	Charge func(TelemetryState) uint32
+
+	mu       sync.Mutex
+	conn     net.Conn
+	nextID   uint16
+	inflight map[uint16]mqttMessage
+	lastSent time.Time
+
+	running  bool
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewMQTTPublisher creates a publisher using cfg
+func NewMQTTPublisher(client *GRPCClient, cfg *MQTTConfig) *MQTTPublisher {
+	return &MQTTPublisher{
+		client:   client,
+		config:   cfg,
+		inflight: make(map[uint16]mqttMessage),
+	}
+}
+
+// Start connects in the background and keeps publishing until Stop,
+// reconnecting whenever the broker goes away
+func (p *MQTTPublisher) Start() {
+	if p.running {
+		return
+	}
+	p.running = true
+	p.stopChan = make(chan struct{})
+	p.wg.Add(1)
+	go p.run()
+}
+
+// Stop marks the station offline, disconnects and waits for the
+// publisher to finish
+func (p *MQTTPublisher) Stop() {
+	if !p.running {
+		return
+	}
+	p.running = false
+	close(p.stopChan)
+	p.wg.Wait()
+}
+
+// IsRunning returns true while publishing
+func (p *MQTTPublisher) IsRunning() bool {
+	return p.running
+}
+
+func (p *MQTTPublisher) clientID() string {
+	if p.config.ClientID != "" {
+		return p.config.ClientID
+	}
+	return "elrs-map-" + localHostname()
+}
+
+// run connects, publishes, and reconnects after failures
+func (p *MQTTPublisher) run() {
+	defer p.wg.Done()
+	for {
+		err := p.session()
+		if err == nil {
+			return
+		}
+		logMQTT.Warnf("Broker %s: %v (retrying in %s)", p.config.Broker, err, mqttRetryDelay)
+		select {
+		case <-p.stopChan:
+			return
+		case <-time.After(mqttRetryDelay):
+		}
+	}
+}
+
+// session runs one connection until Stop (nil) or an error
+func (p *MQTTPublisher) session() error {
+	conn, err := net.DialTimeout("tcp", p.config.Broker, mqttDialTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	conn.SetDeadline(time.Now().Add(mqttDialTimeout))
+	if _, err := conn.Write(p.connectPacket()); err != nil {
+		return err
+	}
+	kind, body, err := readMQTTPacket(r)
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	if kind != mqttConnack || len(body) < 2 {
+		return fmt.Errorf("connect: unexpected packet %d", kind)
+	}
+	if body[1] != 0 {
+		return fmt.Errorf("connect refused (%s)", mqttConnackReason(body[1]))
+	}
+	conn.SetDeadline(time.Time{})
+
+	p.mu.Lock()
+	p.conn = conn
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.conn = nil
+		p.mu.Unlock()
+	}()
+	logMQTT.Infof("Publishing telemetry to %s as %s", p.config.Broker, p.clientID())
+
+	// Acks and pings come back on their own goroutine; it ends when the
+	// connection closes
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			kind, body, err := readMQTTPacket(r)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			if kind == mqttPuback && len(body) >= 2 {
+				p.mu.Lock()
+				delete(p.inflight, binary.BigEndian.Uint16(body))
+				p.mu.Unlock()
+			}
+		}
+	}()
+
+	if err := p.resend(); err != nil {
+		return err
+	}
+	if err := p.publish(p.config.StatusTopic, []byte(mqttStatusOnline), true); err != nil {
+		return err
+	}
+
+	interval := p.config.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last time.Time
+	for {
+		select {
+		case <-p.stopChan:
+			// A clean goodbye doesn't fire the will, so say offline first
+			p.publish(p.config.StatusTopic, []byte(mqttStatusOff), true)
+			p.write([]byte{mqttDisconnect << 4, 0})
+			p.mu.Lock()
+			p.inflight = make(map[uint16]mqttMessage)
+			p.mu.Unlock()
+			return nil
+		case err := <-readErr:
+			return err
+		case <-ticker.C:
+			state := p.client.GetState()
+			if !state.LastUpdate.After(last) {
+				// Nothing new; keep the connection alive
+				if p.idle() > mqttKeepAlive/2 {
+					if err := p.write([]byte{mqttPingreq << 4, 0}); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			last = state.LastUpdate
+			if err := p.publishTelemetry(state); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// publishTelemetry sends the state as the web map's JSON message
+func (p *MQTTPublisher) publishTelemetry(state TelemetryState) error {
+	t := newTelemetryJSON(state)
+	if p.Charge != nil {
+		t.Charge = p.Charge(state)
+	}
+	if p.Home != nil {
+		if lat, lon, ok := p.Home(); ok {
+			t.Home = &HomeConfig{Set: true, Lat: lat, Lon: lon}
+		}
+	}
+	payload, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return p.publish(p.config.TelemetryTopic, payload, false)
+}
+
+// publish sends a message at the configured QoS; QoS 1 messages are kept
+// until acknowledged, and sent again after a reconnect
+func (p *MQTTPublisher) publish(topic string, payload []byte, retain bool) error {
+	qos := min(1, max(0, p.config.QoS))
+	var id uint16
+	if qos == 1 {
+		p.mu.Lock()
+		// The status always goes out, or a reconnect with a full window
+		// would never come back online
+		if len(p.inflight) >= mqttMaxInflight && topic != p.config.StatusTopic {
+			p.mu.Unlock()
+			return errors.New("broker is not acknowledging messages")
+		}
+		p.nextID++
+		if p.nextID == 0 {
+			p.nextID = 1
+		}
+		id = p.nextID
+		p.inflight[id] = mqttMessage{topic: topic, payload: payload, retain: retain}
+		p.mu.Unlock()
+	}
+	return p.write(mqttPublishPacket(topic, payload, qos, retain, false, id))
+}
+
+// resend sends the newest messages the last connection left unacknowledged
+// and forgets the rest. The session is clean, so the broker kept nothing of
+// them, and old positions are not worth flooding a fresh connection with
+func (p *MQTTPublisher) resend() error {
+	p.mu.Lock()
+	ids := make([]uint16, 0, len(p.inflight))
+	for id := range p.inflight {
+		ids = append(ids, id)
+	}
+	// Newest first, counting back from the last id so a wrap sorts right
+	sort.Slice(ids, func(i, j int) bool { return p.nextID-ids[i] < p.nextID-ids[j] })
+	pending := make(map[uint16]mqttMessage, mqttResendNewest)
+	for i, id := range ids {
+		if i < mqttResendNewest {
+			pending[id] = p.inflight[id]
+		} else {
+			delete(p.inflight, id)
+		}
+	}
+	p.mu.Unlock()
+	for id, m := range pending {
+		if err := p.write(mqttPublishPacket(m.topic, m.payload, 1, m.retain, true, id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *MQTTPublisher) write(packet []byte) error {
+	p.mu.Lock()
+	conn := p.conn
+	p.lastSent = time.Now()
+	p.mu.Unlock()
+	if conn == nil {
+		return errors.New("not connected")
+	}
+	conn.SetWriteDeadline(time.Now().Add(mqttDialTimeout))
+	_, err := conn.Write(packet)
+	return err
+}
+
+func (p *MQTTPublisher) idle() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return time.Since(p.lastSent)
+}
+
+// connectPacket builds CONNECT with a clean session, the credentials if
+// any, and "offline" on the status topic as the retained last will
+func (p *MQTTPublisher) connectPacket() []byte {
+	flags := byte(0x02) // Clean session
+	flags |= 0x04 | byte(min(1, max(0, p.config.QoS)))<<3 | 0x20
+	if p.config.Username != "" {
+		flags |= 0x80
+		if p.config.Password != "" {
+			flags |= 0x40
+		}
+	}
+	body := mqttString(nil, "MQTT")
+	body = append(body, 4, flags) // Protocol level 3.1.1
+	body = binary.BigEndian.AppendUint16(body, uint16(mqttKeepAlive/time.Second))
+	body = mqttString(body, p.clientID())
+	body = mqttString(body, p.config.StatusTopic)
+	body = mqttString(body, mqttStatusOff)
+	if p.config.Username != "" {
+		body = mqttString(body, p.config.Username)
+		if p.config.Password != "" {
+			body = mqttString(body, p.config.Password)
+		}
+	}
+	return mqttPacket(mqttConnect<<4, body)
+}
+
+// mqttPublishPacket builds PUBLISH; id is only sent for QoS 1
+func mqttPublishPacket(topic string, payload []byte, qos int, retain, dup bool, id uint16) []byte {
+	header := byte(mqttPublish<<4) | byte(qos)<<1
+	if retain {
+		header |= 0x01
+	}
+	if dup {
+		header |= 0x08
+	}
+	body := mqttString(nil, topic)
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	return mqttPacket(header, append(body, payload...))
+}
+
+// mqttPacket adds the fixed header: type and flags, then the remaining
+// length as a base-128 varint
+func mqttPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+// mqttString appends a length-prefixed UTF-8 string
+func mqttString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// readMQTTPacket reads one packet, returning its type and body
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, shift := 0, 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return 0, nil, errors.New("malformed packet length")
+		}
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
+
+// mqttConnackReason explains a CONNACK return code
+func mqttConnackReason(code byte) string {
+	switch code {
+	case 1:
+		return "unsupported protocol version"
+	case 2:
+		return "client id rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad user name or password"
+	case 5:
+		return "not authorized"
+	}
+	return fmt.Sprintf("code %d", code)
+}
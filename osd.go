@@ -1,3 +1,5 @@
+//go:build !headless
+
 package main
 
 import (
@@ -18,6 +20,15 @@ type OSD struct {
 	textColor    color.RGBA
 	warningColor color.RGBA
 	bgColor      color.RGBA
+
+	// Settings
+	Units    Units
+	Alerts   AlertConfig
+	Elements OSDElements
+	Battery  BatteryConfig
+
+	Nav   *NavInfo   // Waypoint guidance, nil without a mission
+	Rally *RallyInfo // Nearest rally point, nil without any
 }
 
 // NewOSD creates a new OSD overlay
@@ -26,78 +37,117 @@ func NewOSD() *OSD {
 		textColor:    color.RGBA{255, 255, 255, 255},
 		warningColor: color.RGBA{255, 80, 80, 255},
 		bgColor:      color.RGBA{0, 0, 0, 160},
+		Units:        UnitsMetric,
+		Alerts:       DefaultConfig().Alerts,
+		Elements:     DefaultConfig().Display.OSD,
 	}
 }
 
 // Draw renders the OSD overlay
 func (o *OSD) Draw(screen *ebiten.Image, state TelemetryState, homeSet bool, homeDist, homeBearing float64) {
 	o.screenW, o.screenH = screen.Bounds().Dx(), screen.Bounds().Dy()
+	show := o.Elements
 
 	// === TOP LEFT: Coordinates ===
-	o.drawTextBox(screen, fmt.Sprintf("%.5f", state.Latitude), 5, 5)
-	o.drawTextBox(screen, fmt.Sprintf("%.5f", state.Longitude), 5, 22)
+	if show.Coords {
+		o.drawTextBox(screen, fmt.Sprintf("%.5f", state.Latitude), 5, 5)
+		o.drawTextBox(screen, fmt.Sprintf("%.5f", state.Longitude), 5, 22)
+	}
 
 	// === TOP CENTER: Heading ===
-	o.drawHeadingBar(screen, o.screenW/2, 5, state.Heading)
+	if show.Heading {
+		o.drawHeadingBar(screen, o.screenW/2, 5, state.Heading)
+	}
 
 	// === TOP RIGHT: GPS sats ===
-	satStr := fmt.Sprintf("%d sats", state.Satellites)
-	satW := len(satStr)*7 + 8
-	if state.Satellites < 4 {
-		o.drawTextBoxColored(screen, satStr, o.screenW-satW-5, 5, o.warningColor)
-	} else {
-		o.drawTextBox(screen, satStr, o.screenW-satW-5, 5)
+	if show.Sats {
+		satStr := fmt.Sprintf("%d sats", state.Satellites)
+		satW := len(satStr)*7 + 8
+		if int(state.Satellites) < o.Alerts.MinSats {
+			o.drawTextBoxColored(screen, satStr, o.screenW-satW-5, 5, o.warningColor)
+		} else {
+			o.drawTextBox(screen, satStr, o.screenW-satW-5, 5)
+		}
 	}
 
 	// === LEFT SIDE: Speed ===
-	spdStr := fmt.Sprintf("%.0f", state.GroundSpeed)
-	o.drawTextBox(screen, spdStr, 5, o.screenH/2-20)
-	o.drawTextBox(screen, "km/h", 5, o.screenH/2-3)
+	if show.Speed {
+		spdStr := fmt.Sprintf("%.0f", o.Units.Speed(float64(state.GroundSpeed)))
+		o.drawTextBox(screen, spdStr, 5, o.screenH/2-20)
+		o.drawTextBox(screen, o.Units.SpeedLabel(), 5, o.screenH/2-3)
+	}
 
 	// === RIGHT SIDE: Altitude ===
-	altStr := fmt.Sprintf("%dm", state.Altitude)
-	altW := len(altStr)*7 + 8
-	o.drawTextBox(screen, altStr, o.screenW-altW-5, o.screenH/2-20)
+	if show.Altitude {
+		altStr := fmt.Sprintf("%.0f%s", o.Units.Altitude(relativeAltitude(state)), o.Units.AltitudeLabel())
+		altW := len(altStr)*7 + 8
+		if o.Alerts.MaxAltitude > 0 && relativeAltitude(state) > o.Alerts.MaxAltitude {
+			o.drawTextBoxColored(screen, altStr, o.screenW-altW-5, o.screenH/2-20, o.warningColor)
+		} else {
+			o.drawTextBox(screen, altStr, o.screenW-altW-5, o.screenH/2-20)
+		}
+	}
 
 	// Home arrow and distance
-	if homeSet && state.HasGPS {
+	if show.Home && homeSet && state.HasGPS {
 		o.drawHomeArrow(screen, o.screenW-35, o.screenH/2+15, state.Heading, homeBearing)
-		distStr := ""
-		if homeDist >= 1000 {
-			distStr = fmt.Sprintf("%.1fkm", homeDist/1000)
-		} else {
-			distStr = fmt.Sprintf("%.0fm", homeDist)
-		}
+		distStr := o.Units.FormatDistance(homeDist)
 		distW := len(distStr)*7 + 8
-		if homeDist > 5000 {
+		if homeDist > o.Alerts.MaxDistance {
 			o.drawTextBoxColored(screen, distStr, o.screenW-distW-5, o.screenH/2+40, o.warningColor)
 		} else {
 			o.drawTextBox(screen, distStr, o.screenW-distW-5, o.screenH/2+40)
 		}
 	}
 
-	// === BOTTOM LEFT: Battery ===
-	battStr := fmt.Sprintf("%.1fV %d%%", state.Voltage, state.Remaining)
-	if state.Remaining < 20 {
-		o.drawTextBoxColored(screen, battStr, 5, o.screenH-55, o.warningColor)
-	} else {
-		o.drawTextBox(screen, battStr, 5, o.screenH-55)
+	// === LEFT SIDE: Waypoint and rally point ===
+	if show.Guidance {
+		for i, line := range guidanceLines(o.Nav, o.Rally, o.Units) {
+			o.drawTextBox(screen, line, 5, o.screenH/2+20+17*i)
+		}
+	}
+
+	// === BOTTOM LEFT: Battery, current under it ===
+	if show.Battery {
+		battY := o.screenH - 55
+		if !show.Current {
+			battY = o.screenH - 38
+		}
+		batt := o.Battery.Evaluate(state, o.Alerts.BatteryLowPct)
+		battStr := fmt.Sprintf("%.1fV %d%%", state.Voltage, batt.Remaining(state))
+		if batt.Cells > 0 {
+			battStr += fmt.Sprintf(" %dS %.2fV", batt.Cells, batt.CellV)
+		}
+		if batt.Level == BatteryLand {
+			battStr += " LAND"
+		}
+		if batt.Level >= BatteryLow {
+			o.drawTextBoxColored(screen, battStr, 5, battY, o.warningColor)
+		} else {
+			o.drawTextBox(screen, battStr, 5, battY)
+		}
+	}
+	if show.Current {
+		o.drawTextBox(screen, fmt.Sprintf("%.1fA", state.Current), 5, o.screenH-38)
 	}
-	o.drawTextBox(screen, fmt.Sprintf("%.1fA", state.Current), 5, o.screenH-38)
 
 	// === BOTTOM CENTER: Link Quality ===
-	lqStr := fmt.Sprintf("LQ:%d%% RSSI:%d", state.LinkQuality, state.RSSI1)
-	lqW := len(lqStr)*7 + 8
-	if state.LinkQuality < 50 {
-		o.drawTextBoxColored(screen, lqStr, o.screenW/2-lqW/2, o.screenH-38, o.warningColor)
-	} else {
-		o.drawTextBox(screen, lqStr, o.screenW/2-lqW/2, o.screenH-38)
+	if show.LQ {
+		lqStr := fmt.Sprintf("LQ:%d%% RSSI:%d", state.LinkQuality, state.RSSI1)
+		lqW := len(lqStr)*7 + 8
+		if int(state.LinkQuality) < o.Alerts.LQLowPct {
+			o.drawTextBoxColored(screen, lqStr, o.screenW/2-lqW/2, o.screenH-38, o.warningColor)
+		} else {
+			o.drawTextBox(screen, lqStr, o.screenW/2-lqW/2, o.screenH-38)
+		}
 	}
 
 	// === BOTTOM RIGHT: Attitude ===
-	attStr := fmt.Sprintf("P:%+.0f R:%+.0f", state.Pitch, state.Roll)
-	attW := len(attStr)*7 + 8
-	o.drawTextBox(screen, attStr, o.screenW-attW-5, o.screenH-38)
+	if show.Attitude {
+		attStr := fmt.Sprintf("P:%+.0f R:%+.0f", state.Pitch, state.Roll)
+		attW := len(attStr)*7 + 8
+		o.drawTextBox(screen, attStr, o.screenW-attW-5, o.screenH-38)
+	}
 }
 
 // drawTextBox draws text with semi-transparent background
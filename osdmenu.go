@@ -0,0 +1,41 @@
+//go:build !headless
+
+package main
+
+// newOSDMenu builds the screen that picks what the OSD HUD mode shows, for
+// setups that only want two or three readouts over the map
+func (a *App) newOSDMenu() *Menu {
+	m := NewMenu("OSD Elements")
+	cfg := &a.config.Display.OSD
+	m.OnClose = a.saveConfig
+
+	elements := []struct {
+		label string
+		on    *bool
+	}{
+		{"Coordinates", &cfg.Coords},
+		{"Heading bar", &cfg.Heading},
+		{"Satellites", &cfg.Sats},
+		{"Speed", &cfg.Speed},
+		{"Altitude", &cfg.Altitude},
+		{"Home arrow", &cfg.Home},
+		{"Waypoint/rally", &cfg.Guidance},
+		{"Battery", &cfg.Battery},
+		{"Current", &cfg.Current},
+		{"Link quality", &cfg.LQ},
+		{"Attitude", &cfg.Attitude},
+	}
+	for _, e := range elements {
+		on := e.on
+		m.Items = append(m.Items, MenuItem{
+			Label: e.label,
+			Value: func() string { return onOff(*on) },
+			OnAdjust: func(int) {
+				*on = !*on
+				a.osd.Elements = *cfg
+			},
+		})
+	}
+	m.Items = append(m.Items, MenuItem{Label: "Save & close", OnSelect: m.Close})
+	return m
+}
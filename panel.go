@@ -1,9 +1,13 @@
+//go:build !headless
+
 package main
 
 import (
 	"fmt"
+	"image"
 	"image/color"
 	"math"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
@@ -30,6 +34,18 @@ type Panel struct {
 	warningColor  color.RGBA
 	goodColor     color.RGBA
 	yellowColor   color.RGBA
+
+	// Settings
+	RightSide bool
+	Units     Units
+	Alerts    AlertConfig
+	Battery   BatteryConfig
+	HomeLabel string // What distances are measured from: HOME or PILOT
+
+	canvas *ebiten.Image // Offscreen target when drawn on the right side
+
+	Nav   *NavInfo   // Waypoint guidance, nil without a mission
+	Rally *RallyInfo // Nearest rally point, nil without any
 }
 
 // NewPanel creates a new instrument panel
@@ -46,6 +62,9 @@ func NewPanel() *Panel {
 		warningColor: color.RGBA{255, 60, 60, 255},
 		goodColor:    color.RGBA{0, 200, 0, 255},
 		yellowColor:  color.RGBA{255, 200, 0, 255},
+		Units:        UnitsMetric,
+		Alerts:       DefaultConfig().Alerts,
+		HomeLabel:    "HOME",
 	}
 }
 
@@ -54,8 +73,32 @@ func (p *Panel) GetPanelWidth() int {
 	return p.panelW
 }
 
-// Draw renders the full instrument panel
+// Draw renders the full instrument panel on the configured side
 func (p *Panel) Draw(screen *ebiten.Image, state TelemetryState, homeSet bool, homeDist, homeBearing float64) {
+	if !p.RightSide {
+		p.drawPanel(screen, state, homeSet, homeDist, homeBearing)
+		return
+	}
+
+	// The panel is laid out from x=0, so render offscreen and blit it on the right
+	screenW, screenH := screen.Bounds().Dx(), screen.Bounds().Dy()
+	if p.canvas == nil || p.canvas.Bounds().Dy() != screenH {
+		if p.canvas != nil {
+			p.canvas.Dispose()
+		}
+		p.canvas = ebiten.NewImage(p.panelW+2, screenH)
+	}
+	p.canvas.Clear()
+	p.drawPanel(p.canvas, state, homeSet, homeDist, homeBearing)
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(float64(screenW-p.panelW), 0)
+	screen.DrawImage(p.canvas.SubImage(image.Rect(0, 0, p.panelW, screenH)).(*ebiten.Image), op)
+	vector.StrokeLine(screen, float32(screenW-p.panelW), 0, float32(screenW-p.panelW), float32(screenH), 2, color.RGBA{60, 60, 70, 255}, true)
+}
+
+// drawPanel renders the panel with its left edge at x=0
+func (p *Panel) drawPanel(screen *ebiten.Image, state TelemetryState, homeSet bool, homeDist, homeBearing float64) {
 	p.screenW, p.screenH = screen.Bounds().Dx(), screen.Bounds().Dy()
 
 	// Panel background
@@ -77,6 +120,9 @@ func (p *Panel) Draw(screen *ebiten.Image, state TelemetryState, homeSet bool, h
 	gaugeY := ahY + ahH + 15
 	p.drawHorizontalGauges(screen, gaugeY, state)
 
+	// === WAYPOINT AND RALLY POINT ===
+	p.drawGuidance(screen, gaugeY+4*(18+8)+15)
+
 	// Panel right border
 	vector.StrokeLine(screen, float32(p.panelW), 0, float32(p.panelW), float32(p.screenH), 2, color.RGBA{60, 60, 70, 255}, true)
 }
@@ -87,24 +133,28 @@ func (p *Panel) drawTopBar(screen *ebiten.Image, state TelemetryState, homeSet b
 	vector.DrawFilledRect(screen, 0, 0, float32(p.panelW), 35, p.darkBg, true)
 
 	// Row 1: Battery | LQ | SAT
-	battStr := fmt.Sprintf("%.1fV %d%%", state.Voltage, state.Remaining)
-	if state.Remaining < 20 {
+	batt := p.Battery.Evaluate(state, p.Alerts.BatteryLowPct)
+	battStr := fmt.Sprintf("%.1fV %d%%", state.Voltage, batt.Remaining(state))
+	if batt.Level == BatteryLand {
+		battStr = fmt.Sprintf("%.1fV LAND", state.Voltage)
+	}
+	if batt.Level >= BatteryLow {
 		p.drawTextWithBg(screen, battStr, 8, 3, p.warningColor)
 	} else {
 		ebitenutil.DebugPrintAt(screen, battStr, 8, 3)
 	}
 
 	lqStr := fmt.Sprintf("LQ:%d%%", state.LinkQuality)
-	if state.LinkQuality < 50 {
+	if int(state.LinkQuality) < p.Alerts.LQLowPct {
 		p.drawTextWithBg(screen, lqStr, 95, 3, p.warningColor)
 	} else {
 		ebitenutil.DebugPrintAt(screen, lqStr, 95, 3)
 	}
 
 	satStr := fmt.Sprintf("SAT:%d", state.Satellites)
-	if state.Satellites < 4 {
+	if int(state.Satellites) < p.Alerts.MinSats {
 		p.drawTextWithBg(screen, satStr, 165, 3, p.warningColor)
-	} else if state.Satellites >= 6 {
+	} else if int(state.Satellites) >= p.Alerts.MinSats+2 {
 		p.drawTextWithBg(screen, satStr, 165, 3, p.goodColor)
 	} else {
 		ebitenutil.DebugPrintAt(screen, satStr, 165, 3)
@@ -112,13 +162,8 @@ func (p *Panel) drawTopBar(screen *ebiten.Image, state TelemetryState, homeSet b
 
 	// Row 2: Home info
 	if homeSet && state.HasGPS {
-		var homeStr string
-		if homeDist >= 1000 {
-			homeStr = fmt.Sprintf("HOME: %.1fkm %03.0f°", homeDist/1000, homeBearing)
-		} else {
-			homeStr = fmt.Sprintf("HOME: %.0fm %03.0f°", homeDist, homeBearing)
-		}
-		if homeDist > 5000 {
+		homeStr := fmt.Sprintf("%s: %s %03.0f°", p.HomeLabel, p.Units.FormatDistance(homeDist), homeBearing)
+		if homeDist > p.Alerts.MaxDistance {
 			p.drawTextWithBg(screen, homeStr, 8, 18, p.warningColor)
 		} else {
 			ebitenutil.DebugPrintAt(screen, homeStr, 8, 18)
@@ -126,7 +171,19 @@ func (p *Panel) drawTopBar(screen *ebiten.Image, state TelemetryState, homeSet b
 		// Small direction arrow
 		p.drawHomeArrow(screen, p.panelW-25, 24, state.Heading, homeBearing)
 	} else {
-		ebitenutil.DebugPrintAt(screen, "HOME: ---", 8, 18)
+		ebitenutil.DebugPrintAt(screen, p.HomeLabel+": ---", 8, 18)
+	}
+}
+
+// drawGuidance draws the way to the active waypoint and nearest rally point
+func (p *Panel) drawGuidance(screen *ebiten.Image, y int) {
+	lines := guidanceLines(p.Nav, p.Rally, p.Units)
+	if len(lines) == 0 {
+		return
+	}
+	vector.DrawFilledRect(screen, 0, float32(y-5), float32(p.panelW), float32(len(lines)*16+8), p.darkBg, true)
+	for i, line := range lines {
+		ebitenutil.DebugPrintAt(screen, line, 10, y+16*i)
 	}
 }
 
@@ -227,10 +284,10 @@ func (p *Panel) drawAttitudeDisplay(screen *ebiten.Image, x, y, w, h int, state
 
 	// === 5. SPEED TAPE (left side, semi-transparent overlay) ===
 	tapeW := 40
-	p.drawSpeedTape(screen, x, y+25, tapeW, h-55, state.GroundSpeed)
+	p.drawSpeedTape(screen, x, y+25, tapeW, h-55, float32(p.Units.Speed(float64(state.GroundSpeed))))
 
 	// === 6. ALTITUDE TAPE (right side, semi-transparent overlay) ===
-	p.drawAltitudeTape(screen, x+w-tapeW, y+25, tapeW, h-55, int(state.Altitude))
+	p.drawAltitudeTape(screen, x+w-tapeW, y+25, tapeW, h-55, int(p.Units.Altitude(relativeAltitude(state))))
 
 	// === 7. COMPASS RIBBON (bottom, semi-transparent overlay) ===
 	compassH := 25
@@ -461,12 +518,13 @@ func (p *Panel) drawHorizontalGauges(screen *ebiten.Image, startY int, state Tel
 	vector.DrawFilledRect(screen, 0, float32(startY-5), float32(p.panelW), float32(4*(barH+spacing)+10), p.darkBg, true)
 
 	// Battery
-	battPct := float32(state.Remaining) / 100.0
-	p.drawHorizontalBar(screen, x, startY, labelW, barW, barH, battPct, "Batt", fmt.Sprintf("%d%%", state.Remaining))
+	charge := p.Battery.Charge(state)
+	battPct := float32(charge) / 100.0
+	p.drawHorizontalBar(screen, x, startY, labelW, barW, barH, battPct, p.Alerts.Gauges.Battery.Level(float64(charge)), "Batt", fmt.Sprintf("%d%%", charge))
 
 	// Link Quality
 	lqPct := float32(state.LinkQuality) / 100.0
-	p.drawHorizontalBar(screen, x, startY+barH+spacing, labelW, barW, barH, lqPct, "LQ", fmt.Sprintf("%d%%", state.LinkQuality))
+	p.drawHorizontalBar(screen, x, startY+barH+spacing, labelW, barW, barH, lqPct, p.Alerts.Gauges.LQ.Level(float64(state.LinkQuality)), "LQ", fmt.Sprintf("%d%%", state.LinkQuality))
 
 	// RSSI (normalize -120 to -40)
 	rssiNorm := float32(state.RSSI1+120) / 80.0
@@ -476,7 +534,7 @@ func (p *Panel) drawHorizontalGauges(screen *ebiten.Image, startY int, state Tel
 	if rssiNorm > 1 {
 		rssiNorm = 1
 	}
-	p.drawHorizontalBar(screen, x, startY+(barH+spacing)*2, labelW, barW, barH, rssiNorm, "RSSI", fmt.Sprintf("%ddB", state.RSSI1))
+	p.drawHorizontalBar(screen, x, startY+(barH+spacing)*2, labelW, barW, barH, rssiNorm, p.Alerts.Gauges.RSSI.Level(float64(state.RSSI1)), "RSSI", fmt.Sprintf("%ddB", state.RSSI1))
 
 	// SNR (normalize -10 to 20)
 	snrNorm := float32(state.SNR+10) / 30.0
@@ -486,11 +544,12 @@ func (p *Panel) drawHorizontalGauges(screen *ebiten.Image, startY int, state Tel
 	if snrNorm > 1 {
 		snrNorm = 1
 	}
-	p.drawHorizontalBar(screen, x, startY+(barH+spacing)*3, labelW, barW, barH, snrNorm, "SNR", fmt.Sprintf("%ddB", state.SNR))
+	p.drawHorizontalBar(screen, x, startY+(barH+spacing)*3, labelW, barW, barH, snrNorm, p.Alerts.Gauges.SNR.Level(float64(state.SNR)), "SNR", fmt.Sprintf("%ddB", state.SNR))
 }
 
-// drawHorizontalBar draws a single horizontal gauge bar (INAV style)
-func (p *Panel) drawHorizontalBar(screen *ebiten.Image, x, y, labelW, barW, h int, value float32, label, valueStr string) {
+// drawHorizontalBar draws a single horizontal gauge bar (INAV style),
+// colored by level from the gauge's thresholds
+func (p *Panel) drawHorizontalBar(screen *ebiten.Image, x, y, labelW, barW, h int, value float32, level int, label, valueStr string) {
 	if value < 0 {
 		value = 0
 	}
@@ -507,8 +566,10 @@ func (p *Panel) drawHorizontalBar(screen *ebiten.Image, x, y, labelW, barW, h in
 	
 	// Value fill
 	fillW := int(float32(barW-4) * value)
-	fillColor := p.getGaugeColor(value)
-	vector.DrawFilledRect(screen, float32(barX+2), float32(y+2), float32(fillW), float32(h-4), fillColor, true)
+	// A critical bar blinks, so it catches the eye from the side
+	if level != GaugeCritical || time.Now().UnixMilli()%1000 < 500 {
+		vector.DrawFilledRect(screen, float32(barX+2), float32(y+2), float32(fillW), float32(h-4), p.getGaugeColor(level), true)
+	}
 	
 	// Border
 	vector.StrokeRect(screen, float32(barX), float32(y), float32(barW), float32(h), 1, color.RGBA{80, 80, 90, 255}, true)
@@ -517,11 +578,12 @@ func (p *Panel) drawHorizontalBar(screen *ebiten.Image, x, y, labelW, barW, h in
 	ebitenutil.DebugPrintAt(screen, valueStr, barX+barW+5, y+2)
 }
 
-// getGaugeColor returns color based on value (0-1)
-func (p *Panel) getGaugeColor(value float32) color.RGBA {
-	if value > 0.6 {
+// getGaugeColor returns the color for a gauge level
+func (p *Panel) getGaugeColor(level int) color.RGBA {
+	switch level {
+	case GaugeGood:
 		return p.goodColor
-	} else if value > 0.3 {
+	case GaugeWarn:
 		return p.yellowColor
 	}
 	return p.warningColor
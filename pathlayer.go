@@ -0,0 +1,264 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// pathPoint is one flight path sample, alt in meters above takeoff, at
+// when its telemetry arrived, flight which of the session's flights (packs)
+// it belongs to, from 1, poor whether the GPS fix was poor, maxPower
+// whether TX power was at its top, and dist the meters flown since that
+// flight's trail began
+type pathPoint struct {
+	lat, lon, alt float64
+	at            time.Time
+	dist          float64
+	flight        int
+	poor          bool
+	maxPower      bool
+}
+
+// pathFlightColors tell the session's flights apart, repeating after the last
+var pathFlightColors = []color.RGBA{
+	{255, 200, 0, 255},
+	{0, 220, 255, 255},
+	{255, 90, 200, 255},
+	{120, 255, 80, 255},
+	{255, 130, 40, 255},
+	{180, 140, 255, 255},
+}
+
+// pathPoorColor draws the stretches of trail flown on a poor GPS fix
+var pathPoorColor = color.RGBA{150, 150, 150, 255}
+
+// pathFlightColor returns the trail color of a flight
+func pathFlightColor(flight int, alpha uint8) color.RGBA {
+	c := pathFlightColors[(max(1, flight)-1)%len(pathFlightColors)]
+	c.A = alpha
+	return c
+}
+
+// appendPathPoint adds p to the path, counting the distance flown to it
+// since its flight's first point
+func appendPathPoint(path []pathPoint, p pathPoint) []pathPoint {
+	if n := len(path); n > 0 && path[n-1].flight == p.flight {
+		last := path[n-1]
+		p.dist = last.dist + DistanceMeters(last.lat, last.lon, p.lat, p.lon)
+	}
+	return append(path, p)
+}
+
+const (
+	// pathLayerMargin is how far past the map edges the layer reaches, so
+	// panning a little or following the aircraft doesn't re-render it
+	pathLayerMargin = TileSize
+
+	// pathLayerTrim is how many points may fall off the front of the path
+	// before the layer is re-rendered without them
+	pathLayerTrim = 100
+
+	// pathDropEvery is how many points apart the lifted path's drop lines
+	// are, about a second of flight
+	pathDropEvery = 60
+
+	// pathMaxLift caps how far up a point is drawn, in pixels
+	pathMaxLift = 2000
+)
+
+// pathLayer is the flight path pre-rendered into an offscreen image. New
+// points are drawn onto it as they arrive; it is only re-rendered on zoom,
+// when the view leaves it, or when the path is cleared or trimmed.
+type pathLayer struct {
+	img              *ebiten.Image
+	zoom             int
+	lift             float64 // Vertical exaggeration of the 2.5D path, 0 for flat
+	originX, originY float64 // World pixel of the image's top-left corner
+	last             pathPoint
+	count            int // Points drawn
+}
+
+// covers reports whether the layer holds the w x h view at world pixel left, top
+func (l *pathLayer) covers(zoom int, lift, left, top float64, w, h int) bool {
+	if l.img == nil || l.zoom != zoom || l.lift != lift {
+		return false
+	}
+	b := l.img.Bounds()
+	return left >= l.originX && top >= l.originY &&
+		left+float64(w) <= l.originX+float64(b.Dx()) && top+float64(h) <= l.originY+float64(b.Dy())
+}
+
+// render redraws the whole path around the view at world pixel left, top
+func (l *pathLayer) render(path []pathPoint, zoom int, lift, left, top float64, w, h int) {
+	w, h = w+2*pathLayerMargin, h+2*pathLayerMargin
+	if l.img == nil || l.img.Bounds().Dx() != w || l.img.Bounds().Dy() != h {
+		if l.img != nil {
+			l.img.Dispose()
+		}
+		l.img = ebiten.NewImage(w, h)
+	} else {
+		l.img.Clear()
+	}
+	l.zoom, l.lift = zoom, lift
+	l.originX, l.originY = left-pathLayerMargin, top-pathLayerMargin
+
+	// Older segments are fainter
+	for i := 1; i < len(path); i++ {
+		l.segment(path[i-1], path[i], i, uint8(100+155*i/len(path)))
+	}
+	l.count = len(path)
+	l.last = path[len(path)-1]
+}
+
+// extend draws the points added since the last call, or returns false if
+// the path changed in a way that needs a full render
+func (l *pathLayer) extend(path []pathPoint) bool {
+	idx := -1
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == l.last {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 || l.count-(idx+1) > pathLayerTrim {
+		return false
+	}
+	for i := idx + 1; i < len(path); i++ {
+		l.segment(path[i-1], path[i], i, 255)
+	}
+	l.count += len(path) - 1 - idx
+	l.last = path[len(path)-1]
+	return true
+}
+
+// segment draws the path from p1 to p2, the i'th point, in the color of
+// its flight; separate flights aren't joined. Lifted, the path is drawn
+// above its shadow on the ground, with a drop line every so often.
+func (l *pathLayer) segment(p1, p2 pathPoint, i int, alpha uint8) {
+	if p1.flight != p2.flight {
+		return
+	}
+	x1, y1 := LatLonToPixel(p1.lat, p1.lon, l.zoom)
+	x2, y2 := LatLonToPixel(p2.lat, p2.lon, l.zoom)
+	// Near the ±180° seam: the copy of p1 on the layer, and p2 beside it
+	// rather than across the world
+	size := TileSize * math.Exp2(float64(l.zoom))
+	x1 = wrapNear(x1, l.originX+float64(l.img.Bounds().Dx())/2, size)
+	x2 = wrapNear(x2, x1, size)
+	x1, y1, x2, y2 = x1-l.originX, y1-l.originY, x2-l.originX, y2-l.originY
+	if l.lift > 0 {
+		vector.StrokeLine(l.img, float32(x1), float32(y1), float32(x2), float32(y2),
+			1.5, color.RGBA{0, 0, 0, alpha / 2}, true)
+		h2 := pathLiftPixels(p2, float64(l.zoom), l.lift)
+		if i%pathDropEvery == 0 {
+			vector.StrokeLine(l.img, float32(x2), float32(y2), float32(x2), float32(y2-h2),
+				1, color.RGBA{255, 255, 255, alpha / 2}, true)
+		}
+		y1 -= pathLiftPixels(p1, float64(l.zoom), l.lift)
+		y2 -= h2
+	}
+	c := pathFlightColor(p2.flight, alpha)
+	if p2.poor {
+		c = pathPoorColor
+		c.A = alpha
+	}
+	if p2.maxPower {
+		// A halo, so the flight's color still shows
+		halo := txMaxPowerColor
+		halo.A = alpha
+		vector.StrokeLine(l.img, float32(x1), float32(y1), float32(x2), float32(y2), 6, halo, true)
+	}
+	vector.StrokeLine(l.img, float32(x1), float32(y1), float32(x2), float32(y2), 2, c, true)
+}
+
+// pathLiftPixels is how far above its ground position a point is drawn:
+// its altitude at the map's scale there, times the exaggeration. Points
+// below takeoff stay on the ground.
+func pathLiftPixels(p pathPoint, zoom float64, lift float64) float64 {
+	metersPerPixel := 2 * math.Pi * earthRadius * math.Cos(p.lat*math.Pi/180) / (TileSize * math.Exp2(zoom))
+	return math.Min(pathMaxLift, math.Max(0, p.alt)/metersPerPixel*lift)
+}
+
+// drawPathDropLine drops a line from the aircraft's lifted position to the
+// ground under it, so the end of the lifted path meets the aircraft icon
+func (a *App) drawPathDropLine(screen *ebiten.Image, v MapView) {
+	if !a.config.Map.Path3D || len(a.flightPath) == 0 {
+		return
+	}
+	p := a.flightPath[len(a.flightPath)-1]
+	px, py := v.LatLonToScreen(p.lat, p.lon)
+	x, y := float32(px), float32(py)
+	h := float32(pathLiftPixels(p, v.Zoom, a.config.Map.Path3DScale))
+	vector.StrokeLine(screen, x, y, x, y-h, 1.5, color.RGBA{255, 255, 255, 200}, true)
+	vector.DrawFilledCircle(screen, x, y-h, 3, color.RGBA{255, 200, 0, 255}, true)
+}
+
+// pathScreenPos returns where a trail point is drawn on screen
+func (a *App) pathScreenPos(p pathPoint, lifted bool, v MapView) (float64, float64) {
+	x, y := v.LatLonToScreen(p.lat, p.lon)
+	if lifted {
+		y -= pathLiftPixels(p, v.Zoom, a.config.Map.Path3DScale)
+	}
+	return x, y
+}
+
+// drawDistanceMarksWithOffset labels the flight path with the distance
+// flown every distance_marks_m, so a DVR's timeline can be matched to the map
+func (a *App) drawDistanceMarksWithOffset(screen *ebiten.Image, v MapView) {
+	step := a.config.Map.DistanceMarks
+	if step <= 0 || len(a.flightPath) < 2 {
+		return
+	}
+	units := a.config.Display.Units
+	for i := 1; i < len(a.flightPath); i++ {
+		p1, p2 := a.flightPath[i-1], a.flightPath[i]
+		for k := math.Floor(p1.dist/step) + 1; k*step <= p2.dist; k++ {
+			// Where along the segment the mark falls
+			t := (k*step - p1.dist) / (p2.dist - p1.dist)
+			mark := pathPoint{
+				lat: p1.lat + (p2.lat-p1.lat)*t,
+				lon: wrapLon(p1.lon + wrapLon(p2.lon-p1.lon)*t),
+				alt: p1.alt + (p2.alt-p1.alt)*t,
+			}
+			x, y := a.pathScreenPos(mark, a.config.Map.Path3D, v)
+			if x < float64(v.X) || x >= float64(v.X+v.Width) || y < 0 || y >= float64(v.Height) {
+				continue
+			}
+			label := units.FormatDistance(k * step)
+			w := float32(len(label)*6 + 6)
+			sx, sy := float32(x), float32(y)
+			vector.DrawFilledCircle(screen, sx, sy, 3, color.RGBA{255, 255, 255, 255}, true)
+			vector.DrawFilledRect(screen, sx+5, sy-8, w, 16, color.RGBA{0, 0, 0, 170}, false)
+			ebitenutil.DebugPrintAt(screen, label, int(sx)+8, int(sy)-8)
+		}
+	}
+}
+
+// drawFlightLegendWithOffset names the colors of the flights on the trail
+// once it holds more than one
+func (a *App) drawFlightLegendWithOffset(screen *ebiten.Image, v MapView) {
+	if len(a.flightPath) == 0 {
+		return
+	}
+	first, last := a.flightPath[0].flight, a.flightPath[len(a.flightPath)-1].flight
+	if first == last {
+		return
+	}
+	// Under the coordinates in the top left
+	x, y := v.X+5, 45
+	n := last - first + 1
+	vector.DrawFilledRect(screen, float32(x), float32(y), 86, float32(16*n+4), color.RGBA{0, 0, 0, 180}, false)
+	for i := 0; i < n; i++ {
+		ly := float32(y + 2 + 16*i)
+		vector.StrokeLine(screen, float32(x+6), ly+8, float32(x+24), ly+8, 3, pathFlightColor(first+i, 255), false)
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Flight %d", first+i), x+30, int(ly))
+	}
+}
@@ -0,0 +1,149 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"runtime"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// PerfOverlay shows frame rates, tile cache, memory and telemetry rates,
+// for diagnosing stutter on different hardware. Figures are sampled once a
+// second, as reading memory stats briefly stops the world.
+type PerfOverlay struct {
+	Visible bool
+
+	drawn      int // Frames actually drawn since the last sample
+	lastSample time.Time
+	lines      []string
+
+	// Previous sample, for rates
+	lastHits   int64
+	lastMisses int64
+	lastFrames map[string]int64
+
+	// Totals for /metrics, read from the web server's goroutine
+	framesTotal atomic.Int64
+	drawNanos   atomic.Int64
+}
+
+// NewPerfOverlay creates a hidden overlay
+func NewPerfOverlay() *PerfOverlay {
+	return &PerfOverlay{}
+}
+
+// Toggle shows or hides the overlay
+func (p *PerfOverlay) Toggle() {
+	p.Visible = !p.Visible
+	p.lastSample = time.Time{} // Fill it in on the first frame
+}
+
+// FrameDrawn counts a frame that was rendered, as opposed to one power
+// save skipped
+func (p *PerfOverlay) FrameDrawn() {
+	p.drawn++
+	p.framesTotal.Add(1)
+}
+
+// FrameTime adds up how long drawing a frame took
+func (p *PerfOverlay) FrameTime(d time.Duration) {
+	p.drawNanos.Add(int64(d))
+}
+
+// WriteMetrics adds the frame and tile cache figures to /metrics. Frame
+// time is a sum and a count, so rate() of both gives the average.
+func (p *PerfOverlay) WriteMetrics(m metricsWriter, tm *TileManager) {
+	m.counter("elrs_map_frames_drawn_total", "Frames rendered, not counting those power save skipped.", float64(p.framesTotal.Load()))
+	m.counter("elrs_map_frame_draw_seconds_total", "Time spent drawing frames.", time.Duration(p.drawNanos.Load()).Seconds())
+
+	tiles, hits, misses := tm.CacheStats()
+	bytes, downloads := tm.DataUsed()
+	m.gauge("elrs_map_tiles_in_memory", "Map tiles held as images.", float64(tiles))
+	m.counter("elrs_map_tile_cache_hits_total", "Tile lookups found in memory.", float64(hits))
+	m.counter("elrs_map_tile_cache_misses_total", "Tile lookups that had to load the tile.", float64(misses))
+	m.counter("elrs_map_tile_downloads_total", "Tiles downloaded.", float64(downloads))
+	m.counter("elrs_map_tile_download_bytes_total", "Bytes of tiles downloaded.", float64(bytes))
+}
+
+// Update takes a new sample once a second while visible
+func (p *PerfOverlay) Update(tm *TileManager, client *GRPCClient) {
+	if !p.Visible {
+		return
+	}
+	elapsed := time.Since(p.lastSample)
+	if elapsed < time.Second {
+		return
+	}
+	secs := elapsed.Seconds()
+	first := p.lastSample.IsZero()
+	p.lastSample = time.Now()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	tiles, hits, misses := tm.CacheStats()
+	hitRate := "-"
+	if lookups := (hits - p.lastHits) + (misses - p.lastMisses); lookups > 0 && !first {
+		hitRate = fmt.Sprintf("%.0f%%", 100*float64(hits-p.lastHits)/float64(lookups))
+	}
+	p.lastHits, p.lastMisses = hits, misses
+
+	drawn := "-"
+	if !first {
+		drawn = fmt.Sprintf("%.0f", float64(p.drawn)/secs)
+	}
+	p.drawn = 0
+
+	p.lines = []string{
+		fmt.Sprintf("FPS %.0f (drawn %s)  TPS %.0f/%d", ebiten.ActualFPS(), drawn, ebiten.ActualTPS(), ebiten.TPS()),
+		fmt.Sprintf("Tiles %d in memory, hit %s", tiles, hitRate),
+		fmt.Sprintf("Heap %s  GC %d", formatBytes(int64(mem.HeapAlloc)), mem.NumGC),
+		fmt.Sprintf("Goroutines %d", runtime.NumGoroutine()),
+	}
+
+	// Telemetry frames per second by kind
+	frames := client.FrameCounts()
+	kinds := make([]string, 0, len(frames))
+	for k := range frames {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	if len(kinds) > 0 {
+		p.lines = append(p.lines, "Telemetry")
+	}
+	for _, k := range kinds {
+		rate := "-"
+		if !first {
+			rate = fmt.Sprintf("%.1f/s", float64(frames[k]-p.lastFrames[k])/secs)
+		}
+		p.lines = append(p.lines, fmt.Sprintf("  %-8s %s", k, rate))
+	}
+	p.lastFrames = frames
+}
+
+// Draw renders the overlay in the top-right corner
+func (p *PerfOverlay) Draw(screen *ebiten.Image) {
+	if !p.Visible || len(p.lines) == 0 {
+		return
+	}
+	w := 0
+	for _, line := range p.lines {
+		w = max(w, len(line)*6)
+	}
+	boxW := w + 16
+	boxH := len(p.lines)*16 + 8
+	x := screen.Bounds().Dx() - boxW - 5
+
+	vector.DrawFilledRect(screen, float32(x), 5, float32(boxW), float32(boxH), color.RGBA{0, 0, 0, 200}, false)
+	for i, line := range p.lines {
+		ebitenutil.DebugPrintAt(screen, line, x+8, 8+i*16)
+	}
+}
@@ -0,0 +1,59 @@
+//go:build !headless
+
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// setPilot moves the pilot's position, which need not be the takeoff point
+func (a *App) setPilot(lat, lon float64) {
+	a.pilotLat, a.pilotLon, a.pilotSet = lat, lon, true
+}
+
+// updatePilot follows the local GPS, and centers the map on it at its
+// first fix if the aircraft has none yet
+func (a *App) updatePilot(state TelemetryState) {
+	fix, ok := a.localGPS.Fix()
+	if !ok {
+		return
+	}
+	a.setPilot(fix.Lat, fix.Lon)
+	if !a.gpsSeen {
+		a.gpsSeen = true
+		logGPS.Infof("Ground station at %.6f, %.6f", fix.Lat, fix.Lon)
+		if !state.HasGPS || (state.Latitude == 0 && state.Longitude == 0) {
+			a.centerLat, a.centerLon = fix.Lat, fix.Lon
+		}
+	}
+}
+
+// distanceRef returns the point distance and bearing readouts are measured
+// from, and its label: the pilot when chosen and known, as line of sight
+// and RF range are from there, home otherwise
+func (a *App) distanceRef() (lat, lon float64, ok bool, label string) {
+	if a.config.Map.DistanceFrom == "pilot" && a.pilotSet {
+		return a.pilotLat, a.pilotLon, true, "PILOT"
+	}
+	return a.homeLat, a.homeLon, a.homeSet, "HOME"
+}
+
+// drawPilotMarkerWithOffset marks the pilot's position
+func (a *App) drawPilotMarkerWithOffset(screen *ebiten.Image, v MapView) {
+	if !a.pilotSet {
+		return
+	}
+
+	px, py := v.LatLonToScreen(a.pilotLat, a.pilotLon)
+	sx, sy := float32(px), float32(py)
+
+	if v.ContainsX(px) {
+		vector.DrawFilledCircle(screen, sx, sy, 8, color.RGBA{60, 140, 255, 200}, true)
+		vector.StrokeCircle(screen, sx, sy, 8, 2, color.RGBA{255, 255, 255, 255}, true)
+		ebitenutil.DebugPrintAt(screen, "P", int(sx)-3, int(sy)-6)
+	}
+}
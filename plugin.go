@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	pluginRestartDelay = 5 * time.Second
+	pluginMaxLine      = 64 * 1024 // Longest message a plugin may send
+	pluginMaxWidgets   = 16        // Per plugin
+	pluginEventQueue   = 16        // Actions waiting for a slow plugin
+)
+
+// PluginMessage is one line of JSON between elrs-map and a plugin. elrs-map
+// sends "telemetry" every interval and "action" when an action runs; a
+// plugin sends "widget" to show, change or (with empty text) remove a
+// widget, "alert" to raise a notification and "action" to run an action.
+type PluginMessage struct {
+	Type string `json:"type"`
+
+	Telemetry *TelemetryJSON `json:"telemetry,omitempty"` // telemetry
+	Action    string         `json:"action,omitempty"`    // action
+
+	ID    string `json:"id,omitempty"`    // widget
+	Text  string `json:"text,omitempty"`  // widget, alert
+	X     int    `json:"x,omitempty"`     // widget: pixels from the map's left, or right edge when negative
+	Y     int    `json:"y,omitempty"`     // widget: pixels from the top, or bottom edge when negative
+	Color string `json:"color,omitempty"` // widget: #rrggbb for the bar beside it, white when empty
+	Level string `json:"level,omitempty"` // alert: info, warn, error
+}
+
+// PluginWidget is a line of text a plugin keeps on the map
+type PluginWidget struct {
+	Plugin, ID string
+	Text       string
+	X, Y       int
+	Color      string
+}
+
+// PluginHost runs the programs under plugins:, each with its stdin and
+// stdout joined to elrs-map, so power users can add an instrument or an
+// alert in any language without forking the app. A plugin that exits is
+// started again after a few seconds.
+type PluginHost struct {
+	client *GRPCClient
+	config *Config
+
+	// Home returns the home position, if one is set
+	Home func() (lat, lon float64, ok bool)
+	// Alert shows a plugin's alert; nil only logs it
+	Alert func(level LogLevel, text string)
+	// Action runs an action a plugin asks for; nil ignores them
+	Action func(name string) error
+
+	mu      sync.Mutex
+	widgets map[string]PluginWidget // By plugin and id
+	events  []chan PluginMessage    // One per running plugin
+
+	running  bool
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewPluginHost creates a host for the configured plugins
+func NewPluginHost(client *GRPCClient, cfg *Config) *PluginHost {
+	return &PluginHost{client: client, config: cfg, widgets: make(map[string]PluginWidget)}
+}
+
+// Start runs the plugins in the background until Stop
+func (p *PluginHost) Start() {
+	if p.running || len(p.config.Plugins) == 0 {
+		return
+	}
+	p.running = true
+	p.stopChan = make(chan struct{})
+	p.mu.Lock()
+	p.events = p.events[:0]
+	for _, pc := range p.config.Plugins {
+		events := make(chan PluginMessage, pluginEventQueue)
+		p.events = append(p.events, events)
+		p.wg.Add(1)
+		go p.supervise(pc, events)
+	}
+	p.mu.Unlock()
+}
+
+// Stop ends the plugins and removes their widgets
+func (p *PluginHost) Stop() {
+	if !p.running {
+		return
+	}
+	p.running = false
+	close(p.stopChan)
+	p.wg.Wait()
+	p.mu.Lock()
+	p.events = nil
+	p.widgets = make(map[string]PluginWidget)
+	p.mu.Unlock()
+}
+
+// NotifyAction tells the plugins an action ran. A plugin that is behind
+// misses it rather than holding up the caller.
+func (p *PluginHost) NotifyAction(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, events := range p.events {
+		select {
+		case events <- PluginMessage{Type: "action", Action: name}:
+		default:
+		}
+	}
+}
+
+// Widgets returns the plugins' widgets in a steady order
+func (p *PluginHost) Widgets() []PluginWidget {
+	p.mu.Lock()
+	widgets := make([]PluginWidget, 0, len(p.widgets))
+	for _, w := range p.widgets {
+		widgets = append(widgets, w)
+	}
+	p.mu.Unlock()
+	sort.Slice(widgets, func(i, j int) bool {
+		if widgets[i].Plugin != widgets[j].Plugin {
+			return widgets[i].Plugin < widgets[j].Plugin
+		}
+		return widgets[i].ID < widgets[j].ID
+	})
+	return widgets
+}
+
+// supervise keeps a plugin running, starting it again when it exits
+func (p *PluginHost) supervise(pc PluginConfig, events chan PluginMessage) {
+	defer p.wg.Done()
+	name := pc.name()
+	for {
+		if err := p.run(pc, events); err != nil {
+			logPlugin.Warnf("%s: %v", name, err)
+		}
+		p.dropWidgets(name)
+		select {
+		case <-p.stopChan:
+			return
+		case <-time.After(pluginRestartDelay):
+		}
+	}
+}
+
+// run starts a plugin and feeds it until it exits or the host stops
+func (p *PluginHost) run(pc PluginConfig, events chan PluginMessage) error {
+	name := pc.name()
+	cmd := exec.Command(pc.Command, pc.Args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stderr = pluginLog{name}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	logPlugin.Infof("%s: started", name)
+
+	exited := make(chan error, 1)
+	go func() {
+		p.read(name, stdout)
+		exited <- cmd.Wait()
+	}()
+	// Killed on Stop even while a write to it is stuck
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-p.stopChan:
+			cmd.Process.Kill()
+		case <-done:
+		}
+	}()
+
+	interval := pc.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	enc := json.NewEncoder(stdin)
+	for {
+		var msg PluginMessage
+		select {
+		case <-p.stopChan:
+			<-exited
+			return nil
+		case err := <-exited:
+			if err != nil {
+				return fmt.Errorf("exited: %w", err)
+			}
+			return fmt.Errorf("exited")
+		case <-ticker.C:
+			t := p.snapshot()
+			msg = PluginMessage{Type: "telemetry", Telemetry: &t}
+		case msg = <-events:
+		}
+		// A plugin that can't be written to is killed and started again
+		if err := enc.Encode(msg); err != nil {
+			cmd.Process.Kill()
+		}
+	}
+}
+
+// snapshot builds the telemetry plugins get, the same as the web map's
+func (p *PluginHost) snapshot() TelemetryJSON {
+	state := p.client.GetState()
+	t := newTelemetryJSON(state)
+	t.Charge = p.config.Battery.Charge(state)
+	if p.Home != nil {
+		if lat, lon, ok := p.Home(); ok {
+			t.Home = &HomeConfig{Set: true, Lat: lat, Lon: lon}
+		}
+	}
+	return t
+}
+
+// read handles what a plugin sends until it closes its stdout
+func (p *PluginHost) read(name string, r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), pluginMaxLine)
+	for scanner.Scan() {
+		var msg PluginMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			logPlugin.Warnf("%s: bad message: %v", name, err)
+			continue
+		}
+		p.handle(name, msg)
+	}
+	if err := scanner.Err(); err != nil {
+		logPlugin.Warnf("%s: %v", name, err)
+	}
+}
+
+// handle acts on one message from a plugin
+func (p *PluginHost) handle(name string, msg PluginMessage) {
+	switch msg.Type {
+	case "widget":
+		p.setWidget(PluginWidget{Plugin: name, ID: msg.ID, Text: msg.Text, X: msg.X, Y: msg.Y, Color: msg.Color})
+	case "alert":
+		level, ok := ParseLogLevel(msg.Level)
+		if !ok || level < LevelInfo {
+			level = LevelInfo
+		}
+		logPlugin.logf(level, "%s: %s", name, msg.Text)
+		if p.Alert != nil {
+			p.Alert(level, msg.Text)
+		}
+	case "action":
+		if p.Action == nil {
+			logPlugin.Debugf("%s: no actions here, ignoring %s", name, msg.Action)
+		} else if err := p.Action(msg.Action); err != nil {
+			logPlugin.Warnf("%s: %v", name, err)
+		}
+	default:
+		logPlugin.Warnf("%s: unknown message type %q", name, msg.Type)
+	}
+}
+
+// setWidget adds, changes or, when its text is empty, removes a widget
+func (p *PluginHost) setWidget(w PluginWidget) {
+	key := w.Plugin + "/" + w.ID
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if w.Text == "" {
+		delete(p.widgets, key)
+		return
+	}
+	if _, ok := p.widgets[key]; !ok && p.countWidgets(w.Plugin) >= pluginMaxWidgets {
+		logPlugin.Warnf("%s: more than %d widgets, ignoring %q", w.Plugin, pluginMaxWidgets, w.ID)
+		return
+	}
+	p.widgets[key] = w
+}
+
+func (p *PluginHost) countWidgets(plugin string) int {
+	n := 0
+	for _, w := range p.widgets {
+		if w.Plugin == plugin {
+			n++
+		}
+	}
+	return n
+}
+
+// dropWidgets removes the widgets of a plugin that exited
+func (p *PluginHost) dropWidgets(plugin string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, w := range p.widgets {
+		if w.Plugin == plugin {
+			delete(p.widgets, key)
+		}
+	}
+}
+
+// name is what the plugin is called in the log and its widgets' keys
+func (pc PluginConfig) name() string {
+	if pc.Name != "" {
+		return pc.Name
+	}
+	return pc.Command
+}
+
+// pluginLog passes a plugin's stderr to the log
+type pluginLog struct{ name string }
+
+func (l pluginLog) Write(b []byte) (int, error) {
+	logPlugin.Infof("%s: %s", l.name, strings.TrimRight(string(b), "\r\n"))
+	return len(b), nil
+}
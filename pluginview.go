@@ -0,0 +1,36 @@
+//go:build !headless
+
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// drawPluginWidgetsWithOffset draws the plugins' widgets, a line of text
+// each with a bar of the plugin's color, placed from the map's corners
+func (a *App) drawPluginWidgetsWithOffset(screen *ebiten.Image, v MapView) {
+	for _, w := range a.plugins.Widgets() {
+		width := len([]rune(w.Text))*6 + 12
+		x := v.X + w.X
+		if w.X < 0 {
+			x = v.X + v.Width + w.X - width
+		}
+		y := w.Y
+		if w.Y < 0 {
+			y = v.Height - statusBarHeight + w.Y - 16
+		}
+		accent := color.RGBA{255, 255, 255, 255}
+		if w.Color != "" {
+			if c, err := parseHexColor(w.Color); err == nil {
+				accent = c
+			}
+		}
+		vector.DrawFilledRect(screen, float32(x), float32(y), float32(width), 16, color.RGBA{0, 0, 0, 190}, false)
+		vector.DrawFilledRect(screen, float32(x), float32(y), 3, 16, accent, false)
+		ebitenutil.DebugPrintAt(screen, w.Text, x+7, y)
+	}
+}
@@ -0,0 +1,76 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+)
+
+// newPortMenu builds the serial port / baud rate selection menu
+func (a *App) newPortMenu() *Menu {
+	m := NewMenu("Serial Port")
+	m.Rebuild = func(m *Menu) {
+		m.Items = m.Items[:0]
+
+		if len(a.ports) == 0 {
+			msg := "No ports detected"
+			if !a.client.IsConnected() {
+				msg = "Backend not connected"
+			}
+			m.Items = append(m.Items, MenuItem{Label: msg})
+		}
+		for i, port := range a.ports {
+			i := i
+			m.Items = append(m.Items, MenuItem{
+				Label:    port,
+				Active:   func() bool { return i == a.selectedPort },
+				OnSelect: func() { a.selectedPort = i },
+			})
+		}
+
+		m.Items = append(m.Items,
+			MenuItem{
+				Label:    "Baud rate",
+				Value:    func() string { return fmt.Sprintf("%d", a.baudRate) },
+				OnAdjust: a.adjustBaudRate,
+			},
+			MenuItem{
+				Label: "Rescan ports",
+				OnSelect: func() {
+					a.scanPorts()
+					m.Rebuild(m)
+				},
+			},
+			MenuItem{
+				Label: "Start/stop link",
+				Value: func() string {
+					if a.client.IsLinkStarted() {
+						return "ON"
+					}
+					return "OFF"
+				},
+				OnSelect: func() {
+					a.toggleLink()
+					if a.client.IsLinkStarted() {
+						m.Close()
+					}
+				},
+			},
+			MenuItem{Label: "Close", OnSelect: m.Close},
+		)
+	}
+	return m
+}
+
+// adjustBaudRate steps through BaudRates
+func (a *App) adjustBaudRate(delta int) {
+	idx := 0
+	for i, b := range BaudRates {
+		if b == a.baudRate {
+			idx = i
+			break
+		}
+	}
+	n := len(BaudRates)
+	a.baudRate = BaudRates[((idx+delta)%n+n)%n]
+}
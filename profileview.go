@@ -0,0 +1,196 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	// profileHeight is the elevation graph's height, above the status bar
+	profileHeight = 130
+
+	// profileSamples is how many points along the route the graph has
+	profileSamples = 200
+
+	// profileAxisW leaves room for the elevation labels left of the graph
+	profileAxisW = 56
+)
+
+var (
+	profileRouteColor  = color.RGBA{0, 220, 255, 230}
+	profileGroundColor = color.RGBA{150, 115, 70, 255}
+	profilePeakColor   = color.RGBA{255, 70, 70, 255}
+)
+
+// toggleProfile enters or leaves the elevation profile mode, where clicks
+// on the map draw a route and the graph shows the ground along it
+func (a *App) toggleProfile() {
+	a.profiling = !a.profiling
+	a.profileRoute, a.profile = nil, nil
+	if !a.profiling {
+		return
+	}
+	if !a.dem.Available() {
+		a.notify(logApp, LevelWarn, "No elevation tiles (SRTM .hgt) in %s", a.dem.Dir())
+	}
+	a.toasts.Add(LevelInfo, "Elevation profile: click along the route, Backspace undoes, Shift+E closes")
+}
+
+// addProfilePoint extends the route to the map position under x, y
+func (a *App) addProfilePoint(x, y int) {
+	v := a.currentMapView()
+	if !v.Contains(float64(x), float64(y)) {
+		return
+	}
+	lat, lon := v.ScreenToLatLon(float64(x), float64(y))
+	a.profileRoute = append(a.profileRoute, [2]float64{lat, lon})
+	a.profile = a.dem.Profile(a.profileRoute, profileSamples)
+}
+
+// undoProfilePoint takes the last point off the route
+func (a *App) undoProfilePoint() {
+	if len(a.profileRoute) == 0 {
+		return
+	}
+	a.profileRoute = a.profileRoute[:len(a.profileRoute)-1]
+	a.profile = a.dem.Profile(a.profileRoute, profileSamples)
+}
+
+// cursorElevation returns the ground elevation under the mouse, false off
+// the map or without elevation data there
+func (a *App) cursorElevation() (float64, bool) {
+	v := a.currentMapView()
+	x, y := ebiten.CursorPosition()
+	if !v.Contains(float64(x), float64(y)) {
+		return 0, false
+	}
+	return a.dem.Elevation(v.ScreenToLatLon(float64(x), float64(y)))
+}
+
+// drawProfileWithOffset draws the route being profiled on the map, the
+// ground elevation under the cursor, and the graph of the ground along the
+// route with its highest point, to see whether a low route clears a ridge
+func (a *App) drawProfileWithOffset(screen *ebiten.Image, offsetX, mapWidth int) {
+	if !a.profiling {
+		return
+	}
+	v := a.mapView(offsetX, mapWidth)
+	units := a.config.Display.Units
+
+	// Route
+	for i, p := range a.profileRoute {
+		x, y := v.LatLonToScreen(p[0], p[1])
+		if i > 0 {
+			px, py := v.LatLonToScreen(a.profileRoute[i-1][0], a.profileRoute[i-1][1])
+			vector.StrokeLine(screen, float32(px), float32(py), float32(x), float32(y), 2, profileRouteColor, true)
+		}
+		vector.DrawFilledCircle(screen, float32(x), float32(y), 4, profileRouteColor, true)
+	}
+
+	// Cursor
+	cx, cy := ebiten.CursorPosition()
+	if v.Contains(float64(cx), float64(cy)) {
+		label := "Ground: no data"
+		if elev, ok := a.cursorElevation(); ok {
+			label = fmt.Sprintf("Ground %.0f %s", units.Altitude(elev), units.AltitudeLabel())
+		}
+		w := len(label)*6 + 6
+		vector.DrawFilledRect(screen, float32(cx+12), float32(cy+12), float32(w), 16, color.RGBA{0, 0, 0, 190}, false)
+		ebitenutil.DebugPrintAt(screen, label, cx+15, cy+12)
+	}
+
+	// Graph
+	var start, peak *ElevationSample
+	for i := range a.profile {
+		s := &a.profile[i]
+		if !s.OK {
+			continue
+		}
+		if start == nil {
+			start = s
+		}
+		if peak == nil || s.Elevation > peak.Elevation {
+			peak = s
+		}
+	}
+	if start == nil {
+		if len(a.profile) > 0 {
+			a.drawProfileBox(screen, offsetX, mapWidth, []string{"No elevation data along this route"})
+		}
+		return
+	}
+	lo, hi := peak.Elevation, peak.Elevation
+	for _, s := range a.profile {
+		if s.OK {
+			lo = min(lo, s.Elevation)
+		}
+	}
+	state := a.client.GetState()
+	if state.HasGPS {
+		lo, hi = min(lo, float64(state.Altitude)), max(hi, float64(state.Altitude))
+	}
+	// Some headroom, and at least 50 m of range so flat ground stays flat
+	pad := max(25, (hi-lo)*0.1)
+	lo, hi = lo-pad, hi+pad
+
+	gx := float32(offsetX + 10 + profileAxisW)
+	gw := float32(mapWidth - 30 - profileAxisW)
+	gy := float32(a.height - statusBarHeight - lqTimelineHeight - 10 - profileHeight)
+	gh := float32(profileHeight - 20)
+	vector.DrawFilledRect(screen, float32(offsetX+10), gy-4, float32(mapWidth-20), profileHeight+4, color.RGBA{0, 0, 0, 200}, false)
+	total := a.profile[len(a.profile)-1].Dist
+	toX := func(d float64) float32 { return gx + gw*float32(d/max(total, 1)) }
+	toY := func(e float64) float32 { return gy + gh*float32((hi-e)/(hi-lo)) }
+
+	colW := gw/float32(len(a.profile)-1) + 1
+	for _, s := range a.profile {
+		if s.OK {
+			y := toY(s.Elevation)
+			vector.DrawFilledRect(screen, toX(s.Dist)-colW/2, y, colW, gy+gh-y, profileGroundColor, false)
+		}
+	}
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%.0f%s", units.Altitude(hi), units.AltitudeLabel()), offsetX+14, int(gy))
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%.0f%s", units.Altitude(lo), units.AltitudeLabel()), offsetX+14, int(gy+gh)-14)
+
+	// The aircraft's altitude, green where it clears the highest point
+	if state.HasGPS {
+		alt := float64(state.Altitude)
+		c := color.RGBA{255, 80, 80, 255}
+		if alt > peak.Elevation {
+			c = color.RGBA{80, 255, 80, 255}
+		}
+		y := toY(alt)
+		for x := gx; x < gx+gw; x += 10 {
+			vector.StrokeLine(screen, x, y, min(x+6, gx+gw), y, 1, c, false)
+		}
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("AC %.0f%s", units.Altitude(alt), units.AltitudeLabel()), int(gx+gw)-60, int(y)-16)
+	}
+
+	// Highest point, on the graph and the map
+	px, py := toX(peak.Dist), toY(peak.Elevation)
+	vector.DrawFilledCircle(screen, px, py, 4, profilePeakColor, true)
+	mx, my := v.LatLonToScreen(peak.Lat, peak.Lon)
+	vector.StrokeCircle(screen, float32(mx), float32(my), 7, 2, profilePeakColor, true)
+
+	summary := fmt.Sprintf("Length %s  Start %.0f%s  Highest %.0f%s (%+.0f) at %s",
+		units.FormatDistance(total),
+		units.Altitude(start.Elevation), units.AltitudeLabel(),
+		units.Altitude(peak.Elevation), units.AltitudeLabel(),
+		units.Altitude(peak.Elevation-start.Elevation), units.FormatDistance(peak.Dist))
+	ebitenutil.DebugPrintAt(screen, summary, int(gx), int(gy+gh)+2)
+}
+
+// drawProfileBox shows a message where the graph would be
+func (a *App) drawProfileBox(screen *ebiten.Image, offsetX, mapWidth int, lines []string) {
+	y := a.height - statusBarHeight - lqTimelineHeight - 10 - 16*len(lines)
+	vector.DrawFilledRect(screen, float32(offsetX+10), float32(y-4), float32(mapWidth-20), float32(16*len(lines)+8), color.RGBA{0, 0, 0, 200}, false)
+	for i, l := range lines {
+		ebitenutil.DebugPrintAt(screen, l, offsetX+16, y+16*i)
+	}
+}
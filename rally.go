@@ -0,0 +1,98 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+var rallyColor = color.RGBA{255, 150, 0, 255}
+
+// RallyInfo is the way to the nearest rally point
+type RallyInfo struct {
+	Name    string
+	Dist    float64 // Meters
+	Bearing float64 // Degrees from the aircraft
+}
+
+// nearestRally returns the rally point closest to lat, lon, or nil if
+// there are none
+func nearestRally(points []RallyPoint, lat, lon float64) *RallyInfo {
+	var best *RallyInfo
+	for _, p := range points {
+		d := DistanceMeters(lat, lon, p.Lat, p.Lon)
+		if best == nil || d < best.Dist {
+			best = &RallyInfo{Name: p.Name, Dist: d, Bearing: BearingDegrees(lat, lon, p.Lat, p.Lon)}
+		}
+	}
+	return best
+}
+
+// updateRally refreshes the way to the nearest rally point
+func (a *App) updateRally(state TelemetryState) {
+	a.rallyInfo = nil
+	if state.HasGPS {
+		a.rallyInfo = nearestRally(a.config.Rally, float64(state.Latitude), float64(state.Longitude))
+	}
+}
+
+// addRallyHere adds a rally point at the map center
+func (a *App) addRallyHere() {
+	p := RallyPoint{
+		Name: fmt.Sprintf("R%d", len(a.config.Rally)+1),
+		Lat:  a.centerLat,
+		Lon:  a.centerLon,
+	}
+	a.config.Rally = append(a.config.Rally, p)
+	logApp.Infof("Rally point %s at %.6f, %.6f", p.Name, p.Lat, p.Lon)
+}
+
+// drawRallyWithOffset draws the rally points, and lines to them from the
+// aircraft if enabled
+func (a *App) drawRallyWithOffset(screen *ebiten.Image, v MapView) {
+	if len(a.config.Rally) == 0 {
+		return
+	}
+
+	toScreen := func(lat, lon float64) (float32, float32) {
+		x, y := v.LatLonToScreen(lat, lon)
+		return float32(x), float32(y)
+	}
+
+	state := a.client.GetState()
+	if a.config.Map.RallyLines && state.HasGPS {
+		ax, ay := toScreen(float64(state.Latitude), float64(state.Longitude))
+		for _, p := range a.config.Rally {
+			c := rallyColor
+			width := float32(1)
+			if a.rallyInfo != nil && p.Name == a.rallyInfo.Name {
+				width = 2 // Nearest
+			} else {
+				c.A = 140
+			}
+			sx, sy := toScreen(p.Lat, p.Lon)
+			vector.StrokeLine(screen, ax, ay, sx, sy, width, c, true)
+		}
+	}
+
+	for _, p := range a.config.Rally {
+		sx, sy := toScreen(p.Lat, p.Lon)
+		if !v.ContainsX(float64(sx)) {
+			continue
+		}
+		vector.DrawFilledCircle(screen, sx, sy, 7, rallyColor, true)
+		vector.StrokeCircle(screen, sx, sy, 7, 2, color.RGBA{255, 255, 255, 255}, true)
+		ebitenutil.DebugPrintAt(screen, "R", int(sx)-3, int(sy)-8)
+		ebitenutil.DebugPrintAt(screen, p.Name, int(sx)+10, int(sy)-8)
+	}
+}
+
+// rallyLine formats the way to the nearest rally point for the HUD
+func rallyLine(r *RallyInfo, units Units) string {
+	return fmt.Sprintf("RALLY %s %s %03.0f°", r.Name, units.FormatDistance(r.Dist), r.Bearing)
+}
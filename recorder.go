@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRecordInterval is the telemetry sampling period (5 Hz)
+const DefaultRecordInterval = 200 * time.Millisecond
+
+// recordNameLayout names each log after when its session started
+const recordNameLayout = "flight-20060102-150405.csv"
+
+// recorderColumns is the CSV header written at the top of each log
+var recorderColumns = []string{
+	"time", "lat", "lon", "alt_m", "speed_kmh", "heading",
+	"sats", "pitch", "roll", "yaw",
+	"voltage", "current", "capacity_mah", "remaining_pct",
+	"rssi1", "rssi2", "lq", "snr", "tx_power",
+	"baro_alt_m", "vspeed", "mode",
+}
+
+// recordChannels group the columns for record.rates_hz, so slow-changing
+// ones can be written less often than the position. A channel that isn't
+// due leaves its columns empty, and readers carry the last value forward.
+var recordChannels = map[string][]string{
+	"gps":      {"lat", "lon", "alt_m", "speed_kmh", "heading", "sats"},
+	"attitude": {"pitch", "roll", "yaw"},
+	"battery":  {"voltage", "current", "capacity_mah", "remaining_pct"},
+	"link":     {"rssi1", "rssi2", "lq", "snr", "tx_power"},
+	"baro":     {"baro_alt_m", "vspeed"},
+	"mode":     {"mode"},
+}
+
+// Recorder writes telemetry to a CSV file, one file per session,
+// gzipped if record.gzip is set
+type Recorder struct {
+	client *GRPCClient
+	config *RecordConfig
+
+	file       *os.File
+	gz         *gzip.Writer // nil unless compressing
+	buf        *bufio.Writer
+	csv        *csv.Writer
+	lastUpdate time.Time
+	rows       int
+	lastWrite  map[string]time.Time // Per channel with a rate
+	size       *countingWriter      // Bytes in the file, for rotating
+	lastSync   time.Time
+
+	mu       sync.Mutex
+	running  bool
+	stopChan chan struct{}
+	done     chan struct{}
+}
+
+// NewRecorder creates a recorder that samples the client every
+// record.interval; settings changes apply from the next Start
+func NewRecorder(client *GRPCClient, cfg *RecordConfig) *Recorder {
+	return &Recorder{client: client, config: cfg}
+}
+
+// Start opens a new log file and begins sampling
+func (r *Recorder) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running {
+		return nil
+	}
+	if err := os.MkdirAll(r.config.Dir, 0755); err != nil {
+		return err
+	}
+	f, err := r.create()
+	if err != nil {
+		return err
+	}
+	r.open(f)
+
+	r.running = true
+	r.stopChan = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.loop()
+	return nil
+}
+
+// create makes a new log file, named after now
+func (r *Recorder) create() (*os.File, error) {
+	name := time.Now().Format(recordNameLayout)
+	if r.config.Gzip {
+		name += ".gz"
+	}
+	// Never over an earlier log, should two start within a second
+	return os.OpenFile(filepath.Join(r.config.Dir, name), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+}
+
+// open starts writing rows to a new log file
+func (r *Recorder) open(f *os.File) {
+	r.file = f
+	r.size = &countingWriter{w: f}
+	var w io.Writer = r.size
+	r.gz = nil
+	if r.config.Gzip {
+		r.gz = gzip.NewWriter(w)
+		w = r.gz
+	}
+	r.buf = bufio.NewWriter(w)
+	r.csv = csv.NewWriter(r.buf)
+	r.csv.Write(recorderColumns)
+	r.rows = 0
+	r.lastWrite = make(map[string]time.Time)
+	r.lastSync = time.Now()
+
+	logRec.Infof("Recording telemetry to %s", f.Name())
+}
+
+// close flushes the log to disk and closes it
+func (r *Recorder) close() {
+	r.flush()
+	if r.gz != nil {
+		r.gz.Close()
+	}
+	if err := r.file.Sync(); err != nil {
+		logRec.Errorf("Sync error: %v", err)
+	}
+	r.file.Close()
+	logRec.Infof("Recorded %d telemetry rows to %s", r.rows, r.file.Name())
+}
+
+// rotate moves on to a new file once the current one reaches
+// record.max_size_mb, so no single log grows without bound
+func (r *Recorder) rotate() {
+	if r.config.MaxSizeMB <= 0 || r.size.n < int64(r.config.MaxSizeMB)<<20 {
+		return
+	}
+	f, err := r.create()
+	if err != nil {
+		// Carry on in the current file, and try again next time
+		logRec.Errorf("Could not start the next log: %v", err)
+		return
+	}
+	r.close()
+	r.open(f)
+}
+
+// Stop flushes and closes the current log file
+func (r *Recorder) Stop() {
+	r.mu.Lock()
+	if !r.running {
+		r.mu.Unlock()
+		return
+	}
+	r.running = false
+	close(r.stopChan)
+	r.mu.Unlock()
+
+	<-r.done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.close()
+}
+
+// Active returns the log being written, or "" when not recording
+func (r *Recorder) Active() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.running {
+		return ""
+	}
+	return r.file.Name()
+}
+
+// IsRecording returns true while a log file is open
+func (r *Recorder) IsRecording() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running
+}
+
+func (r *Recorder) loop() {
+	defer close(r.done)
+
+	interval := r.config.Interval
+	if interval <= 0 {
+		interval = DefaultRecordInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	flushTicker := time.NewTicker(time.Second)
+	defer flushTicker.Stop()
+
+	for {
+		select {
+		case <-r.stopChan:
+			return
+		case <-ticker.C:
+			r.sample()
+		case <-flushTicker.C:
+			r.mu.Lock()
+			r.flush()
+			r.sync()
+			r.rotate()
+			r.mu.Unlock()
+		}
+	}
+}
+
+// sample writes a row if new telemetry arrived since the last one
+func (r *Recorder) sample() {
+	state := r.client.GetState()
+	if state.LastUpdate.IsZero() || !state.LastUpdate.After(r.lastUpdate) {
+		return
+	}
+	skip := r.channelsSkipped(state.LastUpdate)
+	if skip == nil {
+		return
+	}
+	r.lastUpdate = state.LastUpdate
+
+	f32 := func(v float32, prec int) string { return strconv.FormatFloat(float64(v), 'f', prec, 32) }
+	row := []string{
+		state.LastUpdate.Format(time.RFC3339Nano),
+		f32(state.Latitude, 7), f32(state.Longitude, 7),
+		strconv.Itoa(int(state.Altitude)), f32(state.GroundSpeed, 1), f32(state.Heading, 1),
+		strconv.Itoa(int(state.Satellites)),
+		f32(state.Pitch, 1), f32(state.Roll, 1), f32(state.Yaw, 1),
+		f32(state.Voltage, 2), f32(state.Current, 1),
+		strconv.Itoa(int(state.Capacity)), strconv.Itoa(int(state.Remaining)),
+		strconv.Itoa(int(state.RSSI1)), strconv.Itoa(int(state.RSSI2)),
+		strconv.Itoa(int(state.LinkQuality)), strconv.Itoa(int(state.SNR)),
+		strconv.Itoa(int(state.TXPower)),
+		f32(state.BaroAltitude, 1), f32(state.VerticalSpeed, 1),
+		state.FlightMode,
+	}
+	for i, name := range recorderColumns {
+		if skip[name] {
+			row[i] = ""
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.csv.Write(row); err != nil {
+		logRec.Errorf("Write error: %v", err)
+		return
+	}
+	r.rows++
+}
+
+// channelsSkipped returns the columns of channels that aren't due at t,
+// marking the others written; nil when no channel is due, so there is no
+// row to write at all
+func (r *Recorder) channelsSkipped(t time.Time) map[string]bool {
+	skip := map[string]bool{}
+	due := len(r.config.Rates) < len(recordChannels) // Some channel is written every sample
+	for channel, hz := range r.config.Rates {
+		columns, ok := recordChannels[channel]
+		if !ok || hz <= 0 {
+			due = true
+			continue
+		}
+		if t.Sub(r.lastWrite[channel]) < time.Duration(float64(time.Second)/hz) {
+			for _, c := range columns {
+				skip[c] = true
+			}
+			continue
+		}
+		r.lastWrite[channel] = t
+		due = true
+	}
+	if !due {
+		return nil
+	}
+	return skip
+}
+
+// sync makes the flushed rows durable every record.sync_interval, as the
+// OS may otherwise hold them in memory well past a power cut
+func (r *Recorder) sync() {
+	if r.config.SyncInterval <= 0 || time.Since(r.lastSync) < r.config.SyncInterval {
+		return
+	}
+	r.lastSync = time.Now()
+	if err := r.file.Sync(); err != nil {
+		logRec.Errorf("Sync error: %v", err)
+	}
+}
+
+func (r *Recorder) flush() {
+	r.csv.Flush()
+	if err := r.buf.Flush(); err != nil {
+		logRec.Errorf("Flush error: %v", err)
+	}
+	if r.gz != nil {
+		if err := r.gz.Flush(); err != nil {
+			logRec.Errorf("Flush error: %v", err)
+		}
+	}
+}
+
+// RecordingAt returns the log in dir that was being written at t: the one
+// of the latest session started by then, or "" if there is none
+func RecordingAt(dir string, t time.Time) string {
+	best, bestStart := "", time.Time{}
+	for _, name := range recordings(dir) {
+		base := strings.TrimSuffix(filepath.Base(name), ".gz")
+		start, err := time.ParseInLocation(recordNameLayout, base, time.Local)
+		if err != nil || start.After(t) || start.Before(bestStart) {
+			continue
+		}
+		best, bestStart = name, start
+	}
+	return best
+}
+
+// recordings returns the telemetry logs in dir, plain and gzipped, sorted
+// by name and so by start time
+func recordings(dir string) []string {
+	names, _ := filepath.Glob(filepath.Join(dir, "flight-*.csv"))
+	gzipped, _ := filepath.Glob(filepath.Join(dir, "flight-*.csv.gz"))
+	names = append(names, gzipped...)
+	slices.Sort(names)
+	return names
+}
+
+// openLog opens a telemetry log or CRSF dump, unpacking it if gzipped. A
+// gzipped log cut short, as by a power cut while recording, reads up to
+// where it ends.
+func openLog(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	magic := make([]byte, 2)
+	if n, _ := io.ReadFull(f, magic); n < 2 || magic[0] != 0x1f || magic[1] != 0x8b {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+	f.Seek(0, io.SeekStart)
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &gzipLog{gz: gz, f: f}, nil
+}
+
+// readLog reads a whole telemetry log or CRSF dump, gzipped or not
+func readLog(path string) ([]byte, error) {
+	r, err := openLog(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+type gzipLog struct {
+	gz *gzip.Reader
+	f  *os.File
+}
+
+func (l *gzipLog) Read(p []byte) (int, error) {
+	n, err := l.gz.Read(p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (l *gzipLog) Close() error {
+	l.gz.Close()
+	return l.f.Close()
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,174 @@
+//go:build !headless
+
+package main
+
+import (
+	"image/color"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// powerSaveRefresh is the longest power save keeps a frame, so timers,
+// fading buttons and link-lost ages on screen keep ticking
+const powerSaveRefresh = time.Second
+
+// frameKey is what a frame depends on besides input. Power save redraws
+// only when it differs from the last frame's.
+type frameKey struct {
+	width, height        int
+	centerLat, centerLon float64
+	zoom, hudMode        int
+	homeLat, homeLon     float64
+	homeSet, follow      bool
+	showHelp, touchBtns  bool
+	telemetry            time.Time
+	connected, link      bool
+	tiles, toasts        int
+	cursorX, cursorY     int
+	input                bool
+}
+
+// mapLayerKey is what the pre-rendered tile layer depends on
+type mapLayerKey struct {
+	width, height int
+	view          MapView
+	source        MapSource
+	tiles         int
+}
+
+// applyPowerSave sets the tick rate and screen clearing for the current mode
+func (a *App) applyPowerSave() {
+	display := a.config.Display
+	if display.PowerSave {
+		fps := display.PowerSaveFPS
+		if fps <= 0 {
+			fps = 20
+		}
+		ebiten.SetTPS(fps)
+	} else {
+		ebiten.SetTPS(ebiten.DefaultTPS)
+	}
+	// Skipped draws must leave the last frame on screen
+	ebiten.SetScreenClearedEveryFrame(!display.PowerSave)
+	a.redraw = true
+}
+
+// updateRedraw records whether the next Draw has anything new to show.
+// Draw runs at the display's refresh rate regardless of TPS, so skipping
+// the frames in between also caps the frame rate at the tick rate.
+func (a *App) updateRedraw(state TelemetryState) {
+	key := frameKey{
+		width:     a.width,
+		height:    a.height,
+		centerLat: a.centerLat,
+		centerLon: a.centerLon,
+		zoom:      a.zoom,
+		hudMode:   a.hudMode,
+		homeLat:   a.homeLat,
+		homeLon:   a.homeLon,
+		homeSet:   a.homeSet,
+		follow:    a.followAircraft,
+		showHelp:  a.showHelp,
+		touchBtns: a.showTouchBtns,
+		telemetry: state.LastUpdate,
+		connected: state.Connected,
+		link:      state.LinkStarted,
+		tiles:     a.tileManager.Generation(),
+		toasts:    a.toasts.Generation(),
+		input:     inputActive(),
+	}
+	key.cursorX, key.cursorY = ebiten.CursorPosition()
+
+	// Held keys and buttons redraw every tick, as menus and drags react to them
+	if key != a.lastFrame || key.input || time.Since(a.lastDraw) >= powerSaveRefresh {
+		a.redraw = true
+	}
+	a.lastFrame = key
+}
+
+// skipDraw reports whether power save can keep the frame on screen
+func (a *App) skipDraw() bool {
+	if a.config.Display.PowerSave && !a.redraw {
+		return true
+	}
+	a.redraw = false
+	a.lastDraw = time.Now()
+	a.perf.FrameDrawn()
+	return false
+}
+
+// inputActive reports whether any key, button, touch or wheel is in use
+func inputActive() bool {
+	if len(inpututil.AppendPressedKeys(nil)) > 0 || len(ebiten.AppendTouchIDs(nil)) > 0 {
+		return true
+	}
+	for _, b := range []ebiten.MouseButton{ebiten.MouseButtonLeft, ebiten.MouseButtonRight, ebiten.MouseButtonMiddle} {
+		if ebiten.IsMouseButtonPressed(b) {
+			return true
+		}
+	}
+	wx, wy := ebiten.Wheel()
+	return wx != 0 || wy != 0
+}
+
+// drawMapWithOffset draws the map tiles from a pre-rendered layer, which is
+// only rebuilt when the view moves or a tile arrives
+func (a *App) drawMapWithOffset(screen *ebiten.Image, v MapView) {
+	w, h := screen.Bounds().Dx(), screen.Bounds().Dy()
+	if w <= 0 || h <= 0 {
+		return
+	}
+	key := mapLayerKey{
+		width:  w,
+		height: h,
+		view:   v,
+		source: a.tileManager.GetSource(),
+		tiles:  a.tileManager.Generation(),
+	}
+	if a.mapLayer == nil || a.mapLayerKey.width != w || a.mapLayerKey.height != h {
+		if a.mapLayer != nil {
+			a.mapLayer.Dispose()
+		}
+		a.mapLayer = ebiten.NewImage(w, h)
+		a.mapLayerKey = mapLayerKey{}
+	}
+	if key != a.mapLayerKey {
+		a.mapLayer.Clear()
+		a.drawTilesWithOffset(a.mapLayer, v)
+		a.mapLayerKey = key
+	}
+	screen.DrawImage(a.mapLayer, nil)
+}
+
+// placedTile is a tile image and its top-left world pixel
+type placedTile struct {
+	img  *ebiten.Image
+	x, y float64
+}
+
+// drawPath fills path and strokes its outline, in two draw calls however
+// many shapes it holds
+func drawPath(dst *ebiten.Image, path *vector.Path, fill, stroke color.RGBA) {
+	vs, is := path.AppendVerticesAndIndicesForFilling(nil, nil)
+	if len(is) == 0 {
+		return
+	}
+	drawVertices(dst, vs, is, fill)
+	vs, is = path.AppendVerticesAndIndicesForStroke(vs[:0], is[:0], &vector.StrokeOptions{Width: 1})
+	drawVertices(dst, vs, is, stroke)
+}
+
+func drawVertices(dst *ebiten.Image, vs []ebiten.Vertex, is []uint16, c color.RGBA) {
+	for i := range vs {
+		vs[i].SrcX = 1
+		vs[i].SrcY = 1
+		vs[i].ColorR = float32(c.R) / 255
+		vs[i].ColorG = float32(c.G) / 255
+		vs[i].ColorB = float32(c.B) / 255
+		vs[i].ColorA = float32(c.A) / 255
+	}
+	dst.DrawTriangles(vs, is, emptyImage, &ebiten.DrawTrianglesOptions{AntiAlias: true})
+}
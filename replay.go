@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ReplaySample is one row of a telemetry log with when it was received
+type ReplaySample struct {
+	At    time.Time
+	State TelemetryState
+}
+
+// LoadReplay reads a telemetry log written by the recorder, or a raw CRSF
+// dump, which is taken to advance crsfGap per telemetry frame and to have
+// ended when the file was last written. Either may be gzipped.
+func LoadReplay(path string, crsfGap time.Duration) ([]ReplaySample, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := openLog(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	head, _ := r.Peek(256)
+	if isCRSFDump(head) {
+		samples, err := LoadCRSFDump(path, info.ModTime(), crsfGap)
+		if err != nil {
+			return nil, err
+		}
+		shift := info.ModTime().Sub(samples[len(samples)-1].At)
+		for i := range samples {
+			samples[i].At = samples[i].At.Add(shift)
+		}
+		return samples, nil
+	}
+	return loadReplayCSV(path, r)
+}
+
+// loadReplayCSV reads every row of a telemetry log. Columns are found by
+// name, so logs from older versions load too. Empty fields, from channels
+// recorded at a lower rate, keep the previous row's value.
+func loadReplayCSV(path string, f io.Reader) ([]ReplaySample, error) {
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	col := map[string]int{}
+	for i, name := range header {
+		col[name] = i
+	}
+	if _, ok := col["time"]; !ok {
+		return nil, fmt.Errorf("%s: not a telemetry log (no time column)", path)
+	}
+
+	var samples []ReplaySample
+	var s TelemetryState
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		text := func(name string) string {
+			if i, ok := col[name]; ok && i < len(row) {
+				return row[i]
+			}
+			return ""
+		}
+		num := func(name string, set func(float64)) {
+			if v, err := strconv.ParseFloat(text(name), 64); err == nil {
+				set(v)
+			}
+		}
+		at, err := time.Parse(time.RFC3339Nano, text("time"))
+		if err != nil {
+			continue
+		}
+		num("lat", func(v float64) { s.Latitude = float32(v) })
+		num("lon", func(v float64) { s.Longitude = float32(v) })
+		num("alt_m", func(v float64) { s.Altitude = int32(v) })
+		num("speed_kmh", func(v float64) { s.GroundSpeed = float32(v) })
+		num("heading", func(v float64) { s.Heading = float32(v) })
+		num("sats", func(v float64) { s.Satellites = uint32(v) })
+		num("pitch", func(v float64) { s.Pitch = float32(v) })
+		num("roll", func(v float64) { s.Roll = float32(v) })
+		num("yaw", func(v float64) { s.Yaw = float32(v) })
+		num("voltage", func(v float64) { s.Voltage = float32(v) })
+		num("current", func(v float64) { s.Current = float32(v) })
+		num("capacity_mah", func(v float64) { s.Capacity = uint32(v) })
+		num("remaining_pct", func(v float64) { s.Remaining = uint32(v) })
+		num("rssi1", func(v float64) { s.RSSI1 = int32(v) })
+		num("rssi2", func(v float64) { s.RSSI2 = int32(v) })
+		num("lq", func(v float64) { s.LinkQuality = uint32(v) })
+		num("snr", func(v float64) { s.SNR = int32(v) })
+		num("tx_power", func(v float64) { s.TXPower = uint32(v) })
+		num("baro_alt_m", func(v float64) { s.BaroAltitude = float32(v) })
+		num("vspeed", func(v float64) { s.VerticalSpeed = float32(v) })
+		if mode := text("mode"); mode != "" {
+			s.FlightMode = mode
+		}
+		s.HasGPS = s.Latitude != 0 || s.Longitude != 0
+		samples = append(samples, ReplaySample{At: at, State: s})
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("%s: no telemetry", path)
+	}
+	return samples, nil
+}
+
+// replayTrack returns the positions of replay samples with a fix, for
+// drawing a flight from a CRSF dump like a telemetry log's
+func replayTrack(samples []ReplaySample) []TrackPoint {
+	var track []TrackPoint
+	for _, s := range samples {
+		if !s.State.HasGPS {
+			continue
+		}
+		track = append(track, TrackPoint{
+			Lat: float64(s.State.Latitude), Lon: float64(s.State.Longitude),
+			Alt: float64(s.State.Altitude), LQ: int(s.State.LinkQuality),
+		})
+	}
+	return track
+}
+
+// replayAt returns the last sample received at or before t, or the first
+func replayAt(samples []ReplaySample, t time.Time) ReplaySample {
+	i := sort.Search(len(samples), func(i int) bool { return samples[i].At.After(t) })
+	return samples[max(0, i-1)]
+}
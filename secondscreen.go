@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+)
+
+// secondScreenConfig makes cfg that of a second screen for another
+// elrs-map, usually on this machine and driving the first display: its
+// telemetry comes from that one's web server, at mirror or else at
+// web.listen here, it opens full screen on the second display, and
+// everything that records, serves or drives hardware is left to the first.
+func secondScreenConfig(cfg *Config, mirror string) {
+	if mirror == "" {
+		mirror = localWebAddr(cfg.Web.Listen)
+	}
+	cfg.Backend.Source = "web"
+	cfg.Backend.Address = mirror
+	cfg.Backend.Discover = false
+	cfg.Backend.Backups = nil
+	if cfg.Window.Monitor == 0 {
+		cfg.Window.Monitor = 2
+	}
+	cfg.Window.Fullscreen = true
+
+	cfg.Record.Enabled = false
+	cfg.Web.Enabled = false
+	cfg.Buddy.Enabled = false
+	cfg.MQTT.Enabled = false
+	cfg.Share.Enabled = false
+	cfg.Status.Enabled = false
+	cfg.Upload.Enabled = false
+	cfg.OSDOut.Enabled = false
+	cfg.GPS.Enabled = false
+	cfg.Tracker.Enabled = false
+	cfg.Plugins = nil
+
+	// Its own log, rotated apart from the first one's
+	if cfg.Log.Dir == "" {
+		cfg.Log.Dir = DefaultDataDir()
+	}
+	cfg.Log.Dir = filepath.Join(cfg.Log.Dir, "second-screen")
+
+	// The first one has calibrated the battery readings already
+	for name, p := range cfg.Aircraft.Profiles {
+		p.Voltage, p.Current = SensorCalibration{}, SensorCalibration{}
+		cfg.Aircraft.Profiles[name] = p
+	}
+	logApp.Infof("Second screen for the elrs-map web server at %s", mirror)
+}
+
+// localWebAddr turns a listen address such as ":8080" into one to reach it
+// on this machine
+func localWebAddr(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return listen
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// openSerial opens a serial device in raw 8N1 mode at the given speed.
+// macOS takes the speed as a number, so any rate the driver supports works.
+func openSerial(path string, baud int) (io.ReadWriteCloser, error) {
+	if baud <= 0 {
+		return nil, fmt.Errorf("unsupported baud rate %d", baud)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	fd := int(f.Fd())
+
+	t, err := unix.IoctlGetTermios(fd, unix.TIOCGETA)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a serial port: %w", path, err)
+	}
+	// Equivalent of cfmakeraw, then 8N1 with the receiver on
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB | unix.CSTOPB | unix.CRTSCTS
+	t.Cflag |= unix.CS8 | unix.CREAD | unix.CLOCAL
+	t.Ispeed, t.Ospeed = uint64(baud), uint64(baud)
+	t.Cc[unix.VMIN], t.Cc[unix.VTIME] = 1, 0
+	if err := unix.IoctlSetTermios(fd, unix.TIOCSETA, t); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s at %d baud: %w", path, baud, err)
+	}
+	// Back to blocking writes now that open can't hang on carrier detect
+	if err := unix.SetNonblock(fd, false); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
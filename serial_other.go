@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// openSerial is implemented for Linux, macOS and Windows
+func openSerial(path string, baud int) (io.ReadWriteCloser, error) {
+	return nil, fmt.Errorf("serial ports are not supported on %s", runtime.GOOS)
+}
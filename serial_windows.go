@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	// serialReadTimeout is how long a read waits for data before checking
+	// whether the port was closed, in milliseconds
+	serialReadTimeout = 500
+
+	maxDWORD = ^uint32(0)
+)
+
+// serialPort is a COM port handle. Reads wait for at least one byte, as
+// os.File would take a read timeout for the end of the file.
+type serialPort struct {
+	h      windows.Handle
+	closed atomic.Bool
+}
+
+// openSerial opens a COM port (COM3, or \\.\COM3) in 8N1 mode at the given
+// speed
+func openSerial(path string, baud int) (io.ReadWriteCloser, error) {
+	if baud <= 0 {
+		return nil, fmt.Errorf("unsupported baud rate %d", baud)
+	}
+	// COM10 and up only open through the device namespace
+	name := path
+	if !strings.HasPrefix(name, `\\.\`) {
+		name = `\\.\` + name
+	}
+	p, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	h, err := windows.CreateFile(p, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	var dcb windows.DCB
+	dcb.DCBlength = uint32(unsafe.Sizeof(dcb))
+	if err := windows.GetCommState(h, &dcb); err != nil {
+		windows.CloseHandle(h)
+		return nil, fmt.Errorf("%s is not a serial port: %w", path, err)
+	}
+	dcb.BaudRate = uint32(baud)
+	dcb.ByteSize = 8
+	dcb.Parity = windows.NOPARITY
+	dcb.StopBits = windows.ONESTOPBIT
+	// Binary mode with DTR and RTS on and no flow control
+	const fBinary, fDtrControlEnable, fRtsControlEnable = 1 << 0, 1 << 4, 1 << 12
+	dcb.Flags = fBinary | fDtrControlEnable | fRtsControlEnable
+	if err := windows.SetCommState(h, &dcb); err != nil {
+		windows.CloseHandle(h)
+		return nil, fmt.Errorf("%s at %d baud: %w", path, baud, err)
+	}
+
+	// Return as soon as anything arrives, or after the timeout with nothing
+	timeouts := windows.CommTimeouts{
+		ReadIntervalTimeout:        maxDWORD,
+		ReadTotalTimeoutMultiplier: maxDWORD,
+		ReadTotalTimeoutConstant:   serialReadTimeout,
+	}
+	if err := windows.SetCommTimeouts(h, &timeouts); err != nil {
+		windows.CloseHandle(h)
+		return nil, err
+	}
+	return &serialPort{h: h}, nil
+}
+
+func (s *serialPort) Read(b []byte) (int, error) {
+	for {
+		if s.closed.Load() {
+			return 0, os.ErrClosed
+		}
+		var n uint32
+		if err := windows.ReadFile(s.h, b, &n, nil); err != nil {
+			return 0, err
+		}
+		if n > 0 {
+			return int(n), nil
+		}
+	}
+}
+
+func (s *serialPort) Write(b []byte) (int, error) {
+	if s.closed.Load() {
+		return 0, os.ErrClosed
+	}
+	var n uint32
+	err := windows.WriteFile(s.h, b, &n, nil)
+	return int(n), err
+}
+
+// Close releases the port; a read in progress ends within the read timeout
+func (s *serialPort) Close() error {
+	if s.closed.Swap(true) {
+		return nil
+	}
+	return windows.CloseHandle(s.h)
+}
@@ -0,0 +1,745 @@
+//go:build !headless
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Theme is a set of background colors for the map and instruments
+type Theme struct {
+	MapBg   color.RGBA
+	PanelBg color.RGBA
+	DarkBg  color.RGBA
+	OSDBg   color.RGBA
+}
+
+// Themes available in the settings menu
+var Themes = map[string]Theme{
+	"dark": {
+		MapBg:   color.RGBA{30, 30, 30, 255},
+		PanelBg: color.RGBA{25, 25, 30, 255},
+		DarkBg:  color.RGBA{15, 15, 20, 255},
+		OSDBg:   color.RGBA{0, 0, 0, 160},
+	},
+	// Pure black backgrounds for maximum contrast in sunlight
+	"black": {
+		MapBg:   color.RGBA{0, 0, 0, 255},
+		PanelBg: color.RGBA{0, 0, 0, 255},
+		DarkBg:  color.RGBA{0, 0, 0, 255},
+		OSDBg:   color.RGBA{0, 0, 0, 220},
+	},
+}
+
+var themeNames = []string{"dark", "black"}
+
+// applyConfig pushes the current config into the UI components
+func (a *App) applyConfig() {
+	cfg := a.config
+
+	theme, ok := Themes[cfg.Display.Theme]
+	if !ok {
+		theme = Themes["dark"]
+	}
+	a.mapBg = theme.MapBg
+	a.panel.panelBg = theme.PanelBg
+	a.panel.darkBg = theme.DarkBg
+	a.osd.bgColor = theme.OSDBg
+
+	a.panel.Units = cfg.Display.Units
+	a.panel.Alerts = cfg.Alerts
+	a.panel.RightSide = cfg.Display.PanelSide == "right"
+	a.osd.Units = cfg.Display.Units
+	a.osd.Alerts = cfg.Alerts
+	a.osd.Elements = cfg.Display.OSD
+	a.layers.Configure(cfg.Map.Layers)
+	a.osd.Battery = cfg.Battery
+	a.panel.Battery = cfg.Battery
+	a.cockpitHUD.Battery = cfg.Battery
+	a.cockpitHUD.Alerts = cfg.Alerts
+
+	if source, ok := ParseMapSource(cfg.Map.Source); ok {
+		a.tileManager.SetSource(source)
+	}
+	a.applyPowerSave()
+	a.applyAircraftIcon()
+}
+
+// restoreState applies the saved runtime state from the config
+func (a *App) restoreState() {
+	cfg := a.config
+	st := cfg.State
+
+	a.centerLat, a.centerLon = cfg.Map.DefaultLat, cfg.Map.DefaultLon
+	if st.CenterLat != 0 || st.CenterLon != 0 {
+		a.centerLat, a.centerLon = st.CenterLat, st.CenterLon
+	}
+	if st.Zoom >= MinZoom && st.Zoom <= MaxZoom {
+		a.zoom = st.Zoom
+	}
+	if st.HUDMode >= 0 && st.HUDMode < hudModes {
+		a.hudMode = st.HUDMode
+	}
+	a.followAircraft = cfg.Map.FollowOnStart || st.Follow
+	// Home is only put back once the pilot confirms it is still right
+	if st.Home.Set || st.Pilot.Set {
+		a.homeMenu = a.newHomeMenu(st)
+		a.homeMenu.Open()
+	}
+
+	a.showTouchBtns = cfg.Touch.Enabled
+	a.touchControls.IdleTimeout = cfg.Touch.IdleTimeout
+	a.touchControls.Opacity = cfg.Touch.Opacity
+	if cfg.Backend.BaudRate > 0 {
+		a.baudRate = cfg.Backend.BaudRate
+	}
+}
+
+// captureState copies runtime state into the config for saving
+func (a *App) captureState() {
+	cfg := a.config
+	home, homeSetAt := HomeConfig{Set: a.homeSet, Lat: a.homeLat, Lon: a.homeLon}, a.homeSetAt
+	if a.movingHome {
+		// Only kept once the move is confirmed
+		home.Lat, home.Lon = a.homeBefore[0], a.homeBefore[1]
+	}
+	pilot := HomeConfig{Set: a.pilotSet, Lat: a.pilotLat, Lon: a.pilotLon}
+	// Keep the last session's positions until the pilot answers the prompt
+	if a.homeMenu != nil && a.homeMenu.IsOpen() {
+		if !a.homeSet {
+			home, homeSetAt = cfg.State.Home, cfg.State.HomeSetAt
+		}
+		if !a.pilotSet {
+			pilot = cfg.State.Pilot
+		}
+	}
+	cfg.State = StateConfig{
+		Zoom:      a.zoom,
+		CenterLat: a.centerLat,
+		CenterLon: a.centerLon,
+		HUDMode:   a.hudMode,
+		Follow:    a.followAircraft,
+		Home:      home,
+		HomeSetAt: homeSetAt,
+		Pilot:     pilot,
+	}
+	cfg.Touch.Enabled = a.showTouchBtns
+	cfg.Backend.BaudRate = a.baudRate
+	// The simulator's fake port shouldn't replace the real one
+	if len(a.ports) > 0 && a.selectedPort < len(a.ports) && a.client.HasSerialPorts() {
+		cfg.Backend.Port = a.ports[a.selectedPort]
+	}
+	cfg.Window.Fullscreen = a.fullscreen
+	if !a.fullscreen && a.width > 0 && a.height > 0 {
+		cfg.Window.Width, cfg.Window.Height = a.width, a.height
+	}
+}
+
+// saveConfig writes the config to disk if anything changed since the last save
+func (a *App) saveConfig() {
+	if a.configPath == "" {
+		return
+	}
+	a.captureState()
+
+	data, err := yaml.Marshal(a.config)
+	if err != nil {
+		logConfig.Errorf("Could not encode config: %v", err)
+		return
+	}
+	if bytes.Equal(data, a.savedConfig) {
+		return
+	}
+	if err := writeFileAtomic(a.configPath, data); err != nil {
+		logConfig.Errorf("Could not save config: %v", err)
+		return
+	}
+	a.savedConfig = data
+}
+
+// cycle returns the entry delta steps away from current in options
+func cycle(options []string, current string, delta int) string {
+	idx := 0
+	for i, o := range options {
+		if o == current {
+			idx = i
+			break
+		}
+	}
+	n := len(options)
+	return options[((idx+delta)%n+n)%n]
+}
+
+// newSettingsMenu builds the unified settings screen
+func (a *App) newSettingsMenu() *Menu {
+	m := NewMenu("Settings")
+	m.OnClose = a.saveConfig
+
+	cfg := a.config
+	changed := func() { a.applyConfig() }
+
+	m.Items = []MenuItem{
+		{
+			Label: "Theme",
+			Value: func() string { return cfg.Display.Theme },
+			OnAdjust: func(d int) {
+				cfg.Display.Theme = cycle(themeNames, cfg.Display.Theme, d)
+				changed()
+			},
+		},
+		{
+			Label: "Units",
+			Value: func() string { return string(cfg.Display.Units) },
+			OnAdjust: func(d int) {
+				cfg.Display.Units = Units(cycle([]string{string(UnitsMetric), string(UnitsImperial)}, string(cfg.Display.Units), d))
+				changed()
+			},
+		},
+		{
+			Label: "Panel side",
+			Value: func() string { return cfg.Display.PanelSide },
+			OnAdjust: func(d int) {
+				cfg.Display.PanelSide = cycle([]string{"left", "right"}, cfg.Display.PanelSide, d)
+				changed()
+			},
+		},
+		{
+			Label: "Split map share",
+			Value: func() string { return fmt.Sprintf("%.0f%%", cfg.Display.SplitRatio*100) },
+			OnAdjust: func(d int) {
+				cfg.Display.SplitRatio = float64(clampInt(int(math.Round(cfg.Display.SplitRatio*20))+d, 6, 14)) / 20
+				changed()
+			},
+		},
+		{
+			Label: "Power save",
+			Value: func() string { return onOff(cfg.Display.PowerSave) },
+			OnAdjust: func(int) {
+				cfg.Display.PowerSave = !cfg.Display.PowerSave
+				changed()
+			},
+		},
+		{
+			Label: "Latency graph",
+			Value: func() string { return onOff(cfg.Display.LatencyGraph) },
+			OnAdjust: func(int) {
+				cfg.Display.LatencyGraph = !cfg.Display.LatencyGraph
+				changed()
+			},
+		},
+		{
+			Label: "LQ timeline",
+			Value: func() string { return onOff(cfg.Display.LQTimeline) },
+			OnAdjust: func(int) {
+				cfg.Display.LQTimeline = !cfg.Display.LQTimeline
+				changed()
+			},
+		},
+		{
+			Label: "Mini attitude",
+			Value: func() string { return onOff(cfg.Display.MiniAttitude) },
+			OnAdjust: func(int) {
+				cfg.Display.MiniAttitude = !cfg.Display.MiniAttitude
+				changed()
+			},
+		},
+		{
+			Label: "Status bar",
+			Value: func() string {
+				if cfg.Display.StatusBar.Compact {
+					return "compact"
+				}
+				return "full"
+			},
+			OnAdjust: func(int) {
+				cfg.Display.StatusBar.Compact = !cfg.Display.StatusBar.Compact
+			},
+		},
+		{
+			Label: "OSD elements...",
+			OnSelect: func() {
+				m.Close()
+				a.osdMenu.Open()
+			},
+		},
+		{
+			Label: "Map layers...",
+			OnSelect: func() {
+				m.Close()
+				a.layersMenu.Open()
+			},
+		},
+		{
+			Label: "Map source",
+			Value: func() string { return cfg.Map.Source },
+			OnAdjust: func(d int) {
+				cfg.Map.Source = cycle([]string{"satellite", "street"}, cfg.Map.Source, d)
+				changed()
+			},
+		},
+		{
+			Label: "Map brightness",
+			Value: func() string { return fmt.Sprintf("%+.0f%%", cfg.Map.Brightness*100) },
+			OnAdjust: func(d int) {
+				cfg.Map.Brightness = stepMapColor(cfg.Map.Brightness, d, -0.5, 0.5)
+			},
+		},
+		{
+			Label: "Map contrast",
+			Value: func() string { return fmt.Sprintf("%.0f%%", cfg.Map.Contrast*100) },
+			OnAdjust: func(d int) {
+				cfg.Map.Contrast = stepMapColor(cfg.Map.Contrast, d, 0.5, 2)
+			},
+		},
+		{
+			Label: "Map saturation",
+			Value: func() string { return fmt.Sprintf("%.0f%%", cfg.Map.Saturation*100) },
+			OnAdjust: func(d int) {
+				cfg.Map.Saturation = stepMapColor(cfg.Map.Saturation, d, 0, 2)
+			},
+		},
+		{
+			Label: "Map credit corner",
+			Value: func() string { return cfg.Map.Attribution },
+			OnAdjust: func(d int) {
+				cfg.Map.Attribution = cycle(attributionCorners, cfg.Map.Attribution, d)
+			},
+		},
+		{
+			Label: "Aircraft icon",
+			Value: func() string { return cfg.Aircraft.Profile },
+			OnAdjust: func(d int) {
+				names := make([]string, 0, len(cfg.Aircraft.Profiles))
+				for name := range cfg.Aircraft.Profiles {
+					names = append(names, name)
+				}
+				if len(names) == 0 {
+					return
+				}
+				sort.Strings(names)
+				cfg.Aircraft.Profile = cycle(names, cfg.Aircraft.Profile, d)
+				changed()
+			},
+		},
+		{
+			Label: "Voltage scale",
+			Value: func() string { return calibrationScale(cfg.Aircraft.Profiles[cfg.Aircraft.Profile].Voltage) },
+			OnAdjust: func(d int) {
+				calibrate(cfg, func(p *AircraftIcon) { p.Voltage.Scale = stepScale(p.Voltage, d) })
+			},
+		},
+		{
+			Label: "Current scale",
+			Value: func() string { return calibrationScale(cfg.Aircraft.Profiles[cfg.Aircraft.Profile].Current) },
+			OnAdjust: func(d int) {
+				calibrate(cfg, func(p *AircraftIcon) { p.Current.Scale = stepScale(p.Current, d) })
+			},
+		},
+		{
+			Label: "Current offset",
+			Value: func() string {
+				return fmt.Sprintf("%+.1fA", cfg.Aircraft.Profiles[cfg.Aircraft.Profile].Current.Offset)
+			},
+			OnAdjust: func(d int) {
+				calibrate(cfg, func(p *AircraftIcon) {
+					p.Current.Offset = math.Round(p.Current.Offset*10+float64(d)) / 10
+				})
+			},
+		},
+		{
+			Label: "Home line",
+			Value: func() string { return onOff(cfg.Map.HomeLine) },
+			OnAdjust: func(int) {
+				cfg.Map.HomeLine = !cfg.Map.HomeLine
+			},
+		},
+		{
+			Label: "2.5D flight path",
+			Value: func() string {
+				if !cfg.Map.Path3D {
+					return "off"
+				}
+				return fmt.Sprintf("x%g", cfg.Map.Path3DScale)
+			},
+			OnAdjust: func(d int) {
+				// Off, then growing vertical exaggeration
+				steps := []string{"off", "1", "2", "5", "10"}
+				cur := "off"
+				if cfg.Map.Path3D {
+					cur = fmt.Sprint(cfg.Map.Path3DScale)
+				}
+				next := cycle(steps, cur, d)
+				cfg.Map.Path3D = next != "off"
+				if cfg.Map.Path3D {
+					cfg.Map.Path3DScale, _ = strconv.ParseFloat(next, 64)
+				}
+			},
+		},
+		{
+			Label: "Coordinate grid",
+			Value: func() string { return cfg.Map.Grid },
+			OnAdjust: func(d int) {
+				cfg.Map.Grid = cycle(gridModes, cfg.Map.Grid, d)
+			},
+		},
+		{
+			Label: "Distance marks",
+			Value: func() string {
+				if cfg.Map.DistanceMarks <= 0 {
+					return "off"
+				}
+				return cfg.Display.Units.FormatDistance(cfg.Map.DistanceMarks)
+			},
+			OnAdjust: func(d int) {
+				steps := []string{"0", "500", "1000", "2000", "5000"}
+				next := cycle(steps, fmt.Sprint(cfg.Map.DistanceMarks), d)
+				cfg.Map.DistanceMarks, _ = strconv.ParseFloat(next, 64)
+			},
+		},
+		{
+			Label: "Trail on new flight",
+			Value: func() string { return cfg.Map.ClearOnArm },
+			OnAdjust: func(d int) {
+				cfg.Map.ClearOnArm = cycle(clearOnArmModes, cfg.Map.ClearOnArm, d)
+			},
+		},
+		{
+			Label: "Distance from",
+			Value: func() string { return cfg.Map.DistanceFrom },
+			OnAdjust: func(d int) {
+				cfg.Map.DistanceFrom = cycle([]string{"home", "pilot"}, cfg.Map.DistanceFrom, d)
+			},
+		},
+		{
+			Label: "Heading",
+			Value: func() string {
+				if cfg.Heading.Source == "mag" {
+					return "magnetometer"
+				}
+				return "GPS course"
+			},
+			OnAdjust: func(d int) {
+				cfg.Heading.Source = cycle([]string{"gps", "mag"}, cfg.Heading.Source, d)
+			},
+		},
+		{
+			Label: "Altitude",
+			Value: func() string {
+				if s := a.client.GetState(); cfg.Altitude.Source == "auto" && s.RefSource != "" {
+					return "auto (" + s.RefSource + ")"
+				}
+				return cfg.Altitude.Source
+			},
+			OnAdjust: func(d int) {
+				cfg.Altitude.Source = cycle(AltitudeSources, cfg.Altitude.Source, d)
+			},
+		},
+		{
+			Label:    "Zero altitude",
+			OnSelect: a.zeroAltitude,
+		},
+		{
+			Label: "Declination",
+			Value: func() string {
+				decl, auto := a.heading.Declination()
+				s := fmt.Sprintf("%.1f°", decl)
+				if auto {
+					s += " (WMM)"
+				}
+				return s
+			},
+			// The set value is used when there is no model to compute it
+			OnAdjust: func(d int) {
+				cfg.Heading.Declination = math.Max(-90, math.Min(90, cfg.Heading.Declination+0.5*float64(d)))
+			},
+		},
+		{
+			Label: "Overlays",
+			Value: func() string {
+				if len(a.overlays) == 0 {
+					return "None loaded"
+				}
+				return onOff(cfg.Map.ShowOverlays)
+			},
+			OnAdjust: func(int) {
+				cfg.Map.ShowOverlays = !cfg.Map.ShowOverlays
+			},
+		},
+		{
+			Label: "Compare flight",
+			Value: func() string {
+				if cfg.Map.CompareTrack == "" {
+					return "Off"
+				}
+				return strings.TrimSuffix(strings.TrimSuffix(filepath.Base(cfg.Map.CompareTrack), ".gz"), ".csv")
+			},
+			OnAdjust: func(d int) {
+				logs := append([]string{""}, recentLogs(cfg.Record.Dir, 10)...)
+				cfg.Map.CompareTrack = cycle(logs, cfg.Map.CompareTrack, d)
+				a.loadCompareTrack()
+			},
+		},
+		{
+			Label: "Follow offset",
+			Value: func() string {
+				if cfg.Map.FollowOffset <= 0 {
+					return "centered"
+				}
+				return fmt.Sprintf("%.0f%%", cfg.Map.FollowOffset*100)
+			},
+			OnAdjust: func(d int) {
+				steps := []string{"0", "0.2", "0.33", "0.5", "0.67"}
+				next := cycle(steps, fmt.Sprint(cfg.Map.FollowOffset), d)
+				cfg.Map.FollowOffset, _ = strconv.ParseFloat(next, 64)
+			},
+		},
+		{
+			Label: "Follow look-ahead",
+			Value: func() string {
+				if cfg.Map.FollowAhead <= 0 {
+					return "off"
+				}
+				return cfg.Display.Units.FormatDistance(cfg.Map.FollowAhead)
+			},
+			OnAdjust: func(d int) {
+				steps := []string{"0", "100", "250", "500", "1000"}
+				next := cycle(steps, fmt.Sprint(cfg.Map.FollowAhead), d)
+				cfg.Map.FollowAhead, _ = strconv.ParseFloat(next, 64)
+			},
+		},
+		{
+			Label: "Follow on start",
+			Value: func() string { return onOff(cfg.Map.FollowOnStart) },
+			OnAdjust: func(int) {
+				cfg.Map.FollowOnStart = !cfg.Map.FollowOnStart
+			},
+		},
+		{
+			Label: "Battery warning",
+			Value: func() string { return fmt.Sprintf("%d%%", cfg.Alerts.BatteryLowPct) },
+			OnAdjust: func(d int) {
+				cfg.Alerts.BatteryLowPct = clampInt(cfg.Alerts.BatteryLowPct+5*d, 0, 100)
+				changed()
+			},
+		},
+		{
+			Label: "Battery preset",
+			Value: func() string { return batteryPresets[findBatteryPreset(cfg.Battery.Preset)].Label },
+			OnAdjust: func(d int) {
+				ApplyBatteryPreset(&cfg.Battery, cycle(batteryPresetNames(), cfg.Battery.Preset, d))
+				changed()
+			},
+		},
+		{
+			Label: "Sag compensation",
+			Value: func() string { return onOff(cfg.Battery.SagCompensation) },
+			OnAdjust: func(int) {
+				cfg.Battery.SagCompensation = !cfg.Battery.SagCompensation
+				changed()
+			},
+		},
+		{
+			Label: "Pack resistance",
+			Value: func() string {
+				if cfg.Battery.ResistanceMohm <= 0 {
+					return "chemistry"
+				}
+				return fmt.Sprintf("%.0f mOhm", cfg.Battery.ResistanceMohm)
+			},
+			OnAdjust: func(d int) {
+				cfg.Battery.ResistanceMohm = float64(clampInt(int(cfg.Battery.ResistanceMohm)+5*d, 0, 200))
+				changed()
+			},
+		},
+		{
+			Label: "LQ warning",
+			Value: func() string { return fmt.Sprintf("%d%%", cfg.Alerts.LQLowPct) },
+			OnAdjust: func(d int) {
+				cfg.Alerts.LQLowPct = clampInt(cfg.Alerts.LQLowPct+5*d, 0, 100)
+				changed()
+			},
+		},
+		{
+			Label: "Min satellites",
+			Value: func() string { return fmt.Sprintf("%d", cfg.Alerts.MinSats) },
+			OnAdjust: func(d int) {
+				cfg.Alerts.MinSats = clampInt(cfg.Alerts.MinSats+d, 0, 30)
+				changed()
+			},
+		},
+		{
+			Label: "Legal limits",
+			Value: func() string { return limitPresets[findLimitPreset(cfg.Alerts.Limits)].Label },
+			OnAdjust: func(d int) {
+				ApplyLimitPreset(&cfg.Alerts, cycle(limitPresetNames(), cfg.Alerts.Limits, d))
+				changed()
+			},
+		},
+		{
+			Label: "Max distance",
+			Value: func() string { return cfg.Display.Units.FormatDistance(cfg.Alerts.MaxDistance) },
+			OnAdjust: func(d int) {
+				cfg.Alerts.MaxDistance = float64(clampInt(int(cfg.Alerts.MaxDistance)+500*d, 500, 100000))
+				cfg.Alerts.Limits = "custom"
+				changed()
+			},
+		},
+		{
+			Label: "Max altitude",
+			Value: func() string {
+				if cfg.Alerts.MaxAltitude <= 0 {
+					return "Off"
+				}
+				return fmt.Sprintf("%.0f%s", cfg.Display.Units.Altitude(cfg.Alerts.MaxAltitude), cfg.Display.Units.AltitudeLabel())
+			},
+			OnAdjust: func(d int) {
+				cfg.Alerts.MaxAltitude = float64(clampInt(int(cfg.Alerts.MaxAltitude)+10*d, 0, 5000))
+				cfg.Alerts.Limits = "custom"
+				changed()
+			},
+		},
+		{
+			Label: "Max latency",
+			Value: func() string {
+				if cfg.Alerts.MaxLatencyMs <= 0 {
+					return "Off"
+				}
+				return fmt.Sprintf("%d ms", cfg.Alerts.MaxLatencyMs)
+			},
+			OnAdjust: func(d int) {
+				cfg.Alerts.MaxLatencyMs = clampInt(cfg.Alerts.MaxLatencyMs+50*d, 0, 5000)
+				changed()
+			},
+		},
+		{
+			Label: "Share position",
+			Value: func() string { return onOff(cfg.Buddy.Enabled) },
+			OnAdjust: func(int) {
+				cfg.Buddy.Enabled = !cfg.Buddy.Enabled
+				if !cfg.Buddy.Enabled {
+					a.buddyShare.Stop()
+				} else if err := a.buddyShare.Start(); err != nil {
+					logBuddy.Errorf("Could not start position sharing: %v", err)
+				}
+			},
+		},
+		{
+			Label: "Rally points",
+			Value: func() string { return fmt.Sprintf("%d", len(cfg.Rally)) },
+		},
+		{
+			Label:    "Add rally point at map center",
+			OnSelect: a.addRallyHere,
+		},
+		{
+			Label: "Clear rally points",
+			OnSelect: func() {
+				cfg.Rally = nil
+			},
+		},
+		{
+			Label: "Map data used",
+			Value: func() string {
+				bytes, _ := a.tileManager.DataUsed()
+				return formatBytes(bytes)
+			},
+		},
+		{
+			Label: "Conditions...",
+			OnSelect: func() {
+				m.Close()
+				a.weatherMenu.Open()
+			},
+		},
+		{
+			Label: "Connection...",
+			OnSelect: func() {
+				m.Close()
+				a.openDeviceMenu()
+			},
+		},
+		{
+			Label: "Logbook...",
+			OnSelect: func() {
+				m.Close()
+				a.logbookMenu.Open()
+			},
+		},
+		{
+			Label: "Antenna tracker...",
+			OnSelect: func() {
+				m.Close()
+				a.trackerMenu.Open()
+			},
+		},
+		{
+			Label: "Backend...",
+			OnSelect: func() {
+				m.Close()
+				a.discoverBackends(false)
+				a.backendMenu.Open()
+			},
+		},
+		{
+			Label: "Setup wizard...",
+			OnSelect: func() {
+				m.Close()
+				a.openWizard()
+			},
+		},
+		{
+			Label: "Serial port...",
+			OnSelect: func() {
+				m.Close()
+				a.portMenu.Open()
+			},
+		},
+		{Label: "Save & close", OnSelect: m.Close},
+	}
+	return m
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// calibrate changes the battery sensor calibration of the profile in use
+func calibrate(cfg *Config, f func(p *AircraftIcon)) {
+	p, ok := cfg.Aircraft.Profiles[cfg.Aircraft.Profile]
+	if !ok {
+		return
+	}
+	f(&p)
+	cfg.Aircraft.Profiles[cfg.Aircraft.Profile] = p
+}
+
+// stepScale moves a calibration scale by 1%
+func stepScale(c SensorCalibration, d int) float64 {
+	return math.Max(0.5, math.Min(2, math.Round(c.scale()*100+float64(d))/100))
+}
+
+func calibrationScale(c SensorCalibration) string {
+	return fmt.Sprintf("x%.2f", c.scale())
+}
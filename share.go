@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// shareStale is how long a relayed flight goes without an update before
+	// its viewers are told the station is gone
+	shareStale = 15 * time.Second
+	// shareExpiry is how long a relayed flight is kept after its last update
+	shareExpiry = time.Hour
+	// shareMaxFlights caps the flights one relay holds
+	shareMaxFlights = 100
+	// shareMaxPush caps a pushed message
+	shareMaxPush = 16 << 10
+)
+
+// relayedFlight is the last update a station pushed to this relay
+type relayedFlight struct {
+	telemetry TelemetryJSON
+	at        time.Time
+}
+
+// SharePublisher pushes the telemetry to a relay, another elrs-map's web
+// server on the internet, so anyone with the flight's link can watch it
+// live on a browser map. The link is the relay's /share/<id>/ page.
+type SharePublisher struct {
+	web    *WebServer
+	config *ShareConfig
+	client *http.Client
+	id     string
+
+	failing  bool // Log repeated failures quietly
+	running  bool
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewSharePublisher creates a publisher pushing what web's map shows
+func NewSharePublisher(web *WebServer, cfg *ShareConfig) *SharePublisher {
+	id := cfg.ID
+	if id == "" {
+		// A new, unguessable link each session
+		b := make([]byte, 6)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+	return &SharePublisher{
+		web:    web,
+		config: cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		id:     id,
+	}
+}
+
+// URL returns the link to hand out
+func (s *SharePublisher) URL() string {
+	return strings.TrimSuffix(s.config.Relay, "/") + "/share/" + s.id + "/"
+}
+
+// Start pushes updates in the background until Stop
+func (s *SharePublisher) Start() {
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stopChan = make(chan struct{})
+	s.wg.Add(1)
+	go s.run()
+	logWeb.Infof("Sharing the flight live at %s", s.URL())
+}
+
+// Stop ends the pushes and waits for the last one
+func (s *SharePublisher) Stop() {
+	if !s.running {
+		return
+	}
+	s.running = false
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+// IsRunning returns true while sharing
+func (s *SharePublisher) IsRunning() bool {
+	return s.running
+}
+
+func (s *SharePublisher) run() {
+	defer s.wg.Done()
+	interval := s.config.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+		}
+		// Pushed even when nothing changed, which tells the relay the
+		// station is still there
+		err := s.push()
+		if err != nil && !s.failing {
+			logWeb.Warnf("Live share relay %s: %v", s.config.Relay, err)
+		} else if err == nil && s.failing {
+			logWeb.Infof("Live share relay %s is back", s.config.Relay)
+		}
+		s.failing = err != nil
+	}
+}
+
+func (s *SharePublisher) push() error {
+	body, err := json.Marshal(s.web.snapshot())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(s.config.Relay, "/")+"/share/"+s.id, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.config.Token)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// relayEnabled tells whether this server takes pushes from other stations
+func (w *WebServer) relayEnabled() bool {
+	return w.config.Web.RelayToken != ""
+}
+
+// handleSharePush stores a station's update for its viewers
+func (w *WebServer) handleSharePush(rw http.ResponseWriter, r *http.Request) {
+	if !w.relayEnabled() {
+		http.NotFound(rw, r)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(w.config.Web.RelayToken)) != 1 {
+		http.Error(rw, "bad token", http.StatusUnauthorized)
+		return
+	}
+	var t TelemetryJSON
+	if err := json.NewDecoder(io.LimitReader(r.Body, shareMaxPush)).Decode(&t); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := r.PathValue("id")
+	w.relayMu.Lock()
+	defer w.relayMu.Unlock()
+	if _, ok := w.relay[id]; !ok {
+		for other, f := range w.relay {
+			if time.Since(f.at) > shareExpiry {
+				delete(w.relay, other)
+			}
+		}
+		if len(w.relay) >= shareMaxFlights {
+			http.Error(rw, "relay full", http.StatusServiceUnavailable)
+			return
+		}
+		logWeb.Infof("Relaying flight %s from %s", id, r.RemoteAddr)
+	}
+	w.relay[id] = relayedFlight{telemetry: t, at: time.Now()}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// relayed returns a flight's last update as its viewers should see it
+func (w *WebServer) relayed(id string) (TelemetryJSON, bool) {
+	w.relayMu.Lock()
+	f, ok := w.relay[id]
+	w.relayMu.Unlock()
+	if !ok || time.Since(f.at) > shareExpiry {
+		return TelemetryJSON{}, false
+	}
+	t := f.telemetry
+	if time.Since(f.at) > shareStale {
+		t.Stale = true
+		t.Receiving = false
+	}
+	return t, true
+}
+
+// handleSharePage serves the map page for a relayed flight; the page finds
+// its feed relative to its own address
+func (w *WebServer) handleSharePage(rw http.ResponseWriter, r *http.Request) {
+	if !w.relayEnabled() {
+		http.NotFound(rw, r)
+		return
+	}
+	if _, ok := w.relayed(r.PathValue("id")); !ok {
+		http.Error(rw, "No such flight, or it has ended", http.StatusNotFound)
+		return
+	}
+	page, err := fs.ReadFile(webFiles, "web/index.html")
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.Write(page)
+}
+
+// handleShareTelemetry serves a relayed flight's last update
+func (w *WebServer) handleShareTelemetry(rw http.ResponseWriter, r *http.Request) {
+	t, ok := w.relayed(r.PathValue("id"))
+	if !w.relayEnabled() || !ok {
+		http.NotFound(rw, r)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(t)
+}
+
+// handleShareWebSocket pushes a relayed flight's updates to a viewer
+func (w *WebServer) handleShareWebSocket(rw http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := w.relayed(id); !w.relayEnabled() || !ok {
+		http.NotFound(rw, r)
+		return
+	}
+	w.serveWebSocket(rw, r, func() (TelemetryJSON, bool) { return w.relayed(id) })
+}
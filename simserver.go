@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	pb "elrs-map/proto"
+
+	"google.golang.org/grpc"
+)
+
+// SimServer serves simulated telemetry over the backend's gRPC API, so any
+// ground station (or several at once) can connect to it like a real TX
+type SimServer struct {
+	pb.UnimplementedJoystickControlServer
+
+	sim *Simulator
+
+	mu          sync.Mutex
+	frames      []*pb.Telemetry
+	seq         uint64
+	linkStarted bool
+}
+
+// NewSimServer creates a server around a simulator
+func NewSimServer(sim *Simulator) *SimServer {
+	return &SimServer{sim: sim}
+}
+
+// run steps the simulator; all streams share the same flight
+func (s *SimServer) run(ctx context.Context) {
+	ticker := time.NewTicker(simTickRate)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			frames := s.sim.Step(now)
+			s.mu.Lock()
+			s.frames = frames
+			s.seq++
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *SimServer) GetAppInfo(ctx context.Context, _ *pb.Empty) (*pb.GetAppInfoRes, error) {
+	return &pb.GetAppInfoRes{Version: "elrs-map simulator"}, nil
+}
+
+func (s *SimServer) GetCRSFDevices(ctx context.Context, _ *pb.Empty) (*pb.GetCRSFDevicesRes, error) {
+	res := &pb.GetCRSFDevicesRes{}
+	for _, d := range simDevices() {
+		res.Devices = append(res.Devices, &pb.CRSFDeviceInfoData{DeviceId: d.ID, Name: d.Name})
+	}
+	return res, nil
+}
+
+func (s *SimServer) GetCRSFDeviceFields(ctx context.Context, req *pb.GetCRSFDeviceFieldsReq) (*pb.GetCRSFDeviceFieldsRes, error) {
+	res := &pb.GetCRSFDeviceFieldsRes{}
+	for _, d := range simDevices() {
+		if d.ID != req.DeviceId {
+			continue
+		}
+		for _, p := range d.Params {
+			res.Fields = append(res.Fields, &pb.CRSFDeviceFieldData{DeviceId: d.ID, FieldId: p.ID, Data: encodeCRSFParam(p)})
+		}
+	}
+	return res, nil
+}
+
+func (s *SimServer) SetCRSFDeviceField(ctx context.Context, req *pb.SetCRSFDeviceFieldReq) (*pb.SetCRSFDeviceFieldRes, error) {
+	for _, d := range simDevices() {
+		for _, p := range d.Params {
+			if d.ID == req.DeviceId && p.ID == req.FieldId {
+				logSim.Infof("%s: %s set to %v", d.Name, p.Name, req.Data)
+				return &pb.SetCRSFDeviceFieldRes{Success: true}, nil
+			}
+		}
+	}
+	return &pb.SetCRSFDeviceFieldRes{}, nil
+}
+
+func (s *SimServer) GetTransmitters(ctx context.Context, _ *pb.Empty) (*pb.GetTransmitterRes, error) {
+	return &pb.GetTransmitterRes{Transmitters: []*pb.Transmitter{{Port: "sim"}}}, nil
+}
+
+func (s *SimServer) StartLink(ctx context.Context, req *pb.StartLinkReq) (*pb.Empty, error) {
+	s.mu.Lock()
+	s.linkStarted = true
+	s.mu.Unlock()
+	logSim.Infof("Simulated link started on %s @ %d baud", req.Port, req.BaudRate)
+	return &pb.Empty{}, nil
+}
+
+func (s *SimServer) StopLink(ctx context.Context, _ *pb.Empty) (*pb.Empty, error) {
+	s.mu.Lock()
+	s.linkStarted = false
+	s.mu.Unlock()
+	logSim.Infof("Simulated link stopped")
+	return &pb.Empty{}, nil
+}
+
+// GetTelemetryStream sends each new set of frames while the link is started
+func (s *SimServer) GetTelemetryStream(_ *pb.Empty, stream pb.JoystickControl_GetTelemetryStreamServer) error {
+	ticker := time.NewTicker(simTickRate)
+	defer ticker.Stop()
+
+	var lastSeq uint64
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		frames, seq, started := s.frames, s.seq, s.linkStarted
+		s.mu.Unlock()
+		if !started || seq == lastSeq {
+			continue
+		}
+		lastSeq = seq
+
+		for _, t := range frames {
+			if err := stream.Send(t); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// runSimServer implements the "sim" subcommand: a stand-in backend
+func runSimServer(args []string) {
+	defaults := DefaultConfig()
+	fs := flag.NewFlagSet("sim", flag.ExitOnError)
+	listen := fs.String("listen", ":10000", "gRPC listen address")
+	lat := fs.Float64("lat", defaults.Map.DefaultLat, "Home latitude")
+	lon := fs.Float64("lon", defaults.Map.DefaultLon, "Home longitude")
+	fs.Parse(args)
+
+	lis, err := net.Listen("tcp", *listen)
+	if err != nil {
+		logSim.Fatalf("Failed to listen on %s: %v", *listen, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := NewSimServer(NewSimulator(*lat, *lon))
+	go srv.run(ctx)
+
+	server := grpc.NewServer()
+	pb.RegisterJoystickControlServer(server, srv)
+
+	sigChan := make(chan os.Signal, 1)
+	// Ctrl+C everywhere; SIGTERM from systemd, or closing the console on Windows
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		logSim.Infof("Shutting down...")
+		server.Stop()
+	}()
+
+	// Let ground stations find us without -grpc
+	responder := NewMDNSResponder(backendService, "elrs-map simulator on "+localHostname(), lis.Addr().(*net.TCPAddr).Port, nil)
+	if err := responder.Start(); err != nil {
+		logSim.Warnf("Could not advertise over mDNS: %v", err)
+	}
+	defer responder.Stop()
+
+	logSim.Infof("Simulated backend listening on %s (home %.4f, %.4f)", lis.Addr(), *lat, *lon)
+	if err := server.Serve(lis); err != nil {
+		logSim.Fatalf("Server error: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	pb "elrs-map/proto"
+)
+
+// Simulated flight timeline (seconds into each cycle)
+const (
+	simIdleEnd    = 10.0  // Disarmed on the ground, GPS acquiring
+	simClimbEnd   = 25.0  // Vertical climb to cruise altitude
+	simCruiseEnd  = 285.0 // One lap of the cruise pattern
+	simLandEnd    = 300.0 // Descent back onto home
+	simCycle      = 310.0 // Landed, then the flight repeats on a fresh pack
+	simCruiseAlt  = 100.0 // Meters above home
+	simPatternA   = 900.0 // Cruise ellipse semi-axes in meters
+	simPatternB   = 400.0
+	simPatternBrg = 45.0   // Bearing of the ellipse's long axis
+	simPackMAh    = 1500.0 // 4S pack
+	simCells      = 4
+)
+
+// simTickRate is how often the simulator emits telemetry
+const simTickRate = 100 * time.Millisecond
+
+// Simulator generates realistic fake telemetry: takeoff, a cruise lap, battery
+// drain, and link quality that falls off with distance. It needs no backend
+// and is meant for UI development, demos, and testing layouts indoors.
+type Simulator struct {
+	homeLat, homeLon float64
+	start            time.Time
+	rng              *rand.Rand
+
+	// Integrated state
+	lastT     float64
+	usedMAh   float64
+	lastNorth float64
+	lastEast  float64
+	lastAlt   float64
+	heading   float64
+}
+
+// NewSimulator creates a simulator flying around the given home position
+func NewSimulator(homeLat, homeLon float64) *Simulator {
+	return &Simulator{
+		homeLat: homeLat,
+		homeLon: homeLon,
+		start:   time.Now(),
+		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// position returns the aircraft offset from home in meters at cycle time t
+func (s *Simulator) position(t float64) (north, east, alt float64) {
+	switch {
+	case t < simIdleEnd:
+		return 0, 0, 0
+	case t < simClimbEnd:
+		return 0, 0, simCruiseAlt * (t - simIdleEnd) / (simClimbEnd - simIdleEnd)
+	case t < simCruiseEnd:
+		// Ellipse whose near end sits on home, so the lap starts and ends there
+		theta := math.Pi + 2*math.Pi*(t-simClimbEnd)/(simCruiseEnd-simClimbEnd)
+		x := simPatternA + simPatternA*math.Cos(theta) // Along the long axis
+		y := simPatternB * math.Sin(theta)
+		brg := simPatternBrg * math.Pi / 180
+		north = x*math.Cos(brg) - y*math.Sin(brg)
+		east = x*math.Sin(brg) + y*math.Cos(brg)
+		// Gentle altitude wave so the tapes and vario move
+		alt = simCruiseAlt + 20*math.Sin(3*theta)
+		return north, east, alt
+	case t < simLandEnd:
+		return 0, 0, simCruiseAlt * (simLandEnd - t) / (simLandEnd - simCruiseEnd)
+	default:
+		return 0, 0, 0
+	}
+}
+
+// Step advances the simulation to now and returns the telemetry frames to emit
+func (s *Simulator) Step(now time.Time) []*pb.Telemetry {
+	elapsed := now.Sub(s.start).Seconds()
+	t := math.Mod(elapsed, simCycle)
+	dt := t - s.lastT
+	if dt < 0 {
+		// New cycle on a fresh pack
+		dt = t
+		s.usedMAh = 0
+	}
+	if dt <= 0 {
+		dt = simTickRate.Seconds()
+	}
+	s.lastT = t
+
+	north, east, alt := s.position(t)
+	dn, de, da := north-s.lastNorth, east-s.lastEast, alt-s.lastAlt
+	s.lastNorth, s.lastEast, s.lastAlt = north, east, alt
+
+	horiz := math.Hypot(dn, de)
+	speed := horiz / dt // m/s
+	vspeed := da / dt
+	if horiz > 0.01 {
+		s.heading = math.Mod(math.Atan2(de, dn)*180/math.Pi+360, 360)
+	}
+
+	armed := t >= simIdleEnd && t < simLandEnd
+	flying := t >= simClimbEnd && t < simCruiseEnd
+
+	// Current draw by phase, plus noise
+	current := 0.4
+	switch {
+	case t >= simIdleEnd && t < simClimbEnd:
+		current = 28
+	case flying:
+		current = 14 + 4*math.Abs(math.Sin(t/7))
+	case armed:
+		current = 9
+	}
+	current += s.rng.NormFloat64() * 0.5
+	if current < 0 {
+		current = 0
+	}
+	s.usedMAh += current * dt / 3.6
+	remaining := math.Max(0, 100-s.usedMAh/simPackMAh*100)
+	cellV := 3.5 + 0.7*remaining/100 - current*0.004 // Resting curve minus sag
+	voltage := cellV * simCells
+
+	// Link: solid close in, fading past 800 m
+	dist := math.Hypot(north, east)
+	lq := 100.0
+	if dist > 800 {
+		lq -= (dist - 800) / 1200 * 60
+	}
+	lq = math.Max(0, math.Min(100, lq+s.rng.NormFloat64()*2))
+	rssi := -40 - 20*math.Log10(math.Max(dist, 10)/10) + s.rng.NormFloat64()*1.5
+	snr := 12 - dist/150 + s.rng.NormFloat64()
+
+	// Bank into the turn (tan(roll) = v * omega / g) and pitch along the climb
+	roll, pitch := 0.0, 0.0
+	if flying {
+		omega := 2 * math.Pi / (simCruiseEnd - simClimbEnd)
+		roll = math.Atan(speed*omega/9.81) * 180 / math.Pi
+		pitch = math.Atan2(vspeed, speed) * 180 / math.Pi
+	}
+
+	sats := uint32(0)
+	if elapsed > 5 {
+		sats = uint32(13 + s.rng.Intn(3))
+	}
+
+	mode := "OK"
+	switch {
+	case t >= simCruiseEnd && armed:
+		mode = "RTH"
+	case armed:
+		mode = "ANGL"
+	}
+
+	// Meters to degrees around home
+	lat := s.homeLat + north/111320
+	lon := s.homeLon + east/(111320*math.Cos(s.homeLat*math.Pi/180))
+
+	frames := []*pb.Telemetry{
+		{Data: &pb.Telemetry_LinkStats{LinkStats: &pb.LinkStatsData{
+			Rssi1:       int32(rssi),
+			Rssi2:       int32(rssi - 3 + s.rng.NormFloat64()),
+			LinkQuality: uint32(lq),
+			Snr:         int32(snr),
+			TxPower:     100,
+		}}},
+		{Data: &pb.Telemetry_Attitude{Attitude: &pb.AttitudeData{
+			Pitch: float32(pitch),
+			Roll:  float32(roll),
+			Yaw:   float32(s.heading),
+		}}},
+		{Data: &pb.Telemetry_Battery{Battery: &pb.BatteryData{
+			Voltage:   float32(voltage),
+			Current:   float32(current),
+			Capacity:  uint32(s.usedMAh),
+			Remaining: uint32(remaining),
+		}}},
+		{Data: &pb.Telemetry_BarometerVariometer{BarometerVariometer: &pb.BarometerVariometerData{
+			Altitude:      float32(alt),
+			VerticalSpeed: float32(vspeed),
+		}}},
+		{Data: &pb.Telemetry_FlightMode{FlightMode: &pb.FlightModeData{Mode: mode}}},
+	}
+	if sats > 0 {
+		frames = append(frames, &pb.Telemetry{Data: &pb.Telemetry_Gps{Gps: &pb.GPSData{
+			Latitude:    float32(lat),
+			Longitude:   float32(lon),
+			GroundSpeed: float32(speed * 3.6),
+			Heading:     float32(s.heading),
+			Altitude:    int32(alt),
+			Satellites:  sats,
+		}}})
+	}
+	return frames
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pb "elrs-map/proto"
+)
+
+// errNoCommands is returned for link and device requests to a source that
+// only delivers telemetry, such as a replayed log
+var errNoCommands = errors.New("this telemetry source takes no commands")
+
+// TelemetrySource is where the client's telemetry comes from: the
+// elrs-joystick-control backend, the simulator, or anything else that can
+// produce CRSF-style telemetry frames. The client keeps the state, the
+// stall watchdog and the corrections on top, so a new kind of source only
+// needs to register in telemetrySources.
+type TelemetrySource interface {
+	// Connect reaches the source and tells what it can do
+	Connect() (BackendCaps, error)
+	// Close drops the connection; Connect may be called again after
+	Close()
+	// Reachable returns false once a connected source stops answering
+	Reachable() bool
+	// Stream delivers telemetry frames to frame until ctx is done or the
+	// stream fails. A source without telemetry returns errBackendTooOld.
+	Stream(ctx context.Context, frame func(*pb.Telemetry)) error
+	// Commands returns what takes link and device commands, nil for none
+	Commands() LinkCommands
+}
+
+// LinkCommands controls the TX module behind a source
+type LinkCommands interface {
+	Transmitters() ([]string, error)
+	AppInfo() (string, error)
+	StartLink(port string, baudRate int32) error
+	StopLink() error
+	Devices() ([]CRSFDevice, error)
+	RunCommand(deviceID, fieldID uint32) error
+	SetParam(deviceID, fieldID uint32, value byte) error
+}
+
+// linkedSource is a source whose link is up as soon as it streams, with no
+// TX module to start, such as the simulator
+type linkedSource interface {
+	LinkUp() bool
+}
+
+// telemetrySources creates each kind of source from backend.source (or
+// -source) and the address it is at
+var telemetrySources = map[string]func(addr string, cfg *Config) TelemetrySource{
+	"grpc": func(addr string, _ *Config) TelemetrySource {
+		return newGRPCSource(addr)
+	},
+	"sim": func(_ string, cfg *Config) TelemetrySource {
+		return newSimSource(NewSimulator(cfg.Map.DefaultLat, cfg.Map.DefaultLon))
+	},
+	"web": func(addr string, _ *Config) TelemetrySource {
+		return newWebSource(addr)
+	},
+}
+
+// SourceNames lists the telemetry sources, for -source and backend.source
+func SourceNames() []string {
+	names := make([]string, 0, len(telemetrySources))
+	for name := range telemetrySources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewClient creates a client fed by the named telemetry source at addr
+func NewClient(source, addr string, cfg *Config) (*GRPCClient, error) {
+	create, ok := telemetrySources[source]
+	if !ok {
+		return nil, fmt.Errorf("unknown telemetry source %q (%s)", source, strings.Join(SourceNames(), ", "))
+	}
+	c := newClient(addr, create(addr, cfg))
+	c.newSource = func(addr string) TelemetrySource { return create(addr, cfg) }
+	return c, nil
+}
+
+// simSource feeds the simulator's frames through the normal telemetry path
+type simSource struct {
+	sim *Simulator
+
+	mu     sync.Mutex
+	linked bool // Off, the aircraft keeps flying but isn't heard
+}
+
+func newSimSource(sim *Simulator) *simSource {
+	return &simSource{sim: sim, linked: true}
+}
+
+func (s *simSource) Connect() (BackendCaps, error) {
+	logTelem.Infof("Using simulated telemetry")
+	return allCaps, nil
+}
+
+func (s *simSource) Close() {}
+
+func (s *simSource) Reachable() bool { return true }
+
+func (s *simSource) LinkUp() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.linked
+}
+
+func (s *simSource) Stream(ctx context.Context, frame func(*pb.Telemetry)) error {
+	ticker := time.NewTicker(simTickRate)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			frames := s.sim.Step(now)
+			if !s.LinkUp() {
+				continue
+			}
+			for _, t := range frames {
+				frame(t)
+			}
+		}
+	}
+}
+
+func (s *simSource) Commands() LinkCommands { return s }
+
+func (s *simSource) Transmitters() ([]string, error) { return []string{"sim"}, nil }
+
+func (s *simSource) AppInfo() (string, error) { return "simulator", nil }
+
+func (s *simSource) StartLink(string, int32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.linked = true
+	return nil
+}
+
+func (s *simSource) StopLink() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.linked = false
+	return nil
+}
+
+func (s *simSource) Devices() ([]CRSFDevice, error) { return simDevices(), nil }
+
+func (s *simSource) RunCommand(deviceID, fieldID uint32) error {
+	logTelem.Infof("Simulated command %d on device 0x%02X", fieldID, deviceID)
+	return nil
+}
+
+func (s *simSource) SetParam(deviceID, fieldID uint32, value byte) error {
+	logTelem.Infof("Simulated parameter %d on device 0x%02X set to %d", fieldID, deviceID, value)
+	return nil
+}
+
+// replaySource streams nothing; a recorded flight is fed to the client
+// through Feed instead
+type replaySource struct{}
+
+func (replaySource) Connect() (BackendCaps, error) { return BackendCaps{}, nil }
+
+func (replaySource) Close() {}
+
+func (replaySource) Reachable() bool { return true }
+
+func (replaySource) Stream(ctx context.Context, _ func(*pb.Telemetry)) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (replaySource) Commands() LinkCommands { return nil }
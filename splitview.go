@@ -0,0 +1,66 @@
+//go:build !headless
+
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// splitArea returns the map's left edge and width in the split layout; the
+// cockpit takes the rest, on the panel side
+func (a *App) splitArea() (int, int) {
+	ratio := a.config.Display.SplitRatio
+	if ratio < 0.3 || ratio > 0.7 {
+		ratio = 0.5
+	}
+	mapW := int(math.Round(float64(a.width) * ratio))
+	if a.panel.RightSide {
+		return 0, mapW
+	}
+	return a.width - mapW, mapW
+}
+
+// drawMiniAttitude draws a small horizon and compass under the minimal
+// status box, so attitude is still in view with the map alone
+func (a *App) drawMiniAttitude(screen *ebiten.Image, state TelemetryState) {
+	if !a.config.Display.MiniAttitude {
+		return
+	}
+	// Drawn to an image of its own, which clips the horizon to the box
+	if a.miniPane == nil {
+		a.miniPane = ebiten.NewImage(200, 100)
+	}
+	a.cockpitHUD.DrawMini(a.miniPane, state)
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(5, 45)
+	screen.DrawImage(a.miniPane, op)
+}
+
+// drawCockpitPane draws the full cockpit instruments beside the map. They
+// lay out from their image's origin, so they are drawn to their own image
+// and placed from there.
+func (a *App) drawCockpitPane(screen *ebiten.Image, state TelemetryState, homeDist, homeBearing float64) {
+	mapX, mapW := a.splitArea()
+	paneX, paneW := 0, a.width-mapW
+	if mapX == 0 {
+		paneX = mapW
+	}
+	paneH := a.height - 24 // Above the status bar
+	if paneW <= 0 || paneH <= 0 {
+		return
+	}
+
+	if a.cockpitPane == nil || a.cockpitPane.Bounds().Dx() != paneW || a.cockpitPane.Bounds().Dy() != paneH {
+		if a.cockpitPane != nil {
+			a.cockpitPane.Dispose()
+		}
+		a.cockpitPane = ebiten.NewImage(paneW, paneH)
+	}
+	a.cockpitHUD.DrawPane(a.cockpitPane, state, a.homeSet, homeDist, homeBearing)
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(float64(paneX), 0)
+	screen.DrawImage(a.cockpitPane, op)
+}
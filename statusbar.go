@@ -0,0 +1,170 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	statusBarHeight = 24
+	statusCharW     = 6 // Debug font advance
+	statusDotW      = 11
+)
+
+// statusSegment is one field of the status bar, with a colored dot in front
+// of it when dot is set
+type statusSegment struct {
+	text, short string
+	dot         color.RGBA
+}
+
+func (s statusSegment) label(compact bool) string {
+	if compact {
+		return s.short
+	}
+	return s.text
+}
+
+func (s statusSegment) width(compact bool) int {
+	w := len(s.label(compact)) * statusCharW
+	if s.dot.A > 0 {
+		w += statusDotW
+	}
+	return w
+}
+
+var (
+	statusGood = color.RGBA{100, 255, 100, 255}
+	statusBad  = color.RGBA{255, 100, 100, 255}
+	statusOff  = color.RGBA{120, 120, 120, 255}
+)
+
+// statusSegment builds a field of the status bar; ok is false for a field
+// name it doesn't know
+func (a *App) statusSegment(field string) (statusSegment, bool) {
+	switch field {
+	case "conn":
+		if a.client.IsConnected() {
+			// With backups, which one feeds the telemetry
+			switch role := a.failover.Role(); {
+			case role == "":
+			case strings.HasPrefix(role, "backup"):
+				return statusSegment{"Connected (" + role + ")", "B" + strings.TrimPrefix(role, "backup "), statusGood}, true
+			default:
+				return statusSegment{"Connected (" + role + ")", "", statusGood}, true
+			}
+			return statusSegment{"Connected", "", statusGood}, true
+		}
+		return statusSegment{"Disconnected", "", statusBad}, true
+	case "link":
+		if !a.client.IsLinkStarted() {
+			return statusSegment{"Link", "L", statusOff}, true
+		}
+		// Green while telemetry flows, red once it stops
+		state := a.client.GetState()
+		if state.LastUpdate.IsZero() || time.Since(state.LastUpdate) > telemetryTimeout {
+			return statusSegment{"Link", "L", statusBad}, true
+		}
+		return statusSegment{"Link", "L", statusGood}, true
+	case "lq":
+		state := a.client.GetState()
+		if state.LastUpdate.IsZero() || time.Since(state.LastUpdate) > telemetryTimeout {
+			return statusSegment{"LQ --", "--", statusOff}, true
+		}
+		lq := fmt.Sprintf("%d%%", state.LinkQuality)
+		return statusSegment{"LQ " + lq, lq, lqColor(uint8(min(state.LinkQuality, 100)))}, true
+	case "port":
+		if len(a.ports) == 0 || a.selectedPort >= len(a.ports) {
+			return statusSegment{text: "No ports", short: "-"}, true
+		}
+		port := a.ports[a.selectedPort]
+		return statusSegment{text: fmt.Sprintf("Port: %s@%d", port, a.baudRate), short: filepath.Base(port)}, true
+	case "zoom":
+		return statusSegment{text: fmt.Sprintf("Zoom: %d", a.zoom), short: fmt.Sprintf("Z%d", a.zoom)}, true
+	case "follow":
+		if a.followAircraft {
+			return statusSegment{text: "Follow", short: "FOL"}, true
+		}
+		return statusSegment{text: "Manual", short: "MAN"}, true
+	case "map":
+		name := a.tileManager.SourceName()
+		return statusSegment{text: name, short: strings.ToUpper(name[:min(3, len(name))])}, true
+	case "hud":
+		hud := hudNames[a.hudMode%hudModes]
+		return statusSegment{text: "HUD:" + hud, short: hud}, true
+	case "clock":
+		now := time.Now()
+		return statusSegment{text: now.Format("15:04:05"), short: now.Format("15:04")}, true
+	case "elev":
+		units := a.config.Display.Units
+		if elev, ok := a.cursorElevation(); ok {
+			e := fmt.Sprintf("%.0f%s", units.Altitude(elev), units.AltitudeLabel())
+			return statusSegment{text: "Elev " + e, short: e}, true
+		}
+		return statusSegment{text: "Elev --", short: "--"}, true
+	case "help":
+		return statusSegment{text: "F1=Help", short: "F1"}, true
+	}
+	return statusSegment{}, false
+}
+
+// drawStatusBar draws the configured fields along the bottom of the screen,
+// falling back to the compact labels when the full ones don't fit and
+// dropping fields from the end when even those don't. Unknown field names
+// are skipped.
+func (a *App) drawStatusBar(screen *ebiten.Image) {
+	barY := a.height - statusBarHeight
+	vector.DrawFilledRect(screen, 0, float32(barY), float32(a.width), statusBarHeight, color.RGBA{0, 0, 0, 200}, false)
+
+	var segs []statusSegment
+	for _, f := range a.config.Display.StatusBar.Fields {
+		if seg, ok := a.statusSegment(f); ok {
+			segs = append(segs, seg)
+		}
+	}
+
+	compact := a.config.Display.StatusBar.Compact
+	sep := " | "
+	if !compact {
+		w := 5
+		for _, seg := range segs {
+			w += seg.width(false) + len(sep)*statusCharW
+		}
+		compact = w > a.width
+	}
+	if compact {
+		sep = "  "
+	}
+
+	x := 5
+	textY := barY + 5
+	for i, seg := range segs {
+		sepW := 0
+		if i > 0 {
+			sepW = len(sep) * statusCharW
+		}
+		if x+sepW+seg.width(compact) > a.width {
+			break
+		}
+		if i > 0 {
+			ebitenutil.DebugPrintAt(screen, sep, x, textY)
+			x += sepW
+		}
+		if seg.dot.A > 0 {
+			vector.DrawFilledCircle(screen, float32(x+4), float32(barY+statusBarHeight/2), 4, seg.dot, true)
+			x += statusDotW
+		}
+		label := seg.label(compact)
+		ebitenutil.DebugPrintAt(screen, label, x, textY)
+		x += len(label) * statusCharW
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// StatusLine writes a short line of the flight's state every interval, e.g.
+//
+//	DIST 1.2km | ALT 85m | BATT 15.2V 64% | LQ 98%
+//
+// to stdout or a file, FIFO or serial device, for a script, a serial LCD or
+// a chat bot to pass on. Logs go to stderr, so stdout carries only these.
+type StatusLine struct {
+	client *GRPCClient
+	config *Config
+
+	// Home returns the home position, if one is set
+	Home func() (lat, lon float64, ok bool)
+
+	out      io.Writer
+	file     *os.File // out when it is not stdout
+	failing  bool     // Log repeated failures quietly
+	running  bool
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewStatusLine creates a status line writer for client's telemetry
+func NewStatusLine(client *GRPCClient, cfg *Config) *StatusLine {
+	return &StatusLine{client: client, config: cfg}
+}
+
+// Start writes lines in the background until Stop
+func (s *StatusLine) Start() {
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stopChan = make(chan struct{})
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop ends the lines and closes the output
+func (s *StatusLine) Stop() {
+	if !s.running {
+		return
+	}
+	s.running = false
+	close(s.stopChan)
+	s.wg.Wait()
+	s.close()
+}
+
+func (s *StatusLine) run() {
+	defer s.wg.Done()
+	interval := s.config.Status.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+		}
+		s.write(s.line(s.client.GetState()) + "\n")
+	}
+}
+
+// line formats the state
+func (s *StatusLine) line(state TelemetryState) string {
+	if !state.LinkStarted || state.LastUpdate.IsZero() || time.Since(state.LastUpdate) >= telemetryTimeout {
+		return "NO TELEMETRY"
+	}
+	units := s.config.Display.Units
+	dist := "---"
+	if s.Home != nil && state.HasGPS {
+		if lat, lon, ok := s.Home(); ok {
+			dist = units.FormatDistance(DistanceMeters(lat, lon, float64(state.Latitude), float64(state.Longitude)))
+		}
+	}
+	batt := fmt.Sprintf("%.1fV", state.Voltage)
+	if charge := s.config.Battery.Charge(state); charge > 0 {
+		batt += fmt.Sprintf(" %d%%", charge)
+	}
+	return strings.Join([]string{
+		"DIST " + dist,
+		fmt.Sprintf("ALT %.0f%s", units.Altitude(relativeAltitude(state)), units.AltitudeLabel()),
+		"BATT " + batt,
+		fmt.Sprintf("LQ %d%%", state.LinkQuality),
+	}, " | ")
+}
+
+// write sends a line, opening the output first if need be. A FIFO is
+// opened without waiting for a reader; while there is none the lines are
+// dropped, and it is opened again for the next.
+func (s *StatusLine) write(line string) {
+	if s.out == nil {
+		target := s.config.Status.Output
+		if target == "" || target == "-" {
+			s.out = os.Stdout
+		} else {
+			f, err := os.OpenFile(target, os.O_WRONLY|os.O_APPEND|os.O_CREATE|syscall.O_NONBLOCK, 0644)
+			if err != nil {
+				s.fail(err)
+				return
+			}
+			s.out, s.file = f, f
+		}
+	}
+	if _, err := io.WriteString(s.out, line); err != nil {
+		s.fail(err)
+		s.close()
+		return
+	}
+	if s.failing {
+		logApp.Infof("Status line output %s is back", s.config.Status.Output)
+		s.failing = false
+	}
+}
+
+func (s *StatusLine) fail(err error) {
+	if !s.failing {
+		logApp.Warnf("Status line output: %v", err)
+	}
+	s.failing = true
+}
+
+func (s *StatusLine) close() {
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.out, s.file = nil, nil
+}
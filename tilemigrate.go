@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// runTilesCommand implements "elrs-map tiles migrate": it copies the
+// directory tile cache into one MBTiles file per map source
+func runTilesCommand(args []string) {
+	if len(args) == 0 || args[0] != "migrate" {
+		fmt.Fprintln(os.Stderr, "usage: elrs-map tiles migrate [-config path] [-cache dir] [-remove]")
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("tiles migrate", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath(), "Config file path")
+	cacheDir := fs.String("cache", "", "Tile cache directory (default: from config)")
+	remove := fs.Bool("remove", false, "Delete the tile files once copied")
+	fs.Parse(args[1:])
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		logConfig.Warnf("Could not load config: %v (using defaults)", err)
+	}
+	dir := cfg.CacheDir
+	if *cacheDir != "" {
+		dir = *cacheDir
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		logTile.Fatalf("Could not read %s: %v", dir, err)
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			if err := migrateTileDir(dir, e.Name(), *remove); err != nil {
+				logTile.Fatalf("Migrating %s: %v", e.Name(), err)
+			}
+		}
+	}
+	fmt.Printf("Done. Set \"tiles: {cache: %s}\" in %s to use the new cache.\n", TileCacheMBTiles, *configPath)
+}
+
+// migrateTileDir copies one source's tiles, keeping their fetch times and
+// validators so nothing is downloaded again
+func migrateTileDir(dir, source string, remove bool) error {
+	from := &DirTileStore{dir: filepath.Join(dir, source)}
+	to, err := OpenMBTiles(filepath.Join(dir, source+".mbtiles"), source)
+	if err != nil {
+		return err
+	}
+	defer to.Close()
+
+	count := 0
+	err = from.Each(func(z, x, y int) error {
+		data, fetched, meta, ok := from.Get(z, x, y)
+		if !ok {
+			return nil
+		}
+		if err := to.put(z, x, y, data, meta, fetched); err != nil {
+			return err
+		}
+		if remove {
+			os.Remove(from.tilePath(z, x, y))
+			os.Remove(from.metaPath(z, x, y))
+		}
+		count++
+		if count%1000 == 0 {
+			fmt.Printf("%s: %d tiles...\n", source, count)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if remove {
+		os.Remove(from.dir) // Only succeeds once empty
+	}
+	fmt.Printf("%s: %d tiles copied to %s.mbtiles\n", source, count, source)
+	return nil
+}
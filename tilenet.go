@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tileTimeout bounds a tile request. With a rate limit only the wait for
+// response headers is bounded, since a queued body may take longer.
+const tileTimeout = 10 * time.Second
+
+// newTileHTTPClient returns the client for tile downloads, going through
+// proxy (http://, https:// or socks5://) if set, or the environment's
+// HTTP(S)_PROXY otherwise
+func newTileHTTPClient(proxy string, limited bool) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("proxy %q: %w", proxy, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("proxy %q: unsupported scheme %q", proxy, u.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	client := &http.Client{Transport: transport, Timeout: tileTimeout}
+	if limited {
+		transport.ResponseHeaderTimeout = tileTimeout
+		client.Timeout = 0
+	}
+	return client, nil
+}
+
+// RateLimiter spaces out reads so all downloads together stay under a
+// byte rate. A nil limiter, or one with rate 0, does not limit.
+type RateLimiter struct {
+	mu   sync.Mutex
+	rate float64 // Bytes per second
+	next time.Time
+}
+
+// NewRateLimiter creates a limiter for kbps kilobytes per second
+func NewRateLimiter(kbps int) *RateLimiter {
+	return &RateLimiter{rate: float64(kbps) * 1024}
+}
+
+// Wait blocks until n more bytes fit under the rate
+func (l *RateLimiter) Wait(n int) {
+	if l == nil || l.rate <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// Reader wraps r so reads from it are rate limited
+func (l *RateLimiter) Reader(r io.Reader) io.Reader {
+	return &limitedReader{r: r, limiter: l}
+}
+
+type limitedReader struct {
+	r       io.Reader
+	limiter *RateLimiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > 4096 {
+		p = p[:4096] // Small chunks keep concurrent downloads fair
+	}
+	n, err := lr.r.Read(p)
+	lr.limiter.Wait(n)
+	return n, err
+}
+
+// DataUsage counts bytes downloaded this session
+type DataUsage struct {
+	bytes    atomic.Int64
+	requests atomic.Int64
+}
+
+// Add records one download of n bytes
+func (u *DataUsage) Add(n int) {
+	u.bytes.Add(int64(n))
+	u.requests.Add(1)
+}
+
+// Bytes returns the total downloaded
+func (u *DataUsage) Bytes() int64 {
+	return u.bytes.Load()
+}
+
+// Requests returns the number of downloads
+func (u *DataUsage) Requests() int64 {
+	return u.requests.Load()
+}
+
+// formatBytes formats a byte count, e.g. "12.3 MB"
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.2f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.0f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -0,0 +1,102 @@
+//go:build !headless
+
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// prefetchLevels is how many zoom levels, from the view's, are prefetched
+const prefetchLevels = 3
+
+// prefetchArea is a spot whose tiles have been prefetched
+type prefetchArea struct {
+	lat, lon float64
+	source   MapSource
+}
+
+// PrefetchAround downloads the tiles within the configured radius of a
+// spot, usually home, into the cache in the background: at zoom and the two
+// levels in from it, nearest first, up to the configured number of tiles.
+// Tiles already cached are skipped. Calling it again for the same spot and
+// source does nothing; a new spot stops the last one's prefetch.
+func (tm *TileManager) PrefetchAround(lat, lon float64, zoom int) {
+	if tm.prefetchRadius <= 0 || tm.prefetchMax <= 0 {
+		return
+	}
+	tm.mu.Lock()
+	area := prefetchArea{lat: lat, lon: lon, source: tm.source}
+	if tm.prefetched == area {
+		tm.mu.Unlock()
+		return
+	}
+	tm.prefetched = area
+	tm.prefetchGen++
+	gen := tm.prefetchGen
+	tm.mu.Unlock()
+
+	var coords []TileCoord
+	for z := zoom; z < zoom+prefetchLevels && z <= MaxZoom; z++ {
+		coords = append(coords, tilesAround(lat, lon, tm.prefetchRadius, z)...)
+	}
+	if len(coords) > tm.prefetchMax {
+		coords = coords[:tm.prefetchMax]
+	}
+	go tm.prefetch(coords, area.source, gen)
+}
+
+// prefetch downloads the tiles that aren't cached yet, until a newer
+// prefetch starts, the manager closes, or a download fails
+func (tm *TileManager) prefetch(coords []TileCoord, source MapSource, gen int) {
+	store := tm.stores[source]
+	downloaded, cached := 0, 0
+	for _, c := range coords {
+		tm.mu.RLock()
+		stop := tm.closed || tm.prefetchGen != gen
+		tm.mu.RUnlock()
+		if stop {
+			return
+		}
+		if _, fetched, _, ok := store.Get(c.Z, c.X, c.Y); ok && (tm.maxAge <= 0 || time.Since(fetched) < tm.maxAge) {
+			cached++
+			continue
+		}
+		if tm.downloadTile(c, source, false) == nil {
+			logTile.Warnf("Prefetch around home stopped after %d tiles", downloaded)
+			return
+		}
+		downloaded++
+	}
+	logTile.Infof("Prefetched %d tiles around home (%d were cached)", downloaded, cached)
+}
+
+// tilesAround returns the tiles at zoom within radius meters of a spot,
+// nearest first
+func tilesAround(lat, lon, radius float64, zoom int) []TileCoord {
+	dLat := radius / 111320
+	dLon := radius / (111320 * math.Max(0.01, math.Cos(lat*math.Pi/180)))
+	// Pixels rather than LatLonToTile, which rounds west of 180°W the wrong way
+	px0, py0 := LatLonToPixel(math.Min(85, lat+dLat), lon-dLon, zoom)
+	px1, py1 := LatLonToPixel(math.Max(-85, lat-dLat), lon+dLon, zoom)
+	x0, y0 := int(math.Floor(px0/TileSize)), int(math.Floor(py0/TileSize))
+	x1, y1 := int(math.Floor(px1/TileSize)), int(math.Floor(py1/TileSize))
+	cx, cy := LatLonToPixel(lat, lon, zoom)
+
+	n := 1 << zoom
+	var coords []TileCoord
+	for x := x0; x <= x1 && x-x0 < n; x++ {
+		for y := max(0, y0); y <= min(n-1, y1); y++ {
+			coords = append(coords, TileCoord{X: ((x % n) + n) % n, Y: y, Z: zoom})
+		}
+	}
+	// Distance from the spot to the tile's middle, in tiles
+	dist := func(c TileCoord) float64 {
+		dx := float64(c.X) + 0.5 - cx/TileSize
+		dx -= float64(n) * math.Round(dx/float64(n))
+		return math.Hypot(dx, float64(c.Y)+0.5-cy/TileSize)
+	}
+	sort.SliceStable(coords, func(i, j int) bool { return dist(coords[i]) < dist(coords[j]) })
+	return coords
+}
@@ -1,29 +1,26 @@
+//go:build !headless
+
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"image"
+	"image/draw"
 	_ "image/jpeg" // Register JPEG decoder
 	_ "image/png"  // Register PNG decoder
 	"io"
-	"log"
 	"math"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
-const (
-	TileSize    = 256
-	MaxZoom     = 19
-	MinZoom     = 1
-	DefaultZoom = 15
-)
-
 // MapSource represents the map tile source
 type MapSource int
 
@@ -32,6 +29,52 @@ const (
 	MapSourceSatellite                  // ESRI World Imagery
 )
 
+// TileSource defines where a map source's tiles come from and the credit
+// its terms of use require on the map
+type TileSource struct {
+	Key         string // Config name, also the tile cache's folder
+	Name        string
+	URL         string // {z}, {x} and {y} are replaced with the tile's coordinates
+	Attribution string // Shown in a corner of the map while the source is on
+}
+
+// tileSources are the map sources' definitions.
+// Note: ESRI uses {z}/{y}/{x} order (not {z}/{x}/{y} like OSM)
+var tileSources = map[MapSource]TileSource{
+	MapSourceStreet: {
+		Key:         "street",
+		Name:        "Street",
+		URL:         "https://server.arcgisonline.com/ArcGIS/rest/services/World_Street_Map/MapServer/tile/{z}/{y}/{x}",
+		Attribution: "Tiles (c) Esri - Esri, HERE, Garmin, USGS, (c) OpenStreetMap contributors",
+	},
+	MapSourceSatellite: {
+		Key:         "satellite",
+		Name:        "Satellite",
+		URL:         "https://server.arcgisonline.com/ArcGIS/rest/services/World_Imagery/MapServer/tile/{z}/{y}/{x}",
+		Attribution: "Tiles (c) Esri - Esri, Maxar, Earthstar Geographics",
+	},
+}
+
+// TileURL returns the address of a tile
+func (s TileSource) TileURL(c TileCoord) string {
+	return strings.NewReplacer("{z}", strconv.Itoa(c.Z), "{x}", strconv.Itoa(c.X), "{y}", strconv.Itoa(c.Y)).Replace(s.URL)
+}
+
+// Key returns the config name of the source
+func (s MapSource) Key() string {
+	return tileSources[s].Key
+}
+
+// ParseMapSource returns the source for a config name
+func ParseMapSource(key string) (MapSource, bool) {
+	for s, def := range tileSources {
+		if def.Key == key {
+			return s, true
+		}
+	}
+	return MapSourceSatellite, false
+}
+
 // TileCoord represents a tile coordinate
 type TileCoord struct {
 	X, Y, Z int
@@ -45,25 +88,99 @@ type TileCacheKey struct {
 
 // TileManager handles map tile downloading and caching
 type TileManager struct {
-	cacheDir  string
-	source    MapSource
-	tiles     map[TileCacheKey]*ebiten.Image
-	loading   map[TileCacheKey]bool
-	mu        sync.RWMutex
-	client    *http.Client
+	cacheDir string
+	source   MapSource
+	tiles    map[TileCacheKey]*ebiten.Image
+	loading  map[TileCacheKey]bool
+	mu       sync.RWMutex
+	client   *http.Client
+	limiter  *RateLimiter
+	usage    DataUsage
+	stores   map[MapSource]TileStore
+	loaded   int // Bumped whenever a tile arrives, so cached layers know to redraw
+	hits     atomic.Int64
+	misses   atomic.Int64
+
+	// Workers take tiles from queue (newest first) and decode them into
+	// ready; Upload turns a few of those into GPU images each frame
+	queue  []TileCacheKey
+	ready  []decodedTile
+	wake   *sync.Cond
+	closed bool
+
+	// Cached tiles older than maxAge are revalidated (0 = never)
+	maxAge      time.Duration
+	revalidated map[TileCacheKey]bool
+
+	onDownloadError func(reason string)
+
+	// Tiles around home kept in the cache, see PrefetchAround
+	prefetchRadius float64
+	prefetchMax    int
+	prefetched     prefetchArea
+	prefetchGen    int
 }
 
 // NewTileManager creates a new tile manager
-func NewTileManager(cacheDir string) *TileManager {
-	return &TileManager{
-		cacheDir: cacheDir,
-		source:   MapSourceSatellite, // Default to satellite for FPV
-		tiles:    make(map[TileCacheKey]*ebiten.Image),
-		loading:  make(map[TileCacheKey]bool),
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+func NewTileManager(cacheDir string, cfg TileConfig) *TileManager {
+	client, err := newTileHTTPClient(cfg.Proxy, cfg.MaxRateKB > 0)
+	if err != nil {
+		logTile.Errorf("Ignoring tile proxy: %v", err)
+		client, _ = newTileHTTPClient("", cfg.MaxRateKB > 0)
+	} else if cfg.Proxy != "" {
+		logTile.Infof("Downloading tiles through %s", cfg.Proxy)
 	}
+	tm := &TileManager{
+		cacheDir:       cacheDir,
+		source:         MapSourceSatellite, // Default to satellite for FPV
+		tiles:          make(map[TileCacheKey]*ebiten.Image),
+		loading:        make(map[TileCacheKey]bool),
+		client:         client,
+		limiter:        NewRateLimiter(cfg.MaxRateKB),
+		maxAge:         cfg.MaxAge,
+		prefetchRadius: cfg.PrefetchRadius,
+		prefetchMax:    cfg.PrefetchMax,
+		revalidated:    make(map[TileCacheKey]bool),
+		stores:         make(map[MapSource]TileStore),
+	}
+	for source, def := range tileSources {
+		store, err := OpenTileStore(cfg.Cache, cacheDir, def.Key)
+		if err != nil {
+			logTile.Errorf("Could not open tile cache: %v (using %s)", err, TileCacheDir)
+			store, _ = OpenTileStore(TileCacheDir, cacheDir, def.Key)
+		}
+		tm.stores[source] = store
+	}
+	tm.wake = sync.NewCond(&tm.mu)
+	for i := 0; i < tileWorkers; i++ {
+		go tm.worker()
+	}
+	return tm
+}
+
+// Close stops the workers and closes the tile cache
+func (tm *TileManager) Close() {
+	tm.mu.Lock()
+	tm.closed = true
+	tm.mu.Unlock()
+	tm.wake.Broadcast()
+	for _, store := range tm.stores {
+		store.Close()
+	}
+}
+
+// CacheStats returns the number of tiles in memory, and how many lookups
+// found their tile there or had to load it
+func (tm *TileManager) CacheStats() (tiles int, hits, misses int64) {
+	tm.mu.RLock()
+	tiles = len(tm.tiles)
+	tm.mu.RUnlock()
+	return tiles, tm.hits.Load(), tm.misses.Load()
+}
+
+// DataUsed returns the bytes and tiles downloaded this session
+func (tm *TileManager) DataUsed() (bytes, tiles int64) {
+	return tm.usage.Bytes(), tm.usage.Requests()
 }
 
 // SetSource changes the map source
@@ -80,6 +197,23 @@ func (tm *TileManager) GetSource() MapSource {
 	return tm.source
 }
 
+// OnDownloadError sets a function told when a tile can't be downloaded,
+// called from the download workers
+func (tm *TileManager) OnDownloadError(f func(reason string)) {
+	tm.mu.Lock()
+	tm.onDownloadError = f
+	tm.mu.Unlock()
+}
+
+func (tm *TileManager) downloadFailed(reason string) {
+	tm.mu.RLock()
+	f := tm.onDownloadError
+	tm.mu.RUnlock()
+	if f != nil {
+		f(reason)
+	}
+}
+
 // ToggleSource switches between street and satellite
 func (tm *TileManager) ToggleSource() MapSource {
 	tm.mu.Lock()
@@ -95,30 +229,64 @@ func (tm *TileManager) ToggleSource() MapSource {
 
 // SourceName returns human-readable source name
 func (tm *TileManager) SourceName() string {
-	switch tm.GetSource() {
-	case MapSourceStreet:
-		return "Street"
-	case MapSourceSatellite:
-		return "Satellite"
-	default:
-		return "Unknown"
+	if def, ok := tileSources[tm.GetSource()]; ok {
+		return def.Name
+	}
+	return "Unknown"
+}
+
+// Attribution returns the credit the current source requires on the map
+func (tm *TileManager) Attribution() string {
+	return tileSources[tm.GetSource()].Attribution
+}
+
+// Sources lists the map sources for the web map, the one on screen first
+func (tm *TileManager) Sources() []WebTileSource {
+	current := tm.GetSource()
+	sources := []WebTileSource{{Key: current.Key(), Attribution: tileSources[current].Attribution}}
+	for s, def := range tileSources {
+		if s != current {
+			sources = append(sources, WebTileSource{Key: def.Key, Attribution: def.Attribution})
+		}
+	}
+	return sources
+}
+
+// Tile returns a cached tile's image for the web map. With download set, a
+// tile that isn't cached is fetched (and cached) like one on screen.
+func (tm *TileManager) Tile(key string, z, x, y int, download bool) ([]byte, bool) {
+	source, ok := ParseMapSource(key)
+	if !ok {
+		return nil, false
+	}
+	store := tm.stores[source]
+	if data, _, _, ok := store.Get(z, x, y); ok {
+		return data, true
 	}
+	if !download || tm.downloadTile(TileCoord{X: x, Y: y, Z: z}, source, false) == nil {
+		return nil, false
+	}
+	data, _, _, ok := store.Get(z, x, y)
+	return data, ok
 }
 
-// LatLonToTile converts lat/lon to tile coordinates at given zoom
+// LatLonToTile converts lat/lon to tile coordinates at given zoom. The
+// tile is on the world: the column wraps at ±180° and the row is clamped
+// near the poles.
 func LatLonToTile(lat, lon float64, zoom int) (int, int) {
-	n := math.Pow(2, float64(zoom))
-	x := int((lon + 180.0) / 360.0 * n)
-	latRad := lat * math.Pi / 180.0
-	y := int((1.0 - math.Asinh(math.Tan(latRad))/math.Pi) / 2.0 * n)
+	px, py := LatLonToPixel(lat, wrapLon(lon), zoom)
+	n := 1 << zoom
+	x := int(math.Floor(px/TileSize)) % n
+	y := min(n-1, max(0, int(math.Floor(py/TileSize))))
 	return x, y
 }
 
-// LatLonToPixel converts lat/lon to pixel coordinates within a tile at given zoom
+// LatLonToPixel converts lat/lon to pixel coordinates within a tile at given zoom.
+// Latitudes past MaxLat are clamped to the top or bottom of the world.
 func LatLonToPixel(lat, lon float64, zoom int) (float64, float64) {
 	n := math.Pow(2, float64(zoom))
 	x := (lon + 180.0) / 360.0 * n * TileSize
-	latRad := lat * math.Pi / 180.0
+	latRad := clampLat(lat) * math.Pi / 180.0
 	y := (1.0 - math.Asinh(math.Tan(latRad))/math.Pi) / 2.0 * n * TileSize
 	return x, y
 }
@@ -132,6 +300,16 @@ func TileToLatLon(x, y, zoom int) (float64, float64) {
 	return lat, lon
 }
 
+// PixelToLatLon converts world pixel coordinates at a zoom back to lat/lon.
+// Pixels off the side of the world wrap around the ±180° seam and those
+// above or below it clamp to MaxLat.
+func PixelToLatLon(x, y float64, zoom int) (float64, float64) {
+	size := math.Pow(2, float64(zoom)) * TileSize
+	lon := wrapLon(x/size*360.0 - 180.0)
+	lat := math.Atan(math.Sinh(math.Pi*(1-2*y/size))) * 180.0 / math.Pi
+	return clampLat(lat), lon
+}
+
 // GetTile returns a tile image, loading it if necessary
 func (tm *TileManager) GetTile(coord TileCoord) *ebiten.Image {
 	source := tm.GetSource()
@@ -140,6 +318,7 @@ func (tm *TileManager) GetTile(coord TileCoord) *ebiten.Image {
 	tm.mu.RLock()
 	if tile, ok := tm.tiles[key]; ok {
 		tm.mu.RUnlock()
+		tm.hits.Add(1)
 		return tile
 	}
 	if tm.loading[key] {
@@ -147,162 +326,322 @@ func (tm *TileManager) GetTile(coord TileCoord) *ebiten.Image {
 		return nil
 	}
 	tm.mu.RUnlock()
+	tm.misses.Add(1)
 
-	// Mark as loading and start async load
+	// Mark as loading and queue it for a worker
 	tm.mu.Lock()
 	tm.loading[key] = true
+	tm.queue = append(tm.queue, key)
+	// Panning fast queues tiles that are long off screen; forget the oldest
+	if drop := len(tm.queue) - tileQueueMax; drop > 0 {
+		for _, k := range tm.queue[:drop] {
+			delete(tm.loading, k)
+		}
+		tm.queue = append(tm.queue[:0], tm.queue[drop:]...)
+	}
 	tm.mu.Unlock()
-
-	go tm.loadTile(coord, source)
+	tm.wake.Signal()
 	return nil
 }
 
-func (tm *TileManager) loadTile(coord TileCoord, source MapSource) {
-	key := TileCacheKey{Coord: coord, Source: source}
+// Preheat queues the tiles of a screen-sized view, so they are loaded
+// before anything asks for them. Views preheated later load first.
+func (tm *TileManager) Preheat(lat, lon float64, zoom, screenW, screenH int) {
+	for _, coord := range tm.GetTilesForView(lat, lon, zoom, screenW, screenH) {
+		tm.GetTile(coord)
+	}
+}
 
-	defer func() {
+// worker loads queued tiles until the manager is closed
+func (tm *TileManager) worker() {
+	for {
 		tm.mu.Lock()
-		delete(tm.loading, key)
+		for len(tm.queue) == 0 && !tm.closed {
+			tm.wake.Wait()
+		}
+		if tm.closed {
+			tm.mu.Unlock()
+			return
+		}
+		// Newest first: that's what is on screen now
+		key := tm.queue[len(tm.queue)-1]
+		tm.queue = tm.queue[:len(tm.queue)-1]
 		tm.mu.Unlock()
-	}()
 
-	// Try cache first
-	img := tm.loadFromCache(coord, source)
-	if img != nil {
-		tm.mu.Lock()
-		tm.tiles[key] = img
-		tm.mu.Unlock()
-		return
+		tm.loadTile(key)
 	}
+}
 
-	// Download from ESRI
-	img = tm.downloadTile(coord, source)
+// loadTile reads a tile from the cache or the server and queues the
+// decoded image for upload. The last result it queues is marked done.
+func (tm *TileManager) loadTile(key TileCacheKey) {
+	coord, source := key.Coord, key.Source
+
+	// Try cache first
+	img, fetched := tm.loadFromCache(coord, source)
 	if img != nil {
 		tm.mu.Lock()
-		tm.tiles[key] = img
+		// Check stale tiles with the server once per session
+		stale := tm.maxAge > 0 && time.Since(fetched) > tm.maxAge && !tm.revalidated[key]
+		tm.revalidated[key] = true
 		tm.mu.Unlock()
+		tm.queueReady(decodedTile{key: key, img: img, done: !stale})
+		if !stale {
+			return
+		}
 	}
+
+	// Download from ESRI, or revalidate the cached copy
+	img = tm.downloadTile(coord, source, img != nil)
+	tm.queueReady(decodedTile{key: key, img: img, done: true})
 }
 
-func (tm *TileManager) cachePath(coord TileCoord, source MapSource) string {
-	sourceDir := "satellite"
-	if source == MapSourceStreet {
-		sourceDir = "street"
+func (tm *TileManager) queueReady(t decodedTile) {
+	tm.mu.Lock()
+	tm.ready = append(tm.ready, t)
+	tm.mu.Unlock()
+}
+
+// Upload turns up to tileUploadsPerFrame decoded tiles into images. It
+// must be called from the game loop, once per frame.
+func (tm *TileManager) Upload() {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	uploads := 0
+	n := 0
+	for _, t := range tm.ready {
+		if t.img != nil {
+			if uploads == tileUploadsPerFrame {
+				break
+			}
+			tm.tiles[t.key] = uploadTile(t.img)
+			tm.loaded++
+			uploads++
+		}
+		if t.done {
+			delete(tm.loading, t.key)
+		}
+		n++
 	}
-	return filepath.Join(tm.cacheDir, sourceDir, fmt.Sprintf("%d_%d_%d.jpg", coord.Z, coord.X, coord.Y))
+	tm.ready = append(tm.ready[:0], tm.ready[n:]...)
 }
 
-func (tm *TileManager) loadFromCache(coord TileCoord, source MapSource) *ebiten.Image {
-	path := tm.cachePath(coord, source)
-	f, err := os.Open(path)
-	if err != nil {
-		return nil
+// Loading returns how many tiles are queued or being loaded
+func (tm *TileManager) Loading() int {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return len(tm.loading)
+}
+
+// Generation changes whenever the set of loaded tiles does
+func (tm *TileManager) Generation() int {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return tm.loaded
+}
+
+// loadFromCache returns the cached tile and when it was fetched
+func (tm *TileManager) loadFromCache(coord TileCoord, source MapSource) (image.Image, time.Time) {
+	data, fetched, _, ok := tm.stores[source].Get(coord.Z, coord.X, coord.Y)
+	if !ok {
+		return nil, time.Time{}
 	}
-	defer f.Close()
 
-	// ESRI returns JPEG for satellite, PNG for street
-	img, _, err := image.Decode(f)
+	img, err := decodeTile(data)
 	if err != nil {
-		return nil
+		return nil, time.Time{}
 	}
 
-	return ebiten.NewImageFromImage(img)
+	return img, fetched
 }
 
-func (tm *TileManager) downloadTile(coord TileCoord, source MapSource) *ebiten.Image {
-	// ESRI tile URLs
-	// Note: ESRI uses {z}/{y}/{x} order (not {z}/{x}/{y} like OSM)
-	var url string
-	switch source {
-	case MapSourceStreet:
-		url = fmt.Sprintf("https://server.arcgisonline.com/ArcGIS/rest/services/World_Street_Map/MapServer/tile/%d/%d/%d", coord.Z, coord.Y, coord.X)
-	case MapSourceSatellite:
-		url = fmt.Sprintf("https://server.arcgisonline.com/ArcGIS/rest/services/World_Imagery/MapServer/tile/%d/%d/%d", coord.Z, coord.Y, coord.X)
-	}
+// downloadTile fetches a tile. With revalidate set, the cached copy's
+// validators are sent along, and nil is returned if the server says it is
+// unchanged (or can't be reached), so the cached image stays.
+func (tm *TileManager) downloadTile(coord TileCoord, source MapSource, revalidate bool) image.Image {
+	url := tileSources[source].TileURL(coord)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		log.Printf("Tile request error %v: %v", coord, err)
+		logTile.Warnf("Request error %v: %v", coord, err)
 		return nil
 	}
 	req.Header.Set("User-Agent", "ELRS-GroundStation/1.0")
+	store := tm.stores[source]
+	if revalidate {
+		_, _, meta, _ := store.Get(coord.Z, coord.X, coord.Y)
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
 
 	resp, err := tm.client.Do(req)
 	if err != nil {
-		log.Printf("Tile download error %v: %v", coord, err)
+		logTile.Warnf("Download error %v: %v", coord, err)
+		if !revalidate {
+			tm.downloadFailed("no connection")
+		}
 		return nil
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		// Still current; restart its max-age
+		store.Touch(coord.Z, coord.X, coord.Y)
+		logTile.Debugf("Revalidated %v", coord)
+		return nil
+	}
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("Tile HTTP error %v: status %d", coord, resp.StatusCode)
+		logTile.Warnf("HTTP error %v: status %d", coord, resp.StatusCode)
+		if !revalidate {
+			tm.downloadFailed(fmt.Sprintf("HTTP %d", resp.StatusCode))
+		}
 		return nil
 	}
 
 	// Read image data
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Tile read error %v: %v", coord, err)
+	buf := tileBufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer tileBufPool.Put(buf)
+	if _, err := buf.ReadFrom(tm.limiter.Reader(resp.Body)); err != nil {
+		logTile.Warnf("Read error %v: %v", coord, err)
 		return nil
 	}
-
-	// Ensure cache directory exists
-	cacheDir := filepath.Dir(tm.cachePath(coord, source))
-	os.MkdirAll(cacheDir, 0755)
+	data := buf.Bytes()
+	tm.usage.Add(len(data))
+	logTile.Debugf("Downloaded %v (%d bytes)", coord, len(data))
 
 	// Save to cache
-	cachePath := tm.cachePath(coord, source)
-	if err := os.WriteFile(cachePath, data, 0644); err != nil {
-		log.Printf("Tile cache write error %v: %v", coord, err)
+	meta := tileMeta{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	if err := store.Put(coord.Z, coord.X, coord.Y, data, meta); err != nil {
+		logTile.Warnf("Cache write error %v: %v", coord, err)
 	}
 
 	// Decode for display
-	img, _, err := image.Decode(NewByteReader(data))
+	img, err := decodeTile(data)
 	if err != nil {
-		log.Printf("Tile decode error %v: %v", coord, err)
+		logTile.Warnf("Decode error %v: %v", coord, err)
 		return nil
 	}
 
-	return ebiten.NewImageFromImage(img)
+	return img
 }
 
-// GetTilesForView returns all tile coordinates needed for the given view
+// GetTilesForView returns all tile coordinates needed for the given view,
+// each once
 func (tm *TileManager) GetTilesForView(centerLat, centerLon float64, zoom, screenW, screenH int) []TileCoord {
-	// Calculate center tile
-	centerX, centerY := LatLonToTile(centerLat, centerLon, zoom)
-
-	// Calculate how many tiles we need in each direction
-	tilesX := (screenW / TileSize) + 2
-	tilesY := (screenH / TileSize) + 2
-
+	cx, cy := LatLonToPixel(centerLat, centerLon, zoom)
+	w, h := float64(screenW), float64(screenH)
+	seen := make(map[TileCoord]bool)
 	var coords []TileCoord
-	for dx := -tilesX / 2; dx <= tilesX/2; dx++ {
-		for dy := -tilesY / 2; dy <= tilesY/2; dy++ {
-			x := centerX + dx
-			y := centerY + dy
-
-			// Wrap X coordinate
-			n := 1 << zoom
-			x = ((x % n) + n) % n
-
-			// Skip invalid Y
-			if y < 0 || y >= n {
-				continue
-			}
-
-			coords = append(coords, TileCoord{X: x, Y: y, Z: zoom})
+	for _, t := range TilesInBounds(cx-w/2, cy-h/2, w, h, zoom) {
+		if !seen[t.Coord] {
+			seen[t.Coord] = true
+			coords = append(coords, t.Coord)
 		}
 	}
 	return coords
 }
 
+// ViewTile is a tile covering part of a view and the world pixel its
+// top-left corner goes at. Past the ±180° seam that is off the side of the
+// world, on the next copy of it, while Coord is the tile wrapped back.
+type ViewTile struct {
+	Coord TileCoord
+	X, Y  float64
+}
+
+// TilesInBounds returns the tiles covering w x h world pixels at left, top.
+// Columns past the seam wrap around, repeating the world when the view is
+// wider than it; rows above and below the world are left out.
+func TilesInBounds(left, top, w, h float64, zoom int) []ViewTile {
+	n := 1 << zoom
+	x0, x1 := int(math.Floor(left/TileSize)), int(math.Ceil((left+w)/TileSize))
+	y0, y1 := max(0, int(math.Floor(top/TileSize))), min(n, int(math.Ceil((top+h)/TileSize)))
+
+	var tiles []ViewTile
+	for x := x0; x < x1; x++ {
+		for y := y0; y < y1; y++ {
+			tiles = append(tiles, ViewTile{
+				Coord: TileCoord{X: ((x % n) + n) % n, Y: y, Z: zoom},
+				X:     float64(x * TileSize),
+				Y:     float64(y * TileSize),
+			})
+		}
+	}
+	return tiles
+}
+
 // ClearCache removes all cached tiles
 func (tm *TileManager) ClearCache() {
 	tm.mu.Lock()
 	tm.tiles = make(map[TileCacheKey]*ebiten.Image)
+	tm.loaded++
 	tm.mu.Unlock()
 }
 
+const (
+	// tileWorkers is how many tiles load at once. Downloads dominate, so
+	// this is about the tile server's patience more than CPU cores.
+	tileWorkers = 4
+
+	// tileUploadsPerFrame bounds GPU uploads, so tiles streaming in don't
+	// hitch the render loop
+	tileUploadsPerFrame = 4
+
+	// tileQueueMax is how many tiles may wait for a worker
+	tileQueueMax = 64
+)
+
+// decodedTile is a tile decoded by a worker, waiting for upload. done marks
+// the last result for the tile, after which it may be requested again.
+type decodedTile struct {
+	key  TileCacheKey
+	img  image.Image // nil if nothing changed or loading failed
+	done bool
+}
+
+// Buffers for tile downloads and decoding, reused so panning over new
+// ground doesn't leave a trail of garbage for the collector
+var (
+	tileBufPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+	tilePixPool = sync.Pool{New: func() any { return image.NewRGBA(image.Rect(0, 0, TileSize, TileSize)) }}
+)
+
+// decodeTile decodes a tile in a worker. JPEGs decode to YCbCr, which
+// NewImageFromImage would convert into a fresh RGBA image on the game loop;
+// the conversion happens here instead, into a pooled image.
+func decodeTile(data []byte) (image.Image, error) {
+	// ESRI returns JPEG for satellite, PNG for street
+	src, _, err := image.Decode(NewByteReader(data))
+	if err != nil {
+		return nil, err
+	}
+	b := src.Bounds()
+	if b.Dx() != TileSize || b.Dy() != TileSize {
+		return src, nil
+	}
+	rgba := tilePixPool.Get().(*image.RGBA)
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	return rgba, nil
+}
+
+// uploadTile copies a decoded tile to the GPU and recycles its buffer
+func uploadTile(src image.Image) *ebiten.Image {
+	rgba, ok := src.(*image.RGBA)
+	if !ok || rgba.Bounds() != image.Rect(0, 0, TileSize, TileSize) {
+		return ebiten.NewImageFromImage(src)
+	}
+	img := ebiten.NewImage(TileSize, TileSize)
+	img.WritePixels(rgba.Pix)
+	tilePixPool.Put(rgba)
+	return img
+}
+
 // ByteReader wraps a byte slice for io.Reader
 type ByteReader struct {
 	data []byte
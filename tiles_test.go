@@ -0,0 +1,95 @@
+//go:build !headless
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPixelToLatLon(t *testing.T) {
+	const zoom = 2 // 1024 pixels around
+	tests := []struct {
+		name     string
+		x, y     float64
+		lat, lon float64
+	}{
+		{"middle", 512, 512, 0, 0},
+		{"above the world", 512, -500, MaxLat, 0},
+		{"below the world", 512, 1500, -MaxLat, 0},
+		{"top edge", 0, 0, MaxLat, -180},
+		{"left of the seam", -256, 512, 0, 90},
+		{"right of the seam", 1024 + 128, 512, 0, -135},
+		{"a world over", 512 + 3*1024, 512, 0, 0},
+	}
+	for _, tt := range tests {
+		lat, lon := PixelToLatLon(tt.x, tt.y, zoom)
+		if math.Abs(lat-tt.lat) > 1e-6 || math.Abs(lon-tt.lon) > 1e-9 {
+			t.Errorf("%s: PixelToLatLon(%v, %v) = %v, %v, want %v, %v", tt.name, tt.x, tt.y, lat, lon, tt.lat, tt.lon)
+		}
+	}
+}
+
+func TestLatLonToTile(t *testing.T) {
+	const zoom = 3
+	n := 1 << zoom
+	tests := []struct {
+		name     string
+		lat, lon float64
+		x, y     int
+	}{
+		{"origin", 0, 0, n / 2, n / 2},
+		{"seam east", 0, 180, 0, n / 2},
+		{"past the seam", 0, 190, 0, n / 2},
+		{"just west of the seam", 0, 179.9, n - 1, n / 2},
+		{"north pole", 89.9, 0, n / 2, 0},
+		{"south pole", -90, 0, n / 2, n - 1},
+	}
+	for _, tt := range tests {
+		if x, y := LatLonToTile(tt.lat, tt.lon, zoom); x != tt.x || y != tt.y {
+			t.Errorf("%s: LatLonToTile(%v, %v) = %d, %d, want %d, %d", tt.name, tt.lat, tt.lon, x, y, tt.x, tt.y)
+		}
+	}
+}
+
+func TestGetTilesForView(t *testing.T) {
+	tm := &TileManager{}
+	tests := []struct {
+		name     string
+		lat, lon float64
+		zoom     int
+		w, h     int
+		cols     []int // Columns that must be there
+		count    int   // Tiles expected, 0 to not check
+	}{
+		{"across the seam", 0, 179.99, 4, 800, 600, []int{15, 0}, 0},
+		{"across the seam going west", 0, -179.99, 4, 800, 600, []int{15, 0}, 0},
+		{"near the north pole", 84, 0, 3, 800, 600, []int{3, 4}, 0},
+		{"near the south pole", -84, 0, 3, 800, 600, []int{3, 4}, 0},
+		{"wider than the world", 0, 0, 1, 1600, 600, []int{0, 1}, 4},
+	}
+	for _, tt := range tests {
+		coords := tm.GetTilesForView(tt.lat, tt.lon, tt.zoom, tt.w, tt.h)
+		n := 1 << tt.zoom
+		seen := make(map[TileCoord]bool)
+		cols := make(map[int]bool)
+		for _, c := range coords {
+			if c.X < 0 || c.X >= n || c.Y < 0 || c.Y >= n || c.Z != tt.zoom {
+				t.Errorf("%s: tile %+v is off the world", tt.name, c)
+			}
+			if seen[c] {
+				t.Errorf("%s: tile %+v listed twice", tt.name, c)
+			}
+			seen[c] = true
+			cols[c.X] = true
+		}
+		for _, col := range tt.cols {
+			if !cols[col] {
+				t.Errorf("%s: no tiles in column %d", tt.name, col)
+			}
+		}
+		if tt.count > 0 && len(coords) != tt.count {
+			t.Errorf("%s: %d tiles, want %d", tt.name, len(coords), tt.count)
+		}
+	}
+}
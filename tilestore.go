@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tile cache backends
+const (
+	TileCacheDir     = "dir"     // One image file per tile
+	TileCacheMBTiles = "mbtiles" // One SQLite file per map source
+)
+
+// tileMeta holds the HTTP validators of a cached tile
+type tileMeta struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// TileStore keeps downloaded tiles of one map source on disk
+type TileStore interface {
+	// Get returns a tile, when it was last fetched or revalidated, and its
+	// validators; ok is false if the tile isn't cached
+	Get(z, x, y int) (data []byte, fetched time.Time, meta tileMeta, ok bool)
+	// Put stores a freshly downloaded tile
+	Put(z, x, y int, data []byte, meta tileMeta) error
+	// Touch marks a tile as revalidated now
+	Touch(z, x, y int) error
+	Close() error
+}
+
+// TileCacheReader serves the tile cache to the web map without a display,
+// where there is no tile manager; it only has what is already cached
+type TileCacheReader struct {
+	kind, dir string
+	mu        sync.Mutex
+	stores    map[string]TileStore
+}
+
+// NewTileCacheReader reads the cache of the given kind under dir
+func NewTileCacheReader(kind, dir string) *TileCacheReader {
+	return &TileCacheReader{kind: kind, dir: dir, stores: make(map[string]TileStore)}
+}
+
+// Sources lists the map sources with a cache, satellite first
+func (c *TileCacheReader) Sources() []WebTileSource {
+	var sources []WebTileSource
+	for _, key := range []string{"satellite", "street"} {
+		if c.store(key) != nil {
+			sources = append(sources, WebTileSource{Key: key})
+		}
+	}
+	return sources
+}
+
+// Tile returns a cached tile; nothing is downloaded
+func (c *TileCacheReader) Tile(source string, z, x, y int, download bool) ([]byte, bool) {
+	store := c.store(source)
+	if store == nil {
+		return nil, false
+	}
+	data, _, _, ok := store.Get(z, x, y)
+	return data, ok
+}
+
+// store opens a source's cache the first time it is asked for, if there
+// is one; an unknown name must not create an empty cache
+func (c *TileCacheReader) store(source string) TileStore {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.stores[source]; ok {
+		return s
+	}
+	if source != "satellite" && source != "street" {
+		return nil
+	}
+	path := filepath.Join(c.dir, source)
+	if c.kind == TileCacheMBTiles {
+		path += ".mbtiles"
+	}
+	var store TileStore
+	if _, err := os.Stat(path); err == nil {
+		if store, err = OpenTileStore(c.kind, c.dir, source); err != nil {
+			logTile.Warnf("Could not open tile cache: %v", err)
+			store = nil
+		}
+	}
+	c.stores[source] = store
+	return store
+}
+
+// Close closes the caches opened so far
+func (c *TileCacheReader) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.stores {
+		if s != nil {
+			s.Close()
+		}
+	}
+}
+
+// OpenTileStore opens the cache for one source (e.g. "satellite") under dir
+func OpenTileStore(kind, dir, source string) (TileStore, error) {
+	switch kind {
+	case TileCacheDir, "":
+		return &DirTileStore{dir: filepath.Join(dir, source)}, nil
+	case TileCacheMBTiles:
+		return OpenMBTiles(filepath.Join(dir, source+".mbtiles"), source)
+	default:
+		return nil, fmt.Errorf("unknown tile cache %q (dir, mbtiles)", kind)
+	}
+}
+
+// DirTileStore is the original cache layout: z_x_y.jpg files with the
+// validators in a .meta file next to each. The file's modification time is
+// when it was last fetched or revalidated.
+type DirTileStore struct {
+	dir string
+}
+
+func (s *DirTileStore) tilePath(z, x, y int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%d_%d_%d.jpg", z, x, y))
+}
+
+func (s *DirTileStore) metaPath(z, x, y int) string {
+	return strings.TrimSuffix(s.tilePath(z, x, y), ".jpg") + ".meta"
+}
+
+// Get reads a tile file and its validators
+func (s *DirTileStore) Get(z, x, y int) ([]byte, time.Time, tileMeta, bool) {
+	var meta tileMeta
+	path := s.tilePath(z, x, y)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, meta, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, meta, false
+	}
+	// Missing or broken .meta files mean no validators
+	if metaData, err := os.ReadFile(s.metaPath(z, x, y)); err == nil {
+		json.Unmarshal(metaData, &meta)
+	}
+	return data, info.ModTime(), meta, true
+}
+
+// Put writes a tile file, and its validators if the server sent any
+func (s *DirTileStore) Put(z, x, y int, data []byte, meta tileMeta) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.tilePath(z, x, y), data, 0644); err != nil {
+		return err
+	}
+	if meta == (tileMeta{}) {
+		os.Remove(s.metaPath(z, x, y))
+		return nil
+	}
+	metaData, _ := json.Marshal(meta)
+	return os.WriteFile(s.metaPath(z, x, y), metaData, 0644)
+}
+
+// Touch restarts a tile's max-age
+func (s *DirTileStore) Touch(z, x, y int) error {
+	now := time.Now()
+	return os.Chtimes(s.tilePath(z, x, y), now, now)
+}
+
+// Close does nothing; files are closed after each access
+func (s *DirTileStore) Close() error {
+	return nil
+}
+
+// Each calls fn for every cached tile, for migrating to another store
+func (s *DirTileStore) Each(fn func(z, x, y int) error) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		var z, x, y int
+		if e.IsDir() || filepath.Ext(e.Name()) != ".jpg" {
+			continue
+		}
+		if _, err := fmt.Sscanf(e.Name(), "%d_%d_%d.jpg", &z, &x, &y); err != nil {
+			continue
+		}
+		if err := fn(z, x, y); err != nil {
+			return err
+		}
+	}
+	return nil
+}
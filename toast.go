@@ -0,0 +1,121 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	toastDuration      = 4 * time.Second
+	toastErrorDuration = 8 * time.Second // Long enough to read the fix
+	maxToasts          = 4
+)
+
+// Toast is a short message shown over the map for a few seconds
+type Toast struct {
+	Text  string
+	Level LogLevel
+	Count int // Repeats folded into this one
+	At    time.Time
+}
+
+func (t Toast) expired(now time.Time) bool {
+	d := toastDuration
+	if t.Level >= LevelError {
+		d = toastErrorDuration
+	}
+	return now.Sub(t.At) > d
+}
+
+// Toasts queues notifications for the field user, who never sees the log.
+// It is safe to add to from any goroutine.
+type Toasts struct {
+	mu    sync.Mutex
+	items []Toast
+	gen   int
+}
+
+// Add shows a message, or refreshes it if it is already on screen
+func (t *Toasts) Add(level LogLevel, format string, args ...interface{}) {
+	text := fmt.Sprintf(format, args...)
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.gen++
+	for i := range t.items {
+		if t.items[i].Text == text && !t.items[i].expired(now) {
+			t.items[i].Count++
+			t.items[i].At = now
+			return
+		}
+	}
+	t.items = append(t.items, Toast{Text: text, Level: level, Count: 1, At: now})
+	if len(t.items) > maxToasts {
+		t.items = t.items[len(t.items)-maxToasts:]
+	}
+}
+
+// Active drops expired toasts and returns the rest, oldest first
+func (t *Toasts) Active() []Toast {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	kept := t.items[:0]
+	for _, item := range t.items {
+		if !item.expired(now) {
+			kept = append(kept, item)
+		}
+	}
+	t.items = kept
+	return append([]Toast(nil), kept...)
+}
+
+// Generation changes whenever a toast is added, for power save redraws
+func (t *Toasts) Generation() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.gen
+}
+
+// notify logs a message to l and shows it as a toast
+func (a *App) notify(l *Logger, level LogLevel, format string, args ...interface{}) {
+	l.logf(level, format, args...)
+	a.toasts.Add(level, format, args...)
+}
+
+// drawToastsWithOffset stacks the toasts at the bottom of the map, newest
+// lowest, above the LQ timeline and status bar
+func (a *App) drawToastsWithOffset(screen *ebiten.Image, offsetX, mapWidth int) {
+	toasts := a.toasts.Active()
+	y := a.height - 24 - lqTimelineHeight - 10
+	for i := len(toasts) - 1; i >= 0; i-- {
+		t := toasts[i]
+		text := t.Text
+		if t.Count > 1 {
+			text = fmt.Sprintf("%s (x%d)", text, t.Count)
+		}
+		bg := color.RGBA{40, 40, 40, 220}
+		switch t.Level {
+		case LevelWarn:
+			bg = color.RGBA{170, 110, 0, 230}
+		case LevelError:
+			bg = color.RGBA{180, 30, 30, 230}
+		}
+		w := len(text)*6 + 16
+		h := 22
+		y -= h
+		x := offsetX + (mapWidth-w)/2
+		vector.DrawFilledRect(screen, float32(x), float32(y), float32(w), float32(h), bg, false)
+		ebitenutil.DebugPrintAt(screen, text, x+8, y+4)
+		y -= 4
+	}
+}
@@ -1,7 +1,10 @@
+//go:build !headless
+
 package main
 
 import (
 	"image/color"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
@@ -17,8 +20,16 @@ type TouchButton struct {
 	Active     bool   // Toggle state for toggle buttons
 	Visible    bool
 	OnPress    func()
+
+	// Layout and binding from the config file
+	Action   string
+	Anchor   string
+	Col, Row int
 }
 
+// touchFadeDuration is how long hidden touch buttons take to fade out
+const touchFadeDuration = 500 * time.Millisecond
+
 // TouchControls manages touch UI elements
 type TouchControls struct {
 	buttons  []*TouchButton
@@ -27,15 +38,26 @@ type TouchControls struct {
 	btnColor color.RGBA
 	actColor color.RGBA
 	txtColor color.RGBA
+
+	// Grid cell size used by the anchor layout
+	btnW, btnH, margin int
+
+	// Auto-hide: buttons fade out after IdleTimeout without input (0 = never)
+	IdleTimeout  time.Duration
+	Opacity      float64 // 0.0-1.0, applied on top of the button colors
+	lastActivity time.Time
 }
 
 // NewTouchControls creates touch control manager
 func NewTouchControls() *TouchControls {
 	return &TouchControls{
-		buttons:  make([]*TouchButton, 0),
-		btnColor: color.RGBA{60, 60, 60, 200},
-		actColor: color.RGBA{0, 150, 0, 200},
-		txtColor: color.RGBA{255, 255, 255, 255},
+		buttons:      make([]*TouchButton, 0),
+		btnColor:     color.RGBA{60, 60, 60, 200},
+		actColor:     color.RGBA{0, 150, 0, 200},
+		txtColor:     color.RGBA{255, 255, 255, 255},
+		IdleTimeout:  DefaultTouchIdleTimeout,
+		Opacity:      1.0,
+		lastActivity: time.Now(),
 	}
 }
 
@@ -57,17 +79,58 @@ func (tc *TouchControls) AddButton(x, y, w, h int, label, icon string, onPress f
 
 // Update checks for touch/click events
 func (tc *TouchControls) Update() {
+	// A touch while the buttons are hidden only wakes them up, so a tap
+	// on an invisible button doesn't fire an action by surprise
+	hidden := tc.visibility() == 0
+
 	// Handle mouse clicks
 	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
-		mx, my := ebiten.CursorPosition()
-		tc.handlePress(mx, my)
+		tc.lastActivity = time.Now()
+		if !hidden {
+			mx, my := ebiten.CursorPosition()
+			tc.handlePress(mx, my)
+		}
 	}
 
 	// Handle touch
 	touchIDs := inpututil.AppendJustPressedTouchIDs(nil)
 	for _, id := range touchIDs {
-		tx, ty := ebiten.TouchPosition(id)
-		tc.handlePress(tx, ty)
+		tc.lastActivity = time.Now()
+		if !hidden {
+			tx, ty := ebiten.TouchPosition(id)
+			tc.handlePress(tx, ty)
+		}
+	}
+}
+
+// Wake resets the idle timer so hidden buttons reappear
+func (tc *TouchControls) Wake() {
+	tc.lastActivity = time.Now()
+}
+
+// visibility returns the current fade factor (1 = fully shown, 0 = hidden)
+func (tc *TouchControls) visibility() float64 {
+	if tc.IdleTimeout <= 0 {
+		return 1
+	}
+	idle := time.Since(tc.lastActivity) - tc.IdleTimeout
+	if idle <= 0 {
+		return 1
+	}
+	if idle >= touchFadeDuration {
+		return 0
+	}
+	return 1 - float64(idle)/float64(touchFadeDuration)
+}
+
+// fade scales a color's alpha by the opacity setting and fade factor
+func (tc *TouchControls) fade(c color.RGBA, factor float64) color.RGBA {
+	// Colors are premultiplied, so scale every channel
+	return color.RGBA{
+		R: uint8(float64(c.R) * factor),
+		G: uint8(float64(c.G) * factor),
+		B: uint8(float64(c.B) * factor),
+		A: uint8(float64(c.A) * factor),
 	}
 }
 
@@ -87,6 +150,11 @@ func (tc *TouchControls) handlePress(x, y int) {
 
 // Draw renders all touch buttons
 func (tc *TouchControls) Draw(screen *ebiten.Image) {
+	factor := tc.visibility() * tc.Opacity
+	if factor <= 0 {
+		return
+	}
+
 	for _, btn := range tc.buttons {
 		if !btn.Visible {
 			continue
@@ -97,12 +165,15 @@ func (tc *TouchControls) Draw(screen *ebiten.Image) {
 		if btn.Active {
 			bgColor = tc.actColor
 		}
-		vector.DrawFilledRect(screen, float32(btn.X), float32(btn.Y), float32(btn.W), float32(btn.H), bgColor, true)
+		vector.DrawFilledRect(screen, float32(btn.X), float32(btn.Y), float32(btn.W), float32(btn.H), tc.fade(bgColor, factor), true)
 
 		// Border
-		vector.StrokeRect(screen, float32(btn.X), float32(btn.Y), float32(btn.W), float32(btn.H), 2, tc.txtColor, true)
+		vector.StrokeRect(screen, float32(btn.X), float32(btn.Y), float32(btn.W), float32(btn.H), 2, tc.fade(tc.txtColor, factor), true)
 
-		// Label
+		// Label (debug text can't be tinted, so only draw it while mostly visible)
+		if factor < 0.5 {
+			continue
+		}
 		labelX := btn.X + btn.W/2 - len(btn.Label)*3
 		labelY := btn.Y + btn.H/2 - 6
 		if btn.Icon != "" {
@@ -121,94 +192,108 @@ func (tc *TouchControls) UpdateLayout(screenW, screenH int) {
 	tc.screenW = screenW
 	tc.screenH = screenH
 
-	btnW := 60
-	btnH := 45
-	margin := 5
-	bottomY := screenH - btnH - 30 // Above status bar
+	cellH := tc.btnH + tc.margin
+	bottomInset := 30 // Keep clear of the status bar
 
-	// Position each button by label
 	for _, btn := range tc.buttons {
-		switch btn.Label {
-		case "ZOOM+":
-			btn.X, btn.Y = margin, bottomY-btnH-margin
-		case "ZOOM-":
-			btn.X, btn.Y = margin, bottomY
-		case "FLLW":
-			btn.X, btn.Y = margin+btnW+margin, bottomY
-		case "HOME":
-			btn.X, btn.Y = margin+btnW+margin, bottomY-btnH-margin
-		case "CLR":
-			btn.X, btn.Y = margin+(btnW+margin)*2, bottomY
-		case "HUD":
-			btn.X, btn.Y = margin+(btnW+margin)*2, bottomY-btnH-margin
-		case "LINK":
-			btn.X, btn.Y, btn.W = screenW/2-40, margin, 80
-		case "PORT":
-			btn.X, btn.Y = screenW/2-40-btnW-margin, margin
+		// Horizontal placement: columns are as wide as their widest button,
+		// so a wide one pushes its neighbours along instead of overlapping
+		colW := tc.columnWidths(btn.Anchor, btn.Row)
+		switch btn.Anchor {
+		case "top-left", "left", "bottom-left":
+			btn.X = tc.margin
+			for c := 0; c < btn.Col; c++ {
+				btn.X += colW(c) + tc.margin
+			}
+		case "top-right", "right", "bottom-right":
+			right := screenW - tc.margin
+			for c := 0; c < btn.Col; c++ {
+				right -= colW(c) + tc.margin
+			}
+			btn.X = right - btn.W
+		default: // top, center, bottom: column 0 is centered
+			switch {
+			case btn.Col > 0:
+				btn.X = screenW/2 + colW(0)/2 + tc.margin
+				for c := 1; c < btn.Col; c++ {
+					btn.X += colW(c) + tc.margin
+				}
+			case btn.Col < 0:
+				right := screenW/2 - colW(0)/2 - tc.margin
+				for c := -1; c > btn.Col; c-- {
+					right -= colW(c) + tc.margin
+				}
+				btn.X = right - btn.W
+			default:
+				btn.X = screenW/2 - btn.W/2
+			}
+		}
+
+		// Vertical placement
+		switch btn.Anchor {
+		case "top-left", "top", "top-right":
+			btn.Y = tc.margin + btn.Row*cellH
+		case "bottom-left", "bottom", "bottom-right":
+			btn.Y = screenH - bottomInset - btn.H - btn.Row*cellH
+		default: // left, center, right
+			btn.Y = screenH/2 - btn.H/2 + btn.Row*cellH
 		}
 	}
 }
 
-// SetupDefaultButtons creates the standard control buttons
-func (tc *TouchControls) SetupDefaultButtons(app *App) {
-	// These will be repositioned in UpdateLayout
-	tc.AddButton(0, 0, 60, 45, "ZOOM+", "", func() {
-		if app.zoom < MaxZoom {
-			app.zoom++
+// columnWidths returns the width of each column in an anchor's row: its
+// widest button, or the configured button width for an empty column
+func (tc *TouchControls) columnWidths(anchor string, row int) func(col int) int {
+	widths := make(map[int]int)
+	for _, btn := range tc.buttons {
+		if btn.Anchor == anchor && btn.Row == row {
+			widths[btn.Col] = max(widths[btn.Col], btn.W)
 		}
-	})
-
-	tc.AddButton(0, 0, 60, 45, "ZOOM-", "", func() {
-		if app.zoom > MinZoom {
-			app.zoom--
+	}
+	return func(col int) int {
+		if w, ok := widths[col]; ok {
+			return w
 		}
-	})
+		return tc.btnW
+	}
+}
 
-	tc.AddButton(0, 0, 60, 45, "FLLW", "", func() {
-		app.followAircraft = !app.followAircraft
-	})
+// SetupButtons replaces the current buttons with the configured layout
+func (tc *TouchControls) SetupButtons(app *App, cfg TouchConfig) {
+	tc.buttons = tc.buttons[:0]
+	tc.btnW, tc.btnH, tc.margin = cfg.ButtonWidth, cfg.ButtonHeight, cfg.Margin
+	tc.screenW, tc.screenH = 0, 0 // Force relayout
 
-	tc.AddButton(0, 0, 60, 45, "HOME", "", func() {
-		state := app.client.GetState()
-		if state.HasGPS {
-			app.homeLat = float64(state.Latitude)
-			app.homeLon = float64(state.Longitude)
-			app.homeSet = true
+	for _, bc := range cfg.Buttons {
+		if _, ok := app.actions[bc.Action]; !ok {
+			logApp.Warnf("Touch button %q: unknown action %q", bc.Label, bc.Action)
+			continue
 		}
-	})
-
-	tc.AddButton(0, 0, 60, 45, "CLR", "", func() {
-		app.flightPath = nil
-	})
-
-	tc.AddButton(0, 0, 60, 45, "HUD", "", func() {
-		app.hudMode = (app.hudMode + 1) % 3
-	})
-
-	tc.AddButton(0, 0, 80, 45, "LINK", "", func() {
-		if app.client.IsLinkStarted() {
-			app.client.StopLink()
-		} else if len(app.ports) > 0 && app.selectedPort < len(app.ports) {
-			app.client.StartLink(app.ports[app.selectedPort], 420000)
+		w, h := bc.Width, bc.Height
+		if w <= 0 {
+			w = cfg.ButtonWidth
 		}
-	})
-
-	tc.AddButton(0, 0, 60, 45, "PORT", "", func() {
-		if len(app.ports) > 0 {
-			app.selectedPort = (app.selectedPort + 1) % len(app.ports)
+		if h <= 0 {
+			h = cfg.ButtonHeight
 		}
-	})
+		action := bc.Action
+		// Through runAction, so plugins hear about touch presses too
+		btn := tc.AddButton(0, 0, w, h, bc.Label, "", func() { app.runAction(action) })
+		btn.Action = bc.Action
+		btn.Anchor = bc.Anchor
+		btn.Col, btn.Row = bc.Col, bc.Row
+	}
 }
 
 // UpdateButtonStates updates active states based on app state
 func (tc *TouchControls) UpdateButtonStates(app *App) {
 	for _, btn := range tc.buttons {
-		switch btn.Label {
-		case "FLLW":
+		switch btn.Action {
+		case "follow":
 			btn.Active = app.followAircraft
-		case "HUD":
+		case "hud":
 			btn.Active = app.hudMode > 0
-		case "LINK":
+		case "link":
 			btn.Active = app.client.IsLinkStarted()
 		}
 	}
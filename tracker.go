@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// Servo pulse limits and frame period for the tracker outputs
+const (
+	servoPeriodNs  = 20000000 // 50 Hz
+	servoMinPulse  = 500      // Microseconds
+	servoMaxPulse  = 2500
+	trackerMinDist = 10.0 // Meters; closer than this the bearing is just GPS noise
+)
+
+// TrackerTest is a fixed pose used while calibrating the tracker
+type TrackerTest int
+
+const (
+	TrackerTestOff TrackerTest = iota // Follow the aircraft
+	TrackerTestCenter
+	TrackerTestLeft
+	TrackerTestRight
+	TrackerTestUp
+)
+
+var trackerTestNames = []string{"off", "center", "left 45", "right 45", "up 45"}
+
+// String returns the menu name of the test pose
+func (t TrackerTest) String() string {
+	return trackerTestNames[t]
+}
+
+// Tracker points a pan/tilt antenna tracker at the aircraft from the home
+// position using hobby servos on the Pi's hardware PWM outputs
+type Tracker struct {
+	config *TrackerConfig // Shared with the settings menu, so trims apply live
+	Test   TrackerTest
+
+	pan, tilt *pwmChannel
+	running   bool
+
+	// Last computed pointing, for the calibration screen
+	bearing, elevation float64
+	pointing           bool
+}
+
+// NewTracker creates a tracker using the given calibration
+func NewTracker(cfg *TrackerConfig) *Tracker {
+	return &Tracker{config: cfg}
+}
+
+// Start opens the PWM outputs if the tracker is enabled
+func (t *Tracker) Start() error {
+	if !t.config.Enabled || t.running {
+		return nil
+	}
+	if runtime.GOOS != "linux" {
+		logTracker.Infof("PWM is only available on Linux - antenna tracker disabled")
+		return nil
+	}
+	chip := fmt.Sprintf("/sys/class/pwm/pwmchip%d", t.config.PWMChip)
+	if _, err := os.Stat(chip); os.IsNotExist(err) {
+		logTracker.Infof("PWM not available (%s missing) - antenna tracker disabled", chip)
+		return nil
+	}
+
+	pan, err := openPWM(t.config.PWMChip, t.config.PanChannel, t.config.PanCenter)
+	if err != nil {
+		return fmt.Errorf("pan channel %d: %w", t.config.PanChannel, err)
+	}
+	tilt, err := openPWM(t.config.PWMChip, t.config.TiltChannel, t.config.TiltLevel)
+	if err != nil {
+		pan.Close()
+		return fmt.Errorf("tilt channel %d: %w", t.config.TiltChannel, err)
+	}
+	t.pan, t.tilt = pan, tilt
+	t.running = true
+	logTracker.Infof("Antenna tracker started on pwmchip%d (pan %d, tilt %d)", t.config.PWMChip, t.config.PanChannel, t.config.TiltChannel)
+	return nil
+}
+
+// Stop releases the PWM outputs; the servos go limp
+func (t *Tracker) Stop() {
+	if !t.running {
+		return
+	}
+	t.running = false
+	t.pan.Close()
+	t.tilt.Close()
+}
+
+// IsRunning returns true while the servos are driven
+func (t *Tracker) IsRunning() bool {
+	return t.running
+}
+
+// Pointing returns the last bearing and elevation from home to the
+// aircraft, or ok=false when there is nothing to track
+func (t *Tracker) Pointing() (bearing, elevation float64, ok bool) {
+	return t.bearing, t.elevation, t.pointing
+}
+
+// Update aims the tracker at the aircraft. Without a fix or home, or when
+// the aircraft is on top of home, the servos hold their last position.
+func (t *Tracker) Update(state TelemetryState, homeLat, homeLon float64, homeSet bool) {
+	lat, lon := float64(state.Latitude), float64(state.Longitude)
+	t.pointing = homeSet && state.HasGPS && (lat != 0 || lon != 0)
+	if t.pointing {
+		dist := DistanceMeters(homeLat, homeLon, lat, lon)
+		if dist < trackerMinDist {
+			t.pointing = false
+		} else {
+			t.bearing = BearingDegrees(homeLat, homeLon, lat, lon)
+			t.elevation = math.Atan2(relativeAltitude(state), dist) * 180 / math.Pi
+		}
+	}
+	if !t.running {
+		return
+	}
+
+	var pan, tilt float64 // Degrees from the tracker's center and level
+	switch t.Test {
+	case TrackerTestOff:
+		if !t.pointing {
+			return
+		}
+		pan, tilt = t.angles(t.bearing, t.elevation)
+	case TrackerTestLeft:
+		pan = -45
+	case TrackerTestRight:
+		pan = 45
+	case TrackerTestUp:
+		tilt = 45
+	}
+
+	cfg := t.config
+	t.pan.SetPulse(servoPulse(float64(cfg.PanCenter) + pan*cfg.PanUsPerDeg))
+	t.tilt.SetPulse(servoPulse(float64(cfg.TiltLevel) + tilt*cfg.TiltUsPerDeg))
+}
+
+// angles converts a bearing and elevation into pan and tilt servo angles.
+// Trackers with 180 degrees of tilt flip over to reach behind the pan range.
+func (t *Tracker) angles(bearing, elevation float64) (pan, tilt float64) {
+	cfg := t.config
+	pan = math.Mod(bearing-cfg.Heading+540, 360) - 180 // -180..180 from center
+	tilt = math.Max(0, elevation)
+
+	if math.Abs(pan) > cfg.PanRange && cfg.TiltMax >= 180 {
+		if pan > 0 {
+			pan -= 180
+		} else {
+			pan += 180
+		}
+		tilt = 180 - tilt
+	}
+	pan = math.Max(-cfg.PanRange, math.Min(cfg.PanRange, pan))
+	tilt = math.Min(cfg.TiltMax, tilt)
+	return pan, tilt
+}
+
+// relativeAltitude returns the aircraft height above home, from the source
+// altitude.source picks. Without one the flight controller's baro altitude
+// is zeroed at arming, so prefer it over GPS.
+func relativeAltitude(state TelemetryState) float64 {
+	if state.RefSource != "" {
+		return float64(state.RefAltitude)
+	}
+	if state.BaroAltitude != 0 {
+		return float64(state.BaroAltitude)
+	}
+	return float64(state.Altitude)
+}
+
+// servoPulse rounds and clamps a pulse width to the safe servo range
+func servoPulse(us float64) int {
+	return int(math.Round(math.Max(servoMinPulse, math.Min(servoMaxPulse, us))))
+}
+
+// pwmChannel is one sysfs PWM output, e.g. GPIO 18/19 with dtoverlay=pwm-2chan
+type pwmChannel struct {
+	chip, channel int
+	path          string
+	lastPulse     int
+}
+
+// openPWM exports a PWM channel and starts it at the given pulse width
+func openPWM(chip, channel, pulse int) (*pwmChannel, error) {
+	chipPath := fmt.Sprintf("/sys/class/pwm/pwmchip%d", chip)
+	p := &pwmChannel{
+		chip:    chip,
+		channel: channel,
+		path:    fmt.Sprintf("%s/pwm%d", chipPath, channel),
+	}
+	if _, err := os.Stat(p.path); os.IsNotExist(err) {
+		if err := os.WriteFile(chipPath+"/export", []byte(strconv.Itoa(channel)), 0644); err != nil {
+			return nil, err
+		}
+		// Wait for sysfs to create the channel directory
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if err := p.write("period", servoPeriodNs); err != nil {
+		return nil, err
+	}
+	if err := p.SetPulse(pulse); err != nil {
+		return nil, err
+	}
+	if err := p.write("enable", 1); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SetPulse sets the pulse width in microseconds, skipping unchanged writes
+func (p *pwmChannel) SetPulse(us int) error {
+	if us == p.lastPulse {
+		return nil
+	}
+	if err := p.write("duty_cycle", us*1000); err != nil {
+		return err
+	}
+	p.lastPulse = us
+	return nil
+}
+
+// Close disables and unexports the channel
+func (p *pwmChannel) Close() {
+	p.write("enable", 0)
+	os.WriteFile(fmt.Sprintf("/sys/class/pwm/pwmchip%d/unexport", p.chip), []byte(strconv.Itoa(p.channel)), 0644)
+}
+
+func (p *pwmChannel) write(attr string, value int) error {
+	return os.WriteFile(p.path+"/"+attr, []byte(strconv.Itoa(value)), 0644)
+}
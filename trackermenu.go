@@ -0,0 +1,119 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// newTrackerMenu builds the antenna tracker calibration screen. Trims apply
+// live, and the test poses hold the servos still while they are adjusted.
+func (a *App) newTrackerMenu() *Menu {
+	m := NewMenu("Antenna Tracker")
+	cfg := &a.config.Tracker
+	t := a.tracker
+	m.OnClose = func() {
+		t.Test = TrackerTestOff
+		a.saveConfig()
+	}
+
+	us := func(v int) string { return fmt.Sprintf("%dus", v) }
+	m.Items = []MenuItem{
+		{
+			Label: "Enabled",
+			Value: func() string {
+				if cfg.Enabled && !t.IsRunning() {
+					return "no PWM"
+				}
+				return onOff(cfg.Enabled)
+			},
+			OnAdjust: func(int) {
+				cfg.Enabled = !cfg.Enabled
+				if !cfg.Enabled {
+					t.Stop()
+				} else if err := t.Start(); err != nil {
+					logTracker.Errorf("Could not start tracker: %v", err)
+				}
+			},
+		},
+		{
+			Label: "Pointing",
+			Value: func() string {
+				bearing, elevation, ok := t.Pointing()
+				if !ok {
+					return "---"
+				}
+				return fmt.Sprintf("%03.0f° / %.0f°", bearing, elevation)
+			},
+		},
+		{
+			Label: "Test pose",
+			Value: func() string { return t.Test.String() },
+			OnAdjust: func(d int) {
+				t.Test = trackerTestByName(cycle(trackerTestNames, t.Test.String(), d))
+			},
+		},
+		{
+			Label: "Facing",
+			Value: func() string { return fmt.Sprintf("%03.0f°", cfg.Heading) },
+			OnAdjust: func(d int) {
+				cfg.Heading = math.Mod(cfg.Heading+5*float64(d)+360, 360)
+			},
+		},
+		{
+			Label:    "Pan center",
+			Value:    func() string { return us(cfg.PanCenter) },
+			OnAdjust: func(d int) { cfg.PanCenter = clampInt(cfg.PanCenter+10*d, servoMinPulse, servoMaxPulse) },
+		},
+		{
+			Label: "Pan us/deg",
+			Value: func() string { return fmt.Sprintf("%.1f", cfg.PanUsPerDeg) },
+			OnAdjust: func(d int) {
+				cfg.PanUsPerDeg = math.Round((cfg.PanUsPerDeg+0.1*float64(d))*10) / 10
+			},
+		},
+		{
+			Label: "Pan range",
+			Value: func() string { return fmt.Sprintf("+/-%.0f°", cfg.PanRange) },
+			OnAdjust: func(d int) {
+				cfg.PanRange = float64(clampInt(int(cfg.PanRange)+10*d, 10, 180))
+			},
+		},
+		{
+			Label:    "Tilt level",
+			Value:    func() string { return us(cfg.TiltLevel) },
+			OnAdjust: func(d int) { cfg.TiltLevel = clampInt(cfg.TiltLevel+10*d, servoMinPulse, servoMaxPulse) },
+		},
+		{
+			Label: "Tilt us/deg",
+			Value: func() string { return fmt.Sprintf("%.1f", cfg.TiltUsPerDeg) },
+			OnAdjust: func(d int) {
+				cfg.TiltUsPerDeg = math.Round((cfg.TiltUsPerDeg+0.1*float64(d))*10) / 10
+			},
+		},
+		{
+			Label: "Tilt travel",
+			Value: func() string { return fmt.Sprintf("%.0f°", cfg.TiltMax) },
+			OnAdjust: func(int) {
+				if cfg.TiltMax >= 180 {
+					cfg.TiltMax = 90
+				} else {
+					cfg.TiltMax = 180
+				}
+			},
+		},
+		{Label: "Save & close", OnSelect: m.Close},
+	}
+	return m
+}
+
+// trackerTestByName returns the test pose with the given menu name
+func trackerTestByName(name string) TrackerTest {
+	for i, n := range trackerTestNames {
+		if n == name {
+			return TrackerTest(i)
+		}
+	}
+	return TrackerTestOff
+}
@@ -0,0 +1,165 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"path/filepath"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	// clickSlop is how far, in pixels, the mouse may move between press
+	// and release for a click rather than a drag
+	clickSlop = 3
+
+	// inspectRadius is how close to the trail, in pixels, a click picks a point
+	inspectRadius = 12
+
+	// inspectMaxGap is how far from a point's time the nearest recorded row
+	// may be, so a point flown while recording was off doesn't show another's
+	inspectMaxGap = 5 * time.Second
+)
+
+// trackInspection is the trail point clicked on and the telemetry recorded
+// nearest to it
+type trackInspection struct {
+	point    pathPoint
+	lifted   bool   // Drawn where the 2.5D path puts it
+	source   string // The log the values came from
+	sample   ReplaySample
+	recorded bool // sample holds the recorded values, not just the point's
+}
+
+// inspectAt shows the telemetry of the trail point under a click at x, y,
+// on the live trail or the past flight under it, or closes the popup
+func (a *App) inspectAt(x, y int) {
+	v := a.currentMapView()
+	if x < v.X || x >= v.X+v.Width {
+		return
+	}
+	best := float64(inspectRadius * inspectRadius)
+	var hit *trackInspection
+	for _, p := range a.flightPath {
+		px, py := a.pathScreenPos(p, a.config.Map.Path3D, v)
+		if d := (px-float64(x))*(px-float64(x)) + (py-float64(y))*(py-float64(y)); d <= best {
+			best, hit = d, &trackInspection{point: p, lifted: a.config.Map.Path3D}
+		}
+	}
+	live := hit != nil
+	for _, t := range a.compareTrack {
+		p := pathPoint{lat: t.Lat, lon: t.Lon, alt: t.Alt}
+		px, py := a.pathScreenPos(p, false, v)
+		if d := (px-float64(x))*(px-float64(x)) + (py-float64(y))*(py-float64(y)); d < best {
+			best, hit, live = d, &trackInspection{point: p}, false
+		}
+	}
+	a.inspection = hit
+	if hit == nil {
+		return
+	}
+	if live {
+		a.inspectLive(hit)
+	} else {
+		a.inspectCompare(hit)
+	}
+}
+
+// inspectLive looks the point up by time in the log being recorded then
+func (a *App) inspectLive(in *trackInspection) {
+	path := ""
+	if !in.point.at.IsZero() {
+		path = RecordingAt(a.config.Record.Dir, in.point.at)
+	}
+	if path == "" {
+		a.notify(logRec, LevelWarn, "No flight recording has this point; turn recording on to inspect the trail")
+		return
+	}
+	samples, err := LoadReplay(path, DefaultCRSFFrameGap)
+	if err != nil {
+		a.notify(logRec, LevelWarn, "Could not read the flight recording: %v", err)
+		return
+	}
+	s := replayAt(samples, in.point.at)
+	if gap := in.point.at.Sub(s.At); gap < -inspectMaxGap || gap > inspectMaxGap {
+		a.notify(logRec, LevelWarn, "%s has no telemetry from then", filepath.Base(path))
+		return
+	}
+	in.source, in.sample, in.recorded = filepath.Base(path), s, true
+}
+
+// inspectCompare looks the point up by position in the past flight's log
+func (a *App) inspectCompare(in *trackInspection) {
+	path := a.config.Map.CompareTrack
+	samples, err := LoadReplay(path, DefaultCRSFFrameGap)
+	if err != nil {
+		a.notify(logApp, LevelWarn, "Could not read the comparison track: %v", err)
+		return
+	}
+	best := -1.0
+	for _, s := range samples {
+		if !s.State.HasGPS {
+			continue
+		}
+		d := DistanceMeters(in.point.lat, in.point.lon, float64(s.State.Latitude), float64(s.State.Longitude))
+		if best < 0 || d < best {
+			best, in.sample = d, s
+		}
+	}
+	in.source, in.recorded = filepath.Base(path), best >= 0
+}
+
+// drawInspectionWithOffset marks the clicked trail point and lists its
+// recorded telemetry beside it
+func (a *App) drawInspectionWithOffset(screen *ebiten.Image, v MapView) {
+	in := a.inspection
+	if in == nil {
+		return
+	}
+	x, y := a.pathScreenPos(in.point, in.lifted && a.config.Map.Path3D, v)
+	sx, sy := float32(x), float32(y)
+	if x < float64(v.X) || x >= float64(v.X+v.Width) {
+		return
+	}
+	vector.DrawFilledCircle(screen, sx, sy, 4, color.RGBA{255, 255, 255, 255}, true)
+	vector.StrokeCircle(screen, sx, sy, 7, 2, color.RGBA{0, 0, 0, 200}, true)
+
+	units := a.config.Display.Units
+	var lines []string
+	if in.recorded {
+		s := in.sample.State
+		lines = []string{
+			in.sample.At.Local().Format("15:04:05") + "  " + in.source,
+			fmt.Sprintf("Alt   %.0f %s", units.Altitude(relativeAltitude(s)), units.AltitudeLabel()),
+			fmt.Sprintf("Speed %.0f %s", units.Speed(float64(s.GroundSpeed)), units.SpeedLabel()),
+			fmt.Sprintf("LQ    %d%%", s.LinkQuality),
+			fmt.Sprintf("Volt  %.2f V", s.Voltage),
+		}
+	} else {
+		// Only what the trail itself keeps
+		if !in.point.at.IsZero() {
+			lines = append(lines, in.point.at.Local().Format("15:04:05")+"  not recorded")
+		}
+		lines = append(lines, fmt.Sprintf("Alt   %.0f %s", units.Altitude(in.point.alt), units.AltitudeLabel()))
+	}
+
+	w := 0
+	for _, l := range lines {
+		w = max(w, len([]rune(l))*6+8)
+	}
+	// Beside the point, flipped left near the map's right edge
+	bx := int(sx) + 12
+	if bx+w > v.X+v.Width {
+		bx = int(sx) - 12 - w
+	}
+	by := max(0, min(int(sy)-8*len(lines), a.height-24-16*len(lines)))
+	vector.DrawFilledRect(screen, float32(bx), float32(by), float32(w), float32(16*len(lines)), color.RGBA{0, 0, 0, 200}, false)
+	for i, l := range lines {
+		ebitenutil.DebugPrintAt(screen, l, bx+4, by+16*i)
+	}
+}
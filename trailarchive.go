@@ -0,0 +1,119 @@
+//go:build !headless
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// clearOnArmModes are the map.clear_on_arm settings
+var clearOnArmModes = []string{"off", "clear", "archive"}
+
+// trailArchiveLayout names the file a session's archived trails go to
+const trailArchiveLayout = "trails-20060102-150405.gpx"
+
+// newFlight reports that a flight just began: an arm where the flight mode
+// shows arming, a takeoff otherwise
+func (a *App) newFlight(state TelemetryState) bool {
+	armed := a.armWatch.Update(state.FlightMode)
+	takeoff := false
+	if flying, start := a.logbook.Flying(); flying && !start.Equal(a.flightStart) {
+		a.flightStart = start
+		takeoff = true
+	}
+	if a.armWatch.Reports() {
+		return armed
+	}
+	return takeoff
+}
+
+// startFlight moves the trail on to a new flight, in its own color, first
+// clearing the last one's trail or archiving it to the session's GPX file
+// if map.clear_on_arm says so
+func (a *App) startFlight() {
+	if !a.flightSeen {
+		// What came before the first flight is setup, not worth keeping
+		a.flightSeen = true
+		if a.config.Map.ClearOnArm != "off" {
+			a.flightPath = nil
+		}
+		return
+	}
+	switch a.config.Map.ClearOnArm {
+	case "clear":
+		a.flightPath = nil
+	case "archive":
+		if len(a.flightPath) > 1 {
+			path, err := a.archiveTrail(a.flightPath)
+			if err != nil {
+				a.notify(logRec, LevelError, "Archiving the trail: %v", err)
+				break
+			}
+			a.notify(logRec, LevelInfo, "Flight %d's trail archived to %s", a.flightNum, filepath.Base(path))
+		}
+		a.flightPath = nil
+	}
+	a.flightNum++
+}
+
+// gpxTrack is a GPX track, one per flight
+type gpxTrack struct {
+	Name   string          `xml:"name"`
+	Points []gpxTrackPoint `xml:"trkseg>trkpt"`
+}
+
+type gpxTrackPoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Time string  `xml:"time,omitempty"`
+}
+
+// archiveTrail adds a trail to the session's GPX file in the record folder,
+// a track per flight it holds, and returns the file's path
+func (a *App) archiveTrail(trail []pathPoint) (string, error) {
+	if a.trailArchive == "" {
+		a.trailArchive = filepath.Join(a.config.Record.Dir, time.Now().Format(trailArchiveLayout))
+	}
+	for _, p := range trail {
+		name := fmt.Sprintf("Flight %d", p.flight)
+		n := len(a.archivedTracks)
+		if n == 0 || a.archivedTracks[n-1].Name != name {
+			a.archivedTracks = append(a.archivedTracks, gpxTrack{Name: name})
+			n++
+		}
+		t := &a.archivedTracks[n-1]
+		t.Points = append(t.Points, gpxTrackPoint{Lat: p.lat, Lon: p.lon, Time: formatGPXTime(p.at)})
+	}
+
+	doc := struct {
+		XMLName xml.Name   `xml:"gpx"`
+		Version string     `xml:"version,attr"`
+		Creator string     `xml:"creator,attr"`
+		NS      string     `xml:"xmlns,attr"`
+		Tracks  []gpxTrack `xml:"trk"`
+	}{Version: "1.1", Creator: "elrs-map", NS: "http://www.topografix.com/GPX/1/1", Tracks: a.archivedTracks}
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(a.config.Record.Dir, 0755); err != nil {
+		return "", err
+	}
+	// The whole session is rewritten each time, so a crash loses no flight
+	tmp := a.trailArchive + ".tmp"
+	if err := os.WriteFile(tmp, append([]byte(xml.Header), data...), 0644); err != nil {
+		return "", err
+	}
+	return a.trailArchive, os.Rename(tmp, a.trailArchive)
+}
+
+func formatGPXTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,51 @@
+package main
+
+import "time"
+
+// txPowerWarnEvery keeps a TX power bouncing off its top from repeating
+// the warning
+const txPowerWarnEvery = 30 * time.Second
+
+// TXPowerTracker tells when dynamic power has ramped the transmitter to its
+// top power, the sign the link is running out of margin while LQ may still
+// read 100%
+type TXPowerTracker struct {
+	lowest, highest uint32 // mW seen this session
+	atMax           bool
+	warned          time.Time
+}
+
+// Update takes the current TX power in mW and reports whether it is at the
+// top: limit when set, else the highest seen this session. A power that has
+// never moved is not flagged, as dynamic power must be off.
+func (t *TXPowerTracker) Update(power, limit uint32) bool {
+	if power == 0 {
+		t.atMax = false
+		return false
+	}
+	if t.lowest == 0 || power < t.lowest {
+		t.lowest = power
+	}
+	t.highest = max(t.highest, power)
+	if limit > 0 {
+		t.atMax = power >= limit
+	} else {
+		t.atMax = power >= t.highest && t.highest > t.lowest
+	}
+	return t.atMax
+}
+
+// AtMax tells whether the last update was at the top power
+func (t *TXPowerTracker) AtMax() bool {
+	return t.atMax
+}
+
+// Warn reports whether to warn of being at the top power now, at most
+// every txPowerWarnEvery
+func (t *TXPowerTracker) Warn(now time.Time) bool {
+	if !t.atMax || now.Sub(t.warned) < txPowerWarnEvery {
+		return false
+	}
+	t.warned = now
+	return true
+}
@@ -0,0 +1,81 @@
+//go:build !headless
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// runUI opens the map window and runs until it is closed
+func runUI(client *GRPCClient, cfg *Config, configPath string, opts uiOptions) {
+	logApp.Infof("Default location: %.4f, %.4f", cfg.Map.DefaultLat, cfg.Map.DefaultLon)
+
+	// Create tile cache directory
+	if err := os.MkdirAll(cfg.CacheDir, 0755); err != nil {
+		logTile.Fatalf("Failed to create cache directory: %v", err)
+	}
+
+	// Initialize components
+	tileManager := NewTileManager(cfg.CacheDir, cfg.Tiles)
+	app := NewApp(client, tileManager, cfg, cfg.Window.Width, cfg.Window.Height, cfg.Window.Fullscreen)
+	app.configPath = configPath
+	if opts.hud != "" {
+		mode, ok := hudModeNames[opts.hud]
+		if !ok {
+			logApp.Fatalf("Unknown -hud %q (map, osd, panel, split, big)", opts.hud)
+		}
+		app.hudMode = mode
+	}
+	if opts.secondScreen {
+		// The first screen saves the settings, logs the flights and keeps
+		// the crash snapshot
+		app.secondScreen = true
+		app.configPath = ""
+		app.logbook = &Logbook{}
+		app.restoreMenu = nil
+	} else if _, err := os.Stat(configPath); errors.Is(err, os.ErrNotExist) {
+		// Without a config file this is the first run: walk through setup
+		app.openWizard()
+	}
+	if opts.configErr != nil {
+		// Saving now would replace the user's file with the defaults, so
+		// leave it as it is to be fixed
+		app.configPath = ""
+		app.toasts.Add(LevelError, "Config not loaded, settings won't be saved: %v", opts.configErr)
+	}
+
+	// Signals stop the game loop like Q does, so cleanup runs on one path
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		app.RequestQuit()
+	}()
+
+	// Run the application; the log is closed by main once this returns
+	err := app.Run()
+	app.Shutdown()
+	if err != nil {
+		logApp.Fatalf("Application error: %v", err)
+	}
+}
+
+// selectMonitor puts the window on the n'th display, from 1, when there
+// is one; 0 leaves it on the primary
+func selectMonitor(n int) {
+	if n <= 0 {
+		return
+	}
+	monitors := ebiten.AppendMonitors(nil)
+	if n > len(monitors) {
+		logApp.Warnf("No display %d (found %d), using the primary", n, len(monitors))
+		return
+	}
+	ebiten.SetMonitor(monitors[n-1])
+	logApp.Infof("On display %d (%s)", n, monitors[n-1].Name())
+}
@@ -0,0 +1,15 @@
+//go:build headless
+
+package main
+
+// runUI falls back to headless mode in builds without the Ebiten UI
+// (go build -tags headless), which need no display or X11 libraries
+func runUI(client *GRPCClient, cfg *Config, configPath string, _ uiOptions) {
+	logApp.Infof("Built without the UI")
+	runHeadless(client, cfg)
+}
+
+// runVideoCommand needs the UI to draw the map and OSD
+func runVideoCommand(args []string) {
+	logVideo.Fatalf("Built without the UI, which video export draws with")
+}
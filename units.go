@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Units selects metric or imperial display
+type Units string
+
+const (
+	UnitsMetric   Units = "metric"
+	UnitsImperial Units = "imperial"
+)
+
+// Speed converts km/h to the display unit
+func (u Units) Speed(kmh float64) float64 {
+	if u == UnitsImperial {
+		return kmh * 0.621371
+	}
+	return kmh
+}
+
+// SpeedLabel returns the speed unit suffix
+func (u Units) SpeedLabel() string {
+	if u == UnitsImperial {
+		return "mph"
+	}
+	return "km/h"
+}
+
+// Altitude converts meters to the display unit
+func (u Units) Altitude(m float64) float64 {
+	if u == UnitsImperial {
+		return m * 3.28084
+	}
+	return m
+}
+
+// AltitudeLabel returns the altitude unit suffix
+func (u Units) AltitudeLabel() string {
+	if u == UnitsImperial {
+		return "ft"
+	}
+	return "m"
+}
+
+// FormatDistance formats a distance in meters, switching to km/mi when far
+func (u Units) FormatDistance(m float64) string {
+	if u == UnitsImperial {
+		ft := m * 3.28084
+		if ft >= 5280 {
+			return fmt.Sprintf("%.1fmi", ft/5280)
+		}
+		return fmt.Sprintf("%.0fft", ft)
+	}
+	if m >= 1000 {
+		return fmt.Sprintf("%.1fkm", m/1000)
+	}
+	return fmt.Sprintf("%.0fm", m)
+}
+
+// FormatXTE formats a cross-track error, e.g. "R 35m"
+func (u Units) FormatXTE(m float64) string {
+	side := "R"
+	if m < 0 {
+		side = "L"
+	}
+	return side + " " + u.FormatDistance(math.Abs(m))
+}
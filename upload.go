@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// uploadSettle is how long a file must go unchanged to count as
+	// finished, so the live log and a trail archive being rewritten wait
+	uploadSettle = 2 * time.Minute
+
+	// uploadStateName keeps what was uploaded, in the record folder
+	uploadStateName = ".uploaded.json"
+)
+
+// uploadedFile is a file as it was when uploaded; a file that has changed
+// since goes up again
+type uploadedFile struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Uploader sends finished telemetry logs and trail archives from the record
+// folder to a WebDAV folder, an S3 bucket or an HTTP endpoint, so flights
+// archive themselves once the ground station is back on the internet. It
+// simply tries every upload.interval; a failed try means no connection yet.
+type Uploader struct {
+	config *UploadConfig
+	record *RecordConfig
+	client *http.Client
+
+	// Active returns the log being recorded, never uploaded while open
+	Active func() string
+
+	failing  bool // Log repeated failures quietly
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewUploader creates an uploader for the logs in record.dir
+func NewUploader(cfg *UploadConfig, record *RecordConfig) *Uploader {
+	return &Uploader{
+		config:   cfg,
+		record:   record,
+		client:   &http.Client{Timeout: 2 * time.Minute},
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start uploads in the background until Stop
+func (u *Uploader) Start() {
+	go u.run()
+}
+
+// Stop ends the background uploads
+func (u *Uploader) Stop() {
+	u.stopOnce.Do(func() { close(u.stopChan) })
+}
+
+func (u *Uploader) run() {
+	interval := u.config.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		u.uploadPending()
+		select {
+		case <-u.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pending returns the finished files that haven't been uploaded as they
+// are now, oldest first
+func (u *Uploader) pending(state map[string]uploadedFile) []string {
+	names := recordings(u.record.Dir)
+	trails, _ := filepath.Glob(filepath.Join(u.record.Dir, "trails-*.gpx"))
+	names = append(names, trails...)
+	sort.Strings(names)
+
+	active := ""
+	if u.Active != nil {
+		active = u.Active()
+	}
+	var files []string
+	for _, name := range names {
+		info, err := os.Stat(name)
+		if err != nil || name == active || time.Since(info.ModTime()) < uploadSettle {
+			continue
+		}
+		done, ok := state[filepath.Base(name)]
+		if ok && done.Size == info.Size() && done.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+		files = append(files, name)
+	}
+	return files
+}
+
+// uploadPending uploads what is pending, stopping at the first failure
+func (u *Uploader) uploadPending() {
+	statePath := filepath.Join(u.record.Dir, uploadStateName)
+	state := map[string]uploadedFile{}
+	if data, err := os.ReadFile(statePath); err == nil {
+		json.Unmarshal(data, &state)
+	}
+
+	files := u.pending(state)
+	uploaded := 0
+	for _, name := range files {
+		info, err := os.Stat(name)
+		if err != nil {
+			continue
+		}
+		if err := u.upload(name); err != nil {
+			if !u.failing {
+				logRec.Warnf("Upload of %s failed, retrying every %s: %v", filepath.Base(name), u.config.Interval, err)
+			}
+			u.failing = true
+			break
+		}
+		u.failing = false
+		state[filepath.Base(name)] = uploadedFile{Size: info.Size(), ModTime: info.ModTime()}
+		uploaded++
+		// Saved after each file, so a cut connection doesn't repeat them all
+		if data, err := json.MarshalIndent(state, "", "  "); err == nil {
+			os.WriteFile(statePath, data, 0644)
+		}
+	}
+	if uploaded > 0 {
+		logRec.Infof("Uploaded %d of %d finished logs to %s", uploaded, len(files), u.config.URL)
+	}
+}
+
+// upload sends one file the configured way
+func (u *Uploader) upload(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(path)
+
+	var req *http.Request
+	switch u.config.Kind {
+	case "s3":
+		req, err = u.s3Request(name, data, time.Now())
+	case "http":
+		req, err = u.postRequest(name, data)
+	default:
+		req, err = http.NewRequest(http.MethodPut, strings.TrimSuffix(u.config.URL, "/")+"/"+url.PathEscape(name), bytes.NewReader(data))
+		if err == nil && u.config.Username != "" {
+			req.SetBasicAuth(u.config.Username, u.config.Password)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: HTTP %d", name, resp.StatusCode)
+	}
+	return nil
+}
+
+// postRequest sends the file as the "file" field of a multipart form
+func (u *Uploader) postRequest(name string, data []byte) (*http.Request, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		return nil, err
+	}
+	part.Write(data)
+	form.Close()
+
+	req, err := http.NewRequest(http.MethodPost, u.config.URL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if u.config.Username != "" {
+		req.SetBasicAuth(u.config.Username, u.config.Password)
+	}
+	return req, nil
+}
+
+// s3Request builds a PUT of the file into the bucket, path style, signed
+// with AWS Signature Version 4. The URL is the service endpoint, e.g.
+// https://s3.eu-west-1.amazonaws.com or a MinIO server.
+func (u *Uploader) s3Request(name string, data []byte, now time.Time) (*http.Request, error) {
+	key := strings.Trim(u.config.Prefix, "/")
+	if key != "" {
+		key += "/"
+	}
+	path := "/" + u.config.Bucket + "/" + key + name
+	req, err := http.NewRequest(http.MethodPut, strings.TrimSuffix(u.config.URL, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	t := now.UTC()
+	amzDate, day := t.Format("20060102T150405Z"), t.Format("20060102")
+	payload := sha256.Sum256(data)
+	payloadHash := hex.EncodeToString(payload[:])
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		http.MethodPut,
+		req.URL.EscapedPath(),
+		"", // No query
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	scope := day + "/" + u.config.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	mac := func(key []byte, msg string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(msg))
+		return h.Sum(nil)
+	}
+	signingKey := mac(mac(mac(mac([]byte("AWS4"+u.config.SecretKey), day), u.config.Region), "s3"), "aws4_request")
+	signature := hex.EncodeToString(mac(signingKey, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		u.config.AccessKey, scope, signedHeaders, signature))
+	return req, nil
+}
@@ -0,0 +1,215 @@
+//go:build !headless
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// videoTileWait is the longest a frame waits for its map tiles to load
+const videoTileWait = 5 * time.Second
+
+// VideoExport replays a telemetry log through the app and pipes each frame
+// to ffmpeg. The flight clock advances by a fixed step per frame rather
+// than in real time, so the video plays at the chosen speed however long a
+// frame takes to draw.
+type VideoExport struct {
+	app     *App
+	samples []ReplaySample
+	width   int
+	height  int
+
+	clock    time.Time     // Flight time of the frame being drawn
+	step     time.Duration // Flight time per frame
+	end      time.Time
+	frames   int
+	total    int
+	waitFrom time.Time // When this frame started waiting for tiles
+
+	frame  *ebiten.Image
+	pixels []byte
+	ffmpeg *exec.Cmd
+	pipe   io.WriteCloser
+	done   bool
+	err    error
+}
+
+// runVideoCommand handles "elrs-map video log.csv": renders a recorded
+// flight (a telemetry log or raw CRSF dump) with the map and HUD into an MP4
+func runVideoCommand(args []string) {
+	fs := flag.NewFlagSet("video", flag.ExitOnError)
+	configPath := fs.String("config", DefaultConfigPath(), "Config file path")
+	out := fs.String("o", "", "Output file (default: the log's name with .mp4)")
+	speed := fs.Float64("speed", 4, "Playback speed, 1 for real time")
+	fps := fs.Int("fps", 30, "Frames per second")
+	size := fs.String("size", "1280x720", "Video width x height")
+	hud := fs.String("hud", "osd", "HUD: map, osd, panel, split, big")
+	zoom := fs.Int("zoom", 16, "Map zoom level")
+	ffmpeg := fs.String("ffmpeg", "ffmpeg", "ffmpeg command")
+	crsfGap := fs.Duration("crsf-gap", DefaultCRSFFrameGap, "Time between telemetry frames of a raw CRSF dump")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: elrs-map video [-o out.mp4] [-speed 4] [-fps 30] [-size 1280x720] [-hud osd] log.csv")
+		os.Exit(2)
+	}
+	logPath := fs.Arg(0)
+	if *out == "" {
+		*out = strings.TrimSuffix(logPath, filepath.Ext(logPath)) + ".mp4"
+	}
+	var width, height int
+	if _, err := fmt.Sscanf(*size, "%dx%d", &width, &height); err != nil || width <= 0 || height <= 0 {
+		logVideo.Fatalf("Bad -size %q, want e.g. 1280x720", *size)
+	}
+	// yuv420p needs even dimensions
+	width, height = width&^1, height&^1
+	mode, ok := hudModeNames[*hud]
+	if !ok {
+		logVideo.Fatalf("Unknown -hud %q (map, osd, panel, split, big)", *hud)
+	}
+	if *speed <= 0 || *fps <= 0 {
+		logVideo.Fatalf("-speed and -fps must be positive")
+	}
+
+	samples, err := LoadReplay(logPath, *crsfGap)
+	if err != nil {
+		logVideo.Fatalf("Could not load %v", err)
+	}
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		logConfig.Warnf("Could not load config: %v (using defaults)", err)
+	}
+	// Start from a clean view; the session state belongs to live flying
+	cfg.State = StateConfig{}
+	cfg.Display.PowerSave = false
+	cfg.Touch.Enabled = false
+
+	v := &VideoExport{
+		samples: samples,
+		width:   width,
+		height:  height,
+		clock:   samples[0].At,
+		step:    time.Duration(float64(time.Second) * *speed / float64(*fps)),
+		end:     samples[len(samples)-1].At,
+	}
+	v.total = int(v.end.Sub(v.clock)/v.step) + 1
+
+	tileManager := NewTileManager(cfg.CacheDir, cfg.Tiles)
+	defer tileManager.Close()
+	v.app = NewApp(NewReplayClient(filepath.Base(logPath)), tileManager, cfg, width, height, false)
+	v.setup(mode, *zoom)
+
+	v.ffmpeg = exec.Command(*ffmpeg, "-y", "-loglevel", "error",
+		"-f", "rawvideo", "-pix_fmt", "rgba", "-s", fmt.Sprintf("%dx%d", width, height),
+		"-r", fmt.Sprint(*fps), "-i", "-",
+		"-c:v", "libx264", "-pix_fmt", "yuv420p", "-movflags", "+faststart", *out)
+	v.ffmpeg.Stdout, v.ffmpeg.Stderr = os.Stdout, os.Stderr
+	if v.pipe, err = v.ffmpeg.StdinPipe(); err != nil {
+		logVideo.Fatalf("ffmpeg: %v", err)
+	}
+	if err := v.ffmpeg.Start(); err != nil {
+		logVideo.Fatalf("Could not run %s (is ffmpeg installed?): %v", *ffmpeg, err)
+	}
+
+	logVideo.Infof("Rendering %s (%s of flight) to %s at %gx, %d frames", logPath,
+		FormatETE(v.end.Sub(v.clock)), *out, *speed, v.total)
+	ebiten.SetWindowSize(width, height)
+	ebiten.SetWindowTitle("ELRS Ground Station - rendering " + filepath.Base(*out))
+	// Draw as fast as frames can be encoded, not at the display's rate
+	ebiten.SetVsyncEnabled(false)
+	ebiten.SetTPS(ebiten.SyncWithFPS)
+	runErr := ebiten.RunGame(v)
+
+	v.pipe.Close()
+	if err := v.ffmpeg.Wait(); err != nil && v.err == nil {
+		v.err = fmt.Errorf("ffmpeg: %w", err)
+	}
+	switch {
+	case runErr != nil && !errors.Is(runErr, ebiten.Termination):
+		logVideo.Fatalf("Rendering failed: %v", runErr)
+	case v.err != nil:
+		logVideo.Fatalf("Rendering failed: %v", v.err)
+	case !v.done:
+		logVideo.Warnf("Stopped after %d of %d frames; %s has the flight up to there", v.frames, v.total, *out)
+	default:
+		logVideo.Infof("Wrote %s", *out)
+	}
+}
+
+// setup puts the app in the HUD mode, following the aircraft, with home
+// where the log first has a fix
+func (v *VideoExport) setup(mode, zoom int) {
+	a := v.app
+	a.hudMode = mode
+	a.zoom = max(MinZoom, min(MaxZoom, zoom))
+	a.followAircraft = true
+	a.maxPathLen = len(v.samples) * 10
+	// Keep the replay out of the logbook, and skip crash recovery
+	a.logbook = &Logbook{}
+	a.restoreMenu = nil
+	for _, s := range v.samples {
+		if s.State.HasGPS {
+			a.setHome(float64(s.State.Latitude), float64(s.State.Longitude))
+			a.centerLat, a.centerLon = a.homeLat, a.homeLon
+			break
+		}
+	}
+	v.frame = ebiten.NewImage(v.width, v.height)
+	v.pixels = make([]byte, 4*v.width*v.height)
+	v.waitFrom = time.Now()
+}
+
+// Update feeds the sample of the frame's flight time and runs the app
+func (v *VideoExport) Update() error {
+	if v.done || v.err != nil {
+		return ebiten.Termination
+	}
+	v.app.client.Feed(replayAt(v.samples, v.clock).State)
+	if err := v.app.Update(); err != nil {
+		return err
+	}
+	// The video's size, not the window's
+	v.app.width, v.app.height = v.width, v.height
+	return nil
+}
+
+// Draw renders the frame, previews it in the window and, once its tiles
+// are in, sends it to ffmpeg and moves the flight clock on
+func (v *VideoExport) Draw(screen *ebiten.Image) {
+	v.app.Draw(v.frame)
+	screen.DrawImage(v.frame, nil)
+	if v.done || v.err != nil {
+		return
+	}
+	if v.app.tileManager.Loading() > 0 && time.Since(v.waitFrom) < videoTileWait {
+		return
+	}
+
+	v.frame.ReadPixels(v.pixels)
+	if _, err := v.pipe.Write(v.pixels); err != nil {
+		v.err = fmt.Errorf("ffmpeg: %w", err)
+		return
+	}
+	v.frames++
+	if v.frames%max(1, v.total/10) == 0 {
+		logVideo.Infof("%d%%", v.frames*100/v.total)
+	}
+	v.clock = v.clock.Add(v.step)
+	v.waitFrom = time.Now()
+	v.done = v.clock.After(v.end)
+}
+
+// Layout keeps the drawing at the video's size, scaled into the window
+func (v *VideoExport) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return v.width, v.height
+}
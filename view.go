@@ -0,0 +1,202 @@
+//go:build !headless
+
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	// fitMaxZoom keeps zoom-to-fit from diving in on a short track
+	fitMaxZoom = 17
+
+	// fitMargin is the room left around a fitted track, in pixels
+	fitMargin = 40
+
+	// wheelZoomStep is the wheel travel per zoom level; a mouse notch is 1,
+	// a trackpad sends a stream of fractions
+	wheelZoomStep = 1.0
+
+	// wheelIdleReset drops leftover travel after a pause, so a half swipe
+	// doesn't zoom on the next touch
+	wheelIdleReset = 300 * time.Millisecond
+
+	// wheelZoomCooldown keeps trackpad momentum from racing through levels
+	wheelZoomCooldown = 150 * time.Millisecond
+)
+
+// wheelZoom adds a tick's wheel travel and zooms a level each time it adds
+// up to a step
+func (a *App) wheelZoom(dy float64) {
+	now := time.Now()
+	if dy == 0 {
+		if now.Sub(a.wheelLast) > wheelIdleReset {
+			a.wheelAccum = 0
+		}
+		return
+	}
+	// Turning back drops the travel the other way
+	if a.wheelAccum*dy < 0 {
+		a.wheelAccum = 0
+	}
+	a.wheelLast = now
+	a.wheelAccum = math.Max(-2*wheelZoomStep, math.Min(2*wheelZoomStep, a.wheelAccum+dy))
+	if math.Abs(a.wheelAccum) < wheelZoomStep || now.Sub(a.wheelStep) < wheelZoomCooldown {
+		return
+	}
+
+	a.wheelStep = now
+	if a.wheelAccum > 0 {
+		a.wheelAccum -= wheelZoomStep
+		if a.zoom < MaxZoom {
+			a.zoom++
+		}
+	} else {
+		a.wheelAccum += wheelZoomStep
+		if a.zoom > MinZoom {
+			a.zoom--
+		}
+	}
+}
+
+// zoomToFit centers and zooms the map on the flight path and home
+func (a *App) zoomToFit() {
+	var points []pathPoint
+	points = append(points, a.flightPath...)
+	if a.homeSet {
+		points = append(points, pathPoint{lat: a.homeLat, lon: a.homeLon})
+	}
+	if len(points) == 0 {
+		return
+	}
+
+	// Longitudes are taken relative to the first point, so a track across
+	// the ±180° seam isn't fitted as if it went around the world
+	minLat, minLon := points[0].lat, points[0].lon
+	maxLat, maxLon := minLat, minLon
+	for _, p := range points[1:] {
+		lon := points[0].lon + wrapLon(p.lon-points[0].lon)
+		minLat, maxLat = math.Min(minLat, p.lat), math.Max(maxLat, p.lat)
+		minLon, maxLon = math.Min(minLon, lon), math.Max(maxLon, lon)
+	}
+
+	_, mapWidth := a.mapArea()
+	a.centerLat, a.centerLon, a.zoom = fitView(minLat, minLon, maxLat, maxLon, mapWidth, a.height)
+	// Following would pull the view straight back to the aircraft
+	a.followAircraft = false
+}
+
+// centerOnAircraft moves the view to the aircraft once, leaving follow as is
+func (a *App) centerOnAircraft() {
+	state := a.client.GetState()
+	if !state.HasGPS || (state.Latitude == 0 && state.Longitude == 0) {
+		return
+	}
+	a.centerLat, a.centerLon = float64(state.Latitude), float64(state.Longitude)
+}
+
+// centerOnHome moves the view to home. Follow is turned off, or it would
+// pull the view straight back.
+func (a *App) centerOnHome() {
+	if !a.homeSet {
+		return
+	}
+	a.centerLat, a.centerLon = a.homeLat, a.homeLon
+	a.followAircraft = false
+}
+
+// fitView returns the center of a lat/lon box and the closest zoom at which
+// it fits in w x h pixels
+func fitView(minLat, minLon, maxLat, maxLon float64, w, h int) (float64, float64, int) {
+	// World pixels at zoom 0; each zoom level doubles them
+	left, top := LatLonToPixel(maxLat, minLon, 0)
+	right, bottom := LatLonToPixel(minLat, maxLon, 0)
+
+	zoom := fitMaxZoom
+	for zoom > MinZoom {
+		scale := math.Pow(2, float64(zoom))
+		if (right-left)*scale <= float64(w-2*fitMargin) && (bottom-top)*scale <= float64(h-2*fitMargin) {
+			break
+		}
+		zoom--
+	}
+
+	// Center in Mercator space, so the box is centered on screen too
+	cx, cy := (left+right)/2, (top+bottom)/2
+	lat, lon := PixelToLatLon(cx, cy, 0)
+	return lat, lon, zoom
+}
+
+// panKeys moves the map while the arrow keys or WASD are held, at
+// input.pan_speed screen pixels a second, input.pan_fast times that with
+// Shift
+func (a *App) panKeys() {
+	var dx, dy float64
+	if ebiten.IsKeyPressed(ebiten.KeyUp) || ebiten.IsKeyPressed(ebiten.KeyW) {
+		dy--
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyDown) || ebiten.IsKeyPressed(ebiten.KeyS) {
+		dy++
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyLeft) || ebiten.IsKeyPressed(ebiten.KeyA) {
+		dx--
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyRight) || ebiten.IsKeyPressed(ebiten.KeyD) {
+		dx++
+	}
+	if dx == 0 && dy == 0 {
+		return
+	}
+
+	input := a.config.Input
+	step := input.PanSpeed / float64(ebiten.TPS())
+	if ebiten.IsKeyPressed(ebiten.KeyShift) && input.PanFast > 0 {
+		step *= input.PanFast
+	}
+	// The place that far from the middle becomes the middle, which also
+	// wraps it over the ±180° seam and stops it short of the poles
+	v := a.currentMapView()
+	mx, my := v.middle()
+	a.centerLat, a.centerLon = v.ScreenToLatLon(mx+dx*step, my+dy*step)
+	a.followAircraft = false
+}
+
+const (
+	// followLeadMax keeps the aircraft on screen however far ahead the map
+	// looks, as a share of the way from the middle to the edge
+	followLeadMax = 0.8
+
+	// followLeadSmoothing is the share of the way to a new lead the map
+	// moves each tick, so a wavering track doesn't shake it
+	followLeadSmoothing = 0.05
+)
+
+// followCenter keeps the aircraft in view while following. With
+// map.follow_offset or map.follow_look_ahead_m the map's middle leads it
+// along its track, showing more of where it is heading; slower than the
+// profile's slow_kmh, where the track is noise, it eases back to the
+// aircraft.
+func (a *App) followCenter(state TelemetryState) {
+	lat := float64(state.Latitude)
+	v := a.currentMapView()
+	v.CenterLat, v.CenterLon = lat, float64(state.Longitude)
+
+	var lx, ly float64
+	m := a.config.Map
+	slowKmh, _ := a.config.Aircraft.SlowHeading()
+	if (m.FollowOffset > 0 || m.FollowAhead > 0) && float64(state.GroundSpeed) >= slowKmh {
+		half := float64(min(v.Width, v.Height-statusBarHeight)) / 2
+		lead := m.FollowOffset*half + m.FollowAhead/v.MetersPerPixel(lat)
+		lead = math.Min(lead, followLeadMax*half)
+		sin, cos := math.Sincos(v.ScreenHeading(float64(state.Heading)) * math.Pi / 180)
+		lx, ly = lead*sin, -lead*cos
+	}
+	a.followLead[0] += (lx - a.followLead[0]) * followLeadSmoothing
+	a.followLead[1] += (ly - a.followLead[1]) * followLeadSmoothing
+
+	mx, my := v.middle()
+	a.centerLat, a.centerLon = v.ScreenToLatLon(mx+a.followLead[0], my+a.followLead[1])
+}
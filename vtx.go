@@ -0,0 +1,27 @@
+package main
+
+import "strconv"
+
+// vtxBandOptions are the bands of the ELRS VTX Administrator, "Off" first
+var vtxBandOptions = []string{"Off", "A", "B", "E", "F", "R", "L"}
+
+// vtxChannels are the 5.8 GHz channel frequencies in MHz per band
+var vtxChannels = map[string][8]int{
+	"A": {5865, 5845, 5825, 5805, 5785, 5765, 5745, 5725},
+	"B": {5733, 5752, 5771, 5790, 5809, 5828, 5847, 5866},
+	"E": {5705, 5685, 5665, 5645, 5885, 5905, 5925, 5945},
+	"F": {5740, 5760, 5780, 5800, 5820, 5840, 5860, 5880},
+	"R": {5658, 5695, 5732, 5769, 5806, 5843, 5880, 5917},
+	"L": {5362, 5399, 5436, 5473, 5510, 5547, 5584, 5621},
+}
+
+// vtxFrequency returns the frequency of a band ("R") and channel ("1"-"8")
+// in MHz, or 0 if either is unknown
+func vtxFrequency(band, channel string) int {
+	ch, err := strconv.Atoi(channel)
+	freqs, ok := vtxChannels[band]
+	if err != nil || !ok || ch < 1 || ch > len(freqs) {
+		return 0
+	}
+	return freqs[ch-1]
+}
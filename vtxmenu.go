@@ -0,0 +1,142 @@
+//go:build !headless
+
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// vtxFolder is the TX module's parameter folder for VTX control
+const vtxFolder = "VTX Administrator"
+
+// vtxParams returns the TX module and the selection parameters in its VTX
+// folder, plus the command that sends them to the VTX
+func (a *App) vtxParams() (tx CRSFDevice, params []CRSFParam, send CRSFParam, ok bool) {
+	for _, d := range a.devices.devices {
+		if d.ID != crsfAddrTX {
+			continue
+		}
+		folder, found := d.Param(vtxFolder)
+		if !found || folder.Type != crsfTypeFolder {
+			return d, nil, CRSFParam{}, false
+		}
+		for _, p := range d.Params {
+			if p.Hidden || uint32(p.Parent) != folder.ID {
+				continue
+			}
+			switch p.Type {
+			case crsfTypeTextSelect:
+				params = append(params, p)
+			case crsfTypeCommand:
+				send = p
+			}
+		}
+		return d, params, send, send.ID != 0
+	}
+	return CRSFDevice{}, nil, CRSFParam{}, false
+}
+
+// openVTXMenu shows the VTX control, querying the devices first if needed
+func (a *App) openVTXMenu() {
+	if len(a.devices.devices) == 0 {
+		a.fetchDevices()
+	}
+	a.vtxMenu.Open()
+}
+
+// newVTXMenu builds the VTX control: band, channel, power and pit mode are
+// picked here and sent through the TX module's VTX Administrator, which
+// passes them to the VTX over the link
+func (a *App) newVTXMenu() *Menu {
+	m := NewMenu("VTX")
+	pending := map[uint32]int{} // Field ID to option index
+	m.Rebuild = func(m *Menu) {
+		m.Items = nil
+		tx, params, send, ok := a.vtxParams()
+		if !ok {
+			label := "TX has no VTX Administrator"
+			switch {
+			case a.fetchingDevices:
+				label = "Querying devices..."
+			case errors.Is(a.devices.err, errBackendTooOld):
+				label = "Backend too old for VTX control"
+			}
+			m.Items = append(m.Items, MenuItem{Label: label}, MenuItem{Label: "Close", OnSelect: m.Close})
+			return
+		}
+
+		value := func(name string) string {
+			for _, p := range params {
+				if p.Name == name {
+					return p.Options[pending[p.ID]]
+				}
+			}
+			return ""
+		}
+		for _, p := range params {
+			if _, seen := pending[p.ID]; !seen || pending[p.ID] >= len(p.Options) {
+				pending[p.ID] = min(p.Selected, len(p.Options)-1)
+			}
+			m.Items = append(m.Items, MenuItem{
+				Label: p.Name,
+				Value: func() string { return p.Options[pending[p.ID]] },
+				OnAdjust: func(d int) {
+					n := len(p.Options)
+					pending[p.ID] = ((pending[p.ID]+d)%n + n) % n
+				},
+			})
+		}
+		m.Items = append(m.Items,
+			MenuItem{Label: "Frequency", Value: func() string {
+				if f := vtxFrequency(value("Band"), value("Channel")); f > 0 {
+					return fmt.Sprintf("%d MHz", f)
+				}
+				return "-"
+			}},
+			MenuItem{Label: "Send to VTX", OnSelect: func() {
+				a.sendVTX(tx, params, send, pending)
+			}},
+			MenuItem{Label: "Status", Value: func() string {
+				if a.deviceStatus == "" {
+					return "-"
+				}
+				return a.deviceStatus
+			}},
+			MenuItem{Label: "Close", OnSelect: m.Close},
+		)
+	}
+	return m
+}
+
+// sendVTX writes the changed settings to the TX module and has it send
+// them to the VTX
+func (a *App) sendVTX(tx CRSFDevice, params []CRSFParam, send CRSFParam, pending map[uint32]int) {
+	type change struct {
+		id    uint32
+		value byte
+	}
+	var changes []change
+	for _, p := range params {
+		if pending[p.ID] != p.Selected {
+			changes = append(changes, change{p.ID, byte(pending[p.ID])})
+		}
+	}
+	a.deviceStatus = "Sending to VTX..."
+	go func() {
+		for _, c := range changes {
+			if err := a.client.SetParam(tx.ID, c.id, c.value); err != nil {
+				logTelem.Errorf("VTX: %v", err)
+				a.commandDone <- "VTX failed: " + err.Error()
+				return
+			}
+		}
+		if err := a.client.RunCommand(tx.ID, send.ID); err != nil {
+			logTelem.Errorf("VTX: %v", err)
+			a.commandDone <- "VTX failed: " + err.Error()
+			return
+		}
+		logTelem.Infof("VTX settings sent")
+		a.commandDone <- "VTX: sent"
+	}()
+}
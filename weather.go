@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	weatherCheckInterval = 30 * time.Second
+	weatherMoveDistance  = 5000.0 // Meters; refetch early when the location moves this far
+	weatherStaleAfter    = 3 * time.Hour
+)
+
+// WindLevel is the wind at a height above ground
+type WindLevel struct {
+	Height float64 `json:"height"` // Meters above ground
+	Speed  float64 `json:"speed"`  // km/h
+	Dir    float64 `json:"dir"`    // Degrees the wind blows from
+}
+
+// Weather is the forecast for the flying site. Wind speeds are km/h so they
+// go through Units.Speed like the aircraft's ground speed.
+type Weather struct {
+	Time        time.Time   `json:"time"` // When it was fetched
+	Lat         float64     `json:"lat"`
+	Lon         float64     `json:"lon"`
+	Temperature float64     `json:"temperature"` // °C
+	WindSpeed   float64     `json:"wind_speed"`  // At 10 m
+	WindDir     float64     `json:"wind_dir"`
+	WindGust    float64     `json:"wind_gust"`
+	Aloft       []WindLevel `json:"aloft"` // Ascending height, surface first
+	Metar       string      `json:"metar,omitempty"`
+}
+
+// WindAt interpolates the wind at height meters above ground. Above the
+// highest forecast level the top value is used.
+func (w *Weather) WindAt(height float64) (speed, dir float64) {
+	levels := w.Aloft
+	if len(levels) == 0 {
+		return w.WindSpeed, w.WindDir
+	}
+	if height <= levels[0].Height {
+		return levels[0].Speed, levels[0].Dir
+	}
+	for i := 1; i < len(levels); i++ {
+		lo, hi := levels[i-1], levels[i]
+		if height > hi.Height {
+			continue
+		}
+		f := (height - lo.Height) / (hi.Height - lo.Height)
+		// Blend as vectors so 350° and 10° average to north, not south
+		x := lerp(lo.Speed*math.Sin(lo.Dir*math.Pi/180), hi.Speed*math.Sin(hi.Dir*math.Pi/180), f)
+		y := lerp(lo.Speed*math.Cos(lo.Dir*math.Pi/180), hi.Speed*math.Cos(hi.Dir*math.Pi/180), f)
+		dir = math.Mod(math.Atan2(x, y)*180/math.Pi+360, 360)
+		return lerp(lo.Speed, hi.Speed, f), dir
+	}
+	top := levels[len(levels)-1]
+	return top.Speed, top.Dir
+}
+
+func lerp(a, b, f float64) float64 {
+	return a + (b-a)*f
+}
+
+// WeatherService keeps the forecast for one location fresh in the
+// background. The last result is cached on disk, so the conditions card
+// still has something to show at a field without internet.
+type WeatherService struct {
+	config    *WeatherConfig
+	cachePath string
+	client    *http.Client
+
+	mu       sync.Mutex
+	current  *Weather
+	lat, lon float64
+	located  bool
+	lastTry  time.Time
+	failing  bool // Log repeated failures quietly
+	fetching bool
+
+	refresh  chan struct{}
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// WeatherCachePath returns where the last forecast is kept
+func WeatherCachePath() string {
+	return filepath.Join(DefaultDataDir(), "weather.json")
+}
+
+// NewWeatherService creates a weather service, loading any cached forecast
+func NewWeatherService(cfg *WeatherConfig, cachePath string) *WeatherService {
+	s := &WeatherService{
+		config:    cfg,
+		cachePath: cachePath,
+		client:    &http.Client{Timeout: 15 * time.Second},
+		refresh:   make(chan struct{}, 1),
+		stopChan:  make(chan struct{}),
+	}
+	if data, err := os.ReadFile(cachePath); err == nil {
+		w := &Weather{}
+		if json.Unmarshal(data, w) == nil {
+			s.current = w
+		}
+	}
+	return s
+}
+
+// Start fetches in the background until Stop
+func (s *WeatherService) Start() {
+	go s.run()
+}
+
+// Stop ends the background fetches
+func (s *WeatherService) Stop() {
+	s.stopOnce.Do(func() { close(s.stopChan) })
+}
+
+// SetLocation sets where the forecast is for, usually home
+func (s *WeatherService) SetLocation(lat, lon float64) {
+	s.mu.Lock()
+	s.lat, s.lon, s.located = lat, lon, true
+	s.mu.Unlock()
+}
+
+// Refresh fetches now instead of waiting for the next interval
+func (s *WeatherService) Refresh() {
+	select {
+	case s.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Current returns the latest forecast, or nil if there is none. Forecasts
+// older than a few hours are dropped, they say little about the wind now.
+func (s *WeatherService) Current() *Weather {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil || time.Since(s.current.Time) > weatherStaleAfter {
+		return nil
+	}
+	w := *s.current
+	return &w
+}
+
+// IsFetching returns true while a request is in flight
+func (s *WeatherService) IsFetching() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetching
+}
+
+func (s *WeatherService) run() {
+	ticker := time.NewTicker(weatherCheckInterval)
+	defer ticker.Stop()
+
+	force := false
+	for {
+		if s.config.Enabled && s.due(force) {
+			s.update()
+		}
+		force = false
+		select {
+		case <-s.stopChan:
+			return
+		case <-s.refresh:
+			force = true
+		case <-ticker.C:
+		}
+	}
+}
+
+// due reports whether the forecast should be fetched again
+func (s *WeatherService) due(force bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.located {
+		return false
+	}
+	if force {
+		return true
+	}
+	if time.Since(s.lastTry) < s.config.Refresh {
+		return false // Also spaces out retries while offline
+	}
+	return s.current == nil ||
+		time.Since(s.current.Time) >= s.config.Refresh ||
+		DistanceMeters(s.current.Lat, s.current.Lon, s.lat, s.lon) > weatherMoveDistance
+}
+
+// update fetches and caches a new forecast
+func (s *WeatherService) update() {
+	s.mu.Lock()
+	lat, lon := s.lat, s.lon
+	s.lastTry = time.Now()
+	s.fetching = true
+	s.mu.Unlock()
+
+	w, err := s.fetch(lat, lon)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetching = false
+	if err != nil {
+		if s.failing {
+			logWeather.Debugf("Could not fetch weather: %v", err)
+		} else {
+			logWeather.Warnf("Could not fetch weather: %v", err)
+		}
+		s.failing = true
+		return
+	}
+	s.failing = false
+	s.current = w
+	logWeather.Infof("Wind %.0f km/h from %03.0f°, gusts %.0f km/h", w.WindSpeed, w.WindDir, w.WindGust)
+
+	if data, err := json.Marshal(w); err == nil {
+		if err := writeFileAtomic(s.cachePath, data); err != nil {
+			logWeather.Warnf("Could not cache weather: %v", err)
+		}
+	}
+}
+
+// openMeteoResponse is the part of the open-meteo forecast we use
+type openMeteoResponse struct {
+	Current struct {
+		Temperature float64 `json:"temperature_2m"`
+		WindSpeed   float64 `json:"wind_speed_10m"`
+		WindDir     float64 `json:"wind_direction_10m"`
+		WindGust    float64 `json:"wind_gusts_10m"`
+	} `json:"current"`
+	// Each hourly series is decoded on its own, "time" is always in there
+	// and is a list of strings
+	Hourly map[string]json.RawMessage `json:"hourly"`
+}
+
+// aloft returns the wind at each forecast height for the first hour
+func (r *openMeteoResponse) aloft() []WindLevel {
+	var levels []WindLevel
+	for _, h := range openMeteoLevels {
+		var speed, dir []*float64 // Null where the model has no value
+		if json.Unmarshal(r.Hourly[fmt.Sprintf("wind_speed_%dm", h)], &speed) != nil ||
+			json.Unmarshal(r.Hourly[fmt.Sprintf("wind_direction_%dm", h)], &dir) != nil {
+			continue
+		}
+		if len(speed) > 0 && len(dir) > 0 && speed[0] != nil && dir[0] != nil {
+			levels = append(levels, WindLevel{Height: float64(h), Speed: *speed[0], Dir: *dir[0]})
+		}
+	}
+	return levels
+}
+
+// openMeteoLevels are the heights open-meteo forecasts wind for, in meters
+var openMeteoLevels = []int{10, 80, 120, 180}
+
+// fetch gets the forecast from open-meteo and, if a station is set, its METAR
+func (s *WeatherService) fetch(lat, lon float64) (*Weather, error) {
+	var hourly []string
+	for _, h := range openMeteoLevels {
+		hourly = append(hourly, fmt.Sprintf("wind_speed_%dm", h), fmt.Sprintf("wind_direction_%dm", h))
+	}
+	q := url.Values{}
+	q.Set("latitude", fmt.Sprintf("%.4f", lat))
+	q.Set("longitude", fmt.Sprintf("%.4f", lon))
+	q.Set("current", "temperature_2m,wind_speed_10m,wind_direction_10m,wind_gusts_10m")
+	q.Set("hourly", strings.Join(hourly, ","))
+	q.Set("forecast_hours", "1")
+	q.Set("wind_speed_unit", "kmh")
+
+	var resp openMeteoResponse
+	if err := s.getJSON("https://api.open-meteo.com/v1/forecast?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+
+	w := &Weather{
+		Time:        time.Now(),
+		Lat:         lat,
+		Lon:         lon,
+		Temperature: resp.Current.Temperature,
+		WindSpeed:   resp.Current.WindSpeed,
+		WindDir:     resp.Current.WindDir,
+		WindGust:    resp.Current.WindGust,
+		Aloft:       resp.aloft(),
+	}
+
+	if station := strings.ToUpper(strings.TrimSpace(s.config.MetarStation)); station != "" {
+		metar, err := s.fetchMetar(station)
+		if err != nil {
+			logWeather.Warnf("Could not fetch METAR for %s: %v", station, err)
+		} else {
+			w.Metar = metar
+		}
+	}
+	return w, nil
+}
+
+// fetchMetar returns the latest raw METAR for an ICAO station
+func (s *WeatherService) fetchMetar(station string) (string, error) {
+	var reports []struct {
+		RawOb string `json:"rawOb"`
+	}
+	if err := s.getJSON("https://aviationweather.gov/api/data/metar?format=json&ids="+url.QueryEscape(station), &reports); err != nil {
+		return "", err
+	}
+	if len(reports) == 0 {
+		return "", fmt.Errorf("no report")
+	}
+	return reports[0].RawOb, nil
+}
+
+func (s *WeatherService) getJSON(u string, v interface{}) error {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "ELRS-GroundStation/1.0")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
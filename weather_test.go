@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// openMeteoSample is a forecast response as open-meteo returns it for the
+// query built in fetch
+const openMeteoSample = `{
+  "latitude": 52.52,
+  "longitude": 13.419998,
+  "generationtime_ms": 0.0629425048828125,
+  "utc_offset_seconds": 0,
+  "timezone": "GMT",
+  "timezone_abbreviation": "GMT",
+  "elevation": 38.0,
+  "current_units": {
+    "time": "iso8601",
+    "interval": "seconds",
+    "temperature_2m": "°C",
+    "wind_speed_10m": "km/h",
+    "wind_direction_10m": "°",
+    "wind_gusts_10m": "km/h"
+  },
+  "current": {
+    "time": "2026-10-16T09:00",
+    "interval": 900,
+    "temperature_2m": 11.3,
+    "wind_speed_10m": 14.8,
+    "wind_direction_10m": 247,
+    "wind_gusts_10m": 31.3
+  },
+  "hourly_units": {
+    "time": "iso8601",
+    "wind_speed_10m": "km/h",
+    "wind_direction_10m": "°",
+    "wind_speed_80m": "km/h",
+    "wind_direction_80m": "°",
+    "wind_speed_120m": "km/h",
+    "wind_direction_120m": "°",
+    "wind_speed_180m": "km/h",
+    "wind_direction_180m": "°"
+  },
+  "hourly": {
+    "time": ["2026-10-16T09:00"],
+    "wind_speed_10m": [14.8],
+    "wind_direction_10m": [247],
+    "wind_speed_80m": [24.1],
+    "wind_direction_80m": [251],
+    "wind_speed_120m": [27.4],
+    "wind_direction_120m": [253],
+    "wind_speed_180m": [null],
+    "wind_direction_180m": [null]
+  }
+}`
+
+func TestOpenMeteoDecode(t *testing.T) {
+	var resp openMeteoResponse
+	if err := json.Unmarshal([]byte(openMeteoSample), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Current.WindSpeed != 14.8 || resp.Current.WindDir != 247 || resp.Current.WindGust != 31.3 {
+		t.Errorf("current wind = %+v", resp.Current)
+	}
+
+	want := []WindLevel{
+		{Height: 10, Speed: 14.8, Dir: 247},
+		{Height: 80, Speed: 24.1, Dir: 251},
+		{Height: 120, Speed: 27.4, Dir: 253},
+	}
+	got := resp.aloft()
+	if len(got) != len(want) {
+		t.Fatalf("aloft = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("aloft[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestOpenMeteoMissingLevel(t *testing.T) {
+	var resp openMeteoResponse
+	data := `{"hourly": {"time": ["2026-10-16T09:00"], "wind_speed_10m": [9], "wind_direction_10m": [180]}}`
+	if err := json.Unmarshal([]byte(data), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	got := resp.aloft()
+	if len(got) != 1 || got[0] != (WindLevel{Height: 10, Speed: 9, Dir: 180}) {
+		t.Errorf("aloft = %+v", got)
+	}
+}
@@ -0,0 +1,119 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// metarRowChars is how much of the METAR fits on one menu row
+const metarRowChars = 44
+
+// newConditionsMenu builds the pre-flight conditions card
+func (a *App) newConditionsMenu() *Menu {
+	m := NewMenu("Conditions")
+	m.Rebuild = func(m *Menu) {
+		w := a.weather.Current()
+		units := a.config.Display.Units
+		wind := func(speed, dir float64) string {
+			return fmt.Sprintf("%.0f %s from %03.0f°", units.Speed(speed), units.SpeedLabel(), dir)
+		}
+
+		m.Items = nil
+		switch {
+		case w != nil:
+			m.Items = append(m.Items,
+				MenuItem{Label: "Updated", Value: func() string { return formatAge(time.Since(w.Time)) }},
+				MenuItem{Label: "Surface wind", Value: func() string { return wind(w.WindSpeed, w.WindDir) }},
+				MenuItem{Label: "Gusts", Value: func() string {
+					return fmt.Sprintf("%.0f %s", units.Speed(w.WindGust), units.SpeedLabel())
+				}},
+				MenuItem{Label: "Temperature", Value: func() string { return fmt.Sprintf("%.0f°C", w.Temperature) }},
+			)
+			for _, l := range w.Aloft[min(1, len(w.Aloft)):] { // Skip 10 m, same as surface
+				m.Items = append(m.Items, MenuItem{
+					Label: fmt.Sprintf("Wind %.0f%s", units.Altitude(l.Height), units.AltitudeLabel()),
+					Value: func() string { return wind(l.Speed, l.Dir) },
+				})
+			}
+			for s := w.Metar; s != ""; {
+				n := min(len(s), metarRowChars)
+				m.Items = append(m.Items, MenuItem{Label: s[:n]})
+				s = s[n:]
+			}
+		case !a.config.Weather.Enabled:
+			m.Items = append(m.Items, MenuItem{Label: "Weather is off (weather.enabled)"})
+		default:
+			m.Items = append(m.Items, MenuItem{Label: "No forecast yet", Value: func() string {
+				if a.weather.IsFetching() {
+					return "fetching..."
+				}
+				return "offline?"
+			}})
+		}
+
+		m.Items = append(m.Items,
+			MenuItem{Label: "Refresh", OnSelect: func() {
+				a.weather.Refresh()
+				m.Close()
+			}},
+			MenuItem{Label: "Close", OnSelect: m.Close},
+		)
+	}
+	return m
+}
+
+// formatAge formats how long ago something happened, e.g. "12 min ago"
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%d min ago", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%dh%02d ago", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
+// drawWindWithOffset draws the wind box in the bottom-right corner of the
+// map: surface wind and gusts, plus the wind at the aircraft's height while
+// it is flying. The arrow points the way the wind blows.
+func (a *App) drawWindWithOffset(screen *ebiten.Image, offsetX, mapWidth int) {
+	w := a.weather.Current()
+	if w == nil {
+		return
+	}
+	units := a.config.Display.Units
+
+	lines := []string{fmt.Sprintf("WIND %.0f G%.0f%s", units.Speed(w.WindSpeed), units.Speed(w.WindGust), units.SpeedLabel())}
+	state := a.client.GetState()
+	if height := relativeAltitude(state); state.HasGPS && height > 20 {
+		speed, dir := w.WindAt(height)
+		lines = append(lines, fmt.Sprintf("@%.0f%s %.0f %03.0f°", units.Altitude(height), units.AltitudeLabel(), units.Speed(speed), dir))
+	}
+
+	boxW, boxH := 170, 12+16*len(lines)
+	x := offsetX + mapWidth - boxW - 5
+	y := a.height - 24 - boxH - 5 - a.attributionInset(true, offsetX, mapWidth) // Above the status bar
+	vector.DrawFilledRect(screen, float32(x), float32(y), float32(boxW), float32(boxH), color.RGBA{0, 0, 0, 180}, true)
+
+	// Arrow toward where the wind is going
+	cx, cy := float32(x+18), float32(y+boxH/2)
+	rad := (w.WindDir + 180) * math.Pi / 180
+	dx, dy := float32(math.Sin(rad)), -float32(math.Cos(rad))
+	arrowColor := color.RGBA{120, 200, 255, 255}
+	vector.StrokeLine(screen, cx-dx*10, cy-dy*10, cx+dx*10, cy+dy*10, 2, arrowColor, true)
+	vector.StrokeLine(screen, cx+dx*10, cy+dy*10, cx+dx*4-dy*5, cy+dy*4+dx*5, 2, arrowColor, true)
+	vector.StrokeLine(screen, cx+dx*10, cy+dy*10, cx+dx*4+dy*5, cy+dy*4-dx*5, 2, arrowColor, true)
+
+	for i, line := range lines {
+		ebitenutil.DebugPrintAt(screen, line, x+34, y+6+16*i)
+	}
+}
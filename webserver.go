@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// webPushInterval is how often telemetry is pushed to WebSocket clients
+const webPushInterval = 200 * time.Millisecond
+
+// TelemetryJSON is the telemetry snapshot sent to browsers
+type TelemetryJSON struct {
+	Time        time.Time `json:"time"`
+	Connected   bool      `json:"connected"`
+	LinkStarted bool      `json:"link_started"`
+	Receiving   bool      `json:"receiving"`
+
+	Lat        float32 `json:"lat"`
+	Lon        float32 `json:"lon"`
+	Alt        int32   `json:"alt"`
+	Speed      float32 `json:"speed"`
+	Heading    float32 `json:"heading"`
+	Satellites uint32  `json:"sats"`
+	HasGPS     bool    `json:"has_gps"`
+
+	Pitch float32 `json:"pitch"`
+	Roll  float32 `json:"roll"`
+
+	Voltage   float32 `json:"voltage"`
+	Current   float32 `json:"current"`
+	Capacity  uint32  `json:"capacity"`
+	Remaining uint32  `json:"remaining"` // From the FC's capacity sensor
+	Charge    uint32  `json:"charge"`    // Remaining, or estimated from the voltage without the sensor
+
+	RSSI1       int32  `json:"rssi1"`
+	RSSI2       int32  `json:"rssi2"`
+	LinkQuality uint32 `json:"lq"`
+	SNR         int32  `json:"snr"`
+	TXPower     uint32 `json:"tx_power"` // mW
+
+	VerticalSpeed float32 `json:"vspeed"`
+	FlightMode    string  `json:"mode"`
+
+	Home   *HomeConfig  `json:"home,omitempty"`
+	Alerts *AlertConfig `json:"alerts,omitempty"`
+
+	// Stale marks a relayed flight whose station stopped pushing updates
+	Stale bool `json:"stale,omitempty"`
+}
+
+// WebTileSource is a map source listed under /tiles/
+type WebTileSource struct {
+	Key         string `json:"key"` // Its folder under /tiles/, e.g. "satellite"
+	Attribution string `json:"attribution,omitempty"`
+}
+
+// WebTiles gives the spotters' map the ground station's tiles
+type WebTiles interface {
+	// Sources lists the map sources there are tiles of, the one to show
+	// first at the top
+	Sources() []WebTileSource
+	// Tile returns a tile's image from the cache, or with download set
+	// from the tile server when it isn't cached
+	Tile(source string, z, x, y int, download bool) ([]byte, bool)
+}
+
+// WebServer serves the browser map and a live telemetry feed
+type WebServer struct {
+	client   *GRPCClient
+	config   *Config
+	addr     string
+	server   *http.Server
+	upgrader websocket.Upgrader
+
+	// Home returns the home position, if one is set
+	Home func() (lat, lon float64, ok bool)
+	// Tiles serves the tile cache under /tiles/, when web.tiles allows
+	Tiles WebTiles
+	// Metrics adds the display's own figures to /metrics
+	Metrics func(m metricsWriter)
+	// Action runs a named action on the display, for POST /api/action/
+	Action func(name string) error
+
+	relayMu sync.Mutex
+	relay   map[string]relayedFlight // Flights other stations share through us
+}
+
+// NewWebServer creates a web server listening on cfg.Web.Listen
+func NewWebServer(client *GRPCClient, cfg *Config) *WebServer {
+	addr := cfg.Web.Listen
+	w := &WebServer{
+		client: client,
+		config: cfg,
+		addr:   addr,
+		relay:  make(map[string]relayedFlight),
+		upgrader: websocket.Upgrader{
+			// Spotters open the page from whatever address the hotspot gave us
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+
+	static, _ := fs.Sub(webFiles, "web")
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.FS(static)))
+	mux.HandleFunc("/api/telemetry", w.handleTelemetry)
+	mux.HandleFunc("/ws", w.handleWebSocket)
+	mux.HandleFunc("GET /tiles/{$}", w.handleTileSources)
+	mux.HandleFunc("GET /metrics", w.handleMetrics)
+	mux.HandleFunc("POST /api/action/{name}", w.handleAction)
+	mux.HandleFunc("GET /tiles/{source}/{z}/{x}/{y}", w.handleTile)
+	mux.HandleFunc("POST /share/{id}", w.handleSharePush)
+	mux.HandleFunc("GET /share/{id}/{$}", w.handleSharePage)
+	mux.HandleFunc("GET /share/{id}/api/telemetry", w.handleShareTelemetry)
+	mux.HandleFunc("GET /share/{id}/ws", w.handleShareWebSocket)
+	w.server = &http.Server{Addr: addr, Handler: mux}
+	return w
+}
+
+// Start begins serving in the background
+func (w *WebServer) Start() {
+	go func() {
+		logWeb.Infof("Web map at http://%s/", w.addr)
+		if err := w.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logWeb.Errorf("Web server error: %v", err)
+		}
+	}()
+}
+
+// Stop closes the listener and all connections
+func (w *WebServer) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	w.server.Shutdown(ctx)
+}
+
+// snapshot builds the current telemetry message
+func (w *WebServer) snapshot() TelemetryJSON {
+	state := w.client.GetState()
+	t := newTelemetryJSON(state)
+	t.Charge = w.config.Battery.Charge(state)
+	t.Alerts = &w.config.Alerts
+	if w.Home != nil {
+		if lat, lon, ok := w.Home(); ok {
+			t.Home = &HomeConfig{Set: true, Lat: lat, Lon: lon}
+		}
+	}
+	return t
+}
+
+// newTelemetryJSON copies a telemetry state into the message browsers and
+// MQTT subscribers get
+func newTelemetryJSON(state TelemetryState) TelemetryJSON {
+	return TelemetryJSON{
+		Time:          time.Now(),
+		Connected:     state.Connected,
+		LinkStarted:   state.LinkStarted,
+		Receiving:     !state.LastUpdate.IsZero() && time.Since(state.LastUpdate) < telemetryTimeout,
+		Lat:           state.Latitude,
+		Lon:           state.Longitude,
+		Alt:           state.Altitude,
+		Speed:         state.GroundSpeed,
+		Heading:       state.Heading,
+		Satellites:    state.Satellites,
+		HasGPS:        state.HasGPS,
+		Pitch:         state.Pitch,
+		Roll:          state.Roll,
+		Voltage:       state.Voltage,
+		Current:       state.Current,
+		Capacity:      state.Capacity,
+		Remaining:     state.Remaining,
+		Charge:        state.Remaining,
+		RSSI1:         state.RSSI1,
+		RSSI2:         state.RSSI2,
+		LinkQuality:   state.LinkQuality,
+		SNR:           state.SNR,
+		TXPower:       state.TXPower,
+		VerticalSpeed: state.VerticalSpeed,
+		FlightMode:    state.FlightMode,
+	}
+}
+
+func (w *WebServer) handleTelemetry(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(w.snapshot())
+}
+
+// handleWebSocket pushes telemetry snapshots until the browser goes away
+func (w *WebServer) handleWebSocket(rw http.ResponseWriter, r *http.Request) {
+	w.serveWebSocket(rw, r, func() (TelemetryJSON, bool) { return w.snapshot(), true })
+}
+
+// serveWebSocket pushes what next returns until the browser goes away or
+// there is nothing more to send
+func (w *WebServer) serveWebSocket(rw http.ResponseWriter, r *http.Request, next func() (TelemetryJSON, bool)) {
+	conn, err := w.upgrader.Upgrade(rw, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// Read side only exists to notice the close
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(webPushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+			t, ok := next()
+			if !ok {
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+			if err := conn.WriteJSON(t); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// errUnknownAction is what Action returns for a name it doesn't know
+var errUnknownAction = errors.New("unknown action")
+
+// handleAction runs the action named in the path, the same as its key or
+// button would, for remote controls and scripts; 404 unless web.action_token
+// is set
+func (w *WebServer) handleAction(rw http.ResponseWriter, r *http.Request) {
+	if w.Action == nil || w.config.Web.ActionToken == "" {
+		http.NotFound(rw, r)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(w.config.Web.ActionToken)) != 1 {
+		http.Error(rw, "bad token", http.StatusUnauthorized)
+		return
+	}
+	if err := w.Action(r.PathValue("name")); err != nil {
+		status := http.StatusServiceUnavailable
+		if errors.Is(err, errUnknownAction) {
+			status = http.StatusBadRequest
+		}
+		http.Error(rw, err.Error(), status)
+		return
+	}
+	logWeb.Debugf("Action %s from %s", r.PathValue("name"), r.RemoteAddr)
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// tilesEnabled tells whether the tile cache is served at all
+func (w *WebServer) tilesEnabled() bool {
+	return w.Tiles != nil && w.config.Web.Tiles != "" && w.config.Web.Tiles != "off"
+}
+
+// handleTileSources lists the map sources under /tiles/, so the page knows
+// which to offer; 404 when tiles aren't served
+func (w *WebServer) handleTileSources(rw http.ResponseWriter, r *http.Request) {
+	if !w.tilesEnabled() {
+		http.NotFound(rw, r)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(w.Tiles.Sources())
+}
+
+// handleTile serves /tiles/{source}/{z}/{x}/{y}, slippy-map style, so any
+// map app on the hotspot can use the ground station's imagery. The y may
+// carry an extension (.jpg, .png), which is ignored.
+func (w *WebServer) handleTile(rw http.ResponseWriter, r *http.Request) {
+	if !w.tilesEnabled() {
+		http.NotFound(rw, r)
+		return
+	}
+	y := r.PathValue("y")
+	if i := strings.IndexByte(y, '.'); i >= 0 {
+		y = y[:i]
+	}
+	zi, errZ := strconv.Atoi(r.PathValue("z"))
+	xi, errX := strconv.Atoi(r.PathValue("x"))
+	yi, errY := strconv.Atoi(y)
+	if errZ != nil || errX != nil || errY != nil || zi < 0 || zi > 22 || xi < 0 || xi >= 1<<zi || yi < 0 || yi >= 1<<zi {
+		http.Error(rw, "bad tile", http.StatusBadRequest)
+		return
+	}
+	data, ok := w.Tiles.Tile(r.PathValue("source"), zi, xi, yi, w.config.Web.Tiles == "passthrough")
+	if !ok {
+		http.NotFound(rw, r)
+		return
+	}
+	rw.Header().Set("Content-Type", http.DetectContentType(data))
+	rw.Header().Set("Cache-Control", "max-age=86400")
+	rw.Write(data)
+}
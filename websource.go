@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	pb "elrs-map/proto"
+
+	"github.com/gorilla/websocket"
+)
+
+// webSource mirrors another elrs-map through its web server's /ws feed,
+// for a second screen or a display elsewhere on the LAN. The other one
+// owns the link, so this one takes no commands.
+type webSource struct {
+	addr string // host:port of the other elrs-map's web server
+
+	mu        sync.Mutex
+	reachable bool
+}
+
+func newWebSource(addr string) *webSource {
+	return &webSource{addr: addr}
+}
+
+// Connect checks the other elrs-map's web server answers
+func (s *webSource) Connect() (BackendCaps, error) {
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + s.addr + "/api/telemetry")
+	if err != nil {
+		return BackendCaps{}, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return BackendCaps{}, fmt.Errorf("web server at %s: %s", s.addr, resp.Status)
+	}
+	s.setReachable(true)
+	logTelem.Infof("Mirroring the elrs-map at %s", s.addr)
+	return BackendCaps{Version: "elrs-map web", Telemetry: true}, nil
+}
+
+func (s *webSource) Close() {}
+
+func (s *webSource) Reachable() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reachable
+}
+
+func (s *webSource) setReachable(ok bool) {
+	s.mu.Lock()
+	s.reachable = ok
+	s.mu.Unlock()
+}
+
+// LinkUp is always true: telemetry only arrives while the other elrs-map
+// has its link up
+func (s *webSource) LinkUp() bool { return true }
+
+// Stream turns the snapshots the web map gets back into telemetry frames.
+// Snapshots keep coming while the other side hears nothing, so only those
+// with fresh telemetry are passed on.
+func (s *webSource) Stream(ctx context.Context, frame func(*pb.Telemetry)) error {
+	dialer := websocket.Dialer{HandshakeTimeout: 5 * time.Second}
+	conn, _, err := dialer.DialContext(ctx, "ws://"+s.addr+"/ws", nil)
+	if err != nil {
+		s.setReachable(false)
+		return err
+	}
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			s.setReachable(false)
+			return err
+		}
+		var t TelemetryJSON
+		if err := json.Unmarshal(data, &t); err != nil {
+			return fmt.Errorf("bad telemetry from %s: %w", s.addr, err)
+		}
+		if !t.Receiving {
+			continue
+		}
+		for _, f := range telemetryFrames(t) {
+			frame(f)
+		}
+	}
+}
+
+func (s *webSource) Commands() LinkCommands { return nil }
+
+// telemetryFrames splits a snapshot into the frames it was built from
+func telemetryFrames(t TelemetryJSON) []*pb.Telemetry {
+	frames := []*pb.Telemetry{
+		{Data: &pb.Telemetry_LinkStats{LinkStats: &pb.LinkStatsData{
+			Rssi1: t.RSSI1, Rssi2: t.RSSI2, LinkQuality: t.LinkQuality, Snr: t.SNR, TxPower: t.TXPower,
+		}}},
+		{Data: &pb.Telemetry_Battery{Battery: &pb.BatteryData{
+			Voltage: t.Voltage, Current: t.Current, Capacity: t.Capacity, Remaining: t.Remaining,
+		}}},
+		{Data: &pb.Telemetry_Attitude{Attitude: &pb.AttitudeData{Pitch: t.Pitch, Roll: t.Roll}}},
+		{Data: &pb.Telemetry_Variometer{Variometer: &pb.VariometerData{VerticalSpeed: t.VerticalSpeed}}},
+	}
+	if t.FlightMode != "" {
+		frames = append(frames, &pb.Telemetry{Data: &pb.Telemetry_FlightMode{FlightMode: &pb.FlightModeData{Mode: t.FlightMode}}})
+	}
+	if t.HasGPS {
+		frames = append(frames, &pb.Telemetry{Data: &pb.Telemetry_Gps{Gps: &pb.GPSData{
+			Latitude: t.Lat, Longitude: t.Lon, Altitude: t.Alt,
+			GroundSpeed: t.Speed, Heading: t.Heading, Satellites: t.Satellites,
+		}}})
+	}
+	return frames
+}
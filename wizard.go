@@ -0,0 +1,215 @@
+//go:build !headless
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// Setup wizard pages, in order
+const (
+	wizardBackend = iota
+	wizardLocation
+	wizardUnits
+	wizardMap
+	wizardPages
+)
+
+var wizardTitles = [wizardPages]string{
+	"Setup 1/4: Backend",
+	"Setup 2/4: Flying site",
+	"Setup 3/4: Units and battery",
+	"Setup 4/4: Map",
+}
+
+// pickDoneW and pickDoneH size the Done button shown while picking the
+// flying site on the map
+const pickDoneW, pickDoneH = 90, 32
+
+// newWizardMenu builds the first-run setup, one page of the menu at a time,
+// so a new user doesn't need the command line flags
+func (a *App) newWizardMenu() *Menu {
+	m := NewMenu("Setup")
+	cfg := a.config
+	m.OnClose = func() {
+		// Picking on the map closes the menu but not the wizard
+		if a.pickingSite {
+			return
+		}
+		a.applyConfig()
+		a.saveConfig()
+	}
+
+	page := func(p int) func() {
+		return func() {
+			a.wizardPage = p
+			m.Rebuild(m)
+		}
+	}
+	nav := func(items ...MenuItem) []MenuItem {
+		if a.wizardPage > 0 {
+			items = append(items, MenuItem{Label: "< Back", OnSelect: page(a.wizardPage - 1)})
+		}
+		if a.wizardPage < wizardPages-1 {
+			items = append(items, MenuItem{Label: "Next >", OnSelect: page(a.wizardPage + 1)})
+		} else {
+			items = append(items, MenuItem{Label: "Finish", OnSelect: m.Close})
+		}
+		return items
+	}
+
+	m.Rebuild = func(m *Menu) {
+		m.Title = wizardTitles[a.wizardPage]
+		m.Items = m.Items[:0]
+
+		switch a.wizardPage {
+		case wizardBackend:
+			m.Items = append(m.Items, MenuItem{
+				Label: "Backend",
+				Value: func() string {
+					if !a.client.IsConnected() {
+						return a.client.Address() + " (down)"
+					}
+					return a.client.Address() + " (ok)"
+				},
+			})
+			for _, s := range a.backends {
+				addr := s.HostPort()
+				m.Items = append(m.Items, MenuItem{
+					Label:    "Use " + s.Instance,
+					Value:    func() string { return addr },
+					Active:   func() bool { return addr == a.client.Address() },
+					OnSelect: func() { a.switchBackend(addr) },
+				})
+			}
+			label := "Search the network"
+			if a.discovering {
+				label = "Searching..."
+			}
+			m.Items = append(m.Items, MenuItem{Label: label, OnSelect: func() {
+				a.discoverBackends(false)
+				m.Rebuild(m)
+			}})
+
+		case wizardLocation:
+			m.Items = append(m.Items,
+				MenuItem{
+					Label: "Map starts at",
+					Value: func() string { return fmt.Sprintf("%.4f, %.4f", cfg.Map.DefaultLat, cfg.Map.DefaultLon) },
+				},
+				MenuItem{Label: "Pick on the map", OnSelect: func() {
+					a.pickingSite = true
+					a.centerLat, a.centerLon = cfg.Map.DefaultLat, cfg.Map.DefaultLon
+					a.followAircraft = false
+					m.Close()
+				}},
+			)
+			if fix, ok := a.localGPS.Fix(); ok {
+				m.Items = append(m.Items, MenuItem{Label: "Use ground station GPS", OnSelect: func() {
+					cfg.Map.DefaultLat, cfg.Map.DefaultLon = fix.Lat, fix.Lon
+					a.centerLat, a.centerLon = fix.Lat, fix.Lon
+				}})
+			}
+
+		case wizardUnits:
+			m.Items = append(m.Items,
+				MenuItem{
+					Label: "Units",
+					Value: func() string { return string(cfg.Display.Units) },
+					OnAdjust: func(d int) {
+						cfg.Display.Units = Units(cycle([]string{string(UnitsMetric), string(UnitsImperial)}, string(cfg.Display.Units), d))
+					},
+				},
+				MenuItem{
+					Label: "Battery cells",
+					Value: func() string {
+						if cfg.Battery.Cells == 0 {
+							return "auto"
+						}
+						return fmt.Sprintf("%dS", cfg.Battery.Cells)
+					},
+					OnAdjust: func(d int) {
+						cfg.Battery.Cells = clampInt(cfg.Battery.Cells+d, 0, 14)
+						cfg.Battery.Preset = "custom"
+					},
+				},
+			)
+
+		case wizardMap:
+			m.Items = append(m.Items, MenuItem{
+				Label: "Map source",
+				Value: func() string { return cfg.Map.Source },
+				OnAdjust: func(d int) {
+					cfg.Map.Source = cycle([]string{"satellite", "street"}, cfg.Map.Source, d)
+					a.applyConfig()
+				},
+			})
+		}
+		m.Items = nav(m.Items...)
+		// Start on Next, so Enter or a push steps through the defaults
+		m.selected = len(m.Items) - 1
+	}
+	return m
+}
+
+// openWizard starts the setup wizard from its first page
+func (a *App) openWizard() {
+	a.wizardPage = wizardBackend
+	a.wizardMenu.Open()
+}
+
+// pickDoneRect is where the Done button sits while picking the site
+func (a *App) pickDoneRect() (x, y, w, h int) {
+	offsetX, mapWidth := a.mapArea()
+	return offsetX + mapWidth/2 - pickDoneW/2, 40, pickDoneW, pickDoneH
+}
+
+// updatePickingSite finishes picking the flying site when Done is tapped
+// or Enter pressed, returning to the wizard. It reports whether the input
+// was used, so it doesn't also start a drag.
+func (a *App) updatePickingSite() bool {
+	done := inpututil.IsKeyJustPressed(ebiten.KeyEnter) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadEnter)
+	x, y, w, h := a.pickDoneRect()
+	inside := func(px, py int) bool { return px >= x && px < x+w && py >= y && py < y+h }
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && inside(ebiten.CursorPosition()) {
+		done = true
+	}
+	for _, id := range inpututil.AppendJustPressedTouchIDs(nil) {
+		if inside(ebiten.TouchPosition(id)) {
+			done = true
+		}
+	}
+	if !done {
+		return false
+	}
+	a.config.Map.DefaultLat, a.config.Map.DefaultLon = a.centerLat, a.centerLon
+	a.pickingSite = false
+	a.wizardMenu.Open()
+	return true
+}
+
+// drawPickingSite draws the crosshair and instructions while picking
+func (a *App) drawPickingSite(screen *ebiten.Image) {
+	if !a.pickingSite {
+		return
+	}
+	offsetX, mapWidth := a.mapArea()
+	cx, cy := float32(offsetX+mapWidth/2), float32(a.height/2)
+	white := color.RGBA{255, 255, 255, 255}
+	vector.StrokeLine(screen, cx-15, cy, cx+15, cy, 2, white, true)
+	vector.StrokeLine(screen, cx, cy-15, cx, cy+15, 2, white, true)
+
+	msg := "Pan and zoom to your flying site"
+	vector.DrawFilledRect(screen, float32(offsetX), 5, float32(mapWidth), 26, color.RGBA{0, 0, 0, 200}, false)
+	ebitenutil.DebugPrintAt(screen, msg, offsetX+mapWidth/2-len(msg)*3, 10)
+
+	x, y, w, h := a.pickDoneRect()
+	vector.DrawFilledRect(screen, float32(x), float32(y), float32(w), float32(h), color.RGBA{0, 120, 200, 255}, false)
+	ebitenutil.DebugPrintAt(screen, "Done", x+w/2-12, y+h/2-8)
+}